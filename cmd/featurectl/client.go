@@ -0,0 +1,488 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"feature/pkg/render"
+)
+
+// client talks to the feature-httpd API.
+type client struct {
+	baseURL string
+	apiKey  string
+	http    *http.Client
+}
+
+func newClient(server, apiKey string) client {
+	return client{
+		baseURL: strings.TrimSuffix(server, "/") + "/api/v1",
+		apiKey:  apiKey,
+		http:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// apiError is an error rendered by the API.
+type apiError struct {
+	status    int
+	msg       string
+	requestID string
+}
+
+func (e apiError) Error() string {
+	msg := fmt.Sprintf("%d %s", e.status, http.StatusText(e.status))
+	if e.msg != "" {
+		msg += ": " + e.msg
+	}
+	if e.requestID != "" {
+		msg += fmt.Sprintf(" (request %s)", e.requestID)
+	}
+	return msg
+}
+
+func isNotFound(err error) bool {
+	var e apiError
+	return errors.As(err, &e) && e.status == http.StatusNotFound
+}
+
+// do sends a request with the given body to the API, and decodes the response
+// body into res, unless res is nil. Bodies that are not readers are encoded as
+// JSON.
+func (c client) do(method, path string, query url.Values, body any, contentType string, res any) error {
+	var r io.Reader
+	switch b := body.(type) {
+	case nil:
+	case io.Reader:
+		r = b
+	default:
+		buf := &bytes.Buffer{}
+		if err := json.NewEncoder(buf).Encode(b); err != nil {
+			return fmt.Errorf("encode request body: %w", err)
+		}
+		r = buf
+		contentType = "application/json"
+	}
+
+	u := c.baseURL + path
+	if len(query) != 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequest(method, u, r)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || 299 < resp.StatusCode {
+		var p render.Problem
+		b, _ := io.ReadAll(resp.Body)
+		if err := json.Unmarshal(b, &p); err != nil || p.Code == "" {
+			// Not a problem, like the errors of proxies in front of the API.
+			return apiError{status: resp.StatusCode, msg: strings.TrimSpace(string(b))}
+		}
+		return apiError{status: resp.StatusCode, msg: p.Detail, requestID: p.RequestID}
+	}
+
+	switch v := res.(type) {
+	case nil:
+		return nil
+	case io.Writer:
+		_, err := io.Copy(v, resp.Body)
+		return err
+	default:
+		if err := json.NewDecoder(resp.Body).Decode(res); err != nil {
+			return fmt.Errorf("decode response body: %w", err)
+		}
+		return nil
+	}
+}
+
+// feature as rendered by the API.
+type feature struct {
+	ID            string          `json:"id,omitempty"`
+	DisplayName   *string         `json:"displayName,omitempty"`
+	TechnicalName string          `json:"technicalName"`
+	ExpiresOn     *int64          `json:"expiresOn,omitempty"`
+	Description   *string         `json:"description,omitempty"`
+	Inverted      bool            `json:"inverted"`
+	Killed        bool            `json:"killed"`
+	CreatedAt     int64           `json:"createdAt,omitempty"`
+	UpdatedAt     int64           `json:"updatedAt,omitempty"`
+	CustomerIDs   []string        `json:"customerIds,omitempty"`
+	Tags          []string        `json:"tags,omitempty"`
+	Owner         *string         `json:"owner,omitempty"`
+	TicketURL     *string         `json:"ticketUrl,omitempty"`
+	Kind          string          `json:"kind,omitempty"`
+	Rules         []targetingRule `json:"rules,omitempty"`
+}
+
+// targetingRule targets the customers whose attribute in the customer
+// directory has one of the values.
+type targetingRule struct {
+	Attribute string   `json:"attribute"`
+	Values    []string `json:"values"`
+}
+
+// saveFeatureRequest is the feature as accepted by the API when saving.
+type saveFeatureRequest struct {
+	DisplayName   *string         `json:"displayName"`
+	TechnicalName string          `json:"technicalName"`
+	ExpiresOn     *int64          `json:"expiresOn"`
+	Description   *string         `json:"description"`
+	Inverted      bool            `json:"inverted"`
+	CustomerIDs   []string        `json:"customerIds"`
+	Tags          []string        `json:"tags"`
+	Owner         *string         `json:"owner"`
+	TicketURL     *string         `json:"ticketUrl"`
+	Kind          string          `json:"kind"`
+	Rules         []targetingRule `json:"rules"`
+}
+
+func (f feature) toSaveRequest() saveFeatureRequest {
+	return saveFeatureRequest{
+		DisplayName:   f.DisplayName,
+		TechnicalName: f.TechnicalName,
+		ExpiresOn:     f.ExpiresOn,
+		Description:   f.Description,
+		Inverted:      f.Inverted,
+		CustomerIDs:   f.CustomerIDs,
+		Tags:          f.Tags,
+		Owner:         f.Owner,
+		TicketURL:     f.TicketURL,
+		Kind:          f.Kind,
+		// Sent even when there are none, so that the saved feature is the
+		// one the caller saw.
+		Rules: append([]targetingRule{}, f.Rules...),
+	}
+}
+
+// listPageSize is the number of features requested per page when listing.
+const listPageSize = 500
+
+// featurePage is a page of a feature listing, as rendered by the API.
+type featurePage struct {
+	Features   []feature `json:"features"`
+	Total      int       `json:"total"`
+	NextCursor string    `json:"nextCursor"`
+}
+
+// listFeatures returns all features matching the filters and sort of query,
+// fetching one page after the other.
+func (c client) listFeatures(query url.Values) ([]feature, error) {
+	q := url.Values{}
+	for k, v := range query {
+		q[k] = v
+	}
+	q.Set("limit", strconv.Itoa(listPageSize))
+
+	var fs []feature
+	for {
+		var page featurePage
+		if err := c.do(http.MethodGet, "/features", q, nil, "", &page); err != nil {
+			return nil, err
+		}
+		fs = append(fs, page.Features...)
+
+		if page.NextCursor == "" {
+			return fs, nil
+		}
+		q.Set("cursor", page.NextCursor)
+	}
+}
+
+type searchResult struct {
+	ID            string  `json:"id"`
+	TechnicalName string  `json:"technicalName"`
+	DisplayName   *string `json:"displayName,omitempty"`
+	Archived      bool    `json:"archived"`
+	Snippet       string  `json:"snippet"`
+}
+
+func (c client) searchFeatures(text string, archived bool) ([]searchResult, error) {
+	query := url.Values{"q": {text}}
+	if archived {
+		query.Set("archived", "true")
+	}
+
+	var res struct {
+		Results []searchResult `json:"results"`
+	}
+	if err := c.do(http.MethodGet, "/features/search", query, nil, "", &res); err != nil {
+		return nil, err
+	}
+	return res.Results, nil
+}
+
+func (c client) getFeature(id string) (*feature, error) {
+	var f feature
+	if err := c.do(http.MethodGet, "/features/"+url.PathEscape(id), nil, nil, "", &f); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+func (c client) createFeature(f feature) error {
+	return c.do(http.MethodPost, "/features", nil, f.toSaveRequest(), "", nil)
+}
+
+type validation struct {
+	Valid  bool     `json:"valid"`
+	Errors []string `json:"errors"`
+}
+
+func (c client) validateFeature(f feature) (*validation, error) {
+	var res validation
+	if err := c.do(http.MethodPost, "/features/validate", nil, f.toSaveRequest(), "", &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// maxUpdateAttempts is the number of times an update is retried, when the
+// feature is changed by someone else in the meantime.
+const maxUpdateAttempts = 3
+
+// updateFeature fetches the current state of the feature, applies fn to it and
+// saves the result. The API rejects updates based on stale data, so in case of
+// a concurrent change, the whole cycle is repeated with fresh data.
+func (c client) updateFeature(id string, fn func(*feature) error) (*feature, error) {
+	var err error
+	for i := 0; i < maxUpdateAttempts; i++ {
+		var f *feature
+		if f, err = c.getFeature(id); err != nil {
+			return nil, err
+		}
+
+		if err := fn(f); err != nil {
+			return nil, err
+		}
+
+		err = c.do(http.MethodPut, "/features/"+url.PathEscape(id), nil, struct {
+			LastUpdatedAt int64              `json:"lastUpdatedAt"`
+			Feature       saveFeatureRequest `json:"feature"`
+		}{
+			LastUpdatedAt: f.UpdatedAt,
+			Feature:       f.toSaveRequest(),
+		}, "", nil)
+		if err == nil {
+			return f, nil
+		}
+		if !isNotFound(err) {
+			return nil, err
+		}
+		// Either the feature was changed since we've fetched it, or it's gone. The
+		// next fetch tells which.
+	}
+	return nil, fmt.Errorf("feature kept changing, gave up after %d attempts: %w", maxUpdateAttempts, err)
+}
+
+func (c client) archiveFeature(id string) error {
+	return c.do(http.MethodPost, "/archived_features", nil, struct {
+		FeatureID string `json:"featureId"`
+	}{FeatureID: id}, "", nil)
+}
+
+func (c client) killFeature(id string, killed bool) error {
+	action := "/kill"
+	if !killed {
+		action = "/unkill"
+	}
+	return c.do(http.MethodPost, "/features/"+url.PathEscape(id)+action, nil, nil, "", nil)
+}
+
+// addCustomers returns the customers that were newly added.
+func (c client) addCustomers(id string, customerIDs []string) ([]string, error) {
+	var res struct {
+		Added []string `json:"added"`
+	}
+	if err := c.do(http.MethodPost, "/features/"+url.PathEscape(id)+"/customers", nil, struct {
+		CustomerIDs []string `json:"customerIds"`
+	}{CustomerIDs: customerIDs}, "", &res); err != nil {
+		return nil, err
+	}
+	return res.Added, nil
+}
+
+type customerUploadReport struct {
+	Added          int   `json:"added"`
+	AlreadyPresent int   `json:"alreadyPresent"`
+	Removed        int   `json:"removed"`
+	Invalid        int   `json:"invalid"`
+	InvalidLines   []int `json:"invalidLines"`
+}
+
+func (c client) uploadCustomers(id string, r io.Reader, contentType string, replace bool) (*customerUploadReport, error) {
+	var res customerUploadReport
+	q := url.Values{"mode": {"append"}}
+	if replace {
+		q.Set("mode", "replace")
+	}
+	if err := c.do(http.MethodPost, "/features/"+url.PathEscape(id)+"/customers/upload", q, r, contentType, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+type customerRename struct {
+	DryRun   bool   `json:"dryRun"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Features []struct {
+		ID            string `json:"id"`
+		TechnicalName string `json:"technicalName"`
+		Merged        bool   `json:"merged"`
+	} `json:"features"`
+}
+
+func (c client) renameCustomer(from, to string, merge, dryRun bool) (*customerRename, error) {
+	var res customerRename
+	q := url.Values{"dryRun": {fmt.Sprint(dryRun)}}
+	if err := c.do(http.MethodPost, "/customers/"+url.PathEscape(from)+"/rename", q, struct {
+		To    string `json:"to"`
+		Merge bool   `json:"merge"`
+	}{To: to, Merge: merge}, "", &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+type alias struct {
+	TechnicalName   string `json:"technicalName"`
+	CreatedAt       int64  `json:"createdAt"`
+	LastEvaluatedAt *int64 `json:"lastEvaluatedAt"`
+	EvaluationCount int    `json:"evaluationCount"`
+}
+
+func (c client) listAliases(id string) ([]alias, error) {
+	var res struct {
+		Aliases []alias `json:"aliases"`
+	}
+	if err := c.do(http.MethodGet, "/features/"+url.PathEscape(id)+"/aliases", nil, nil, "", &res); err != nil {
+		return nil, err
+	}
+	return res.Aliases, nil
+}
+
+func (c client) deleteAlias(id, name string) error {
+	return c.do(http.MethodDelete, "/features/"+url.PathEscape(id)+"/aliases/"+url.PathEscape(name), nil, nil, "", nil)
+}
+
+type evaluation struct {
+	Name     string `json:"name"`
+	Active   bool   `json:"active"`
+	Inverted bool   `json:"inverted"`
+	Killed   bool   `json:"killed"`
+	Expired  bool   `json:"expired"`
+	// RenamedTo is set if Name is a former name of the feature.
+	RenamedTo *string `json:"renamedTo,omitempty"`
+}
+
+// featureRequest builds the request body evaluating the given features for a
+// customer.
+func featureRequest(customerID string, names []string) any {
+	type requestedFeature struct {
+		Name string `json:"name"`
+	}
+	var req struct {
+		Request struct {
+			CustomerID string             `json:"customerId"`
+			Features   []requestedFeature `json:"features"`
+		} `json:"featureRequest"`
+	}
+	req.Request.CustomerID = customerID
+	for _, n := range names {
+		req.Request.Features = append(req.Request.Features, requestedFeature{Name: n})
+	}
+	return req
+}
+
+func (c client) evaluate(customerID string, names []string) ([]evaluation, error) {
+	var res struct {
+		Features []evaluation `json:"features"`
+	}
+	if err := c.do(http.MethodPost, "/features/request", nil, featureRequest(customerID, names), "", &res); err != nil {
+		return nil, err
+	}
+	return res.Features, nil
+}
+
+type explanation struct {
+	EvaluatedAt      int64 `json:"evaluatedAt"`
+	SnapshotLoadedAt int64 `json:"snapshotLoadedAt"`
+	Features         []struct {
+		evaluation
+		Found bool `json:"found"`
+		Steps []struct {
+			Check    string `json:"check"`
+			Result   bool   `json:"result"`
+			Decisive bool   `json:"decisive"`
+			Detail   string `json:"detail"`
+		} `json:"steps"`
+	} `json:"features"`
+}
+
+// explain evaluates features like evaluate, tracing every decision.
+func (c client) explain(customerID string, names []string) (*explanation, error) {
+	var res explanation
+	q := url.Values{"explain": {"true"}}
+	if err := c.do(http.MethodPost, "/features/request", q, featureRequest(customerID, names), "", &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (c client) export(format string, w io.Writer) error {
+	return c.do(http.MethodGet, "/export", url.Values{"format": {format}}, nil, "", w)
+}
+
+type importChange struct {
+	Kind          string `json:"kind"`
+	TechnicalName string `json:"technicalName,omitempty"`
+	ID            string `json:"id,omitempty"`
+	CustomerID    string `json:"customerId,omitempty"`
+}
+
+// name returns the technical name of a changed feature, or the ID of a changed
+// customer.
+func (ch importChange) name() string {
+	if ch.CustomerID != "" {
+		return ch.CustomerID
+	}
+	return ch.TechnicalName
+}
+
+type importReport struct {
+	DryRun  bool           `json:"dryRun"`
+	Creates []importChange `json:"creates"`
+	Updates []importChange `json:"updates"`
+	Deletes []importChange `json:"deletes"`
+}
+
+func (c client) importDocument(r io.Reader, contentType string, dryRun bool) (*importReport, error) {
+	var res importReport
+	q := url.Values{"dryRun": {fmt.Sprint(dryRun)}}
+	if err := c.do(http.MethodPost, "/import", q, r, contentType, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
@@ -0,0 +1,662 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Output formats.
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// dateLayout is used to print and parse expiry dates.
+const dateLayout = "2006-01-02"
+
+type cli struct {
+	client client
+	out    io.Writer
+	output string
+}
+
+func newFlagSet(name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: featurectl %s %s\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parse parses args, and ensures exactly n positional arguments remain, or at
+// least one if n is negative.
+func parse(fs *flag.FlagSet, args []string, n int) error {
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if (n < 0 && fs.NArg() == 0) || (n >= 0 && fs.NArg() != n) {
+		fs.Usage()
+		return errUsage
+	}
+	return nil
+}
+
+func (c cli) list(args []string) error {
+	fs := newFlagSet("list", "[FLAGS]")
+	search := fs.String("search", "", "Only list features containing this in their names or description.")
+	expired := fs.String("expired", "", "Only list expired (true) or non-expired (false) features.")
+	inverted := fs.String("inverted", "", "Only list inverted (true) or non-inverted (false) features.")
+	customerID := fs.String("customer", "", "Only list features of this customer.")
+	tag := fs.String("tag", "", "Only list features having all of these comma separated tags.")
+	owner := fs.String("owner", "", "Only list features of this owner.")
+	kind := fs.String("kind", "", "Only list features of this kind.")
+	sort := fs.String("sort", "", "Sort by name, created, updated or expiry. Prefix with - for descending order.")
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+
+	query := url.Values{}
+	for k, v := range map[string]string{
+		"q":          *search,
+		"expired":    *expired,
+		"inverted":   *inverted,
+		"customerId": *customerID,
+		"owner":      *owner,
+		"kind":       *kind,
+		"sort":       *sort,
+	} {
+		if v != "" {
+			query.Set(k, v)
+		}
+	}
+	for _, t := range splitList(*tag) {
+		query.Add("tag", t)
+	}
+
+	features, err := c.client.listFeatures(query)
+	if err != nil {
+		return fmt.Errorf("list features: %w", err)
+	}
+
+	return c.print(features, func(w io.Writer) {
+		fmt.Fprintln(w, "ID\tTECHNICAL NAME\tDISPLAY NAME\tKIND\tOWNER\tEXPIRES ON\tINVERTED\tKILLED")
+		for _, f := range features {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%t\t%t\n", f.ID, f.TechnicalName, deref(f.DisplayName), f.Kind, deref(f.Owner), formatDate(f.ExpiresOn), f.Inverted, f.Killed)
+		}
+	})
+}
+
+func (c cli) search(args []string) error {
+	fs := newFlagSet("search", "[--archived] TEXT...")
+	archived := fs.Bool("archived", false, "Also search archived features.")
+	if err := parse(fs, args, -1); err != nil {
+		return err
+	}
+
+	rs, err := c.client.searchFeatures(strings.Join(fs.Args(), " "), *archived)
+	if err != nil {
+		return fmt.Errorf("search features: %w", err)
+	}
+
+	return c.print(rs, func(w io.Writer) {
+		fmt.Fprintln(w, "ID\tTECHNICAL NAME\tDISPLAY NAME\tARCHIVED\tMATCH")
+		for _, r := range rs {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%t\t%s\n", r.ID, r.TechnicalName, deref(r.DisplayName), r.Archived, plainSnippet(r.Snippet))
+		}
+	})
+}
+
+// plainSnippet turns an HTML snippet into text, marking matches with asterisks.
+func plainSnippet(s string) string {
+	s = strings.NewReplacer("<mark>", "*", "</mark>", "*").Replace(s)
+	return html.UnescapeString(s)
+}
+
+func (c cli) get(args []string) error {
+	fs := newFlagSet("get", "ID")
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+
+	f, err := c.client.getFeature(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("get feature: %w", err)
+	}
+
+	return c.printFeature(*f)
+}
+
+// featureFlags registers the flags for the attributes of a feature on fs, and
+// returns a func applying the flags that were set to a feature.
+func featureFlags(fs *flag.FlagSet) func(*feature) error {
+	technicalName := fs.String("technical-name", "", "Technical name of the feature.")
+	displayName := fs.String("display-name", "", "Display name of the feature. Empty to unset.")
+	description := fs.String("description", "", "Description of the feature. Empty to unset.")
+	expiresOn := fs.String("expires-on", "", "Expiry date of the feature as YYYY-MM-DD or RFC 3339. Empty to unset.")
+	inverted := fs.Bool("inverted", false, "Whether the feature is active for everyone but its customers.")
+	customerIDs := fs.String("customers", "", "Comma separated list of customer ids, replacing the current ones.")
+	tags := fs.String("tags", "", "Comma separated list of tags, replacing the current ones.")
+	owner := fs.String("owner", "", "Team or email of the owner of the feature. Empty to unset.")
+	ticketURL := fs.String("ticket", "", "Link to the ticket of the feature. Empty to unset.")
+	kind := fs.String("kind", "", "Kind of the feature: release, experiment, ops or permission. Empty to unset.")
+
+	return func(f *feature) error {
+		var err error
+		fs.Visit(func(fl *flag.Flag) {
+			switch fl.Name {
+			case "technical-name":
+				f.TechnicalName = *technicalName
+			case "display-name":
+				f.DisplayName = nilIfEmpty(*displayName)
+			case "description":
+				f.Description = nilIfEmpty(*description)
+			case "expires-on":
+				f.ExpiresOn, err = parseDate(*expiresOn)
+			case "inverted":
+				f.Inverted = *inverted
+			case "customers":
+				f.CustomerIDs = splitList(*customerIDs)
+			case "tags":
+				f.Tags = splitList(*tags)
+			case "owner":
+				f.Owner = nilIfEmpty(*owner)
+			case "ticket":
+				f.TicketURL = nilIfEmpty(*ticketURL)
+			case "kind":
+				f.Kind = *kind
+			}
+		})
+		return err
+	}
+}
+
+func (c cli) create(args []string) error {
+	fs := newFlagSet("create", "[FLAGS] TECHNICAL_NAME")
+	apply := featureFlags(fs)
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+
+	f := feature{TechnicalName: fs.Arg(0)}
+	if err := apply(&f); err != nil {
+		return err
+	}
+
+	if err := c.client.createFeature(f); err != nil {
+		return fmt.Errorf("create feature: %w", err)
+	}
+
+	fmt.Fprintf(c.out, "Feature %s created.\n", f.TechnicalName)
+	return nil
+}
+
+// errInvalidFeature makes validate exit with a failure status once the
+// violations are printed.
+var errInvalidFeature = errors.New("feature is invalid")
+
+func (c cli) validate(args []string) error {
+	fs := newFlagSet("validate", "[FLAGS] TECHNICAL_NAME")
+	apply := featureFlags(fs)
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+
+	f := feature{TechnicalName: fs.Arg(0)}
+	if err := apply(&f); err != nil {
+		return err
+	}
+
+	v, err := c.client.validateFeature(f)
+	if err != nil {
+		return fmt.Errorf("validate feature: %w", err)
+	}
+
+	err = c.print(v, func(w io.Writer) {
+		if v.Valid {
+			fmt.Fprintf(w, "Feature %s is valid.\n", f.TechnicalName)
+		}
+		for _, e := range v.Errors {
+			fmt.Fprintln(w, e)
+		}
+	})
+	if err == nil && !v.Valid {
+		return errInvalidFeature
+	}
+	return err
+}
+
+func (c cli) update(args []string) error {
+	fs := newFlagSet("update", "[FLAGS] ID")
+	apply := featureFlags(fs)
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+
+	f, err := c.client.updateFeature(fs.Arg(0), apply)
+	if err != nil {
+		return fmt.Errorf("update feature: %w", err)
+	}
+
+	fmt.Fprintf(c.out, "Feature %s updated.\n", f.TechnicalName)
+	return nil
+}
+
+func (c cli) archive(args []string) error {
+	fs := newFlagSet("archive", "ID")
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+
+	if err := c.client.archiveFeature(fs.Arg(0)); err != nil {
+		return fmt.Errorf("archive feature: %w", err)
+	}
+
+	fmt.Fprintf(c.out, "Feature %s archived.\n", fs.Arg(0))
+	return nil
+}
+
+func (c cli) kill(args []string, killed bool) error {
+	name := "kill"
+	if !killed {
+		name = "unkill"
+	}
+
+	fs := newFlagSet(name, "ID")
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+
+	if err := c.client.killFeature(fs.Arg(0), killed); err != nil {
+		return fmt.Errorf("%s feature: %w", name, err)
+	}
+
+	fmt.Fprintf(c.out, "Feature %s %sed.\n", fs.Arg(0), name)
+	return nil
+}
+
+func (c cli) customers(args []string) error {
+	if len(args) > 0 && args[0] == "upload" {
+		return c.uploadCustomers(args[1:])
+	}
+	if len(args) > 0 && args[0] == "rename" {
+		return c.renameCustomer(args[1:])
+	}
+	if len(args) == 0 || (args[0] != "add" && args[0] != "remove") {
+		fmt.Fprint(os.Stderr, "Usage: featurectl customers add|remove ID CUSTOMER_ID...\n       featurectl customers upload [--replace] ID FILE\n       featurectl customers rename [--merge] [--dry-run] CUSTOMER_ID NEW_ID\n")
+		return errUsage
+	}
+	action := args[0]
+
+	fs := newFlagSet("customers "+action, "ID CUSTOMER_ID...")
+	if err := parse(fs, args[1:], -1); err != nil {
+		return err
+	}
+	if fs.NArg() < 2 {
+		fs.Usage()
+		return errUsage
+	}
+	id, customerIDs := fs.Arg(0), fs.Args()[1:]
+
+	if action == "add" {
+		added, err := c.client.addCustomers(id, customerIDs)
+		if err != nil {
+			return fmt.Errorf("add customers: %w", err)
+		}
+		fmt.Fprintf(c.out, "%d customer(s) added.\n", len(added))
+		return nil
+	}
+
+	// There is no endpoint for removing customers, so the feature is updated
+	// with the remaining ones instead.
+	remove := make(map[string]struct{}, len(customerIDs))
+	for _, id := range customerIDs {
+		remove[id] = struct{}{}
+	}
+	var removed int
+	if _, err := c.client.updateFeature(id, func(f *feature) error {
+		remaining := f.CustomerIDs[:0]
+		for _, id := range f.CustomerIDs {
+			if _, ok := remove[id]; !ok {
+				remaining = append(remaining, id)
+			}
+		}
+		removed = len(f.CustomerIDs) - len(remaining)
+		f.CustomerIDs = remaining
+		return nil
+	}); err != nil {
+		return fmt.Errorf("remove customers: %w", err)
+	}
+
+	fmt.Fprintf(c.out, "%d customer(s) removed.\n", removed)
+	return nil
+}
+
+func (c cli) uploadCustomers(args []string) error {
+	fs := newFlagSet("customers upload", "[--replace] ID FILE")
+	replace := fs.Bool("replace", false, "Remove the customers of the feature missing from FILE.")
+	if err := parse(fs, args, 2); err != nil {
+		return err
+	}
+	path := fs.Arg(1)
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open file: %w", err)
+	}
+	defer f.Close()
+
+	contentType := "text/plain"
+	if strings.ToLower(filepath.Ext(path)) == ".csv" {
+		contentType = "text/csv"
+	}
+
+	report, err := c.client.uploadCustomers(fs.Arg(0), f, contentType, *replace)
+	if err != nil {
+		return fmt.Errorf("upload customers: %w", err)
+	}
+
+	return c.print(report, func(w io.Writer) {
+		fmt.Fprintf(w, "Added:\t%d\n", report.Added)
+		fmt.Fprintf(w, "Already present:\t%d\n", report.AlreadyPresent)
+		fmt.Fprintf(w, "Removed:\t%d\n", report.Removed)
+		fmt.Fprintf(w, "Invalid:\t%d\n", report.Invalid)
+		if len(report.InvalidLines) != 0 {
+			lines := make([]string, len(report.InvalidLines))
+			for i, l := range report.InvalidLines {
+				lines[i] = strconv.Itoa(l)
+			}
+			fmt.Fprintf(w, "Invalid lines:\t%s\n", strings.Join(lines, ", "))
+		}
+	})
+}
+
+func (c cli) renameCustomer(args []string) error {
+	fs := newFlagSet("customers rename", "[--merge] [--dry-run] CUSTOMER_ID NEW_ID")
+	merge := fs.Bool("merge", false, "Merge into NEW_ID if it is already in use.")
+	dryRun := fs.Bool("dry-run", false, "Only list the features the rename would change.")
+	if err := parse(fs, args, 2); err != nil {
+		return err
+	}
+
+	res, err := c.client.renameCustomer(fs.Arg(0), fs.Arg(1), *merge, *dryRun)
+	if err != nil {
+		return fmt.Errorf("rename customer: %w", err)
+	}
+
+	if c.output == outputJSON {
+		return c.print(res, nil)
+	}
+
+	for _, f := range res.Features {
+		if f.Merged {
+			fmt.Fprintf(c.out, "- %s %s\n", res.From, f.TechnicalName)
+		} else {
+			fmt.Fprintf(c.out, "~ %s -> %s %s\n", res.From, res.To, f.TechnicalName)
+		}
+	}
+
+	if res.DryRun {
+		fmt.Fprintf(c.out, "%d feature(s) would be changed.\n", len(res.Features))
+	} else {
+		fmt.Fprintf(c.out, "%d feature(s) changed.\n", len(res.Features))
+	}
+	return nil
+}
+
+func (c cli) aliases(args []string) error {
+	if len(args) > 0 && args[0] == "remove" {
+		fs := newFlagSet("aliases remove", "ID NAME")
+		if err := parse(fs, args[1:], 2); err != nil {
+			return err
+		}
+		if err := c.client.deleteAlias(fs.Arg(0), fs.Arg(1)); err != nil {
+			return fmt.Errorf("remove alias: %w", err)
+		}
+		fmt.Fprintf(c.out, "Alias %s removed.\n", fs.Arg(1))
+		return nil
+	}
+
+	fs := newFlagSet("aliases", "ID")
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+
+	as, err := c.client.listAliases(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("list aliases: %w", err)
+	}
+
+	return c.print(as, func(w io.Writer) {
+		fmt.Fprintln(w, "NAME\tCREATED ON\tLAST EVALUATED ON\tEVALUATIONS")
+		for _, a := range as {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\n", a.TechnicalName, formatDate(&a.CreatedAt), formatDate(a.LastEvaluatedAt), a.EvaluationCount)
+		}
+	})
+}
+
+func (c cli) evaluate(args []string) error {
+	fs := newFlagSet("evaluate", "[--explain] --customer=ID NAME...")
+	customerID := fs.String("customer", "", "Id of the customer to evaluate the features for.")
+	explain := fs.Bool("explain", false, "Show how every evaluation was decided.")
+	if err := parse(fs, args, -1); err != nil {
+		return err
+	}
+	if *customerID == "" {
+		fs.Usage()
+		return errUsage
+	}
+
+	if *explain {
+		e, err := c.client.explain(*customerID, fs.Args())
+		if err != nil {
+			return fmt.Errorf("explain features: %w", err)
+		}
+
+		return c.print(e, func(w io.Writer) {
+			fmt.Fprintf(w, "Evaluated at:\t%s\n", time.UnixMilli(e.EvaluatedAt).UTC().Format(time.RFC3339))
+			fmt.Fprintf(w, "Configuration loaded at:\t%s\n", time.UnixMilli(e.SnapshotLoadedAt).UTC().Format(time.RFC3339))
+			for _, f := range e.Features {
+				fmt.Fprintf(w, "\n%s\tactive: %t\n", f.Name, f.Active)
+				for _, s := range f.Steps {
+					marker := " "
+					if s.Decisive {
+						marker = "*"
+					}
+					fmt.Fprintf(w, "%s %s\t%s\n", marker, s.Check, s.Detail)
+				}
+			}
+		})
+	}
+
+	es, err := c.client.evaluate(*customerID, fs.Args())
+	if err != nil {
+		return fmt.Errorf("evaluate features: %w", err)
+	}
+
+	return c.print(es, func(w io.Writer) {
+		fmt.Fprintln(w, "NAME\tACTIVE\tINVERTED\tKILLED\tEXPIRED\tRENAMED TO")
+		for _, e := range es {
+			fmt.Fprintf(w, "%s\t%t\t%t\t%t\t%t\t%s\n", e.Name, e.Active, e.Inverted, e.Killed, e.Expired, deref(e.RenamedTo))
+		}
+	})
+}
+
+func (c cli) export(args []string) error {
+	fs := newFlagSet("export", "[--format=json|yaml] [--out=FILE]")
+	format := fs.String("format", "", "Document format, either json or yaml. Inferred from --out by default.")
+	out := fs.String("out", "", "Path to write the document to. Defaults to stdout.")
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+
+	if *format == "" {
+		*format = formatFromPath(*out)
+	}
+
+	w := c.out
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return fmt.Errorf("create file: %w", err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if err := c.client.export(*format, w); err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	return nil
+}
+
+func (c cli) importDocument(args []string) error {
+	fs := newFlagSet("import", "[--dry-run] FILE")
+	dryRun := fs.Bool("dry-run", false, "Only report the changes the import would make.")
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+	path := fs.Arg(0)
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open file: %w", err)
+	}
+	defer f.Close()
+
+	contentType := "application/json"
+	if formatFromPath(path) == "yaml" {
+		contentType = "application/yaml"
+	}
+
+	report, err := c.client.importDocument(f, contentType, *dryRun)
+	if err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+
+	if c.output == outputJSON {
+		return c.print(report, nil)
+	}
+
+	for _, ch := range report.Creates {
+		fmt.Fprintf(c.out, "+ %s %s\n", ch.Kind, ch.name())
+	}
+	for _, ch := range report.Updates {
+		fmt.Fprintf(c.out, "~ %s %s\n", ch.Kind, ch.name())
+	}
+	for _, ch := range report.Deletes {
+		fmt.Fprintf(c.out, "- %s %s\n", ch.Kind, ch.name())
+	}
+
+	n := len(report.Creates) + len(report.Updates) + len(report.Deletes)
+	switch {
+	case n == 0:
+		fmt.Fprintln(c.out, "No changes.")
+	case report.DryRun:
+		fmt.Fprintf(c.out, "%d change(s) would be made.\n", n)
+	default:
+		fmt.Fprintf(c.out, "%d change(s) made.\n", n)
+	}
+	return nil
+}
+
+func (c cli) printFeature(f feature) error {
+	return c.print(f, func(w io.Writer) {
+		fmt.Fprintf(w, "ID:\t%s\n", f.ID)
+		fmt.Fprintf(w, "Technical name:\t%s\n", f.TechnicalName)
+		fmt.Fprintf(w, "Display name:\t%s\n", deref(f.DisplayName))
+		fmt.Fprintf(w, "Description:\t%s\n", deref(f.Description))
+		fmt.Fprintf(w, "Expires on:\t%s\n", formatDate(f.ExpiresOn))
+		fmt.Fprintf(w, "Inverted:\t%t\n", f.Inverted)
+		fmt.Fprintf(w, "Killed:\t%t\n", f.Killed)
+		fmt.Fprintf(w, "Created at:\t%s\n", time.UnixMilli(f.CreatedAt).Format(time.RFC3339))
+		fmt.Fprintf(w, "Updated at:\t%s\n", time.UnixMilli(f.UpdatedAt).Format(time.RFC3339))
+		fmt.Fprintf(w, "Customers:\t%s\n", strings.Join(f.CustomerIDs, ", "))
+		fmt.Fprintf(w, "Tags:\t%s\n", strings.Join(f.Tags, ", "))
+		fmt.Fprintf(w, "Owner:\t%s\n", deref(f.Owner))
+		fmt.Fprintf(w, "Ticket:\t%s\n", deref(f.TicketURL))
+		fmt.Fprintf(w, "Kind:\t%s\n", f.Kind)
+		for _, r := range f.Rules {
+			fmt.Fprintf(w, "Rule:\t%s in %s\n", r.Attribute, strings.Join(r.Values, ", "))
+		}
+	})
+}
+
+// print writes v as JSON, or calls table with a tabwriter if the output format
+// is table.
+func (c cli) print(v any, table func(io.Writer)) error {
+	if c.output == outputJSON || table == nil {
+		enc := json.NewEncoder(c.out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	w := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	table(w)
+	return w.Flush()
+}
+
+func formatFromPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return "yaml"
+	default:
+		return "json"
+	}
+}
+
+func formatDate(ms *int64) string {
+	if ms == nil {
+		return "-"
+	}
+	return time.UnixMilli(*ms).UTC().Format(dateLayout)
+}
+
+func parseDate(s string) (*int64, error) {
+	if s == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(dateLayout, s)
+	if err != nil {
+		if t, err = time.Parse(time.RFC3339, s); err != nil {
+			return nil, fmt.Errorf("parse expiry date %q: expected YYYY-MM-DD or RFC 3339", s)
+		}
+	}
+	ms := t.UnixMilli()
+	return &ms, nil
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return "-"
+	}
+	return *s
+}
+
+func nilIfEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func splitList(s string) []string {
+	var res []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			res = append(res, v)
+		}
+	}
+	return res
+}
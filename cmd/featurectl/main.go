@@ -0,0 +1,144 @@
+// Command featurectl manages features through the HTTP API of feature-httpd.
+//
+// The server URL and API key are read from --server and --api-key, the
+// FEATURECTL_SERVER and FEATURECTL_API_KEY environment variables, or the
+// server and api_key keys of the config file, in that order of precedence.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/viper"
+)
+
+const usage = `Usage: featurectl [--config=FILE] [--server=URL] [--api-key=KEY] [--output=table|json] COMMAND [ARGS]
+
+Commands:
+  list [FLAGS]                              List features, optionally filtered and sorted.
+  search [--archived] TEXT...               Search features by name, description and tags.
+  get ID                                    Show a feature including its customers.
+  create [FLAGS] TECHNICAL_NAME             Create a feature.
+  validate [FLAGS] TECHNICAL_NAME           Check a feature against the validation policy, without creating it.
+  update [FLAGS] ID                         Change the given attributes of a feature.
+  archive ID                                Archive a feature.
+  kill ID                                   Turn a feature off for every customer.
+  unkill ID                                 Restore the evaluation of a killed feature.
+  customers add ID CUSTOMER_ID...           Add customers to a feature.
+  customers remove ID CUSTOMER_ID...        Remove customers from a feature.
+  customers upload [--replace] ID FILE      Add the customers listed in a CSV or text FILE to a feature.
+  customers rename [--merge] [--dry-run] CUSTOMER_ID NEW_ID
+                                            Rename a customer in every feature.
+  aliases ID                                List the former names of a feature and their usage.
+  aliases remove ID NAME                    Remove a former name that is no longer evaluated.
+  evaluate [--explain] --customer=ID NAME...
+                                            Evaluate features for a customer, optionally explaining why.
+  export [--format=json|yaml] [--out=FILE]  Write all flag configuration to FILE, or stdout.
+  import [--dry-run] FILE                   Make flag configuration match FILE.
+
+Run 'featurectl COMMAND --help' for the flags of a command.
+`
+
+// errUsage signals that the command line was malformed. The usage has already
+// been printed at that point.
+var errUsage = errors.New("invalid usage")
+
+func main() {
+	configFile := flag.String("config", defaultConfigFile(), "Path to config file.")
+	server := flag.String("server", "", "URL of the feature-httpd server.")
+	apiKey := flag.String("api-key", "", "API key sent as bearer token.")
+	output := flag.String("output", outputTable, "Output format, either table or json.")
+
+	flag.Usage = func() { fmt.Fprint(flag.CommandLine.Output(), usage) }
+	flag.Parse()
+
+	viper.SetDefault("server", "http://localhost:8080")
+	viper.BindEnv("server", "FEATURECTL_SERVER")
+	viper.BindEnv("api_key", "FEATURECTL_API_KEY")
+
+	if *configFile != "" {
+		viper.SetConfigFile(*configFile)
+		if err := viper.ReadInConfig(); err != nil && !(errors.Is(err, os.ErrNotExist) && *configFile == defaultConfigFile()) {
+			fail(fmt.Errorf("read config file: %w", err))
+		}
+	}
+	if *server != "" {
+		viper.Set("server", *server)
+	}
+	if *apiKey != "" {
+		viper.Set("api_key", *apiKey)
+	}
+
+	if *output != outputTable && *output != outputJSON {
+		fail(fmt.Errorf("unknown output format %q", *output))
+	}
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cli := cli{
+		client: newClient(viper.GetString("server"), viper.GetString("api_key")),
+		out:    os.Stdout,
+		output: *output,
+	}
+
+	args := flag.Args()
+	var err error
+	switch args[0] {
+	case "list":
+		err = cli.list(args[1:])
+	case "search":
+		err = cli.search(args[1:])
+	case "get":
+		err = cli.get(args[1:])
+	case "create":
+		err = cli.create(args[1:])
+	case "validate":
+		err = cli.validate(args[1:])
+	case "update":
+		err = cli.update(args[1:])
+	case "archive":
+		err = cli.archive(args[1:])
+	case "kill":
+		err = cli.kill(args[1:], true)
+	case "unkill":
+		err = cli.kill(args[1:], false)
+	case "customers":
+		err = cli.customers(args[1:])
+	case "aliases":
+		err = cli.aliases(args[1:])
+	case "evaluate":
+		err = cli.evaluate(args[1:])
+	case "export":
+		err = cli.export(args[1:])
+	case "import":
+		err = cli.importDocument(args[1:])
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if errors.Is(err, errUsage) {
+		os.Exit(2)
+	}
+	if err != nil {
+		fail(err)
+	}
+}
+
+func defaultConfigFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "featurectl", "config.yaml")
+}
+
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "featurectl: %s\n", err)
+	os.Exit(1)
+}
@@ -6,6 +6,7 @@ import (
 	"embed"
 	"flag"
 	"github.com/go-chi/cors"
+	"io"
 	"io/fs"
 	"net/http"
 	"os"
@@ -14,14 +15,24 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/andybalholm/brotli"
 	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rs/zerolog/hlog"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/viper"
 
 	"feature/feature"
+	"feature/migrations"
 	"feature/pkg/config"
+	"feature/pkg/metrics"
+	"feature/pkg/migrate"
+	"feature/pkg/sqlx"
+	"feature/pkg/static"
 )
 
 //go:embed dist
@@ -29,6 +40,7 @@ var app embed.FS
 
 func main() {
 	envFile := flag.String("env-file", "", "Path to env file containing configuration.")
+	memory := flag.Bool("memory", false, "Keep all data in memory instead of the database. Data is lost on shutdown.")
 
 	flag.Parse()
 
@@ -41,42 +53,35 @@ func main() {
 		}
 	}
 
-	db, err := sql.Open("sqlite3", config.DSN())
-	if err != nil {
-		log.Fatal().
-			Err(err).
-			Msg("failed to open connection to database")
+	var featureStore feature.Repository
+	if *memory {
+		featureStore = feature.NewMemoryStore()
+	} else {
+		featureStore = openStore()
 	}
 
-	if err := db.Ping(); err != nil {
+	policy, err := feature.NewValidationPolicy(
+		config.FeatureNamePattern(),
+		config.FeatureNameMinLength(),
+		config.FeatureNameMaxLength(),
+		config.FeatureNameReservedPrefixes(),
+		config.FeatureRequiredFields(),
+	)
+	if err != nil {
 		log.Fatal().
 			Err(err).
-			Msg("failed to ping database")
+			Msg("failed to load validation policy")
 	}
 
-	featureStore := feature.NewStore(db)
-	featureService := feature.NewService(featureStore)
+	featureService := feature.NewService(featureStore).WithValidationPolicy(policy)
 	featureHandler := feature.NewHandler(featureService)
 
-	apiHandler := chi.NewRouter()
-
-	apiHandler.Route("/api/v1", func(r chi.Router) {
-		r.Route("/features", func(r chi.Router) {
-			r.Get("/", featureHandler.ListFeatures)
-			r.Post("/", featureHandler.SaveFeature)
-			r.Post("/request", featureHandler.RequestFeaturesAsCustomer) // Couldn't come up with a better name.
-
-			r.Route("/{featureId}", func(r chi.Router) {
-				r.Get("/", featureHandler.GetFeature)
-				r.Put("/", featureHandler.UpdateFeature)
-				r.Post("/customers", featureHandler.SaveFeatureCustomers)
-			})
-		})
+	prometheus.MustRegister(
+		feature.NewStateCollector(featureStore),
+		feature.NewEvaluationCacheCollector(featureService),
+	)
 
-		r.Route("/archived_features", func(r chi.Router) {
-			r.Post("/", featureHandler.SaveArchivedFeature)
-		})
-	})
+	apiHandler := newAPIHandler(featureHandler)
 
 	appDir, err := fs.Sub(app, "dist/frontend")
 	if err != nil {
@@ -85,7 +90,7 @@ func main() {
 			Msg("failed to open app filesystem")
 	}
 
-	appHandler := http.FileServer(http.FS(appDir))
+	appHandler := static.Handler(appDir)
 
 	rootHandler := chi.NewRouter()
 
@@ -93,8 +98,13 @@ func main() {
 		cors.Handler(cors.Options{
 			AllowedOrigins: []string{"*"},
 			AllowedMethods: []string{"GET", "POST", "PUT", "DELETE"},
+			// The defaults of the cors package, plus conditional requests.
+			AllowedHeaders: []string{"Origin", "Accept", "Content-Type", "X-Requested-With", "If-None-Match"},
+			ExposedHeaders: []string{"ETag", "X-Request-Id"},
 		}),
 		hlog.NewHandler(log.Logger),
+		// Logged with every line, and reported to clients in error responses.
+		hlog.RequestIDHandler("requestId", "X-Request-Id"),
 		hlog.AccessHandler(func(r *http.Request, status, size int, duration time.Duration) {
 			hlog.FromRequest(r).
 				Info().
@@ -104,8 +114,13 @@ func main() {
 				Stringer("url", r.URL).
 				Msg("ACCESS")
 		}),
+		metrics.Middleware,
+		// Frontend assets compressed ahead of time are served as they are.
+		compress,
 	)
 
+	rootHandler.Handle("/metrics", promhttp.Handler())
+
 	rootHandler.HandleFunc("/*", func(w http.ResponseWriter, r *http.Request) {
 		switch {
 		case strings.HasPrefix(r.URL.Path, "/api"):
@@ -125,6 +140,21 @@ func main() {
 		WriteTimeout: config.ServerWriteTimeout(),
 	}
 
+	usageCtx, stopUsageTracking := context.WithCancel(context.Background())
+	usageTracked := make(chan struct{})
+	go func() {
+		featureService.TrackUsage(usageCtx, config.UsageFlushInterval())
+		close(usageTracked)
+	}()
+
+	webhookCtx, stopWebhookDelivery := context.WithCancel(context.Background())
+	defer stopWebhookDelivery()
+	go featureService.DeliverWebhooks(webhookCtx, config.WebhookDeliveryInterval())
+
+	cacheCtx, stopCacheRefresh := context.WithCancel(context.Background())
+	defer stopCacheRefresh()
+	go featureService.RefreshEvaluationCache(cacheCtx, config.EvaluationCacheRefreshInterval())
+
 	idleConnsClosed := make(chan struct{})
 	go func() {
 		sigint := make(chan os.Signal, 1)
@@ -136,6 +166,11 @@ func main() {
 				Err(err).
 				Msg("error shutting down HTTP server")
 		}
+
+		// Flush the remaining usage only once no more evaluations can come in.
+		stopUsageTracking()
+		<-usageTracked
+
 		close(idleConnsClosed)
 	}()
 
@@ -149,3 +184,133 @@ func main() {
 	}
 	<-idleConnsClosed
 }
+
+// newAPIHandler routes the API to featureHandler. Routes must be described in
+// the OpenAPI document of the feature package.
+func newAPIHandler(featureHandler feature.Handler) chi.Router {
+	r := chi.NewRouter()
+
+	var (
+		limitBody       = maxBodySize(config.ServerMaxBodySize())
+		limitUploadBody = maxBodySize(config.ServerMaxUploadBodySize())
+		limitImportBody = maxBodySize(config.ServerMaxImportBodySize())
+	)
+
+	r.Route("/api/v1", func(r chi.Router) {
+		r.Get("/openapi.json", featureHandler.OpenAPI)
+		r.Get("/docs", featureHandler.APIDocs)
+		r.Get("/docs/{file}", featureHandler.APIDocsAsset)
+
+		r.Route("/features", func(r chi.Router) {
+			r.Get("/", featureHandler.ListFeatures)
+			r.With(limitBody).Post("/", featureHandler.SaveFeature)
+			r.With(limitBody).Post("/request", featureHandler.RequestFeaturesAsCustomer) // Couldn't come up with a better name.
+			r.Get("/stale", featureHandler.ListStaleFeatures)
+			r.Get("/search", featureHandler.SearchFeatures)
+			r.With(limitBody).Post("/validate", featureHandler.ValidateFeature)
+
+			r.Route("/{featureId}", func(r chi.Router) {
+				r.Get("/", featureHandler.GetFeature)
+				r.With(limitBody).Put("/", featureHandler.UpdateFeature)
+				r.With(limitBody).Post("/customers", featureHandler.SaveFeatureCustomers)
+				r.With(limitUploadBody).Post("/customers/upload", featureHandler.UploadFeatureCustomers)
+				r.Post("/kill", featureHandler.KillFeature)
+				r.Post("/unkill", featureHandler.UnkillFeature)
+				r.Get("/aliases", featureHandler.ListFeatureAliases)
+				r.Delete("/aliases/{alias}", featureHandler.DeleteFeatureAlias)
+			})
+		})
+
+		r.Route("/customers", func(r chi.Router) {
+			r.Get("/", featureHandler.ListCustomers)
+			r.With(limitUploadBody).Post("/upload", featureHandler.UploadCustomers)
+			r.Get("/{customerId}", featureHandler.GetCustomer)
+			r.With(limitBody).Put("/{customerId}", featureHandler.SaveCustomer)
+			r.Delete("/{customerId}", featureHandler.DeleteCustomer)
+			r.With(limitBody).Post("/{customerId}/rename", featureHandler.RenameCustomer)
+		})
+
+		r.Route("/archived_features", func(r chi.Router) {
+			r.With(limitBody).Post("/", featureHandler.SaveArchivedFeature)
+		})
+
+		r.Get("/export", featureHandler.ExportConfiguration)
+		r.With(limitImportBody).Post("/import", featureHandler.ImportConfiguration)
+
+		r.Route("/webhooks", func(r chi.Router) {
+			r.Get("/", featureHandler.ListWebhooks)
+			r.With(limitBody).Post("/", featureHandler.SaveWebhook)
+			r.Delete("/{webhookId}", featureHandler.DeleteWebhook)
+		})
+	})
+
+	return r
+}
+
+// compress compresses the responses of next for the clients accepting it,
+// preferring brotli over gzip and deflate.
+func compress(next http.Handler) http.Handler {
+	c := middleware.NewCompressor(5, compressibleContentTypes...)
+	c.SetEncoder("br", func(w io.Writer, level int) io.Writer {
+		return brotli.NewWriterLevel(w, level)
+	})
+	return c.Handler(next)
+}
+
+// compressibleContentTypes are the types of the responses compressed for the
+// clients accepting it.
+var compressibleContentTypes = []string{
+	"application/json",
+	"application/problem+json",
+	"application/yaml",
+	"text/csv",
+	"text/html",
+	"text/css",
+	"text/plain",
+	"text/javascript",
+	"application/javascript",
+	"image/svg+xml",
+}
+
+// maxBodySize limits the size of request bodies to n bytes. Handlers reading
+// past the limit fail with 413 Request Entity Too Large.
+func maxBodySize(n int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Body = http.MaxBytesReader(w, r.Body, n)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// openStore connects to the configured database, migrating it if enabled.
+func openStore() feature.Store {
+	db, err := sql.Open(config.Driver(), config.DSN())
+	if err != nil {
+		log.Fatal().
+			Err(err).
+			Msg("failed to open connection to database")
+	}
+
+	if err := db.Ping(); err != nil {
+		log.Fatal().
+			Err(err).
+			Msg("failed to ping database")
+	}
+
+	if config.AutoMigrate() {
+		m, err := migrate.New(db, migrations.For(sqlx.DialectOf(db)))
+		if err != nil {
+			log.Fatal().
+				Err(err).
+				Msg("failed to load migrations")
+		}
+		if err := m.Up(context.Background()); err != nil {
+			log.Fatal().
+				Err(err).
+				Msg("failed to apply migrations")
+		}
+	}
+
+	return feature.NewStore(db)
+}
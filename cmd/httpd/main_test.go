@@ -0,0 +1,160 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/go-chi/chi"
+
+	"feature/feature"
+	"feature/pkg/set"
+)
+
+// TestAPIRoutesAreSpecified fails when the routes of the API and the paths of
+// its OpenAPI document drift apart.
+func TestAPIRoutesAreSpecified(t *testing.T) {
+	handler := newAPIHandler(feature.NewHandler(feature.NewService(feature.NewMemoryStore())))
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/api/v1/openapi.json", nil))
+	if res.Code != http.StatusOK {
+		t.Fatalf("Status codes not equal.\nwant: %d\ngot:  %d", http.StatusOK, res.Code)
+	}
+
+	var spec struct {
+		Servers []struct {
+			URL string `json:"url"`
+		} `json:"servers"`
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&spec); err != nil {
+		t.Fatalf("failed to decode the OpenAPI document: %s", err)
+	}
+	if len(spec.Servers) != 1 {
+		t.Fatalf("Expected a single server, got: %d", len(spec.Servers))
+	}
+
+	var specified []string
+	for path, item := range spec.Paths {
+		for method := range item {
+			if method == "parameters" {
+				continue
+			}
+			specified = append(specified, strings.ToUpper(method)+" "+spec.Servers[0].URL+path)
+		}
+	}
+
+	var routed []string
+	err := chi.Walk(handler, func(method string, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		// Routes mounted with Route are registered with a trailing slash.
+		if route != "/" {
+			route = strings.TrimSuffix(route, "/")
+		}
+		routed = append(routed, method+" "+route)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to walk the routes: %s", err)
+	}
+
+	var (
+		unspecified = set.Sub(set.Of(routed...), set.Of(specified...)).ToSlice()
+		unrouted    = set.Sub(set.Of(specified...), set.Of(routed...)).ToSlice()
+	)
+	sort.Strings(unspecified)
+	sort.Strings(unrouted)
+	if len(unspecified) != 0 || len(unrouted) != 0 {
+		t.Errorf("Routes not equal to the OpenAPI paths.\nmissing from the spec: %v\nnot routed: %v", unspecified, unrouted)
+	}
+}
+
+func TestCompress(t *testing.T) {
+	body := strings.Repeat(`{"technicalName":"feature-1","enabled":true}`, 100)
+
+	tests := map[string]struct {
+		contentType    string
+		acceptEncoding string
+
+		wantEncoding string
+	}{
+		"brotli is preferred": {
+			contentType:    "application/json",
+			acceptEncoding: "gzip, deflate, br",
+
+			wantEncoding: "br",
+		},
+		"gzip without brotli": {
+			contentType:    "application/json",
+			acceptEncoding: "gzip, deflate",
+
+			wantEncoding: "gzip",
+		},
+		"identity": {
+			contentType: "application/json",
+
+			wantEncoding: "",
+		},
+		"problems": {
+			contentType:    "application/problem+json",
+			acceptEncoding: "gzip, deflate, br",
+
+			wantEncoding: "br",
+		},
+		"incompressible type": {
+			contentType:    "application/octet-stream",
+			acceptEncoding: "gzip, deflate, br",
+
+			wantEncoding: "",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			handler := compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", test.contentType)
+				io.WriteString(w, body)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if test.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", test.acceptEncoding)
+			}
+			res := httptest.NewRecorder()
+
+			handler.ServeHTTP(res, req)
+
+			if enc := res.Header().Get("Content-Encoding"); enc != test.wantEncoding {
+				t.Errorf("Content encodings not equal.\nwant: %s\ngot:  %s", test.wantEncoding, enc)
+			}
+
+			var r io.Reader = res.Body
+			switch test.wantEncoding {
+			case "br":
+				r = brotli.NewReader(res.Body)
+			case "gzip":
+				zr, err := gzip.NewReader(res.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				r = zr
+			}
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("failed to decode the response: %s", err)
+			}
+			if string(got) != body {
+				t.Errorf("Response bodies not equal.\nwant: %s\ngot:  %s", body, got)
+			}
+		})
+	}
+}
@@ -1,23 +1,40 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"feature/migrations"
 	"feature/pkg/config"
+	"feature/pkg/migrate"
+	"feature/pkg/sqlx"
 	"flag"
 	"fmt"
-	"io"
-	"io/fs"
 	"os"
-	"path/filepath"
+	"strconv"
+	"text/tabwriter"
+	"time"
 
+	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/viper"
 )
 
+const usage = `Usage: makedb [--env-file=FILE] [COMMAND]
+
+Commands:
+  up          Apply all pending migrations. The default.
+  down        Revert the most recently applied migration.
+  to N        Apply or revert migrations until N is the most recently applied one.
+  status      List all migrations and whether they are applied.
+  baseline N  Record migrations up to N as applied without running them, for
+              databases created before migrations were tracked.
+`
+
 func main() {
 	envFile := flag.String("env-file", "", "Path to env file containing configuration.")
 
+	flag.Usage = func() { fmt.Fprint(flag.CommandLine.Output(), usage) }
 	flag.Parse()
 
 	if *envFile != "" {
@@ -29,7 +46,7 @@ func main() {
 		}
 	}
 
-	db, err := sql.Open("sqlite3", config.DSN())
+	db, err := sql.Open(config.Driver(), config.DSN())
 	if err != nil {
 		log.Fatal().
 			Err(err).
@@ -42,34 +59,67 @@ func main() {
 			Msg("failed to ping database")
 	}
 
-	err = filepath.Walk("migrations", func(path string, info fs.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
+	m, err := migrate.New(db, migrations.For(sqlx.DialectOf(db)))
+	if err != nil {
+		log.Fatal().
+			Err(err).
+			Msg("failed to load migrations")
+	}
 
-		if info.IsDir() {
-			return nil
-		}
+	ctx := context.Background()
 
-		f, err := os.Open(path)
-		if err != nil {
-			return fmt.Errorf("open file: %w", err)
-		}
+	args := flag.Args()
+	if len(args) == 0 {
+		args = []string{"up"}
+	}
 
-		b, err := io.ReadAll(f)
-		if err != nil {
-			return fmt.Errorf("read file: %w", err)
+	switch args[0] {
+	case "up":
+		err = m.Up(ctx)
+	case "down":
+		err = m.Down(ctx)
+	case "to", "baseline":
+		if len(args) != 2 {
+			flag.Usage()
+			os.Exit(2)
 		}
-
-		if _, err := db.Exec(string(b)); err != nil {
-			return fmt.Errorf("execute query: %w", err)
+		version, convErr := strconv.Atoi(args[1])
+		if convErr != nil {
+			flag.Usage()
+			os.Exit(2)
 		}
-
-		return nil
-	})
+		if args[0] == "to" {
+			err = m.To(ctx, version)
+		} else {
+			err = m.Baseline(ctx, version)
+		}
+	case "status":
+		err = printStatus(ctx, m)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
 	if err != nil {
 		log.Fatal().
 			Err(err).
-			Msg("failed to execute migrations")
+			Msgf("failed to %s", args[0])
+	}
+}
+
+func printStatus(ctx context.Context, m *migrate.Migrator) error {
+	ss, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+	for _, s := range ss {
+		appliedAt := "pending"
+		if s.AppliedAt != nil {
+			appliedAt = s.AppliedAt.Local().Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, appliedAt)
 	}
+	return w.Flush()
 }
@@ -0,0 +1,199 @@
+package feature
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// An Alias is a former technical name of a feature. Evaluations asking for it
+// resolve to the renamed feature, so that renames don't break clients still
+// using the old name.
+type Alias struct {
+	TechnicalName string
+	FeatureID     uuid.UUID
+	CreatedAt     time.Time
+}
+
+// AliasUsage is an alias along with how much it is still evaluated.
+type AliasUsage struct {
+	Alias
+	LastEvaluatedAt *time.Time
+	// EvaluationCount is the number of evaluations of the alias within the
+	// usage window.
+	EvaluationCount int
+}
+
+type errAliasNotFound struct {
+	technicalName string
+}
+
+func (e errAliasNotFound) Error() string {
+	return fmt.Sprintf("alias %q does not exist", e.technicalName)
+}
+
+func (e errAliasNotFound) Code() int {
+	return http.StatusNotFound
+}
+
+func (e errAliasNotFound) ErrorCode() string {
+	return "alias-not-found"
+}
+
+// errTechnicalNameTaken is returned when a feature is given a technical name
+// that is an alias of another feature.
+type errTechnicalNameTaken struct {
+	technicalName string
+	featureID     uuid.UUID
+}
+
+func (e errTechnicalNameTaken) Error() string {
+	return fmt.Sprintf("technical name %q is an alias of feature %s", e.technicalName, e.featureID)
+}
+
+func (e errTechnicalNameTaken) Code() int {
+	return http.StatusConflict
+}
+
+func (e errTechnicalNameTaken) ErrorCode() string {
+	return "technical-name-taken"
+}
+
+type errAliasInUse struct {
+	technicalName   string
+	evaluationCount int
+}
+
+func (e errAliasInUse) Error() string {
+	return fmt.Sprintf("alias %q is still in use, with %d evaluations in the last %d days", e.technicalName, e.evaluationCount, int(usageWindow.Hours()/24))
+}
+
+func (e errAliasInUse) Code() int {
+	return http.StatusConflict
+}
+
+func (e errAliasInUse) ErrorCode() string {
+	return "alias-in-use"
+}
+
+// findAlias returns the alias with the given technical name, or nil if there
+// is none.
+func findAlias(ctx context.Context, store AliasRepository, technicalName string) (*Alias, error) {
+	a, err := store.FindAlias(ctx, technicalName)
+	if errors.As(err, &errAliasNotFound{}) {
+		return nil, nil
+	}
+	return a, err
+}
+
+// claimTechnicalName ensures that no other feature than featureID has the
+// given technical name as alias. An alias of featureID itself is dropped, as
+// the feature is renamed back to it.
+func claimTechnicalName(ctx context.Context, store AliasRepository, featureID uuid.UUID, technicalName string) error {
+	a, err := findAlias(ctx, store, technicalName)
+	if err != nil {
+		return fmt.Errorf("find alias: %w", err)
+	}
+	switch {
+	case a == nil:
+		return nil
+	case a.FeatureID != featureID:
+		return errTechnicalNameTaken{technicalName: technicalName, featureID: a.FeatureID}
+	}
+
+	if err := store.DeleteAlias(ctx, technicalName); err != nil {
+		return fmt.Errorf("delete alias: %w", err)
+	}
+	return nil
+}
+
+// findAliases returns the aliases of a feature along with their usage.
+func (svc Service) findAliases(ctx context.Context, featureID uuid.UUID) ([]AliasUsage, error) {
+	if _, err := svc.store.FindFeature(ctx, featureID); err != nil {
+		return nil, fmt.Errorf("find feature: %w", err)
+	}
+
+	as, err := svc.store.FindAliasesByFeatureID(ctx, featureID)
+	if err != nil {
+		return nil, fmt.Errorf("find aliases: %w", err)
+	}
+
+	usageByName, err := findUsageByName(ctx, svc.store, svc.timeFunc().Add(-usageWindow))
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]AliasUsage, 0, len(as))
+	for _, a := range as {
+		au := AliasUsage{Alias: a}
+		if u, ok := usageByName[a.TechnicalName]; ok {
+			au.LastEvaluatedAt = &u.LastEvaluatedAt
+			au.EvaluationCount = u.EvaluationCount
+		}
+		res = append(res, au)
+	}
+	return res, nil
+}
+
+// deleteAlias removes an alias of a feature, provided it hasn't been evaluated
+// within the usage window. Pending usage is flushed first, so that recent
+// evaluations count.
+func (svc Service) deleteAlias(ctx context.Context, featureID uuid.UUID, technicalName string) error {
+	if err := svc.flushUsage(ctx); err != nil {
+		return fmt.Errorf("flush usage: %w", err)
+	}
+
+	tx, err := svc.store.Begin(ctx, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	a, err := tx.FindAlias(ctx, technicalName)
+	if err == nil && a.FeatureID != featureID {
+		err = errAliasNotFound{technicalName: technicalName}
+	}
+	if err != nil {
+		return fmt.Errorf("find alias: %w", err)
+	}
+
+	usageByName, err := findUsageByName(ctx, tx, svc.timeFunc().Add(-usageWindow))
+	if err != nil {
+		return err
+	}
+	if u := usageByName[technicalName]; u.EvaluationCount > 0 {
+		return errAliasInUse{technicalName: technicalName, evaluationCount: u.EvaluationCount}
+	}
+
+	if err := tx.DeleteAlias(ctx, technicalName); err != nil {
+		return fmt.Errorf("delete alias: %w", err)
+	}
+
+	if err := svc.commit(tx); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// findUsageByName returns the usage of technical names, with evaluation counts
+// summed since t.
+func findUsageByName(ctx context.Context, store UsageRepository, t time.Time) (map[string]Usage, error) {
+	us, err := store.FindUsage(ctx, t)
+	if err != nil {
+		return nil, fmt.Errorf("find usage: %w", err)
+	}
+
+	res := make(map[string]Usage, len(us))
+	for _, u := range us {
+		res[u.TechnicalName] = u
+	}
+	return res, nil
+}
@@ -0,0 +1,116 @@
+package feature
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+func (s Store) FindAllAliases(ctx context.Context) ([]Alias, error) {
+	defer observeQuery("findAllAliases")()
+
+	rs, err := s.db.QueryContext(
+		ctx,
+		//language=sqlite
+		`SELECT technical_name,feature_id,created_at FROM feature_aliases ORDER BY created_at, technical_name`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanAliases(rs)
+}
+
+func (s Store) FindAliasesByFeatureID(ctx context.Context, featureID uuid.UUID) ([]Alias, error) {
+	defer observeQuery("findAliasesByFeatureID")()
+
+	rs, err := s.db.QueryContext(
+		ctx,
+		//language=sqlite
+		`SELECT technical_name,feature_id,created_at FROM feature_aliases WHERE feature_id=? ORDER BY created_at, technical_name`,
+		featureID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanAliases(rs)
+}
+
+func (s Store) FindAlias(ctx context.Context, technicalName string) (*Alias, error) {
+	defer observeQuery("findAlias")()
+
+	var a Alias
+	err := s.db.QueryRowContext(
+		ctx,
+		//language=sqlite
+		`SELECT technical_name,feature_id,created_at FROM feature_aliases WHERE technical_name=?`,
+		technicalName,
+	).Scan(&a.TechnicalName, &a.FeatureID, &a.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errAliasNotFound{technicalName: technicalName}
+	}
+	if err != nil {
+		return nil, err
+	}
+	a.CreatedAt = a.CreatedAt.UTC()
+	return &a, nil
+}
+
+func scanAliases(rs *sql.Rows) ([]Alias, error) {
+	var as []Alias
+	for rs.Next() {
+		var a Alias
+		if err := rs.Scan(&a.TechnicalName, &a.FeatureID, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		a.CreatedAt = a.CreatedAt.UTC()
+		as = append(as, a)
+	}
+
+	if err := rs.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := rs.Close(); err != nil {
+		return nil, err
+	}
+
+	return as, nil
+}
+
+func (s Store) SaveAlias(ctx context.Context, a Alias) error {
+	defer observeQuery("saveAlias")()
+
+	_, err := s.db.ExecContext(
+		ctx,
+		//language=sqlite
+		`INSERT INTO feature_aliases (technical_name,feature_id,created_at) VALUES (?,?,?)`,
+		a.TechnicalName, a.FeatureID, a.CreatedAt.UTC(),
+	)
+	return err
+}
+
+func (s Store) DeleteAlias(ctx context.Context, technicalName string) error {
+	defer observeQuery("deleteAlias")()
+
+	res, err := s.db.ExecContext(
+		ctx,
+		//language=sqlite
+		`DELETE FROM feature_aliases WHERE technical_name=?`,
+		technicalName,
+	)
+	if err != nil {
+		return err
+	}
+
+	rs, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rs == 0 {
+		return errAliasNotFound{technicalName: technicalName}
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+// Renders the OpenAPI document served next to the page. Kept out of the page,
+// so that a Content-Security-Policy of script-src 'self' doesn't block it.
+window.onload = function () {
+  window.ui = SwaggerUIBundle({
+    url: "openapi.json",
+    dom_id: "#swagger-ui",
+    deepLinking: true,
+  });
+};
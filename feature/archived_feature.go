@@ -6,7 +6,7 @@ import (
 	"github.com/google/uuid"
 )
 
-type archivedFeature struct {
+type ArchivedFeature struct {
 	ID            uuid.UUID
 	DisplayName   *string
 	TechnicalName string
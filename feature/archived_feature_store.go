@@ -2,7 +2,9 @@ package feature
 
 import "context"
 
-func (s Store) saveArchivedFeature(ctx context.Context, f feature) error {
+func (s Store) SaveArchivedFeature(ctx context.Context, f Feature) error {
+	defer observeQuery("saveArchivedFeature")()
+
 	r := featureToRow(f)
 	_, err := s.db.ExecContext(
 		ctx,
@@ -10,5 +12,47 @@ func (s Store) saveArchivedFeature(ctx context.Context, f feature) error {
 		`INSERT INTO archived_features (id,display_name,technical_name,description,created_at,updated_at) VALUES (?,?,?,?,?,?)`,
 		r.ID, r.DisplayName, r.TechnicalName, r.Description, r.CreatedAt, r.UpdatedAt,
 	)
-	return err
+	if err != nil {
+		return err
+	}
+	return s.saveSearchDocument(ctx, r, true)
+}
+
+func (s Store) FindAllArchivedFeatures(ctx context.Context) ([]ArchivedFeature, error) {
+	defer observeQuery("findAllArchivedFeatures")()
+
+	rs, err := s.db.QueryContext(
+		ctx,
+		//language=sqlite
+		`SELECT id,display_name,technical_name,description,created_at,updated_at FROM archived_features`,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var afs []ArchivedFeature
+	for rs.Next() {
+		var af ArchivedFeature
+		if err := rs.Scan(
+			&af.ID,
+			&af.DisplayName,
+			&af.TechnicalName,
+			&af.Description,
+			&af.CreatedAt,
+			&af.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		af.CreatedAt, af.UpdatedAt = af.CreatedAt.UTC(), af.UpdatedAt.UTC()
+		afs = append(afs, af)
+	}
+
+	if err := rs.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := rs.Close(); err != nil {
+		return nil, err
+	}
+	return afs, nil
 }
@@ -0,0 +1,286 @@
+package feature
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"feature/pkg/set"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// evaluationCache keeps a snapshot of all features and their customers in
+// memory, along with the directory attributes their rules target, so that
+// evaluations don't have to query the store.
+//
+// The snapshot is dropped whenever a Service mutation commits, and loaded again
+// by the next evaluation. Changes made by other replicas are only picked up by
+// polling, see Service.RefreshEvaluationCache.
+type evaluationCache struct {
+	// loadMu is held while loading a snapshot, so that concurrent misses load
+	// it only once.
+	loadMu sync.Mutex
+
+	// mu guards the fields below.
+	mu       sync.RWMutex
+	snapshot *evaluationSnapshot
+	// generation is incremented on every invalidation. Snapshots loaded during
+	// an older generation may be outdated and are not kept.
+	generation uint64
+}
+
+type evaluationSnapshot struct {
+	// features are in store order, which is the order evaluations return them in.
+	features []cachedFeature
+	// attributes are the attributes of the customers in the directory, by
+	// customer ID. Only the attributes targeted by rules are kept.
+	attributes map[string]map[string]string
+	loadedAt   time.Time
+	// version is a hash of the loaded configuration, so that replicas having
+	// loaded the same configuration agree on it.
+	version string
+}
+
+type cachedFeature struct {
+	technicalName string
+	inverted      bool
+	killed        bool
+	expiresOn     *time.Time
+	customerIDs   set.Set[string]
+	rules         []TargetingRule
+	// aliases are the former technical names of the feature.
+	aliases []string
+}
+
+func newEvaluationCache() *evaluationCache {
+	return &evaluationCache{}
+}
+
+func newEvaluationSnapshot(fs []Feature, as []Alias, ps []CustomerProfile, loadedAt time.Time) *evaluationSnapshot {
+	aliases := make(map[uuid.UUID][]string)
+	for _, a := range as {
+		aliases[a.FeatureID] = append(aliases[a.FeatureID], a.TechnicalName)
+	}
+
+	targeted := make(set.Set[string])
+	for _, f := range fs {
+		for _, r := range f.Rules {
+			targeted[r.Attribute] = struct{}{}
+		}
+	}
+	attributes := make(map[string]map[string]string)
+	for _, p := range ps {
+		for k, v := range p.Attributes {
+			if _, ok := targeted[k]; !ok {
+				continue
+			}
+			if attributes[p.ID] == nil {
+				attributes[p.ID] = make(map[string]string)
+			}
+			attributes[p.ID][k] = v
+		}
+	}
+
+	h := sha256.New()
+	// Features, aliases and attributes consist of strings, times and UUIDs
+	// only, which always encode.
+	_ = json.NewEncoder(h).Encode(struct {
+		Features   []Feature
+		Aliases    []Alias
+		Attributes map[string]map[string]string
+	}{fs, as, attributes})
+
+	res := &evaluationSnapshot{
+		features:   make([]cachedFeature, 0, len(fs)),
+		attributes: attributes,
+		loadedAt:   loadedAt,
+		version:    fmt.Sprintf("%x", h.Sum(nil)),
+	}
+	for _, f := range fs {
+		res.features = append(res.features, cachedFeature{
+			technicalName: f.TechnicalName,
+			inverted:      f.Inverted,
+			killed:        f.Killed,
+			expiresOn:     f.ExpiresOn,
+			customerIDs:   set.Of(f.CustomerIDs...),
+			rules:         f.Rules,
+			aliases:       aliases[f.ID],
+		})
+	}
+	return res
+}
+
+// evaluate resolves the given features for a customer at t, the same way
+// Store.FindCustomerFeaturesByTechnicalNames does. Unlike the store, it also
+// resolves aliases: features asked for by a former name are returned under that
+// name, right after the feature itself.
+func (s *evaluationSnapshot) evaluate(customerID string, t time.Time, technicalNames ...string) []CustomerFeature {
+	names := set.Of(technicalNames...)
+
+	var cfs []CustomerFeature
+	for _, f := range s.features {
+		cf := CustomerFeature{
+			TechnicalName: f.technicalName,
+			Inverted:      f.inverted,
+			Killed:        f.killed,
+			Expired:       f.expiresOn != nil && f.expiresOn.Before(t),
+			HasFeature:    f.targets(customerID, s.attributes[customerID]),
+		}
+
+		if _, ok := names[f.technicalName]; ok {
+			cfs = append(cfs, cf)
+		}
+		for _, alias := range f.aliases {
+			if _, ok := names[alias]; ok {
+				aliased := cf
+				aliased.TechnicalName, aliased.RenamedTo = alias, f.technicalName
+				cfs = append(cfs, aliased)
+			}
+		}
+	}
+	return cfs
+}
+
+// targets reports whether the feature targets a customer with the given
+// directory attributes, either by listing it or by one of its rules.
+func (f cachedFeature) targets(customerID string, attributes map[string]string) bool {
+	_, listed := f.customerIDs[customerID]
+	return listed || matchingRule(f.rules, attributes) != nil
+}
+
+// versionAt returns the version of the configuration as of t. Besides the
+// configuration itself, it changes whenever a feature expires.
+func (s *evaluationSnapshot) versionAt(t time.Time) string {
+	var expired int
+	for _, f := range s.features {
+		if f.expiresOn != nil && f.expiresOn.Before(t) {
+			expired++
+		}
+	}
+	return s.version + "-" + strconv.Itoa(expired)
+}
+
+func (c *evaluationCache) current() *evaluationSnapshot {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.snapshot
+}
+
+// invalidate drops the snapshot, along with any snapshot being loaded.
+func (c *evaluationCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.snapshot = nil
+	c.generation++
+}
+
+// load reads a snapshot from the store and keeps it, unless the cache was
+// invalidated in the meantime. The snapshot is returned either way, as it
+// reflects the store as of loading.
+func (c *evaluationCache) load(ctx context.Context, store Repository, now time.Time) (*evaluationSnapshot, error) {
+	c.mu.RLock()
+	generation := c.generation
+	c.mu.RUnlock()
+
+	fs, err := store.FindAllFeaturesWithCustomers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	as, err := store.FindAllAliases(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// The directory is only needed, and thus only loaded, when rules target it.
+	var ps []CustomerProfile
+	for _, f := range fs {
+		if len(f.Rules) != 0 {
+			if ps, err = store.FindAllCustomerProfiles(ctx); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	s := newEvaluationSnapshot(fs, as, ps, now)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generation == generation {
+		c.snapshot = s
+	}
+	return s, nil
+}
+
+// evaluationSnapshot returns the cached snapshot, loading it if needed.
+func (svc Service) evaluationSnapshot(ctx context.Context) (*evaluationSnapshot, error) {
+	if s := svc.cache.current(); s != nil {
+		evaluationCacheRequestsTotal.WithLabelValues("hit").Inc()
+		return s, nil
+	}
+	evaluationCacheRequestsTotal.WithLabelValues("miss").Inc()
+
+	svc.cache.loadMu.Lock()
+	defer svc.cache.loadMu.Unlock()
+
+	// Another evaluation may have loaded it while waiting.
+	if s := svc.cache.current(); s != nil {
+		return s, nil
+	}
+	return svc.cache.load(ctx, svc.store, svc.timeFunc())
+}
+
+// configurationVersion returns a version of the flag configuration, which
+// changes with every committed mutation and whenever a feature expires. Like
+// evaluations, it reflects changes made through other replicas only once the
+// evaluation snapshot is refreshed.
+func (svc Service) configurationVersion(ctx context.Context) (string, error) {
+	snapshot, err := svc.evaluationSnapshot(ctx)
+	if err != nil {
+		return "", fmt.Errorf("load evaluation snapshot: %w", err)
+	}
+	return snapshot.versionAt(svc.timeFunc()), nil
+}
+
+// commit commits tx, and invalidates the evaluation cache, as it no longer
+// reflects the store.
+func (svc Service) commit(tx UnitOfWork) error {
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	svc.cache.invalidate()
+	return nil
+}
+
+// RefreshEvaluationCache periodically reloads the evaluation cache from the
+// store, until ctx is cancelled. This picks up changes made by other replicas
+// sharing the store.
+func (svc Service) RefreshEvaluationCache(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := svc.refreshEvaluationCache(ctx); err != nil {
+				log.Error().
+					Err(err).
+					Msg("failed to refresh evaluation cache")
+			}
+		}
+	}
+}
+
+func (svc Service) refreshEvaluationCache(ctx context.Context) error {
+	svc.cache.loadMu.Lock()
+	defer svc.cache.loadMu.Unlock()
+
+	if _, err := svc.cache.load(ctx, svc.store, svc.timeFunc()); err != nil {
+		return fmt.Errorf("load evaluation snapshot: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package feature
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEvaluationCache(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		refTime = time.Now().Truncate(time.Second).UTC()
+		store   = NewMemoryStore()
+		svc     = NewService(store)
+	)
+	svc.timeFunc = func() time.Time { return refTime }
+
+	evaluate := func(t *testing.T, want ...CustomerFeature) {
+		t.Helper()
+		got, err := svc.findCustomerFeaturesByTechnicalNames(ctx, "1234", "feature-1", "feature-2")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("Customer features not equal.\nwant: %v\ngot:  %v", want, got)
+		}
+	}
+
+	var (
+		hits   = testutil.ToFloat64(evaluationCacheRequestsTotal.WithLabelValues("hit"))
+		misses = testutil.ToFloat64(evaluationCacheRequestsTotal.WithLabelValues("miss"))
+	)
+	assertRequests := func(t *testing.T, wantHits, wantMisses float64) {
+		t.Helper()
+		gotHits := testutil.ToFloat64(evaluationCacheRequestsTotal.WithLabelValues("hit")) - hits
+		gotMisses := testutil.ToFloat64(evaluationCacheRequestsTotal.WithLabelValues("miss")) - misses
+		if gotHits != wantHits || gotMisses != wantMisses {
+			t.Errorf("Cache requests not equal.\nwant: %v hits, %v misses\ngot:  %v hits, %v misses", wantHits, wantMisses, gotHits, gotMisses)
+		}
+	}
+
+	// The first evaluation loads the snapshot, later ones use it.
+	evaluate(t)
+	evaluate(t)
+	assertRequests(t, 1, 1)
+
+	// Mutations through the service invalidate the snapshot.
+	if err := svc.saveFeature(ctx, Feature{TechnicalName: "feature-1", CustomerIDs: []string{"1234"}}); err != nil {
+		t.Fatal(err)
+	}
+	evaluate(t, CustomerFeature{TechnicalName: "feature-1", HasFeature: true})
+	assertRequests(t, 1, 2)
+
+	// Changes made elsewhere are only seen once the snapshot is refreshed.
+	if err := store.SaveFeature(ctx, Feature{ID: uuid.New(), TechnicalName: "feature-2", Inverted: true, CreatedAt: refTime, UpdatedAt: refTime}); err != nil {
+		t.Fatal(err)
+	}
+	evaluate(t, CustomerFeature{TechnicalName: "feature-1", HasFeature: true})
+
+	if err := svc.refreshEvaluationCache(ctx); err != nil {
+		t.Fatal(err)
+	}
+	evaluate(t,
+		CustomerFeature{TechnicalName: "feature-1", HasFeature: true},
+		CustomerFeature{TechnicalName: "feature-2", Inverted: true},
+	)
+	assertRequests(t, 3, 2)
+
+	c := NewEvaluationCacheCollector(svc).(evaluationCacheCollector)
+	c.timeFunc = func() time.Time { return refTime.Add(90 * time.Second) }
+
+	want := `
+# HELP feature_evaluation_cache_age_seconds Time since the evaluation snapshot was loaded from the store.
+# TYPE feature_evaluation_cache_age_seconds gauge
+feature_evaluation_cache_age_seconds 90
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+}
+
+// invalidatingRepository invalidates cache while features are being loaded, as
+// a concurrent mutation would.
+type invalidatingRepository struct {
+	MemoryStore
+	cache *evaluationCache
+}
+
+func (r invalidatingRepository) FindAllFeaturesWithCustomers(ctx context.Context) ([]Feature, error) {
+	r.cache.invalidate()
+	return r.MemoryStore.FindAllFeaturesWithCustomers(ctx)
+}
+
+func TestEvaluationCacheInvalidatedWhileLoading(t *testing.T) {
+	c := newEvaluationCache()
+
+	s, err := c.load(context.Background(), invalidatingRepository{MemoryStore: NewMemoryStore(), cache: c}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s == nil {
+		t.Error("Expected the loaded snapshot to be returned")
+	}
+	if c.current() != nil {
+		t.Error("Expected the outdated snapshot not to be kept")
+	}
+}
+
+func TestEvaluationSnapshotVersion(t *testing.T) {
+	var (
+		refTime = time.Now().Truncate(time.Second).UTC()
+		fs      = []Feature{{
+			ID:            uuid.New(),
+			TechnicalName: "feature-1",
+			Rules:         []TargetingRule{{Attribute: "plan", Values: []string{"enterprise"}}},
+			CreatedAt:     refTime,
+			UpdatedAt:     refTime,
+		}}
+		version = func(attributes map[string]string) string {
+			return newEvaluationSnapshot(fs, nil, []CustomerProfile{{ID: "1234", Attributes: attributes}}, refTime).version
+		}
+	)
+
+	free := version(map[string]string{"plan": "free", "region": "eu"})
+	if got := version(map[string]string{"plan": "enterprise", "region": "eu"}); got == free {
+		t.Error("Expected the version to change along with a targeted attribute")
+	}
+	if got := version(map[string]string{"plan": "free", "region": "us"}); got != free {
+		t.Error("Expected the version not to change along with an attribute no rule targets")
+	}
+}
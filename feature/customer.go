@@ -2,19 +2,26 @@ package feature
 
 import "github.com/google/uuid"
 
-type customer struct {
+type Customer struct {
 	ID         uuid.UUID
 	FeatureID  uuid.UUID
 	CustomerID string
 }
 
-type customerFeature struct {
+type CustomerFeature struct {
 	TechnicalName string
 	Inverted      bool
+	Killed        bool
 	Expired       bool
 	HasFeature    bool
+	// RenamedTo is the current technical name of the feature, if it was asked
+	// for by an alias.
+	RenamedTo string
 }
 
-func (cf customerFeature) isActive() bool {
+func (cf CustomerFeature) isActive() bool {
+	if cf.Killed {
+		return false
+	}
 	return cf.HasFeature && !cf.Inverted
 }
@@ -0,0 +1,169 @@
+package feature
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"feature/pkg/render"
+
+	"github.com/google/uuid"
+)
+
+// CustomerRename lists the features affected, or that would be affected, by
+// renaming a customer ID.
+type CustomerRename struct {
+	DryRun   bool
+	From     string
+	To       string
+	Features []RenamedFeature
+}
+
+// RenamedFeature is a feature whose customer is renamed.
+type RenamedFeature struct {
+	ID            uuid.UUID
+	TechnicalName string
+	// Merged is set if the feature already has the new customer ID, so that
+	// the old one is removed instead of renamed.
+	Merged bool
+}
+
+type errCustomerExists struct {
+	id string
+}
+
+func (e errCustomerExists) Error() string {
+	return fmt.Sprintf("customer %q already exists, merge instead", e.id)
+}
+
+func (e errCustomerExists) Code() int {
+	return http.StatusConflict
+}
+
+func (e errCustomerExists) ErrorCode() string {
+	return "customer-exists"
+}
+
+var errRenameToSelf = render.NewBadRequest("'to' must differ from the renamed customer id")
+
+// renameCustomer replaces the customer ID from with to in every feature and in
+// the customer directory, within a single transaction.
+//
+// Unless merge is set, the rename fails if to is already in use. Otherwise,
+// features having both customers keep to, and the directory entry of to is
+// kept over the one of from. With dryRun set, the affected features are only
+// reported.
+func (svc Service) renameCustomer(ctx context.Context, from, to string, merge, dryRun bool) (*CustomerRename, error) {
+	if !validCustomerID(to) {
+		return nil, render.NewBadRequest(fmt.Sprintf("'to' must be 1 to %d characters long, without whitespace", maxCustomerIDLength))
+	}
+	if from == to {
+		return nil, errRenameToSelf
+	}
+
+	tx, err := svc.store.Begin(ctx, &sql.TxOptions{
+		Isolation: sql.LevelSerializable,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	fromFeatures, err := tx.FindFeaturesByCustomerID(ctx, from)
+	if err != nil {
+		return nil, fmt.Errorf("find features of %q: %w", from, err)
+	}
+	fromProfile, err := findOptionalCustomerProfile(ctx, tx, from)
+	if err != nil {
+		return nil, err
+	}
+	if len(fromFeatures) == 0 && fromProfile == nil {
+		return nil, errCustomerNotFound{id: from}
+	}
+
+	toFeatures, err := tx.FindFeaturesByCustomerID(ctx, to)
+	if err != nil {
+		return nil, fmt.Errorf("find features of %q: %w", to, err)
+	}
+	toProfile, err := findOptionalCustomerProfile(ctx, tx, to)
+	if err != nil {
+		return nil, err
+	}
+	if !merge && (len(toFeatures) != 0 || toProfile != nil) {
+		return nil, errCustomerExists{id: to}
+	}
+
+	hasTo := make(map[uuid.UUID]struct{}, len(toFeatures))
+	for _, f := range toFeatures {
+		hasTo[f.ID] = struct{}{}
+	}
+
+	res := CustomerRename{
+		DryRun:   dryRun,
+		From:     from,
+		To:       to,
+		Features: []RenamedFeature{},
+	}
+	for _, f := range fromFeatures {
+		_, merged := hasTo[f.ID]
+		res.Features = append(res.Features, RenamedFeature{
+			ID:            f.ID,
+			TechnicalName: f.TechnicalName,
+			Merged:        merged,
+		})
+	}
+
+	if dryRun {
+		return &res, nil
+	}
+
+	if err := tx.RenameCustomerID(ctx, from, to); err != nil {
+		return nil, fmt.Errorf("rename customer id: %w", err)
+	}
+
+	if fromProfile != nil {
+		if toProfile == nil {
+			p := *fromProfile
+			p.ID = to
+			p.UpdatedAt = svc.timeFunc()
+			if err := tx.SaveCustomerProfiles(ctx, p); err != nil {
+				return nil, fmt.Errorf("save customer profile: %w", err)
+			}
+		}
+		if err := tx.DeleteCustomerProfile(ctx, from); err != nil {
+			return nil, fmt.Errorf("delete customer profile: %w", err)
+		}
+	}
+
+	for i, rf := range res.Features {
+		if err := svc.publish(ctx, tx, eventFeatureCustomersRemoved, fromFeatures[i], from); err != nil {
+			return nil, fmt.Errorf("publish feature customers removed: %w", err)
+		}
+		if !rf.Merged {
+			if err := svc.publish(ctx, tx, eventFeatureCustomersAdded, fromFeatures[i], to); err != nil {
+				return nil, fmt.Errorf("publish feature customers added: %w", err)
+			}
+		}
+	}
+
+	if err := svc.commit(tx); err != nil {
+		return nil, fmt.Errorf("commit transaction: %w", err)
+	}
+
+	return &res, nil
+}
+
+// findOptionalCustomerProfile returns the profile of the customer, or nil if
+// the customer isn't in the directory.
+func findOptionalCustomerProfile(ctx context.Context, repo Repository, id string) (*CustomerProfile, error) {
+	p, err := repo.FindCustomerProfile(ctx, id)
+	if errors.As(err, &errCustomerNotFound{}) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find customer profile %q: %w", id, err)
+	}
+	return p, nil
+}
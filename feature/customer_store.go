@@ -2,63 +2,252 @@ package feature
 
 import (
 	"context"
+	"errors"
+	"feature/pkg/set"
 	"feature/pkg/slices"
+	"feature/pkg/sqlx"
 	"fmt"
 	"github.com/google/uuid"
 	"time"
 
 	"github.com/doug-martin/goqu/v9"
+	_ "github.com/doug-martin/goqu/v9/dialect/postgres"
 	_ "github.com/doug-martin/goqu/v9/dialect/sqlite3"
 )
 
-func (s Store) saveCustomers(ctx context.Context, cs ...customer) error {
-	if len(cs) == 0 {
-		// At least one customer must be given for the built query to be valid.
-		return nil
+// SaveCustomers saves the given customers, in chunks.
+func (s Store) SaveCustomers(ctx context.Context, cs ...Customer) error {
+	defer observeQuery("saveCustomers")()
+
+	for len(cs) > 0 {
+		chunk := cs
+		if len(chunk) > customerChunkSize {
+			chunk = chunk[:customerChunkSize]
+		}
+		cs = cs[len(chunk):]
+
+		query, args, err := goqu.Dialect(s.dialect.Goqu()).
+			Insert(goqu.T("customer_features")).
+			Rows(slices.Map(func(c Customer) goqu.Record {
+				return goqu.Record{
+					"id":          c.ID,
+					"feature_id":  c.FeatureID,
+					"customer_id": c.CustomerID,
+				}
+			}, chunk...)).
+			Prepared(true).
+			ToSQL()
+		if err != nil {
+			return fmt.Errorf("bad query: %w", err)
+		}
+
+		if _, err := s.db.ExecContext(ctx, query, args...); err != nil {
+			return err
+		}
 	}
+	return nil
+}
+
+// customerChunkSize is the number of customers written per statement, keeping
+// the number of bind parameters below the limits of the databases.
+const customerChunkSize = 300
+
+// SaveMissingCustomers saves the customers the feature doesn't have yet,
+// ignoring the others, including the ones saved concurrently. Customers are
+// inserted in chunks. It returns the IDs of the customers saved, in the order
+// given.
+func (s Store) SaveMissingCustomers(ctx context.Context, featureID uuid.UUID, cs ...Customer) ([]string, error) {
+	defer observeQuery("saveMissingCustomers")()
 
-	query, args, err := goqu.Dialect("sqlite3").
-		Insert(goqu.T("customer_features")).
-		Rows(slices.Map(func(c customer) goqu.Record {
-			return goqu.Record{
-				"id":          c.ID,
-				"feature_id":  c.FeatureID,
-				"customer_id": c.CustomerID,
+	var saved []string
+	for len(cs) > 0 {
+		chunk := cs
+		if len(chunk) > customerChunkSize {
+			chunk = chunk[:customerChunkSize]
+		}
+		cs = cs[len(chunk):]
+
+		query, args, err := goqu.Dialect(s.dialect.Goqu()).
+			Insert(goqu.T("customer_features")).
+			Rows(slices.Map(func(c Customer) goqu.Record {
+				return goqu.Record{
+					"id":          c.ID,
+					"feature_id":  featureID,
+					"customer_id": c.CustomerID,
+				}
+			}, chunk...)).
+			OnConflict(goqu.DoNothing()).
+			Prepared(true).
+			ToSQL()
+		if err != nil {
+			return nil, fmt.Errorf("bad query: %w", err)
+		}
+
+		res, err := s.db.ExecContext(ctx, query, args...)
+		if err != nil {
+			return nil, err
+		}
+
+		n, err := res.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if int(n) == len(chunk) {
+			saved = append(saved, slices.Map(func(c Customer) string { return c.CustomerID }, chunk...)...)
+			continue
+		}
+
+		// Skipped rows keep the IDs they were saved with, so the rows having
+		// the IDs of the chunk are the ones inserted.
+		ids, err := s.findInsertedCustomers(ctx, chunk)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range chunk {
+			if _, ok := ids[c.ID]; ok {
+				saved = append(saved, c.CustomerID)
 			}
-		}, cs...)).
+		}
+	}
+
+	return saved, nil
+}
+
+// findInsertedCustomers returns the IDs of the given customers that are stored.
+func (s Store) findInsertedCustomers(ctx context.Context, cs []Customer) (set.Set[uuid.UUID], error) {
+	query, args, err := goqu.Dialect(s.dialect.Goqu()).
+		From(goqu.T("customer_features")).
+		Select(goqu.C("id")).
+		Where(goqu.C("id").In(slices.Map(func(c Customer) uuid.UUID { return c.ID }, cs...))).
 		Prepared(true).
 		ToSQL()
 	if err != nil {
-		return fmt.Errorf("bad query: %w", err)
+		return nil, fmt.Errorf("bad query: %w", err)
 	}
 
-	_, err = s.db.ExecContext(
-		ctx,
-		query,
-		args...,
-	)
-	return err
+	rs, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(set.Set[uuid.UUID])
+	for rs.Next() {
+		var id uuid.UUID
+		if err := rs.Scan(&id); err != nil {
+			return nil, err
+		}
+		res[id] = struct{}{}
+	}
+
+	if err := rs.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := rs.Close(); err != nil {
+		return nil, err
+	}
+
+	return res, nil
 }
 
-func (s Store) deleteCustomersByCustomerIDs(ctx context.Context, customerIDs ...string) error {
+// DeleteFeatureCustomers deletes the given customers of a single feature, in
+// chunks. Other features of the customers are left alone.
+func (s Store) DeleteFeatureCustomers(ctx context.Context, featureID uuid.UUID, customerIDs ...string) error {
+	defer observeQuery("deleteFeatureCustomers")()
+
 	if len(customerIDs) == 0 {
 		return nil
 	}
 
-	query, args, err := goqu.Dialect("sqlite3").
-		Delete(goqu.T("customer_features")).
-		Where(goqu.C("customer_id").In(customerIDs)).
-		Prepared(true).
-		ToSQL()
+	for len(customerIDs) > 0 {
+		chunk := customerIDs
+		if len(chunk) > customerChunkSize {
+			chunk = chunk[:customerChunkSize]
+		}
+		customerIDs = customerIDs[len(chunk):]
+
+		query, args, err := goqu.Dialect(s.dialect.Goqu()).
+			Delete(goqu.T("customer_features")).
+			Where(
+				goqu.C("feature_id").Eq(featureID),
+				goqu.C("customer_id").In(chunk),
+			).
+			Prepared(true).
+			ToSQL()
+		if err != nil {
+			return fmt.Errorf("bad query: %w", err)
+		}
+
+		if _, err := s.db.ExecContext(ctx, query, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s Store) FindAllCustomerFeatures(ctx context.Context) ([]Customer, error) {
+	defer observeQuery("findAllCustomerFeatures")()
+
+	rs, err := s.db.QueryContext(
+		ctx,
+		//language=sqlite
+		`SELECT id,feature_id,customer_id FROM customer_features`,
+	)
 	if err != nil {
-		return fmt.Errorf("bad query: %w", err)
+		return nil, err
 	}
 
-	_, err = s.db.ExecContext(ctx, query, args...)
-	return err
+	var cs []Customer
+	for rs.Next() {
+		var c Customer
+		if err := rs.Scan(&c.ID, &c.FeatureID, &c.CustomerID); err != nil {
+			return nil, err
+		}
+		cs = append(cs, c)
+	}
+
+	if err := rs.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := rs.Close(); err != nil {
+		return nil, err
+	}
+
+	return cs, nil
+}
+
+// DeleteCustomersByCustomerIDs deletes the given customers from every feature,
+// in chunks.
+func (s Store) DeleteCustomersByCustomerIDs(ctx context.Context, customerIDs ...string) error {
+	defer observeQuery("deleteCustomersByCustomerIDs")()
+
+	for len(customerIDs) > 0 {
+		chunk := customerIDs
+		if len(chunk) > customerChunkSize {
+			chunk = chunk[:customerChunkSize]
+		}
+		customerIDs = customerIDs[len(chunk):]
+
+		query, args, err := goqu.Dialect(s.dialect.Goqu()).
+			Delete(goqu.T("customer_features")).
+			Where(goqu.C("customer_id").In(chunk)).
+			Prepared(true).
+			ToSQL()
+		if err != nil {
+			return fmt.Errorf("bad query: %w", err)
+		}
+
+		if _, err := s.db.ExecContext(ctx, query, args...); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (s Store) findCustomerIDsByFeatureID(ctx context.Context, featureID uuid.UUID) ([]string, error) {
+func (s Store) FindCustomerIDsByFeatureID(ctx context.Context, featureID uuid.UUID) ([]string, error) {
+	defer observeQuery("findCustomerIDsByFeatureID")()
+
 	//language=sqlite
 	rs, err := s.db.QueryContext(ctx, `SELECT customer_id FROM customer_features WHERE feature_id = ?`, featureID)
 	if err != nil {
@@ -85,20 +274,58 @@ func (s Store) findCustomerIDsByFeatureID(ctx context.Context, featureID uuid.UU
 	return ids, nil
 }
 
-func (s Store) findCustomerFeaturesByTechnicalNames(ctx context.Context, customerID string, t time.Time, technicalNames ...string) ([]customerFeature, error) {
+// CountCustomersByFeature returns the number of customers per feature. Features
+// without customers are omitted.
+func (s Store) CountCustomersByFeature(ctx context.Context) (map[uuid.UUID]int, error) {
+	defer observeQuery("countCustomersByFeature")()
+
+	//language=sqlite
+	rs, err := s.db.QueryContext(ctx, `SELECT feature_id, COUNT(*) FROM customer_features GROUP BY feature_id`)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[uuid.UUID]int)
+	for rs.Next() {
+		var (
+			id uuid.UUID
+			n  int
+		)
+		if err := rs.Scan(&id, &n); err != nil {
+			return nil, err
+		}
+		res[id] = n
+	}
+
+	if err := rs.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := rs.Close(); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (s Store) FindCustomerFeaturesByTechnicalNames(ctx context.Context, customerID string, t time.Time, technicalNames ...string) ([]CustomerFeature, error) {
+	defer observeQuery("findCustomerFeaturesByTechnicalNames")()
+
 	if len(technicalNames) == 0 {
 		return nil, nil
 	}
 
-	query, args, err := goqu.Dialect("sqlite3").
+	query, args, err := goqu.Dialect(s.dialect.Goqu()).
 		Select(
 			goqu.I("f.technical_name"),
 			goqu.I("f.inverted"),
+			goqu.I("f.killed"),
 			goqu.V(goqu.And(
 				goqu.I("f.expires_on").IsNotNull(),
 				goqu.I("f.expires_on").Lt(t),
 			)).As("expired"),
 			goqu.I("cf.feature_id").IsNotNull().As("customer_has_feature"),
+			goqu.I("f.rules"),
 		).
 		From(goqu.T("features").As("f")).
 		LeftJoin(
@@ -120,13 +347,20 @@ func (s Store) findCustomerFeaturesByTechnicalNames(ctx context.Context, custome
 		return nil, err
 	}
 
-	var cfs []customerFeature
+	var (
+		cfs   []CustomerFeature
+		rules [][]TargetingRule
+	)
 	for rs.Next() {
-		var cf customerFeature
-		if err := rs.Scan(&cf.TechnicalName, &cf.Inverted, &cf.Expired, &cf.HasFeature); err != nil {
+		var (
+			cf CustomerFeature
+			r  sqlx.JSONArray[TargetingRule]
+		)
+		if err := rs.Scan(&cf.TechnicalName, &cf.Inverted, &cf.Killed, &cf.Expired, &cf.HasFeature, &r); err != nil {
 			return nil, err
 		}
 		cfs = append(cfs, cf)
+		rules = append(rules, r)
 	}
 
 	if err := rs.Err(); err != nil {
@@ -137,5 +371,115 @@ func (s Store) findCustomerFeaturesByTechnicalNames(ctx context.Context, custome
 		return nil, err
 	}
 
+	if err := s.matchRules(ctx, customerID, cfs, rules); err != nil {
+		return nil, err
+	}
+
 	return cfs, nil
 }
+
+// matchRules marks the features whose rules match the directory attributes of
+// the customer as features the customer has. The directory is only queried
+// when some of the features have rules.
+func (s Store) matchRules(ctx context.Context, customerID string, cfs []CustomerFeature, rules [][]TargetingRule) error {
+	var (
+		attributes map[string]string
+		loaded     bool
+	)
+	for i := range cfs {
+		if cfs[i].HasFeature || len(rules[i]) == 0 {
+			continue
+		}
+		if !loaded {
+			p, err := s.FindCustomerProfile(ctx, customerID)
+			if errors.As(err, &errCustomerNotFound{}) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			attributes, loaded = p.Attributes, true
+		}
+		cfs[i].HasFeature = matchingRule(rules[i], attributes) != nil
+	}
+	return nil
+}
+
+// FindFeaturesByCustomerID returns the features the customer has, without their
+// customers, ordered by technical name.
+func (s Store) FindFeaturesByCustomerID(ctx context.Context, customerID string) ([]Feature, error) {
+	defer observeQuery("findFeaturesByCustomerID")()
+
+	rs, err := s.db.QueryContext(
+		ctx,
+		//language=sqlite
+		`SELECT f.id,f.display_name,f.technical_name,f.expires_on,f.description,f.inverted,f.killed,f.killed_at,f.created_at,f.updated_at,f.tags,f.owner,f.ticket_url,f.kind,f.rules
+		FROM features f
+		JOIN customer_features cf ON cf.feature_id = f.id
+		WHERE cf.customer_id = ?
+		ORDER BY f.technical_name`,
+		customerID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var fs []Feature
+	for rs.Next() {
+		var fr featureRow
+		if err := rs.Scan(
+			&fr.ID,
+			&fr.DisplayName,
+			&fr.TechnicalName,
+			&fr.ExpiresOn,
+			&fr.Description,
+			&fr.Inverted,
+			&fr.Killed,
+			&fr.KilledAt,
+			&fr.CreatedAt,
+			&fr.UpdatedAt,
+			&fr.Tags,
+			&fr.Owner,
+			&fr.TicketURL,
+			&fr.Kind,
+			&fr.Rules,
+		); err != nil {
+			return nil, err
+		}
+		fs = append(fs, fr.toFeature())
+	}
+
+	if err := rs.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := rs.Close(); err != nil {
+		return nil, err
+	}
+
+	return fs, nil
+}
+
+// RenameCustomerID replaces the customer ID from with to in every feature.
+// Features that already have to lose from instead, merging both customers.
+func (s Store) RenameCustomerID(ctx context.Context, from, to string) error {
+	defer observeQuery("renameCustomerID")()
+
+	if _, err := s.db.ExecContext(
+		ctx,
+		//language=sqlite
+		`DELETE FROM customer_features
+		WHERE customer_id = ? AND feature_id IN (SELECT feature_id FROM customer_features WHERE customer_id = ?)`,
+		from, to,
+	); err != nil {
+		return err
+	}
+
+	_, err := s.db.ExecContext(
+		ctx,
+		//language=sqlite
+		`UPDATE customer_features SET customer_id = ? WHERE customer_id = ?`,
+		to, from,
+	)
+	return err
+}
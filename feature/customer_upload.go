@@ -0,0 +1,244 @@
+package feature
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"feature/pkg/render"
+	"feature/pkg/set"
+
+	"github.com/google/uuid"
+)
+
+// CustomerUploadMode tells what happens to the customers of a feature missing
+// from an upload.
+type CustomerUploadMode string
+
+const (
+	// UploadAppend keeps the customers missing from the upload.
+	UploadAppend CustomerUploadMode = "append"
+	// UploadReplace removes the customers missing from the upload.
+	UploadReplace CustomerUploadMode = "replace"
+)
+
+// Formats of customer uploads.
+const (
+	// uploadFormatLines is one customer ID per line.
+	uploadFormatLines = "lines"
+	// uploadFormatCSV is CSV with the customer ID in the first column, and an
+	// optional header row.
+	uploadFormatCSV = "csv"
+)
+
+// maxCustomerIDLength is the maximum length of uploaded customer IDs, in
+// characters.
+const maxCustomerIDLength = 255
+
+// maxReportedInvalidLines is the maximum number of invalid lines listed in an
+// upload report.
+const maxReportedInvalidLines = 100
+
+// CustomerUploadReport sums up the outcome of a customer upload.
+type CustomerUploadReport struct {
+	Added int
+	// AlreadyPresent counts the customers the feature already had, including
+	// repetitions within the upload.
+	AlreadyPresent int
+	// Removed counts the customers removed in replace mode.
+	Removed int
+	Invalid int
+	// InvalidLines are the line numbers of the first invalid customer IDs.
+	InvalidLines []int
+}
+
+var errBadUploadMode = render.NewBadRequest(fmt.Sprintf("'mode' must be one of %s or %s", UploadAppend, UploadReplace))
+
+// uploadCustomers adds the customer IDs read from r to the feature, within a
+// single transaction. In replace mode, customers missing from the upload are
+// removed. Invalid customer IDs are skipped and reported.
+func (svc Service) uploadCustomers(ctx context.Context, featureID uuid.UUID, mode CustomerUploadMode, format string, r io.Reader) (*CustomerUploadReport, error) {
+	if mode != UploadAppend && mode != UploadReplace {
+		return nil, errBadUploadMode
+	}
+
+	tx, err := svc.store.Begin(ctx, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	f, err := tx.FindFeature(ctx, featureID)
+	if err != nil {
+		return nil, fmt.Errorf("find feature: %w", err)
+	}
+
+	ids, err := tx.FindCustomerIDsByFeatureID(ctx, featureID)
+	if err != nil {
+		return nil, fmt.Errorf("find customer ids by feature id: %w", err)
+	}
+
+	var (
+		report   CustomerUploadReport
+		current  = set.Of(ids...)
+		uploaded = set.Set[string]{}
+		pending  []Customer
+		added    []string
+	)
+	// Customers are saved as they are read, a chunk at a time, rather than
+	// once the whole upload is read. Customers added concurrently since
+	// reading the current ones are skipped by the store, and count as already
+	// present.
+	flush := func() error {
+		saved, err := tx.SaveMissingCustomers(ctx, featureID, pending...)
+		if err != nil {
+			return fmt.Errorf("save customers: %w", err)
+		}
+		report.Added += len(saved)
+		report.AlreadyPresent += len(pending) - len(saved)
+		added = append(added, saved...)
+		pending = pending[:0]
+		return nil
+	}
+
+	var saveErr error
+	err = readCustomerIDs(r, format, func(line int, customerID string) error {
+		if !validCustomerID(customerID) {
+			report.Invalid++
+			if len(report.InvalidLines) < maxReportedInvalidLines {
+				report.InvalidLines = append(report.InvalidLines, line)
+			}
+			return nil
+		}
+
+		_, isUploaded := uploaded[customerID]
+		_, isCurrent := current[customerID]
+		uploaded[customerID] = struct{}{}
+		if isUploaded || isCurrent {
+			report.AlreadyPresent++
+			return nil
+		}
+
+		id, err := svc.uuidFunc()
+		if err != nil {
+			saveErr = fmt.Errorf("generate customer feature join table id: %w", err)
+			return saveErr
+		}
+		pending = append(pending, Customer{ID: id, FeatureID: featureID, CustomerID: customerID})
+		if len(pending) == customerChunkSize {
+			saveErr = flush()
+		}
+		return saveErr
+	})
+	if saveErr != nil {
+		return nil, saveErr
+	}
+	if err != nil {
+		return nil, render.TagBadRequest(fmt.Errorf("read customer ids: %w", err))
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	if mode == UploadReplace {
+		removed = set.Sub(current, uploaded).ToSlice()
+		if err := tx.DeleteFeatureCustomers(ctx, featureID, removed...); err != nil {
+			return nil, fmt.Errorf("delete removed customers: %w", err)
+		}
+		report.Removed = len(removed)
+	}
+
+	if 0 < len(added) {
+		if err := svc.publish(ctx, tx, eventFeatureCustomersAdded, *f, added...); err != nil {
+			return nil, fmt.Errorf("publish feature customers added: %w", err)
+		}
+	}
+
+	if 0 < len(removed) {
+		if err := svc.publish(ctx, tx, eventFeatureCustomersRemoved, *f, removed...); err != nil {
+			return nil, fmt.Errorf("publish feature customers removed: %w", err)
+		}
+	}
+
+	if err := svc.commit(tx); err != nil {
+		return nil, fmt.Errorf("commit transaction: %w", err)
+	}
+
+	return &report, nil
+}
+
+// readCustomerIDs calls fn with every customer ID read from r, along with its
+// line number, until fn fails. Blank lines are skipped.
+func readCustomerIDs(r io.Reader, format string, fn func(line int, customerID string) error) error {
+	if format == uploadFormatCSV {
+		return readCSVCustomerIDs(r, fn)
+	}
+
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		id := strings.TrimSpace(sc.Text())
+		if id == "" {
+			continue
+		}
+		if err := fn(line, id); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
+
+// csvHeaders are the header names recognized in the first row of CSV uploads.
+var csvHeaders = set.Of("customerid", "customer_id", "customer", "id")
+
+func readCSVCustomerIDs(r io.Reader, fn func(line int, customerID string) error) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	cr.TrimLeadingSpace = true
+
+	for first := true; ; first = false {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		id := strings.TrimSpace(record[0])
+		if first {
+			if _, ok := csvHeaders[strings.ToLower(id)]; ok {
+				continue
+			}
+		}
+		if id == "" && len(record) == 1 {
+			continue
+		}
+
+		line, _ := cr.FieldPos(0)
+		if err := fn(line, id); err != nil {
+			return err
+		}
+	}
+}
+
+// validCustomerID reports whether id can be stored as customer ID: it must not
+// be empty, too long, or contain whitespace or control characters.
+func validCustomerID(id string) bool {
+	if id == "" || utf8.RuneCountInString(id) > maxCustomerIDLength || !utf8.ValidString(id) {
+		return false
+	}
+	return strings.IndexFunc(id, func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsControl(r)
+	}) == -1
+}
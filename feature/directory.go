@@ -0,0 +1,304 @@
+package feature
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"feature/pkg/render"
+)
+
+// A CustomerProfile is the entry of a customer in the customer directory. It
+// describes the customer behind an ID used in customer_features, which needn't
+// have a profile.
+type CustomerProfile struct {
+	ID          string
+	DisplayName *string
+	// Attributes are arbitrary properties of the customer, such as its plan
+	// or region.
+	Attributes map[string]string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+type errCustomerNotFound struct {
+	id string
+}
+
+func (e errCustomerNotFound) Error() string {
+	return fmt.Sprintf("customer %q does not exist", e.id)
+}
+
+func (e errCustomerNotFound) Code() int {
+	return http.StatusNotFound
+}
+
+func (e errCustomerNotFound) ErrorCode() string {
+	return "customer-not-found"
+}
+
+// Page sizes of customer lookups.
+const (
+	defaultCustomerPageSize = 20
+	maxCustomerPageSize     = 100
+)
+
+var errBadCustomerPageSize = render.NewBadRequest(fmt.Sprintf("'limit' must be between 1 and %d", maxCustomerPageSize))
+
+// normalize trims the display name and attributes of p, dropping empty ones.
+func (p *CustomerProfile) normalize() {
+	if p.DisplayName != nil {
+		if name := strings.TrimSpace(*p.DisplayName); name != "" {
+			p.DisplayName = &name
+		} else {
+			p.DisplayName = nil
+		}
+	}
+
+	attributes := make(map[string]string, len(p.Attributes))
+	for k, v := range p.Attributes {
+		if v = strings.TrimSpace(v); v != "" {
+			attributes[strings.TrimSpace(k)] = v
+		}
+	}
+	p.Attributes = attributes
+}
+
+// validate checks a normalized profile.
+func (p CustomerProfile) validate() error {
+	if !validCustomerID(p.ID) {
+		return render.NewBadRequest(fmt.Sprintf("'id' must be 1 to %d characters long, without whitespace", maxCustomerIDLength))
+	}
+	for k := range p.Attributes {
+		if k == "" {
+			return render.NewBadRequest("attribute names must not be empty")
+		}
+	}
+	return nil
+}
+
+// findCustomerProfiles looks up the profiles whose ID starts with text, or
+// whose display name contains it. An empty text matches every profile.
+func (svc Service) findCustomerProfiles(ctx context.Context, text string, limit int) ([]CustomerProfile, error) {
+	if limit == 0 {
+		limit = defaultCustomerPageSize
+	}
+	if limit < 1 || maxCustomerPageSize < limit {
+		return nil, errBadCustomerPageSize
+	}
+
+	ps, err := svc.store.FindCustomerProfiles(ctx, strings.TrimSpace(text), limit)
+	if err != nil {
+		return nil, fmt.Errorf("find customer profiles: %w", err)
+	}
+	return ps, nil
+}
+
+// saveCustomerProfile creates the profile of a customer, or replaces it. It
+// returns the saved profile.
+func (svc Service) saveCustomerProfile(ctx context.Context, p CustomerProfile) (*CustomerProfile, error) {
+	p.normalize()
+	if err := p.validate(); err != nil {
+		return nil, fmt.Errorf("validate customer: %w", err)
+	}
+
+	tx, err := svc.store.Begin(ctx, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	p.CreatedAt = svc.timeFunc()
+	p.UpdatedAt = p.CreatedAt
+	if err := tx.SaveCustomerProfiles(ctx, p); err != nil {
+		return nil, fmt.Errorf("save customer profile: %w", err)
+	}
+
+	saved, err := tx.FindCustomerProfile(ctx, p.ID)
+	if err != nil {
+		return nil, fmt.Errorf("find saved customer profile: %w", err)
+	}
+
+	if err := svc.commit(tx); err != nil {
+		return nil, fmt.Errorf("commit transaction: %w", err)
+	}
+
+	return saved, nil
+}
+
+// deleteCustomerProfile removes the profile of a customer, which may stop the
+// rules of features from targeting it.
+func (svc Service) deleteCustomerProfile(ctx context.Context, id string) error {
+	tx, err := svc.store.Begin(ctx, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := tx.DeleteCustomerProfile(ctx, id); err != nil {
+		return fmt.Errorf("delete customer profile: %w", err)
+	}
+
+	if err := svc.commit(tx); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+	return nil
+}
+
+// CustomerProfileUploadReport sums up the outcome of a customer directory
+// upload.
+type CustomerProfileUploadReport struct {
+	Created int
+	Updated int
+	Invalid int
+	// InvalidLines are the line numbers of the first invalid rows.
+	InvalidLines []int
+}
+
+// Columns of customer directory uploads. Other columns are attributes.
+const (
+	profileColumnID          = "id"
+	profileColumnDisplayName = "displayname"
+)
+
+var errMissingIDColumn = render.NewBadRequest("header row must have an 'id' column")
+
+// uploadCustomerProfiles creates or replaces the profiles read from a CSV
+// document, within a single transaction. The first row is the header, naming
+// an 'id' column, an optional 'displayName' column and any number of attribute
+// columns. Invalid rows are skipped and reported. Rows repeating an ID replace
+// the earlier ones.
+func (svc Service) uploadCustomerProfiles(ctx context.Context, r io.Reader) (*CustomerProfileUploadReport, error) {
+	var (
+		report CustomerProfileUploadReport
+		ps     []CustomerProfile
+		index  = map[string]int{}
+		now    = svc.timeFunc()
+	)
+	err := readCustomerProfiles(r, func(line int, p CustomerProfile) {
+		p.normalize()
+		if p.validate() != nil {
+			report.Invalid++
+			if len(report.InvalidLines) < maxReportedInvalidLines {
+				report.InvalidLines = append(report.InvalidLines, line)
+			}
+			return
+		}
+
+		p.CreatedAt, p.UpdatedAt = now, now
+		if i, ok := index[p.ID]; ok {
+			ps[i] = p
+			return
+		}
+		index[p.ID] = len(ps)
+		ps = append(ps, p)
+	})
+	if errors.Is(err, errMissingIDColumn) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, render.TagBadRequest(fmt.Errorf("read customers: %w", err))
+	}
+
+	tx, err := svc.store.Begin(ctx, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	ids := make([]string, len(ps))
+	for i, p := range ps {
+		ids[i] = p.ID
+	}
+	existing, err := tx.FindCustomerProfilesByIDs(ctx, ids...)
+	if err != nil {
+		return nil, fmt.Errorf("find existing customer profiles: %w", err)
+	}
+	report.Updated = len(existing)
+	report.Created = len(ps) - len(existing)
+
+	if err := tx.SaveCustomerProfiles(ctx, ps...); err != nil {
+		return nil, fmt.Errorf("save customer profiles: %w", err)
+	}
+
+	if err := svc.commit(tx); err != nil {
+		return nil, fmt.Errorf("commit transaction: %w", err)
+	}
+
+	return &report, nil
+}
+
+// readCustomerProfiles calls fn with every profile read from the CSV document
+// r, along with its line number. Blank rows are skipped.
+func readCustomerProfiles(r io.Reader, fn func(line int, p CustomerProfile)) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return errMissingIDColumn
+	}
+	if err != nil {
+		return err
+	}
+	idColumn, nameColumn := -1, -1
+	for i, h := range header {
+		header[i] = strings.TrimSpace(h)
+		switch strings.ToLower(header[i]) {
+		case profileColumnID, "customerid", "customer_id":
+			idColumn = i
+		case profileColumnDisplayName, "display_name", "name":
+			nameColumn = i
+		}
+	}
+	if idColumn == -1 {
+		return errMissingIDColumn
+	}
+
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+
+		var p CustomerProfile
+		if idColumn < len(record) {
+			p.ID = strings.TrimSpace(record[idColumn])
+		}
+		if nameColumn != -1 && nameColumn < len(record) {
+			p.DisplayName = &record[nameColumn]
+		}
+		for i, v := range record {
+			if i == idColumn || i == nameColumn || i >= len(header) || header[i] == "" {
+				continue
+			}
+			if p.Attributes == nil {
+				p.Attributes = map[string]string{}
+			}
+			p.Attributes[header[i]] = v
+		}
+
+		line, _ := cr.FieldPos(0)
+		fn(line, p)
+	}
+}
@@ -0,0 +1,196 @@
+package feature
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"feature/pkg/slices"
+	"feature/pkg/sqlx"
+
+	"github.com/doug-martin/goqu/v9"
+)
+
+func (s Store) FindCustomerProfiles(ctx context.Context, text string, limit int) ([]CustomerProfile, error) {
+	defer observeQuery("findCustomerProfiles")()
+
+	text = likeEscaper.Replace(strings.ToLower(text))
+	rs, err := s.db.QueryContext(
+		ctx,
+		//language=sqlite
+		`SELECT id,display_name,attributes,created_at,updated_at FROM customers
+		WHERE LOWER(id) LIKE ? ESCAPE '!' OR LOWER(COALESCE(display_name,'')) LIKE ? ESCAPE '!'
+		ORDER BY id
+		LIMIT ?`,
+		text+"%", "%"+text+"%", limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanCustomerProfiles(rs)
+}
+
+func (s Store) FindAllCustomerProfiles(ctx context.Context) ([]CustomerProfile, error) {
+	defer observeQuery("findAllCustomerProfiles")()
+
+	rs, err := s.db.QueryContext(
+		ctx,
+		//language=sqlite
+		`SELECT id,display_name,attributes,created_at,updated_at FROM customers ORDER BY id`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanCustomerProfiles(rs)
+}
+
+func (s Store) FindCustomerProfilesByIDs(ctx context.Context, ids ...string) ([]CustomerProfile, error) {
+	defer observeQuery("findCustomerProfilesByIDs")()
+
+	var res []CustomerProfile
+	for len(ids) > 0 {
+		chunk := ids
+		if len(chunk) > customerChunkSize {
+			chunk = chunk[:customerChunkSize]
+		}
+		ids = ids[len(chunk):]
+
+		query, args, err := goqu.Dialect(s.dialect.Goqu()).
+			From(goqu.T("customers")).
+			Select("id", "display_name", "attributes", "created_at", "updated_at").
+			Where(goqu.C("id").In(chunk)).
+			Order(goqu.C("id").Asc()).
+			Prepared(true).
+			ToSQL()
+		if err != nil {
+			return nil, fmt.Errorf("bad query: %w", err)
+		}
+
+		rs, err := s.db.QueryContext(ctx, query, args...)
+		if err != nil {
+			return nil, err
+		}
+		ps, err := scanCustomerProfiles(rs)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, ps...)
+	}
+	return res, nil
+}
+
+func (s Store) FindCustomerProfile(ctx context.Context, id string) (*CustomerProfile, error) {
+	defer observeQuery("findCustomerProfile")()
+
+	rs, err := s.db.QueryContext(
+		ctx,
+		//language=sqlite
+		`SELECT id,display_name,attributes,created_at,updated_at FROM customers WHERE id=?`,
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	ps, err := scanCustomerProfiles(rs)
+	if err != nil {
+		return nil, err
+	}
+	if len(ps) == 0 {
+		return nil, errCustomerNotFound{id: id}
+	}
+	return &ps[0], nil
+}
+
+func scanCustomerProfiles(rs *sql.Rows) ([]CustomerProfile, error) {
+	var ps []CustomerProfile
+	for rs.Next() {
+		var (
+			p          CustomerProfile
+			attributes sqlx.JSONObject[string]
+		)
+		if err := rs.Scan(&p.ID, &p.DisplayName, &attributes, &p.CreatedAt, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		p.Attributes = attributes
+		if p.Attributes == nil {
+			p.Attributes = map[string]string{}
+		}
+		p.CreatedAt = p.CreatedAt.UTC()
+		p.UpdatedAt = p.UpdatedAt.UTC()
+		ps = append(ps, p)
+	}
+
+	if err := rs.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := rs.Close(); err != nil {
+		return nil, err
+	}
+
+	return ps, nil
+}
+
+func (s Store) SaveCustomerProfiles(ctx context.Context, ps ...CustomerProfile) error {
+	defer observeQuery("saveCustomerProfiles")()
+
+	for len(ps) > 0 {
+		chunk := ps
+		if len(chunk) > customerChunkSize {
+			chunk = chunk[:customerChunkSize]
+		}
+		ps = ps[len(chunk):]
+
+		query, args, err := goqu.Dialect(s.dialect.Goqu()).
+			Insert(goqu.T("customers")).
+			Rows(slices.Map(func(p CustomerProfile) goqu.Record {
+				return goqu.Record{
+					"id":           p.ID,
+					"display_name": p.DisplayName,
+					"attributes":   sqlx.JSONObject[string](p.Attributes),
+					"created_at":   p.CreatedAt.UTC(),
+					"updated_at":   p.UpdatedAt.UTC(),
+				}
+			}, chunk...)).
+			OnConflict(goqu.DoUpdate("id", goqu.Record{
+				"display_name": goqu.L("excluded.display_name"),
+				"attributes":   goqu.L("excluded.attributes"),
+				"updated_at":   goqu.L("excluded.updated_at"),
+			})).
+			Prepared(true).
+			ToSQL()
+		if err != nil {
+			return fmt.Errorf("bad query: %w", err)
+		}
+
+		if _, err := s.db.ExecContext(ctx, query, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s Store) DeleteCustomerProfile(ctx context.Context, id string) error {
+	defer observeQuery("deleteCustomerProfile")()
+
+	res, err := s.db.ExecContext(
+		ctx,
+		//language=sqlite
+		`DELETE FROM customers WHERE id=?`,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	rs, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rs == 0 {
+		return errCustomerNotFound{id: id}
+	}
+	return nil
+}
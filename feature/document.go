@@ -0,0 +1,623 @@
+package feature
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+
+	"feature/pkg/render"
+	"feature/pkg/set"
+)
+
+// DocumentVersion is the version of the Document format produced by Export, and
+// the only version accepted by Import.
+const DocumentVersion = 1
+
+// Supported Document encodings.
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+)
+
+// Document is a declarative description of all flag configuration, meant to be
+// kept under version control. Features are identified by their technical name,
+// so that a document can be applied to environments with different feature IDs.
+// The customer directory is included, as the rules of features target its
+// attributes.
+type Document struct {
+	Version          int                       `json:"version" yaml:"version"`
+	Features         []DocumentFeature         `json:"features" yaml:"features"`
+	ArchivedFeatures []DocumentArchivedFeature `json:"archivedFeatures" yaml:"archivedFeatures"`
+	Customers        []DocumentCustomer        `json:"customers" yaml:"customers"`
+}
+
+// DocumentFeature describes a feature, along with its customers.
+type DocumentFeature struct {
+	TechnicalName string          `json:"technicalName" yaml:"technicalName"`
+	DisplayName   *string         `json:"displayName,omitempty" yaml:"displayName,omitempty"`
+	Description   *string         `json:"description,omitempty" yaml:"description,omitempty"`
+	ExpiresOn     *time.Time      `json:"expiresOn,omitempty" yaml:"expiresOn,omitempty"`
+	Inverted      bool            `json:"inverted" yaml:"inverted"`
+	Killed        bool            `json:"killed" yaml:"killed"`
+	KilledAt      *time.Time      `json:"killedAt,omitempty" yaml:"killedAt,omitempty"`
+	CustomerIDs   []string        `json:"customerIds,omitempty" yaml:"customerIds,omitempty"`
+	Tags          []string        `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Owner         *string         `json:"owner,omitempty" yaml:"owner,omitempty"`
+	TicketURL     *string         `json:"ticketUrl,omitempty" yaml:"ticketUrl,omitempty"`
+	Kind          string          `json:"kind,omitempty" yaml:"kind,omitempty"`
+	Rules         []TargetingRule `json:"rules,omitempty" yaml:"rules,omitempty"`
+}
+
+// DocumentArchivedFeature describes an archived feature. Since technical names
+// of archived features need not be unique, they are identified by ID.
+type DocumentArchivedFeature struct {
+	ID            uuid.UUID `json:"id" yaml:"id"`
+	TechnicalName string    `json:"technicalName" yaml:"technicalName"`
+	DisplayName   *string   `json:"displayName,omitempty" yaml:"displayName,omitempty"`
+	Description   *string   `json:"description,omitempty" yaml:"description,omitempty"`
+	CreatedAt     time.Time `json:"createdAt" yaml:"createdAt"`
+	UpdatedAt     time.Time `json:"updatedAt" yaml:"updatedAt"`
+}
+
+// DocumentCustomer describes the profile of a customer in the directory.
+type DocumentCustomer struct {
+	ID          string            `json:"id" yaml:"id"`
+	DisplayName *string           `json:"displayName,omitempty" yaml:"displayName,omitempty"`
+	Attributes  map[string]string `json:"attributes,omitempty" yaml:"attributes,omitempty"`
+}
+
+func (d Document) validate() error {
+	var errs errFeatureInvalid
+
+	if d.Version != DocumentVersion {
+		errs = append(errs, fmt.Sprintf("'version' must be %d", DocumentVersion))
+	}
+
+	names := make(set.Set[string])
+	for _, f := range d.Features {
+		if _, ok := names[f.TechnicalName]; ok {
+			errs = append(errs, fmt.Sprintf("'features' contains technical name %q more than once", f.TechnicalName))
+		}
+		names[f.TechnicalName] = struct{}{}
+	}
+
+	ids := make(set.Set[uuid.UUID])
+	for _, af := range d.ArchivedFeatures {
+		if _, ok := ids[af.ID]; ok {
+			errs = append(errs, fmt.Sprintf("'archivedFeatures' contains id %q more than once", af.ID))
+		}
+		ids[af.ID] = struct{}{}
+	}
+
+	customerIDs := make(set.Set[string])
+	for _, dc := range d.Customers {
+		if _, ok := customerIDs[dc.ID]; ok {
+			errs = append(errs, fmt.Sprintf("'customers' contains id %q more than once", dc.ID))
+		}
+		customerIDs[dc.ID] = struct{}{}
+	}
+
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+func documentFeatureFromFeature(f Feature) DocumentFeature {
+	res := DocumentFeature{
+		TechnicalName: f.TechnicalName,
+		DisplayName:   f.DisplayName,
+		Description:   f.Description,
+		Inverted:      f.Inverted,
+		Killed:        f.Killed,
+		CustomerIDs:   f.CustomerIDs,
+		Tags:          f.Tags,
+		Owner:         f.Owner,
+		TicketURL:     f.TicketURL,
+		Kind:          string(f.Kind),
+		Rules:         f.Rules,
+	}
+	if f.ExpiresOn != nil {
+		res.ExpiresOn = ptr(f.ExpiresOn.UTC())
+	}
+	if f.Killed && f.KilledAt != nil {
+		res.KilledAt = ptr(f.KilledAt.UTC())
+	}
+	sort.Strings(res.CustomerIDs)
+	return res
+}
+
+// toFeature returns the feature described, with its metadata normalized.
+func (df DocumentFeature) toFeature() Feature {
+	f := Feature{
+		DisplayName:   df.DisplayName,
+		TechnicalName: df.TechnicalName,
+		ExpiresOn:     df.ExpiresOn,
+		Description:   df.Description,
+		Inverted:      df.Inverted,
+		Killed:        df.Killed,
+		CustomerIDs:   df.CustomerIDs,
+		Tags:          df.Tags,
+		Owner:         df.Owner,
+		TicketURL:     df.TicketURL,
+		Kind:          FeatureKind(df.Kind),
+		Rules:         df.Rules,
+	}
+	if df.Killed {
+		f.KilledAt = df.KilledAt
+	}
+	f.normalize()
+	return f
+}
+
+// equal reports whether both features describe the same configuration. The
+// time a feature was killed is not part of it, as killing a killed feature
+// again doesn't change that time.
+func (df DocumentFeature) equal(other DocumentFeature) bool {
+	if df.ExpiresOn != nil && other.ExpiresOn != nil {
+		if !df.ExpiresOn.Equal(*other.ExpiresOn) {
+			return false
+		}
+	} else if df.ExpiresOn != other.ExpiresOn {
+		return false
+	}
+
+	return df.TechnicalName == other.TechnicalName &&
+		reflect.DeepEqual(df.DisplayName, other.DisplayName) &&
+		reflect.DeepEqual(df.Description, other.Description) &&
+		df.Inverted == other.Inverted &&
+		df.Killed == other.Killed &&
+		reflect.DeepEqual(set.Of(df.CustomerIDs...), set.Of(other.CustomerIDs...)) &&
+		reflect.DeepEqual(df.Tags, other.Tags) &&
+		reflect.DeepEqual(df.Owner, other.Owner) &&
+		reflect.DeepEqual(df.TicketURL, other.TicketURL) &&
+		df.Kind == other.Kind &&
+		reflect.DeepEqual(df.Rules, other.Rules)
+}
+
+func documentCustomerFromProfile(p CustomerProfile) DocumentCustomer {
+	res := DocumentCustomer{
+		ID:          p.ID,
+		DisplayName: p.DisplayName,
+	}
+	if len(p.Attributes) != 0 {
+		res.Attributes = p.Attributes
+	}
+	return res
+}
+
+// toProfile returns the profile described, normalized.
+func (dc DocumentCustomer) toProfile() CustomerProfile {
+	p := CustomerProfile{
+		ID:          dc.ID,
+		DisplayName: dc.DisplayName,
+		Attributes:  dc.Attributes,
+	}
+	p.normalize()
+	return p
+}
+
+// equal reports whether both customers are described the same.
+func (dc DocumentCustomer) equal(other DocumentCustomer) bool {
+	return dc.ID == other.ID &&
+		reflect.DeepEqual(dc.DisplayName, other.DisplayName) &&
+		reflect.DeepEqual(dc.Attributes, other.Attributes)
+}
+
+// EncodeDocument writes the document to w in the given format.
+func EncodeDocument(w io.Writer, d Document, format string) error {
+	switch format {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(d)
+	case FormatYAML:
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(d); err != nil {
+			return err
+		}
+		return enc.Close()
+	default:
+		return errUnknownFormat{format: format}
+	}
+}
+
+// DecodeDocument reads a document in the given format from r.
+func DecodeDocument(r io.Reader, format string) (*Document, error) {
+	var d Document
+	switch format {
+	case FormatJSON:
+		dec := json.NewDecoder(r)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&d); err != nil {
+			return nil, render.TagBadRequest(err)
+		}
+	case FormatYAML:
+		dec := yaml.NewDecoder(r)
+		dec.KnownFields(true)
+		if err := dec.Decode(&d); err != nil {
+			return nil, render.TagBadRequest(err)
+		}
+	default:
+		return nil, errUnknownFormat{format: format}
+	}
+	return &d, nil
+}
+
+type errUnknownFormat struct {
+	format string
+}
+
+func (e errUnknownFormat) Error() string {
+	return fmt.Sprintf("unknown document format %q", e.format)
+}
+
+func (e errUnknownFormat) Code() int {
+	return http.StatusBadRequest
+}
+
+func (e errUnknownFormat) ErrorCode() string {
+	return "unknown-format"
+}
+
+// ImportReport lists the changes made, or that would be made, by an import.
+type ImportReport struct {
+	DryRun  bool           `json:"dryRun"`
+	Creates []ImportChange `json:"creates"`
+	Updates []ImportChange `json:"updates"`
+	Deletes []ImportChange `json:"deletes"`
+}
+
+// ImportChange identifies a single entity changed by an import. Features are
+// identified by their technical name and ID, customers by their customer ID.
+type ImportChange struct {
+	Kind          string     `json:"kind"`
+	TechnicalName string     `json:"technicalName,omitempty"`
+	ID            *uuid.UUID `json:"id,omitempty"`
+	CustomerID    string     `json:"customerId,omitempty"`
+}
+
+// Kinds of entities changed by an import.
+const (
+	importKindFeature         = "feature"
+	importKindArchivedFeature = "archivedFeature"
+	importKindCustomer        = "customer"
+)
+
+// Export describes all flag configuration as a Document.
+func (svc Service) Export(ctx context.Context) (*Document, error) {
+	tx, err := svc.store.Begin(ctx, &sql.TxOptions{
+		Isolation: sql.LevelRepeatableRead,
+		ReadOnly:  true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	fs, err := tx.FindAllFeaturesWithCustomers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("find all features with customers: %w", err)
+	}
+
+	afs, err := tx.FindAllArchivedFeatures(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("find all archived features: %w", err)
+	}
+
+	ps, err := tx.FindAllCustomerProfiles(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("find all customer profiles: %w", err)
+	}
+
+	d := Document{
+		Version:          DocumentVersion,
+		Features:         make([]DocumentFeature, len(fs)),
+		ArchivedFeatures: make([]DocumentArchivedFeature, len(afs)),
+		Customers:        make([]DocumentCustomer, len(ps)),
+	}
+	for i, p := range ps {
+		d.Customers[i] = documentCustomerFromProfile(p)
+	}
+	for i, f := range fs {
+		d.Features[i] = documentFeatureFromFeature(f)
+	}
+	for i, af := range afs {
+		d.ArchivedFeatures[i] = DocumentArchivedFeature{
+			ID:            af.ID,
+			TechnicalName: af.TechnicalName,
+			DisplayName:   af.DisplayName,
+			Description:   af.Description,
+			CreatedAt:     af.CreatedAt.UTC(),
+			UpdatedAt:     af.UpdatedAt.UTC(),
+		}
+	}
+
+	sort.Slice(d.Features, func(i, j int) bool {
+		return d.Features[i].TechnicalName < d.Features[j].TechnicalName
+	})
+	sort.Slice(d.ArchivedFeatures, func(i, j int) bool {
+		return d.ArchivedFeatures[i].CreatedAt.Before(d.ArchivedFeatures[j].CreatedAt)
+	})
+
+	return &d, nil
+}
+
+// importPlan holds the changes needed to make the flag configuration match a
+// document.
+type importPlan struct {
+	creates         []Feature
+	updates         []featureUpdate
+	deletes         []Feature
+	archivedCreates []Feature
+	customerCreates []CustomerProfile
+	customerUpdates []CustomerProfile
+}
+
+type featureUpdate struct {
+	current, next Feature
+}
+
+func planImport(d Document, fs []Feature, afs []ArchivedFeature, ps []CustomerProfile) importPlan {
+	var plan importPlan
+
+	existing := make(map[string]Feature, len(fs))
+	for _, f := range fs {
+		existing[f.TechnicalName] = f
+	}
+
+	wanted := make(set.Set[string])
+	for _, df := range d.Features {
+		wanted[df.TechnicalName] = struct{}{}
+
+		next := df.toFeature()
+		cur, ok := existing[df.TechnicalName]
+		switch {
+		case !ok:
+			plan.creates = append(plan.creates, next)
+		case !documentFeatureFromFeature(cur).equal(documentFeatureFromFeature(next)):
+			next.ID = cur.ID
+			plan.updates = append(plan.updates, featureUpdate{current: cur, next: next})
+		}
+	}
+
+	for _, f := range fs {
+		if _, ok := wanted[f.TechnicalName]; !ok {
+			plan.deletes = append(plan.deletes, f)
+		}
+	}
+
+	archived := make(set.Set[uuid.UUID])
+	for _, af := range afs {
+		archived[af.ID] = struct{}{}
+	}
+
+	for _, daf := range d.ArchivedFeatures {
+		if _, ok := archived[daf.ID]; ok {
+			continue
+		}
+		plan.archivedCreates = append(plan.archivedCreates, Feature{
+			ID:            daf.ID,
+			DisplayName:   daf.DisplayName,
+			TechnicalName: daf.TechnicalName,
+			Description:   daf.Description,
+			CreatedAt:     daf.CreatedAt,
+			UpdatedAt:     daf.UpdatedAt,
+		})
+	}
+
+	profiles := make(map[string]CustomerProfile, len(ps))
+	for _, p := range ps {
+		profiles[p.ID] = p
+	}
+
+	for _, dc := range d.Customers {
+		next := dc.toProfile()
+		cur, ok := profiles[next.ID]
+		switch {
+		case !ok:
+			plan.customerCreates = append(plan.customerCreates, next)
+		case !documentCustomerFromProfile(cur).equal(documentCustomerFromProfile(next)):
+			plan.customerUpdates = append(plan.customerUpdates, next)
+		}
+	}
+
+	return plan
+}
+
+func (p importPlan) validate(policy ValidationPolicy) error {
+	var errs errFeatureInvalid
+	for _, f := range p.creates {
+		if err := f.validate(policy); err != nil {
+			errs = append(errs, fmt.Sprintf("feature %q: %s", f.TechnicalName, err))
+		}
+	}
+	for _, u := range p.updates {
+		if err := u.next.validate(policy); err != nil {
+			errs = append(errs, fmt.Sprintf("feature %q: %s", u.next.TechnicalName, err))
+		}
+	}
+	for _, cp := range append(p.customerCreates, p.customerUpdates...) {
+		if err := cp.validate(); err != nil {
+			errs = append(errs, fmt.Sprintf("customer %q: %s", cp.ID, err))
+		}
+	}
+
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+// checkTechnicalNames fails if a feature created by the plan would take a
+// technical name that is an alias of a feature the plan keeps, as creating it
+// would. Aliases of the features archived by the plan go away with them.
+func (p importPlan) checkTechnicalNames(ctx context.Context, store AliasRepository) error {
+	deleted := make(set.Set[uuid.UUID], len(p.deletes))
+	for _, f := range p.deletes {
+		deleted[f.ID] = struct{}{}
+	}
+
+	for _, f := range p.creates {
+		a, err := findAlias(ctx, store, f.TechnicalName)
+		if err != nil {
+			return fmt.Errorf("find alias: %w", err)
+		}
+		if a == nil {
+			continue
+		}
+		if _, ok := deleted[a.FeatureID]; !ok {
+			return fmt.Errorf("feature %q: %w", f.TechnicalName, errTechnicalNameTaken{technicalName: f.TechnicalName, featureID: a.FeatureID})
+		}
+	}
+	return nil
+}
+
+func (p importPlan) report(dryRun bool) ImportReport {
+	res := ImportReport{
+		DryRun:  dryRun,
+		Creates: []ImportChange{},
+		Updates: []ImportChange{},
+		Deletes: []ImportChange{},
+	}
+	for _, f := range p.creates {
+		res.Creates = append(res.Creates, ImportChange{Kind: importKindFeature, TechnicalName: f.TechnicalName})
+	}
+	for _, f := range p.archivedCreates {
+		res.Creates = append(res.Creates, ImportChange{Kind: importKindArchivedFeature, TechnicalName: f.TechnicalName, ID: ptr(f.ID)})
+	}
+	for _, cp := range p.customerCreates {
+		res.Creates = append(res.Creates, ImportChange{Kind: importKindCustomer, CustomerID: cp.ID})
+	}
+	for _, u := range p.updates {
+		res.Updates = append(res.Updates, ImportChange{Kind: importKindFeature, TechnicalName: u.next.TechnicalName, ID: ptr(u.next.ID)})
+	}
+	for _, cp := range p.customerUpdates {
+		res.Updates = append(res.Updates, ImportChange{Kind: importKindCustomer, CustomerID: cp.ID})
+	}
+	for _, f := range p.deletes {
+		res.Deletes = append(res.Deletes, ImportChange{Kind: importKindFeature, TechnicalName: f.TechnicalName, ID: ptr(f.ID)})
+	}
+	return res
+}
+
+// Import makes the flag configuration match the given document, within a
+// single transaction. Features missing from the document are archived, while
+// archived features and customer profiles missing from the document are left as
+// they are. With dryRun set, the changes are only reported.
+func (svc Service) Import(ctx context.Context, d Document, dryRun bool) (*ImportReport, error) {
+	if err := d.validate(); err != nil {
+		return nil, fmt.Errorf("validate document: %w", err)
+	}
+
+	tx, err := svc.store.Begin(ctx, &sql.TxOptions{
+		Isolation: sql.LevelSerializable,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	fs, err := tx.FindAllFeaturesWithCustomers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("find all features with customers: %w", err)
+	}
+
+	afs, err := tx.FindAllArchivedFeatures(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("find all archived features: %w", err)
+	}
+
+	ps, err := tx.FindAllCustomerProfiles(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("find all customer profiles: %w", err)
+	}
+
+	plan := planImport(d, fs, afs, ps)
+	if err := plan.validate(svc.policy); err != nil {
+		return nil, fmt.Errorf("validate import: %w", err)
+	}
+
+	// Checked upfront, so that a dry run fails like the import would.
+	if err := plan.checkTechnicalNames(ctx, tx); err != nil {
+		return nil, fmt.Errorf("check technical names: %w", err)
+	}
+
+	report := plan.report(dryRun)
+	if dryRun {
+		return &report, nil
+	}
+
+	// Reuse the regular service methods, so that imported changes are stored and
+	// published like any other. Their transactions join this one.
+	txSvc := svc
+	txSvc.store = tx
+
+	// Archive first, so that technical names of deleted features can be reused.
+	for _, f := range plan.deletes {
+		if err := txSvc.archiveFeature(ctx, f.ID); err != nil {
+			return nil, fmt.Errorf("archive feature %q: %w", f.TechnicalName, err)
+		}
+	}
+
+	now := svc.timeFunc()
+	for _, f := range plan.creates {
+		if f.Killed && f.KilledAt == nil {
+			f.KilledAt = &now
+		}
+		if err := txSvc.saveFeature(ctx, f); err != nil {
+			return nil, fmt.Errorf("create feature %q: %w", f.TechnicalName, err)
+		}
+	}
+
+	for _, u := range plan.updates {
+		// Documents describe features in full, so a feature without rules
+		// loses the rules it has.
+		if u.next.Rules == nil {
+			u.next.Rules = []TargetingRule{}
+		}
+		if err := txSvc.updateFeature(ctx, u.current.UpdatedAt, u.next); err != nil {
+			return nil, fmt.Errorf("update feature %q: %w", u.next.TechnicalName, err)
+		}
+		if u.current.Killed != u.next.Killed {
+			killedAt := now
+			if u.next.KilledAt != nil {
+				killedAt = *u.next.KilledAt
+			}
+			if err := txSvc.setFeatureKilled(ctx, u.next.ID, u.next.Killed, killedAt); err != nil {
+				return nil, fmt.Errorf("toggle kill switch of feature %q: %w", u.next.TechnicalName, err)
+			}
+		}
+	}
+
+	for _, f := range plan.archivedCreates {
+		if err := tx.SaveArchivedFeature(ctx, f); err != nil {
+			return nil, fmt.Errorf("create archived feature %s: %w", f.ID, err)
+		}
+	}
+
+	// Replaced profiles keep their creation time.
+	profiles := append(plan.customerCreates, plan.customerUpdates...)
+	for i := range profiles {
+		profiles[i].CreatedAt, profiles[i].UpdatedAt = now, now
+	}
+	if err := tx.SaveCustomerProfiles(ctx, profiles...); err != nil {
+		return nil, fmt.Errorf("save customer profiles: %w", err)
+	}
+
+	if err := svc.commit(tx); err != nil {
+		return nil, fmt.Errorf("commit transaction: %w", err)
+	}
+
+	return &report, nil
+}
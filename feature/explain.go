@@ -0,0 +1,210 @@
+package feature
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"feature/pkg/set"
+)
+
+// An EvaluationExplanation traces how the evaluation of features for a
+// customer reached its outcomes.
+type EvaluationExplanation struct {
+	// EvaluatedAt is the server time expiry was judged against.
+	EvaluatedAt time.Time
+	// SnapshotLoadedAt is when the evaluated configuration was read from the
+	// store. Changes made since are not reflected yet.
+	SnapshotLoadedAt time.Time
+	Features         []FeatureExplanation
+}
+
+// A FeatureExplanation is the evaluation of a single feature, along with the
+// checks that led to it. Features that don't exist are explained as well, with
+// Found unset.
+type FeatureExplanation struct {
+	CustomerFeature
+	Found bool
+	Steps []ExplanationStep
+}
+
+// An ExplanationStep is a single check made by an evaluation.
+type ExplanationStep struct {
+	Check string
+	// Result tells whether the checked condition holds.
+	Result bool
+	// Decisive is set for the steps that determined the outcome on their own.
+	Decisive bool
+	Detail   string
+}
+
+// Checks made by evaluations, in the order they are explained.
+const (
+	checkExists     = "exists"
+	checkAlias      = "alias"
+	checkKilled     = "killed"
+	checkMembership = "membership"
+	checkRules      = "rules"
+	checkInversion  = "inversion"
+	checkExpiry     = "expiry"
+	checkRollout    = "rollout"
+)
+
+// explainCustomerFeatures evaluates features for a customer the same way
+// findCustomerFeaturesByTechnicalNames does, and traces every decision.
+// Explanations are not recorded as usage of the features.
+func (svc Service) explainCustomerFeatures(ctx context.Context, customerID string, technicalNames ...string) (*EvaluationExplanation, error) {
+	if len(technicalNames) == 0 {
+		return nil, errNoFeatureNames
+	}
+
+	snapshot, err := svc.evaluationSnapshot(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("load evaluation snapshot: %w", err)
+	}
+
+	now := svc.timeFunc()
+	return &EvaluationExplanation{
+		EvaluatedAt:      now,
+		SnapshotLoadedAt: snapshot.loadedAt,
+		Features:         snapshot.explain(customerID, now, technicalNames...),
+	}, nil
+}
+
+// explain evaluates the given features like evaluate, and traces how each
+// outcome was reached. Names matching no feature follow the evaluated ones, in
+// the order they were given.
+func (s *evaluationSnapshot) explain(customerID string, t time.Time, technicalNames ...string) []FeatureExplanation {
+	byName := make(map[string]cachedFeature, len(s.features))
+	for _, f := range s.features {
+		byName[f.technicalName] = f
+	}
+
+	var (
+		res   []FeatureExplanation
+		found = set.Of[string]()
+	)
+	for _, cf := range s.evaluate(customerID, t, technicalNames...) {
+		found[cf.TechnicalName] = struct{}{}
+
+		name := cf.TechnicalName
+		if cf.RenamedTo != "" {
+			name = cf.RenamedTo
+		}
+		res = append(res, FeatureExplanation{
+			CustomerFeature: cf,
+			Found:           true,
+			Steps:           explainSteps(cf, byName[name], s.attributes[customerID], customerID, t),
+		})
+	}
+
+	for _, name := range technicalNames {
+		if _, ok := found[name]; ok {
+			continue
+		}
+		found[name] = struct{}{}
+		res = append(res, FeatureExplanation{
+			CustomerFeature: CustomerFeature{TechnicalName: name},
+			Steps: []ExplanationStep{{
+				Check:    checkExists,
+				Decisive: true,
+				Detail:   fmt.Sprintf("no feature is named %q", name),
+			}},
+		})
+	}
+	return res
+}
+
+// explainSteps traces the evaluation of cf, the outcome for f of a customer
+// with the given directory attributes, mirroring CustomerFeature.isActive.
+func explainSteps(cf CustomerFeature, f cachedFeature, attributes map[string]string, customerID string, t time.Time) []ExplanationStep {
+	expiresOn := f.expiresOn
+
+	var steps []ExplanationStep
+	if cf.RenamedTo != "" {
+		steps = append(steps, ExplanationStep{
+			Check:  checkAlias,
+			Result: true,
+			Detail: fmt.Sprintf("%q is a former name of %q, which is evaluated instead", cf.TechnicalName, cf.RenamedTo),
+		})
+	}
+
+	killed := ExplanationStep{Check: checkKilled, Result: cf.Killed, Decisive: cf.Killed}
+	if cf.Killed {
+		killed.Detail = "the kill switch is on, so the feature is off for every customer"
+	} else {
+		killed.Detail = "the kill switch is off"
+	}
+
+	_, listed := f.customerIDs[customerID]
+	membership := ExplanationStep{Check: checkMembership, Result: listed}
+	if listed {
+		membership.Detail = fmt.Sprintf("customer %q is listed in the customers of the feature", customerID)
+	} else {
+		membership.Detail = fmt.Sprintf("customer %q is not listed in the customers of the feature", customerID)
+	}
+
+	matched := matchingRule(f.rules, attributes)
+	rules := ExplanationStep{Check: checkRules, Result: matched != nil}
+	switch {
+	case len(f.rules) == 0:
+		rules.Detail = "the feature has no targeting rules"
+	case matched != nil:
+		rules.Detail = fmt.Sprintf(
+			"customer %q has %s %q in the customer directory, which the rule on %s targets",
+			customerID, matched.Attribute, attributes[matched.Attribute], matched.Attribute,
+		)
+	default:
+		var has []string
+		for _, r := range f.rules {
+			if v, ok := attributes[r.Attribute]; ok {
+				has = append(has, fmt.Sprintf("%s %q", r.Attribute, v))
+			}
+		}
+		if len(has) == 0 {
+			rules.Detail = fmt.Sprintf("customer %q has none of the attributes targeted by the rules in the customer directory", customerID)
+		} else {
+			rules.Detail = fmt.Sprintf("customer %q matches none of the rules, having %s in the customer directory", customerID, strings.Join(has, ", "))
+		}
+	}
+
+	inversion := ExplanationStep{Check: checkInversion, Result: cf.Inverted}
+	if cf.Inverted {
+		inversion.Detail = "the feature is toggled off, so it is inactive even for listed customers"
+	} else {
+		inversion.Detail = "the feature is toggled on"
+	}
+
+	if !cf.Killed {
+		// Without the kill switch, the toggle and being targeted neither by
+		// membership nor by a rule each turn the feature off. Otherwise, what
+		// targets the customer turns it on.
+		membership.Decisive = !cf.HasFeature || (listed && !cf.Inverted)
+		rules.Decisive = !cf.HasFeature || (matched != nil && !cf.Inverted)
+		inversion.Decisive = cf.Inverted
+	}
+
+	expiry := ExplanationStep{Check: checkExpiry, Result: cf.Expired}
+	switch {
+	case expiresOn == nil:
+		expiry.Detail = "the feature doesn't expire"
+	case cf.Expired:
+		expiry.Detail = fmt.Sprintf(
+			"the feature expired at %s, before the evaluation at %s; expiry is reported to clients, but doesn't turn the feature off",
+			expiresOn.UTC().Format(time.RFC3339), t.UTC().Format(time.RFC3339),
+		)
+	default:
+		expiry.Detail = fmt.Sprintf(
+			"the feature expires at %s, after the evaluation at %s",
+			expiresOn.UTC().Format(time.RFC3339), t.UTC().Format(time.RFC3339),
+		)
+	}
+
+	rollout := ExplanationStep{
+		Check:  checkRollout,
+		Detail: "features have no percentage rollouts, so none was consulted",
+	}
+
+	return append(steps, killed, membership, rules, inversion, expiry, rollout)
+}
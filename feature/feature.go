@@ -1,31 +1,187 @@
 package feature
 
 import (
+	"fmt"
 	"net/http"
+	"net/url"
+	"sort"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/google/uuid"
+
+	"feature/pkg/render"
+	"feature/pkg/set"
 )
 
-// A feature toggle.
-type feature struct {
+// A Feature toggle.
+type Feature struct {
 	ID            uuid.UUID  `json:"id"`
 	DisplayName   *string    `json:"displayName,omitempty"`
 	TechnicalName string     `json:"technicalName"`
 	ExpiresOn     *time.Time `json:"expiresOn,omitempty"`
 	Description   *string    `json:"description,omitempty"`
 	Inverted      bool       `json:"inverted"`
-	CreatedAt     time.Time  `json:"createdAt"`
-	UpdatedAt     time.Time  `json:"updatedAt"`
-	CustomerIDs   []string   `json:"customerIds,omitempty"`
+	Killed        bool       `json:"killed"`
+	// KilledAt is when the kill switch was turned on, if it is.
+	KilledAt    *time.Time `json:"killedAt,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+	CustomerIDs []string   `json:"customerIds,omitempty"`
+	// Tags are free-form labels, sorted and without duplicates.
+	Tags []string `json:"tags,omitempty"`
+	// Owner is the team or person responsible for the feature.
+	Owner     *string     `json:"owner,omitempty"`
+	TicketURL *string     `json:"ticketUrl,omitempty"`
+	Kind      FeatureKind `json:"kind,omitempty"`
+	// Rules target customers by the attributes of their profile in the
+	// customer directory, on top of the listed customers.
+	Rules []TargetingRule `json:"rules,omitempty"`
+}
+
+// A TargetingRule targets the customers whose attribute in the customer
+// directory has one of the given values, like every customer on a given plan.
+type TargetingRule struct {
+	Attribute string   `json:"attribute" yaml:"attribute"`
+	Values    []string `json:"values" yaml:"values"`
 }
 
-func (f feature) validate() error {
-	var errs errFeatureInvalid
+// matches reports whether a customer with the given attributes is targeted.
+func (r TargetingRule) matches(attributes map[string]string) bool {
+	v, ok := attributes[r.Attribute]
+	if !ok {
+		return false
+	}
+	for _, rv := range r.Values {
+		if rv == v {
+			return true
+		}
+	}
+	return false
+}
 
-	if len(f.TechnicalName) < 5 {
-		errs = append(errs, "'technicalName' must be at least 5 characters long")
+// matchingRule returns the first of rules targeting a customer with the given
+// attributes, or nil if there is none.
+func matchingRule(rules []TargetingRule, attributes map[string]string) *TargetingRule {
+	for i := range rules {
+		if rules[i].matches(attributes) {
+			return &rules[i]
+		}
+	}
+	return nil
+}
+
+// FeatureKind tells what a feature is used for, and thus how long it is meant
+// to live.
+type FeatureKind string
+
+// Kinds of features. Features without a kind are not subject to any of the
+// kind-specific rules.
+const (
+	// KindRelease features hide work in progress until it is released, and
+	// must expire.
+	KindRelease FeatureKind = "release"
+	// KindExperiment features select customers for an experiment, and must
+	// expire.
+	KindExperiment FeatureKind = "experiment"
+	// KindOps features control operational aspects of the system.
+	KindOps FeatureKind = "ops"
+	// KindPermission features grant customers access to a product feature.
+	KindPermission FeatureKind = "permission"
+)
+
+func (k FeatureKind) valid() bool {
+	switch k {
+	case "", KindRelease, KindExperiment, KindOps, KindPermission:
+		return true
+	}
+	return false
+}
+
+// mustExpire reports whether features of the kind are short-lived.
+func (k FeatureKind) mustExpire() bool {
+	return k == KindRelease || k == KindExperiment
+}
+
+// normalize trims the metadata of f, unsets empty metadata, and sorts and
+// deduplicates its tags and the values of its rules.
+func (f *Feature) normalize() {
+	trim := func(s *string) *string {
+		if s == nil || strings.TrimSpace(*s) == "" {
+			return nil
+		}
+		return ptr(strings.TrimSpace(*s))
+	}
+	f.Owner, f.TicketURL = trim(f.Owner), trim(f.TicketURL)
+
+	var tags []string
+	for _, t := range f.Tags {
+		tags = append(tags, strings.TrimSpace(t))
+	}
+	f.Tags = sortedUnique(tags)
+
+	var rules []TargetingRule
+	for _, r := range f.Rules {
+		var values []string
+		for _, v := range r.Values {
+			// Empty attributes are dropped from profiles, so they can't match.
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+		rules = append(rules, TargetingRule{Attribute: strings.TrimSpace(r.Attribute), Values: sortedUnique(values)})
+	}
+	f.Rules = rules
+}
+
+// sortedUnique returns the sorted strings of ss, without duplicates.
+func sortedUnique(ss []string) []string {
+	sort.Strings(ss)
+	var res []string
+	for i, s := range ss {
+		if i == 0 || s != ss[i-1] {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+// validate checks f against the fixed rules and the given policy.
+func (f Feature) validate(p ValidationPolicy) error {
+	errs := errFeatureInvalid(p.validateName(f.TechnicalName))
+	errs = append(errs, p.validateRequired(f)...)
+
+	for _, t := range f.Tags {
+		if t == "" || strings.IndexFunc(t, unicode.IsSpace) != -1 {
+			errs = append(errs, fmt.Sprintf("'tags' must not be empty or contain whitespace, got %q", t))
+		}
+	}
+
+	if f.TicketURL != nil {
+		if u, err := url.Parse(*f.TicketURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, "'ticketUrl' must be an http or https URL")
+		}
+	}
+
+	attributes := make(set.Set[string], len(f.Rules))
+	for i, r := range f.Rules {
+		switch _, ok := attributes[r.Attribute]; {
+		case r.Attribute == "":
+			errs = append(errs, fmt.Sprintf("'rules' must name an attribute, missing in rule %d", i+1))
+		case ok:
+			errs = append(errs, fmt.Sprintf("'rules' must not target attribute %q more than once", r.Attribute))
+		}
+		attributes[r.Attribute] = struct{}{}
+		if len(r.Values) == 0 {
+			errs = append(errs, fmt.Sprintf("'rules' must give values, missing in rule %d", i+1))
+		}
+	}
+
+	if !f.Kind.valid() {
+		errs = append(errs, fmt.Sprintf("'kind' must be one of %s, %s, %s or %s", KindRelease, KindExperiment, KindOps, KindPermission))
+	} else if f.Kind.mustExpire() && f.ExpiresOn == nil {
+		errs = append(errs, fmt.Sprintf("'expiresOn' must be set for %s features", f.Kind))
 	}
 
 	if len(errs) != 0 {
@@ -43,3 +199,24 @@ func (e errFeatureInvalid) Error() string {
 func (e errFeatureInvalid) Code() int {
 	return http.StatusBadRequest
 }
+
+func (e errFeatureInvalid) ErrorCode() string {
+	return "validation-failed"
+}
+
+// FieldErrors lists the violations, along with the field named at the start of
+// their message, like 'technicalName'.
+func (e errFeatureInvalid) FieldErrors() []render.FieldError {
+	res := make([]render.FieldError, len(e))
+	for i, msg := range e {
+		res[i].Detail = msg
+		if strings.HasPrefix(msg, "'") {
+			if end := strings.Index(msg[1:], "'"); end != -1 {
+				res[i].Field = msg[1 : end+1]
+			}
+		}
+	}
+	return res
+}
+
+func ptr[T any](t T) *T { return &t }
@@ -0,0 +1,207 @@
+package feature
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FeatureSort is an order in which features can be listed.
+type FeatureSort string
+
+// Orders in which features can be listed. Ties are broken by ID.
+const (
+	SortByName    FeatureSort = "name"
+	SortByCreated FeatureSort = "created"
+	SortByUpdated FeatureSort = "updated"
+	// SortByExpiry lists features without expiry last, in either direction.
+	SortByExpiry FeatureSort = "expiry"
+)
+
+func (s FeatureSort) valid() bool {
+	switch s {
+	case SortByName, SortByCreated, SortByUpdated, SortByExpiry:
+		return true
+	}
+	return false
+}
+
+// FeatureQuery selects a page of features. Zero valued filters match every
+// feature.
+type FeatureQuery struct {
+	// Search matches features containing it in their technical name, display
+	// name or description, ignoring case.
+	Search     string
+	Expired    *bool
+	Inverted   *bool
+	CustomerID string
+	// Tags matches features having all of them.
+	Tags []string
+	// Owner matches features of this owner, ignoring case.
+	Owner string
+	Kind  FeatureKind
+
+	Sort       FeatureSort
+	Descending bool
+
+	// After is the cursor of the page to return, nil for the first page.
+	After *FeatureCursor
+	Limit int
+}
+
+// FeaturePage is a page of features matching a FeatureQuery.
+type FeaturePage struct {
+	Features []Feature
+	// Total is the number of features matching the query across all pages.
+	Total int
+	// Next is the cursor of the next page, nil on the last page.
+	Next *FeatureCursor
+}
+
+// FeatureCursor marks the position of a feature in a listing, pages start
+// right after it. It is only valid for the sort it was created for.
+type FeatureCursor struct {
+	Sort       FeatureSort `json:"s"`
+	Descending bool        `json:"d,omitempty"`
+	// Name is the technical name, when sorting by name.
+	Name string `json:"n,omitempty"`
+	// Time is the time sorted by when sorting by time, nil for features without
+	// expiry.
+	Time *time.Time `json:"t,omitempty"`
+	ID   uuid.UUID  `json:"i"`
+}
+
+func cursorOf(q FeatureQuery, f Feature) *FeatureCursor {
+	c := &FeatureCursor{Sort: q.Sort, Descending: q.Descending, ID: f.ID}
+	switch q.Sort {
+	case SortByName:
+		c.Name = f.TechnicalName
+	case SortByCreated:
+		c.Time = ptr(f.CreatedAt)
+	case SortByUpdated:
+		c.Time = ptr(f.UpdatedAt)
+	case SortByExpiry:
+		c.Time = f.ExpiresOn
+	}
+	return c
+}
+
+// feature returns a feature positioned where the cursor is.
+func (c FeatureCursor) feature() Feature {
+	f := Feature{ID: c.ID, TechnicalName: c.Name}
+	if c.Time != nil {
+		f.CreatedAt, f.UpdatedAt = *c.Time, *c.Time
+	}
+	f.ExpiresOn = c.Time
+	return f
+}
+
+// String encodes the cursor for use in URLs.
+func (c FeatureCursor) String() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// ParseFeatureCursor decodes a cursor encoded by FeatureCursor.String.
+func ParseFeatureCursor(s string) (*FeatureCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("decode cursor: %w", err)
+	}
+
+	var c FeatureCursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("decode cursor: %w", err)
+	}
+	if !c.Sort.valid() {
+		return nil, fmt.Errorf("unknown cursor sort %q", c.Sort)
+	}
+	return &c, nil
+}
+
+// matches reports whether f passes the filters of q, at time t.
+func (q FeatureQuery) matches(f Feature, customerIDs []string, t time.Time) bool {
+	if q.Search != "" {
+		search := strings.ToLower(q.Search)
+		contains := func(s *string) bool { return s != nil && strings.Contains(strings.ToLower(*s), search) }
+		if !contains(&f.TechnicalName) && !contains(f.DisplayName) && !contains(f.Description) {
+			return false
+		}
+	}
+	if q.Expired != nil && *q.Expired != (f.ExpiresOn != nil && f.ExpiresOn.Before(t)) {
+		return false
+	}
+	if q.Inverted != nil && *q.Inverted != f.Inverted {
+		return false
+	}
+	if q.CustomerID != "" {
+		found := false
+		for _, id := range customerIDs {
+			found = found || id == q.CustomerID
+		}
+		if !found {
+			return false
+		}
+	}
+	for _, tag := range q.Tags {
+		found := false
+		for _, t := range f.Tags {
+			found = found || t == tag
+		}
+		if !found {
+			return false
+		}
+	}
+	if q.Owner != "" && (f.Owner == nil || !strings.EqualFold(*f.Owner, q.Owner)) {
+		return false
+	}
+	if q.Kind != "" && q.Kind != f.Kind {
+		return false
+	}
+	return true
+}
+
+// compare orders a and b the way q lists them, returning a negative number if a
+// comes first.
+func (q FeatureQuery) compare(a, b Feature) int {
+	var res int
+	switch q.Sort {
+	case SortByName:
+		res = strings.Compare(a.TechnicalName, b.TechnicalName)
+	case SortByCreated:
+		res = compareTimes(a.CreatedAt, b.CreatedAt)
+	case SortByUpdated:
+		res = compareTimes(a.UpdatedAt, b.UpdatedAt)
+	case SortByExpiry:
+		switch {
+		case a.ExpiresOn == nil && b.ExpiresOn == nil:
+		case a.ExpiresOn == nil:
+			return 1
+		case b.ExpiresOn == nil:
+			return -1
+		default:
+			res = compareTimes(*a.ExpiresOn, *b.ExpiresOn)
+		}
+	}
+	if res == 0 {
+		res = strings.Compare(a.ID.String(), b.ID.String())
+	}
+	if q.Descending {
+		return -res
+	}
+	return res
+}
+
+func compareTimes(a, b time.Time) int {
+	switch {
+	case a.Before(b):
+		return -1
+	case a.After(b):
+		return 1
+	}
+	return 0
+}
@@ -7,19 +7,25 @@ import (
 	"feature/pkg/sqlx"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
+	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
 	"github.com/google/uuid"
 )
 
-// NewStore initializes and returns a new Store.
+// NewStore initializes and returns a new Store. Both SQLite and PostgreSQL
+// databases are supported.
 func NewStore(db *sql.DB) Store {
-	return Store{db: db}
+	dialect := sqlx.DialectOf(db)
+	return Store{db: bind(db, dialect), dialect: dialect}
 }
 
 // Store provides query methods for feature data.
 type Store struct {
-	db db
+	db      db
+	dialect sqlx.Dialect
 }
 
 // db contains methods common to *sql.DB and *sql.Tx.
@@ -34,36 +40,144 @@ type db interface {
 	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
 }
 
+// bind returns db, wrapped so that the placeholders of queries are rebound if
+// the dialect requires it, and constraint violations are reported as
+// errConstraint.
+func bind(db db, dialect sqlx.Dialect) db {
+	return boundDB{db: db, dialect: dialect}
+}
+
+// boundDB rebinds the placeholders of queries before passing them on, and
+// translates the constraint violations reported by writes.
+type boundDB struct {
+	db      db
+	dialect sqlx.Dialect
+}
+
+func (r boundDB) Exec(query string, args ...any) (sql.Result, error) {
+	res, err := r.db.Exec(r.dialect.Rebind(query), args...)
+	return res, constraintErr(err)
+}
+
+func (r boundDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	res, err := r.db.ExecContext(ctx, r.dialect.Rebind(query), args...)
+	return res, constraintErr(err)
+}
+
+// constraintErr returns err as errConstraint if it reports a constraint
+// violation, or unchanged otherwise.
+func constraintErr(err error) error {
+	if ce := sqlx.AsConstraintError(err); ce != nil {
+		return errConstraint(ce.Error())
+	}
+	return err
+}
+
+func (r boundDB) Query(query string, args ...any) (*sql.Rows, error) {
+	return r.db.Query(r.dialect.Rebind(query), args...)
+}
+
+func (r boundDB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return r.db.QueryContext(ctx, r.dialect.Rebind(query), args...)
+}
+
+func (r boundDB) QueryRow(query string, args ...any) *sql.Row {
+	return r.db.QueryRow(r.dialect.Rebind(query), args...)
+}
+
+func (r boundDB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return r.db.QueryRowContext(ctx, r.dialect.Rebind(query), args...)
+}
+
+func (r boundDB) Prepare(query string) (*sql.Stmt, error) {
+	return r.db.Prepare(r.dialect.Rebind(query))
+}
+
+func (r boundDB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return r.db.PrepareContext(ctx, r.dialect.Rebind(query))
+}
+
+// Begin implements Repository. Units of work are transactions, nested ones are
+// savepoints.
+func (s Store) Begin(ctx context.Context, opts *sql.TxOptions) (UnitOfWork, error) {
+	tx, commit, rollback, err := s.beginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return storeUnitOfWork{Store: *tx, commit: commit, rollback: rollback}, nil
+}
+
+type storeUnitOfWork struct {
+	Store
+	commit   func() error
+	rollback func() error
+}
+
+func (u storeUnitOfWork) Commit() error {
+	return u.commit()
+}
+
+func (u storeUnitOfWork) Rollback() error {
+	return u.rollback()
+}
+
 func (s Store) beginTx(ctx context.Context, opts *sql.TxOptions) (*Store, func() error, func() error, error) {
-	switch v := s.db.(type) {
+	conn := s.db
+	if r, ok := conn.(boundDB); ok {
+		conn = r.db
+	}
+
+	switch v := conn.(type) {
 	case *sql.DB:
 		tx, err := v.BeginTx(ctx, opts)
 		if err != nil {
 			return nil, nil, nil, fmt.Errorf("begin transaction: %w", err)
 		}
-		return &Store{db: tx}, tx.Commit, tx.Rollback, nil
+		return &Store{db: bind(tx, s.dialect), dialect: s.dialect}, tx.Commit, tx.Rollback, nil
 	case *sql.Tx:
-		// Transaction already in progress, return self. We return a noop Commit and
-		// Rollback func in order to allow the first call to `beginTx` to control the
-		// transaction.
-		noop := func() error { return nil }
-		return &s, noop, noop, nil
+		// Transaction already in progress, return self. The first call to `beginTx`
+		// controls the transaction, nested ones only control a savepoint. That way a
+		// nested rollback undoes just the nested work, and on PostgreSQL, a failed
+		// statement doesn't leave the whole transaction aborted.
+		if _, err := v.ExecContext(ctx, "SAVEPOINT nested_tx"); err != nil {
+			return nil, nil, nil, fmt.Errorf("create savepoint: %w", err)
+		}
+		var done bool
+		commit := func() error {
+			if done {
+				return nil
+			}
+			done = true
+			_, err := v.ExecContext(ctx, "RELEASE SAVEPOINT nested_tx")
+			return err
+		}
+		rollback := func() error {
+			if done {
+				return nil
+			}
+			done = true
+			_, err := v.ExecContext(ctx, "ROLLBACK TO SAVEPOINT nested_tx")
+			return err
+		}
+		return &s, commit, rollback, nil
 	default:
 		return nil, nil, nil, fmt.Errorf("unexpected db type: %T", v)
 	}
 }
 
-func (s Store) findAllFeatures(ctx context.Context) ([]feature, error) {
+func (s Store) FindAllFeatures(ctx context.Context) ([]Feature, error) {
+	defer observeQuery("findAllFeatures")()
+
 	rs, err := s.db.QueryContext(
 		ctx,
 		//language=sqlite
-		`SELECT id,display_name,technical_name,expires_on,description,inverted,created_at,updated_at FROM features`,
+		`SELECT id,display_name,technical_name,expires_on,description,inverted,killed,killed_at,created_at,updated_at,tags,owner,ticket_url,kind,rules FROM features`,
 	)
 	if err != nil {
 		return nil, err
 	}
 
-	var fs []feature
+	var fs []Feature
 	for rs.Next() {
 		var fr featureRow
 		if err := rs.Scan(
@@ -73,8 +187,15 @@ func (s Store) findAllFeatures(ctx context.Context) ([]feature, error) {
 			&fr.ExpiresOn,
 			&fr.Description,
 			&fr.Inverted,
+			&fr.Killed,
+			&fr.KilledAt,
 			&fr.CreatedAt,
 			&fr.UpdatedAt,
+			&fr.Tags,
+			&fr.Owner,
+			&fr.TicketURL,
+			&fr.Kind,
+			&fr.Rules,
 		); err != nil {
 			return nil, err
 		}
@@ -92,11 +213,279 @@ func (s Store) findAllFeatures(ctx context.Context) ([]feature, error) {
 	return fs, nil
 }
 
-func (s Store) findFeature(ctx context.Context, id uuid.UUID) (*feature, error) {
+// FindAllFeaturesWithCustomers returns all features, along with the IDs of
+// their customers.
+func (s Store) FindAllFeaturesWithCustomers(ctx context.Context) ([]Feature, error) {
+	defer observeQuery("findAllFeaturesWithCustomers")()
+
+	rs, err := s.db.QueryContext(
+		ctx,
+		//language=sqlite
+		`
+		SELECT
+			f.id,
+			f.display_name,
+			f.technical_name,
+			f.expires_on,
+			f.description,
+			f.inverted,
+			f.killed,
+			f.killed_at,
+			f.created_at,
+			f.updated_at,
+			f.tags,
+			f.owner,
+			f.ticket_url,
+			f.kind,
+			f.rules,
+			(SELECT `+s.dialect.JSONArrayAgg("cf.customer_id")+` FROM customer_features cf WHERE cf.feature_id = f.id) AS customer_ids
+		FROM features f`,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var fs []Feature
+	for rs.Next() {
+		var fr featureRow
+		if err := rs.Scan(
+			&fr.ID,
+			&fr.DisplayName,
+			&fr.TechnicalName,
+			&fr.ExpiresOn,
+			&fr.Description,
+			&fr.Inverted,
+			&fr.Killed,
+			&fr.KilledAt,
+			&fr.CreatedAt,
+			&fr.UpdatedAt,
+			&fr.Tags,
+			&fr.Owner,
+			&fr.TicketURL,
+			&fr.Kind,
+			&fr.Rules,
+			&fr.CustomerIDs,
+		); err != nil {
+			return nil, err
+		}
+		fs = append(fs, fr.toFeature())
+	}
+
+	if err := rs.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := rs.Close(); err != nil {
+		return nil, err
+	}
+
+	return fs, nil
+}
+
+// FindFeatures returns the page of features selected by q, with expiry judged
+// at time t.
+func (s Store) FindFeatures(ctx context.Context, q FeatureQuery, t time.Time) (*FeaturePage, error) {
+	defer observeQuery("findFeatures")()
+
+	filtered := goqu.Dialect(s.dialect.Goqu()).
+		From(goqu.T("features").As("f")).
+		Where(featureFilters(s.dialect, q, t)...).
+		Prepared(true)
+
+	query, args, err := filtered.Select(goqu.COUNT(goqu.Star())).ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("bad count query: %w", err)
+	}
+
+	var res FeaturePage
+	if err := s.db.QueryRowContext(ctx, query, args...).Scan(&res.Total); err != nil {
+		return nil, err
+	}
+
+	page := filtered.
+		Select(
+			goqu.I("f.id"),
+			goqu.I("f.display_name"),
+			goqu.I("f.technical_name"),
+			goqu.I("f.expires_on"),
+			goqu.I("f.description"),
+			goqu.I("f.inverted"),
+			goqu.I("f.killed"),
+			goqu.I("f.killed_at"),
+			goqu.I("f.created_at"),
+			goqu.I("f.updated_at"),
+			goqu.I("f.tags"),
+			goqu.I("f.owner"),
+			goqu.I("f.ticket_url"),
+			goqu.I("f.kind"),
+			goqu.I("f.rules"),
+		).
+		Order(featureOrder(q)...).
+		// One more than requested, to know whether there is a next page.
+		Limit(uint(q.Limit + 1))
+	if q.After != nil {
+		page = page.Where(featureCursorFilter(q, *q.After))
+	}
+
+	query, args, err = page.ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("bad query: %w", err)
+	}
+
+	rs, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	for rs.Next() {
+		var fr featureRow
+		if err := rs.Scan(
+			&fr.ID,
+			&fr.DisplayName,
+			&fr.TechnicalName,
+			&fr.ExpiresOn,
+			&fr.Description,
+			&fr.Inverted,
+			&fr.Killed,
+			&fr.KilledAt,
+			&fr.CreatedAt,
+			&fr.UpdatedAt,
+			&fr.Tags,
+			&fr.Owner,
+			&fr.TicketURL,
+			&fr.Kind,
+			&fr.Rules,
+		); err != nil {
+			return nil, err
+		}
+		res.Features = append(res.Features, fr.toFeature())
+	}
+
+	if err := rs.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := rs.Close(); err != nil {
+		return nil, err
+	}
+
+	if q.Limit < len(res.Features) {
+		res.Features = res.Features[:q.Limit]
+		res.Next = cursorOf(q, res.Features[q.Limit-1])
+	}
+	return &res, nil
+}
+
+// likeEscaper escapes the wildcards of LIKE patterns, along with the escape
+// character itself, so that they match text as is. Patterns must be declared
+// with ESCAPE '!', which unlike a backslash needs no escaping in either
+// dialect.
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+func featureFilters(dialect sqlx.Dialect, q FeatureQuery, t time.Time) []goqu.Expression {
+	var res []goqu.Expression
+	if q.Search != "" {
+		// Escape LIKE wildcards, so that the search is a plain substring match.
+		pattern := "%" + likeEscaper.Replace(strings.ToLower(q.Search)) + "%"
+		res = append(res, goqu.Or(
+			goqu.L("LOWER(f.technical_name) LIKE ? ESCAPE '!'", pattern),
+			goqu.L("LOWER(f.display_name) LIKE ? ESCAPE '!'", pattern),
+			goqu.L("LOWER(f.description) LIKE ? ESCAPE '!'", pattern),
+		))
+	}
+	if q.Expired != nil {
+		expired := goqu.And(goqu.I("f.expires_on").IsNotNull(), goqu.I("f.expires_on").Lt(t.UTC()))
+		if *q.Expired {
+			res = append(res, expired)
+		} else {
+			res = append(res, goqu.Or(goqu.I("f.expires_on").IsNull(), goqu.I("f.expires_on").Gte(t.UTC())))
+		}
+	}
+	if q.Inverted != nil {
+		res = append(res, goqu.I("f.inverted").Eq(*q.Inverted))
+	}
+	if q.CustomerID != "" {
+		res = append(res, goqu.L(
+			"EXISTS (SELECT 1 FROM customer_features cf WHERE cf.feature_id = f.id AND cf.customer_id = ?)",
+			q.CustomerID,
+		))
+	}
+	for _, tag := range q.Tags {
+		res = append(res, goqu.L(dialect.JSONArrayContains("f.tags"), tag))
+	}
+	if q.Owner != "" {
+		res = append(res, goqu.L("LOWER(f.owner) = ?", strings.ToLower(q.Owner)))
+	}
+	if q.Kind != "" {
+		res = append(res, goqu.I("f.kind").Eq(string(q.Kind)))
+	}
+	return res
+}
+
+// featureSortColumn returns the column features are sorted by, besides id.
+func featureSortColumn(sort FeatureSort) exp.IdentifierExpression {
+	switch sort {
+	case SortByCreated:
+		return goqu.I("f.created_at")
+	case SortByUpdated:
+		return goqu.I("f.updated_at")
+	case SortByExpiry:
+		return goqu.I("f.expires_on")
+	default:
+		return goqu.I("f.technical_name")
+	}
+}
+
+func featureOrder(q FeatureQuery) []exp.OrderedExpression {
+	col, id := featureSortColumn(q.Sort), goqu.I("f.id")
+
+	var res []exp.OrderedExpression
+	if q.Sort == SortByExpiry {
+		// Features without expiry come last, regardless of the direction.
+		res = append(res, goqu.L("f.expires_on IS NULL").Asc())
+	}
+	if q.Descending {
+		return append(res, col.Desc(), id.Desc())
+	}
+	return append(res, col.Asc(), id.Asc())
+}
+
+// featureCursorFilter selects the features listed after the cursor c.
+func featureCursorFilter(q FeatureQuery, c FeatureCursor) exp.Expression {
+	col, id := featureSortColumn(q.Sort), goqu.I("f.id")
+
+	after := func(e exp.Comparable, v any) exp.Expression {
+		if q.Descending {
+			return e.Lt(v)
+		}
+		return e.Gt(v)
+	}
+
+	var v any
+	switch {
+	case q.Sort == SortByName:
+		v = c.Name
+	case c.Time != nil:
+		v = c.Time.UTC()
+	default:
+		// Only features without expiry remain, ordered by id.
+		return goqu.And(col.IsNull(), after(id, c.ID))
+	}
+
+	res := goqu.Or(after(col, v), goqu.And(col.Eq(v), after(id, c.ID)))
+	if q.Sort == SortByExpiry {
+		res = res.Append(col.IsNull())
+	}
+	return res
+}
+
+func (s Store) FindFeature(ctx context.Context, id uuid.UUID) (*Feature, error) {
+	defer observeQuery("findFeature")()
+
 	r := s.db.QueryRowContext(
 		ctx,
 		//language=sqlite
-		`SELECT display_name,technical_name,expires_on,description,inverted,created_at,updated_at FROM features WHERE id=?`,
+		`SELECT display_name,technical_name,expires_on,description,inverted,killed,killed_at,created_at,updated_at,tags,owner,ticket_url,kind,rules FROM features WHERE id=?`,
 		id,
 	)
 
@@ -107,8 +496,15 @@ func (s Store) findFeature(ctx context.Context, id uuid.UUID) (*feature, error)
 		&fr.ExpiresOn,
 		&fr.Description,
 		&fr.Inverted,
+		&fr.Killed,
+		&fr.KilledAt,
 		&fr.CreatedAt,
 		&fr.UpdatedAt,
+		&fr.Tags,
+		&fr.Owner,
+		&fr.TicketURL,
+		&fr.Kind,
+		&fr.Rules,
 	); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, errFeatureNotFound{id: id}
@@ -120,7 +516,9 @@ func (s Store) findFeature(ctx context.Context, id uuid.UUID) (*feature, error)
 	return &f, nil
 }
 
-func (s Store) findFeatureWithClients(ctx context.Context, id uuid.UUID) (*feature, error) {
+func (s Store) FindFeatureWithCustomers(ctx context.Context, id uuid.UUID) (*Feature, error) {
+	defer observeQuery("findFeatureWithClients")()
+
 	r := s.db.QueryRowContext(
 		ctx,
 		//language=sqlite
@@ -131,12 +529,17 @@ func (s Store) findFeatureWithClients(ctx context.Context, id uuid.UUID) (*featu
 			f.expires_on,
 			f.description,
 			f.inverted,
+			f.killed,
+			f.killed_at,
 			f.created_at,
 			f.updated_at,
-			CASE WHEN cf.customer_id IS NOT NULL THEN json_group_array(cf.customer_id)
-		END AS 'customer_ids'
+			f.tags,
+			f.owner,
+			f.ticket_url,
+			f.kind,
+			f.rules,
+			(SELECT `+s.dialect.JSONArrayAgg("cf.customer_id")+` FROM customer_features cf WHERE cf.feature_id = f.id) AS customer_ids
 		FROM features f
-		LEFT JOIN customer_features cf ON f.id = cf.feature_id
 		WHERE f.id=?`,
 		id,
 	)
@@ -148,14 +551,20 @@ func (s Store) findFeatureWithClients(ctx context.Context, id uuid.UUID) (*featu
 		&fr.ExpiresOn,
 		&fr.Description,
 		&fr.Inverted,
+		&fr.Killed,
+		&fr.KilledAt,
 		&fr.CreatedAt,
 		&fr.UpdatedAt,
+		&fr.Tags,
+		&fr.Owner,
+		&fr.TicketURL,
+		&fr.Kind,
+		&fr.Rules,
 		&fr.CustomerIDs,
 	); err != nil {
-		return nil, err
-	}
-
-	if err := r.Err(); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errFeatureNotFound{id: id}
+		}
 		return nil, err
 	}
 
@@ -163,24 +572,48 @@ func (s Store) findFeatureWithClients(ctx context.Context, id uuid.UUID) (*featu
 	return &f, nil
 }
 
-func (s Store) saveFeature(ctx context.Context, f feature) error {
+// CountFeatures returns the total number of features, as well as the number
+// of features that have expired at time t.
+func (s Store) CountFeatures(ctx context.Context, t time.Time) (total, expired int, err error) {
+	defer observeQuery("countFeatures")()
+
+	r := s.db.QueryRowContext(
+		ctx,
+		//language=sqlite
+		`SELECT COUNT(*), COUNT(CASE WHEN expires_on IS NOT NULL AND expires_on < ? THEN 1 END) FROM features`,
+		t.UTC(),
+	)
+	if err := r.Scan(&total, &expired); err != nil {
+		return 0, 0, err
+	}
+	return total, expired, nil
+}
+
+func (s Store) SaveFeature(ctx context.Context, f Feature) error {
+	defer observeQuery("saveFeature")()
+
 	r := featureToRow(f)
 	_, err := s.db.ExecContext(
 		ctx,
 		//language=sqlite
-		`INSERT INTO features (id,display_name,technical_name,expires_on,description,inverted,created_at,updated_at) VALUES (?,?,?,?,?,?,?,?)`,
-		r.ID, r.DisplayName, r.TechnicalName, r.ExpiresOn, r.Description, r.Inverted, r.CreatedAt, r.UpdatedAt,
+		`INSERT INTO features (id,display_name,technical_name,expires_on,description,inverted,killed,killed_at,created_at,updated_at,tags,owner,ticket_url,kind,rules) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`,
+		r.ID, r.DisplayName, r.TechnicalName, r.ExpiresOn, r.Description, r.Inverted, r.Killed, r.KilledAt, r.CreatedAt, r.UpdatedAt, r.Tags, r.Owner, r.TicketURL, r.Kind, r.Rules,
 	)
-	return err
+	if err != nil {
+		return err
+	}
+	return s.saveSearchDocument(ctx, r, false)
 }
 
-func (s Store) updateFeature(ctx context.Context, lastUpdatedAt time.Time, f feature) error {
+func (s Store) UpdateFeature(ctx context.Context, lastUpdatedAt time.Time, f Feature) error {
+	defer observeQuery("updateFeature")()
+
 	r := featureToRow(f)
 	res, err := s.db.ExecContext(
 		ctx,
 		//language=sqlite
-		`UPDATE features SET display_name=?, technical_name=?, expires_on=?, description=?, inverted=?, updated_at=? WHERE id=? AND unixepoch(updated_at)=?`,
-		r.DisplayName, r.TechnicalName, r.ExpiresOn, r.Description, r.Inverted, r.UpdatedAt, r.ID, lastUpdatedAt.Unix(),
+		`UPDATE features SET display_name=?, technical_name=?, expires_on=?, description=?, inverted=?, updated_at=?, tags=?, owner=?, ticket_url=?, kind=?, rules=? WHERE id=? AND `+s.dialect.UnixEpoch("updated_at")+`=?`,
+		r.DisplayName, r.TechnicalName, r.ExpiresOn, r.Description, r.Inverted, r.UpdatedAt, r.Tags, r.Owner, r.TicketURL, r.Kind, r.Rules, r.ID, lastUpdatedAt.Unix(),
 	)
 	if err != nil {
 		return err
@@ -196,6 +629,34 @@ func (s Store) updateFeature(ctx context.Context, lastUpdatedAt time.Time, f fea
 		// row not existing, or due to updating based on stale data.
 		return errFeatureNotFound{id: f.ID}
 	}
+	return s.updateSearchDocument(ctx, r)
+}
+
+// SetFeatureKilled toggles the kill switch of a feature. Killing a feature
+// records t as the time it was killed, unless it already is. The feature's
+// updated_at is deliberately left untouched, so that an emergency kill does not
+// invalidate edits that are in progress.
+func (s Store) SetFeatureKilled(ctx context.Context, id uuid.UUID, killed bool, t time.Time) error {
+	defer observeQuery("setFeatureKilled")()
+
+	res, err := s.db.ExecContext(
+		ctx,
+		//language=sqlite
+		`UPDATE features SET killed=?, killed_at=CASE WHEN ? THEN COALESCE(killed_at, ?) END WHERE id=?`,
+		killed, killed, t.UTC(), id,
+	)
+	if err != nil {
+		return err
+	}
+
+	rs, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rs == 0 {
+		return errFeatureNotFound{id: id}
+	}
 	return nil
 }
 
@@ -211,23 +672,48 @@ func (e errFeatureNotFound) Code() int {
 	return http.StatusNotFound
 }
 
-func (s Store) deleteFeature(ctx context.Context, featureID uuid.UUID) error {
+func (e errFeatureNotFound) ErrorCode() string {
+	return "feature-not-found"
+}
+
+func (s Store) DeleteFeature(ctx context.Context, featureID uuid.UUID) error {
+	defer observeQuery("deleteFeature")()
+
+	// Aliases are deleted explicitly, as SQLite only cascades deletes with
+	// foreign keys enabled. Otherwise, former names of the feature could never
+	// be used again.
 	_, err := s.db.ExecContext(
+		ctx,
+		//language=sqlite
+		`DELETE FROM feature_aliases WHERE feature_id=?`,
+		featureID,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(
 		ctx,
 		//language=sqlite
 		`DELETE FROM features WHERE id=?`,
 		featureID,
 	)
-	return err
+	if err != nil {
+		return err
+	}
+	return s.deleteSearchDocument(ctx, featureID)
 }
 
-func featureToRow(f feature) featureRow {
+func featureToRow(f Feature) featureRow {
 	r := featureRow{
 		ID:            f.ID,
 		TechnicalName: f.TechnicalName,
 		Inverted:      f.Inverted,
+		Killed:        f.Killed,
 		CreatedAt:     f.CreatedAt.UTC(),
 		UpdatedAt:     f.UpdatedAt.UTC(),
+		Tags:          f.Tags,
+		Rules:         f.Rules,
 	}
 	if f.DisplayName != nil {
 		r.DisplayName = sql.NullString{String: *f.DisplayName, Valid: true}
@@ -235,9 +721,21 @@ func featureToRow(f feature) featureRow {
 	if f.ExpiresOn != nil {
 		r.ExpiresOn = sql.NullTime{Time: f.ExpiresOn.UTC(), Valid: true}
 	}
+	if f.KilledAt != nil {
+		r.KilledAt = sql.NullTime{Time: f.KilledAt.UTC(), Valid: true}
+	}
 	if f.Description != nil {
 		r.Description = sql.NullString{String: *f.Description, Valid: true}
 	}
+	if f.Owner != nil {
+		r.Owner = sql.NullString{String: *f.Owner, Valid: true}
+	}
+	if f.TicketURL != nil {
+		r.TicketURL = sql.NullString{String: *f.TicketURL, Valid: true}
+	}
+	if f.Kind != "" {
+		r.Kind = sql.NullString{String: string(f.Kind), Valid: true}
+	}
 	return r
 }
 
@@ -248,24 +746,37 @@ type featureRow struct {
 	ExpiresOn     sql.NullTime
 	Description   sql.NullString
 	Inverted      bool
+	Killed        bool
+	KilledAt      sql.NullTime
 	CreatedAt     time.Time
 	UpdatedAt     time.Time
 	CustomerIDs   sqlx.JSONArray[string]
+	Tags          sqlx.JSONArray[string]
+	Owner         sql.NullString
+	TicketURL     sql.NullString
+	Kind          sql.NullString
+	Rules         sqlx.JSONArray[TargetingRule]
 }
 
-func (r featureRow) toFeature() feature {
-	f := feature{
+func (r featureRow) toFeature() Feature {
+	f := Feature{
 		ID:            r.ID,
 		TechnicalName: r.TechnicalName,
 		Inverted:      r.Inverted,
-		CreatedAt:     r.CreatedAt,
-		UpdatedAt:     r.UpdatedAt,
+		Killed:        r.Killed,
+		CreatedAt:     r.CreatedAt.UTC(),
+		UpdatedAt:     r.UpdatedAt.UTC(),
 	}
 	if r.DisplayName.Valid {
 		f.DisplayName = &r.DisplayName.String
 	}
 	if r.ExpiresOn.Valid {
-		f.ExpiresOn = &r.ExpiresOn.Time
+		f.ExpiresOn = new(time.Time)
+		*f.ExpiresOn = r.ExpiresOn.Time.UTC()
+	}
+	if r.KilledAt.Valid {
+		f.KilledAt = new(time.Time)
+		*f.KilledAt = r.KilledAt.Time.UTC()
 	}
 	if r.Description.Valid {
 		f.Description = &r.Description.String
@@ -273,5 +784,18 @@ func (r featureRow) toFeature() feature {
 	if 0 < len(r.CustomerIDs) {
 		f.CustomerIDs = r.CustomerIDs
 	}
+	if 0 < len(r.Tags) {
+		f.Tags = r.Tags
+	}
+	if r.Owner.Valid {
+		f.Owner = &r.Owner.String
+	}
+	if r.TicketURL.Valid {
+		f.TicketURL = &r.TicketURL.String
+	}
+	f.Kind = FeatureKind(r.Kind.String)
+	if 0 < len(r.Rules) {
+		f.Rules = r.Rules
+	}
 	return f
 }
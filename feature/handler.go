@@ -1,6 +1,7 @@
 package feature
 
 import (
+	"bytes"
 	"encoding/json"
 	"feature/pkg/slices"
 	"fmt"
@@ -8,6 +9,8 @@ import (
 	"github.com/google/uuid"
 	"github.com/rs/zerolog/hlog"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"feature/pkg/render"
@@ -23,50 +26,267 @@ type Handler struct {
 	service Service
 }
 
-// ListFeatures renders all features to the client.
+// ListFeatures renders a page of features to the client. Features are filtered
+// by the 'q', 'expired', 'inverted', 'customerId', 'owner' and 'kind' query
+// parameters, and by 'tag', which may be repeated to require several tags. They
+// are sorted by 'sort', one of name, created, updated and expiry, prefixed with
+// '-' for descending order. 'limit' sets the page size, and 'cursor' selects the
+// page following the one that returned it.
 func (h Handler) ListFeatures(w http.ResponseWriter, r *http.Request) {
-	fs, err := h.service.store.findAllFeatures(r.Context())
+	q, err := featureQueryFromRequest(r)
+	if err != nil {
+		render.Error(w, r, err)
+		return
+	}
+
+	// The page only depends on the configuration and the query, so clients
+	// having it already are answered without querying the store.
+	version, err := h.service.configurationVersion(r.Context())
+	if err != nil {
+		hlog.FromRequest(r).
+			Error().
+			Err(err).
+			Msg("failed to determine configuration version")
+		render.Error(w, r, err)
+		return
+	}
+	etag := render.ETag(version, r.URL.RawQuery)
+	if render.NotModified(w, r, etag) {
+		return
+	}
+
+	page, err := h.service.findFeatures(r.Context(), q)
 	if err != nil {
 		hlog.FromRequest(r).
 			Error().
 			Err(err).
-			Msg("failed to find all features")
-		render.Error(w, err)
+			Msg("failed to find features")
+		render.Error(w, r, err)
 		return
 	}
 
-	render.JSON(w, slices.Map(responseFromFeature, fs...))
+	res := featurePageResponse{
+		Features: slices.Map(responseFromFeature, page.Features...),
+		Total:    page.Total,
+	}
+	if page.Next != nil {
+		res.NextCursor = page.Next.String()
+	}
+	render.SetETag(w, etag)
+	render.JSON(w, res)
+}
+
+func featureQueryFromRequest(r *http.Request) (FeatureQuery, error) {
+	var (
+		v   = r.URL.Query()
+		res = FeatureQuery{
+			Search:     v.Get("q"),
+			CustomerID: v.Get("customerId"),
+			Tags:       v["tag"],
+			Owner:      v.Get("owner"),
+			Kind:       FeatureKind(v.Get("kind")),
+			Sort:       FeatureSort(strings.TrimPrefix(v.Get("sort"), "-")),
+			Descending: strings.HasPrefix(v.Get("sort"), "-"),
+		}
+		err error
+	)
+
+	parseBool := func(name string) (*bool, error) {
+		if v.Get(name) == "" {
+			return nil, nil
+		}
+		b, err := strconv.ParseBool(v.Get(name))
+		if err != nil {
+			return nil, render.NewBadRequest(fmt.Sprintf("parse %s: %s", name, err))
+		}
+		return &b, nil
+	}
+
+	if res.Expired, err = parseBool("expired"); err != nil {
+		return res, err
+	}
+	if res.Inverted, err = parseBool("inverted"); err != nil {
+		return res, err
+	}
+
+	if l := v.Get("limit"); l != "" {
+		if res.Limit, err = strconv.Atoi(l); err != nil {
+			return res, render.NewBadRequest(fmt.Sprintf("parse limit: %s", err))
+		}
+		if res.Limit == 0 {
+			return res, errBadFeaturePageSize
+		}
+	}
+
+	if c := v.Get("cursor"); c != "" {
+		if res.After, err = ParseFeatureCursor(c); err != nil {
+			return res, render.NewBadRequest(fmt.Sprintf("parse cursor: %s", err))
+		}
+	}
+
+	return res, nil
+}
+
+type featurePageResponse struct {
+	Features   []featureResponse `json:"features"`
+	Total      int               `json:"total"`
+	NextCursor string            `json:"nextCursor,omitempty"`
+}
+
+// SearchFeatures renders the features matching the 'q' query parameter, best
+// matches first. Archived features are included if 'archived' is true.
+func (h Handler) SearchFeatures(w http.ResponseWriter, r *http.Request) {
+	var (
+		v = r.URL.Query()
+		q = SearchQuery{Text: v.Get("q")}
+	)
+
+	if a := v.Get("archived"); a != "" {
+		var err error
+		if q.IncludeArchived, err = strconv.ParseBool(a); err != nil {
+			render.Error(w, r, render.NewBadRequest(fmt.Sprintf("parse archived: %s", err)))
+			return
+		}
+	}
+
+	if l := v.Get("limit"); l != "" {
+		var err error
+		if q.Limit, err = strconv.Atoi(l); err != nil {
+			render.Error(w, r, render.NewBadRequest(fmt.Sprintf("parse limit: %s", err)))
+			return
+		}
+		if q.Limit == 0 {
+			render.Error(w, r, errBadSearchLimit)
+			return
+		}
+	}
+
+	rs, err := h.service.searchFeatures(r.Context(), q)
+	if err != nil {
+		hlog.FromRequest(r).
+			Error().
+			Err(err).
+			Msg("failed to search features")
+		render.Error(w, r, err)
+		return
+	}
+
+	render.JSON(w, searchResultsResponse{
+		Results: slices.Map(func(sr SearchResult) searchResultResponse {
+			return searchResultResponse{
+				ID:            sr.ID,
+				TechnicalName: sr.TechnicalName,
+				DisplayName:   sr.DisplayName,
+				Archived:      sr.Archived,
+				Snippet:       sr.Snippet,
+			}
+		}, rs...),
+	})
+}
+
+type searchResultsResponse struct {
+	Results []searchResultResponse `json:"results"`
+}
+
+type searchResultResponse struct {
+	ID            uuid.UUID `json:"id"`
+	TechnicalName string    `json:"technicalName"`
+	DisplayName   *string   `json:"displayName,omitempty"`
+	Archived      bool      `json:"archived"`
+	Snippet       string    `json:"snippet"`
+}
+
+// defaultStaleDays is the number of days used by ListStaleFeatures, when the
+// client doesn't specify any.
+const defaultStaleDays = 30
+
+// ListStaleFeatures renders the features that are likely candidates for
+// removal. The 'days' query parameter controls how long a feature must go
+// unevaluated or unchanged to be reported.
+func (h Handler) ListStaleFeatures(w http.ResponseWriter, r *http.Request) {
+	days := defaultStaleDays
+	if v := r.URL.Query().Get("days"); v != "" {
+		var err error
+		if days, err = strconv.Atoi(v); err != nil {
+			render.Error(w, r, render.NewBadRequest(fmt.Sprintf("parse days: %s", err)))
+			return
+		}
+	}
+
+	sfs, err := h.service.findStaleFeatures(r.Context(), days)
+	if err != nil {
+		hlog.FromRequest(r).
+			Error().
+			Err(err).
+			Msg("failed to find stale features")
+		render.Error(w, r, err)
+		return
+	}
+
+	render.JSON(w, staleFeaturesResponse{
+		Features: slices.Map(responseFromStaleFeature, sfs...),
+	})
+}
+
+func responseFromStaleFeature(sf staleFeature) staleFeatureResponse {
+	res := staleFeatureResponse{
+		featureResponse: responseFromFeature(sf.Feature),
+		Reasons:         sf.Reasons,
+		EvaluationCount: sf.EvaluationCount,
+	}
+	if sf.LastEvaluatedAt != nil {
+		res.LastEvaluatedAt = new(int64)
+		*res.LastEvaluatedAt = sf.LastEvaluatedAt.UnixMilli()
+	}
+	return res
+}
+
+type staleFeaturesResponse struct {
+	Features []staleFeatureResponse `json:"features"`
+}
+
+type staleFeatureResponse struct {
+	featureResponse
+	Reasons         []string `json:"reasons"`
+	LastEvaluatedAt *int64   `json:"lastEvaluatedAt,omitempty"`
+	EvaluationCount int      `json:"evaluationCount"`
 }
 
 func (h Handler) GetFeature(w http.ResponseWriter, r *http.Request) {
 	id, err := uuid.Parse(chi.URLParam(r, "featureId"))
 	if err != nil {
-		render.Error(w, render.NewBadRequest(fmt.Sprintf("parse feature id: %s", err)))
+		render.Error(w, r, render.NewBadRequest(fmt.Sprintf("parse feature id: %s", err)))
 		return
 	}
 
-	f, err := h.service.store.findFeatureWithClients(r.Context(), id)
+	f, err := h.service.store.FindFeatureWithCustomers(r.Context(), id)
 	if err != nil {
 		hlog.FromRequest(r).
 			Error().
 			Err(err).
 			Msg("failed to find feature")
-		render.Error(w, err)
+		render.Error(w, r, err)
 		return
 	}
 
 	render.JSON(w, responseFromFeature(*f))
 }
 
-func responseFromFeature(f feature) featureResponse {
+func responseFromFeature(f Feature) featureResponse {
 	res := featureResponse{
 		ID:            f.ID,
 		DisplayName:   f.DisplayName,
 		TechnicalName: f.TechnicalName,
 		Description:   f.Description,
 		Inverted:      f.Inverted,
+		Killed:        f.Killed,
 		CreatedAt:     f.CreatedAt.UnixMilli(),
 		UpdatedAt:     f.UpdatedAt.UnixMilli(),
+		Tags:          f.Tags,
+		Owner:         f.Owner,
+		TicketURL:     f.TicketURL,
+		Kind:          f.Kind,
+		Rules:         f.Rules,
 	}
 	if f.ExpiresOn != nil {
 		res.ExpiresOn = new(int64)
@@ -79,33 +299,49 @@ func responseFromFeature(f feature) featureResponse {
 }
 
 type featureResponse struct {
-	ID            uuid.UUID `json:"id"`
-	DisplayName   *string   `json:"displayName,omitempty"`
-	TechnicalName string    `json:"technicalName"`
-	ExpiresOn     *int64    `json:"expiresOn,omitempty"`
-	Description   *string   `json:"description,omitempty"`
-	Inverted      bool      `json:"inverted"`
-	CreatedAt     int64     `json:"createdAt"`
-	UpdatedAt     int64     `json:"updatedAt"`
-	CustomerIDs   []string  `json:"customerIds,omitempty"`
+	ID            uuid.UUID       `json:"id"`
+	DisplayName   *string         `json:"displayName,omitempty"`
+	TechnicalName string          `json:"technicalName"`
+	ExpiresOn     *int64          `json:"expiresOn,omitempty"`
+	Description   *string         `json:"description,omitempty"`
+	Inverted      bool            `json:"inverted"`
+	Killed        bool            `json:"killed"`
+	CreatedAt     int64           `json:"createdAt"`
+	UpdatedAt     int64           `json:"updatedAt"`
+	CustomerIDs   []string        `json:"customerIds,omitempty"`
+	Tags          []string        `json:"tags,omitempty"`
+	Owner         *string         `json:"owner,omitempty"`
+	TicketURL     *string         `json:"ticketUrl,omitempty"`
+	Kind          FeatureKind     `json:"kind,omitempty"`
+	Rules         []TargetingRule `json:"rules,omitempty"`
 }
 
 type saveFeatureRequest struct {
-	DisplayName   *string  `json:"displayName"`
-	TechnicalName string   `json:"technicalName"`
-	ExpiresOn     *int64   `json:"expiresOn"`
-	Description   *string  `json:"description"`
-	Inverted      bool     `json:"inverted"`
-	CustomerIDs   []string `json:"customerIds"`
+	DisplayName   *string         `json:"displayName"`
+	TechnicalName string          `json:"technicalName"`
+	ExpiresOn     *int64          `json:"expiresOn"`
+	Description   *string         `json:"description"`
+	Inverted      bool            `json:"inverted"`
+	CustomerIDs   []string        `json:"customerIds"`
+	Tags          []string        `json:"tags"`
+	Owner         *string         `json:"owner"`
+	TicketURL     *string         `json:"ticketUrl"`
+	Kind          FeatureKind     `json:"kind"`
+	Rules         []TargetingRule `json:"rules"`
 }
 
-func (r saveFeatureRequest) toFeature() feature {
-	res := feature{
+func (r saveFeatureRequest) toFeature() Feature {
+	res := Feature{
 		DisplayName:   r.DisplayName,
 		TechnicalName: r.TechnicalName,
 		Description:   r.Description,
 		Inverted:      r.Inverted,
 		CustomerIDs:   r.CustomerIDs,
+		Tags:          r.Tags,
+		Owner:         r.Owner,
+		TicketURL:     r.TicketURL,
+		Kind:          r.Kind,
+		Rules:         r.Rules,
 	}
 	if r.ExpiresOn != nil {
 		res.ExpiresOn = new(time.Time)
@@ -121,7 +357,7 @@ func (h Handler) SaveFeature(w http.ResponseWriter, r *http.Request) {
 	dec := json.NewDecoder(r.Body)
 	dec.DisallowUnknownFields()
 	if err := dec.Decode(&req); err != nil {
-		render.Error(w, render.NewBadRequest(fmt.Sprintf("decode request body: %s", err)))
+		render.Error(w, r, render.TagBadRequest(fmt.Errorf("decode request body: %w", err)))
 		return
 	}
 
@@ -130,13 +366,39 @@ func (h Handler) SaveFeature(w http.ResponseWriter, r *http.Request) {
 			Error().
 			Err(err).
 			Msg("failed to save feature")
-		render.Error(w, err)
+		render.Error(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusCreated)
 }
 
+type validateFeatureResponse struct {
+	Valid  bool     `json:"valid"`
+	Errors []string `json:"errors"`
+}
+
+// ValidateFeature validates the feature received via JSON request body against
+// the validation policy, without saving it. Violations are reported in the
+// response rather than as an error, so that drafts can be checked while being
+// edited.
+func (h Handler) ValidateFeature(w http.ResponseWriter, r *http.Request) {
+	var req saveFeatureRequest
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		render.Error(w, r, render.TagBadRequest(fmt.Errorf("decode request body: %w", err)))
+		return
+	}
+
+	errs := h.service.validateFeature(req.toFeature())
+	render.JSON(w, validateFeatureResponse{
+		Valid:  len(errs) == 0,
+		Errors: errs,
+	})
+}
+
 type updateFeatureRequest struct {
 	LastUpdatedAt int64              `json:"lastUpdatedAt"`
 	Feature       saveFeatureRequest `json:"feature"`
@@ -146,7 +408,7 @@ type updateFeatureRequest struct {
 func (h Handler) UpdateFeature(w http.ResponseWriter, r *http.Request) {
 	id, err := uuid.Parse(chi.URLParam(r, "featureId"))
 	if err != nil {
-		render.Error(w, render.NewBadRequest(fmt.Sprintf("parse feature id: %s", err)))
+		render.Error(w, r, render.NewBadRequest(fmt.Sprintf("parse feature id: %s", err)))
 		return
 	}
 
@@ -155,7 +417,7 @@ func (h Handler) UpdateFeature(w http.ResponseWriter, r *http.Request) {
 	dec := json.NewDecoder(r.Body)
 	dec.DisallowUnknownFields()
 	if err := dec.Decode(&req); err != nil {
-		render.Error(w, render.NewBadRequest(fmt.Sprintf("decode request body: %s", err)))
+		render.Error(w, r, render.TagBadRequest(fmt.Errorf("decode request body: %w", err)))
 		return
 	}
 
@@ -166,7 +428,115 @@ func (h Handler) UpdateFeature(w http.ResponseWriter, r *http.Request) {
 			Error().
 			Err(err).
 			Msg("failed to update feature")
-		render.Error(w, err)
+		render.Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type aliasesResponse struct {
+	Aliases []aliasResponse `json:"aliases"`
+}
+
+type aliasResponse struct {
+	TechnicalName   string `json:"technicalName"`
+	CreatedAt       int64  `json:"createdAt"`
+	LastEvaluatedAt *int64 `json:"lastEvaluatedAt"`
+	EvaluationCount int    `json:"evaluationCount"`
+}
+
+// ListFeatureAliases renders the former technical names of a feature, along
+// with how much they are still evaluated.
+func (h Handler) ListFeatureAliases(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "featureId"))
+	if err != nil {
+		render.Error(w, r, render.NewBadRequest(fmt.Sprintf("parse feature id: %s", err)))
+		return
+	}
+
+	as, err := h.service.findAliases(r.Context(), id)
+	if err != nil {
+		hlog.FromRequest(r).
+			Error().
+			Err(err).
+			Msg("failed to find aliases")
+		render.Error(w, r, err)
+		return
+	}
+
+	render.JSON(w, aliasesResponse{
+		Aliases: slices.Map(func(au AliasUsage) aliasResponse {
+			res := aliasResponse{
+				TechnicalName:   au.TechnicalName,
+				CreatedAt:       au.CreatedAt.UnixMilli(),
+				EvaluationCount: au.EvaluationCount,
+			}
+			if au.LastEvaluatedAt != nil {
+				res.LastEvaluatedAt = ptr(au.LastEvaluatedAt.UnixMilli())
+			}
+			return res
+		}, as...),
+	})
+}
+
+// DeleteFeatureAlias removes a former technical name of a feature, once it is
+// no longer evaluated.
+func (h Handler) DeleteFeatureAlias(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "featureId"))
+	if err != nil {
+		render.Error(w, r, render.NewBadRequest(fmt.Sprintf("parse feature id: %s", err)))
+		return
+	}
+
+	if err := h.service.deleteAlias(r.Context(), id, chi.URLParam(r, "alias")); err != nil {
+		hlog.FromRequest(r).
+			Error().
+			Err(err).
+			Msg("failed to delete alias")
+		render.Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// KillFeature turns the feature off for every customer, regardless of its
+// targeting. Unlike UpdateFeature, no lastUpdatedAt is required, as a kill must
+// succeed even while the feature is being edited.
+func (h Handler) KillFeature(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "featureId"))
+	if err != nil {
+		render.Error(w, r, render.NewBadRequest(fmt.Sprintf("parse feature id: %s", err)))
+		return
+	}
+
+	if err := h.service.killFeature(r.Context(), id); err != nil {
+		hlog.FromRequest(r).
+			Error().
+			Err(err).
+			Msg("failed to kill feature")
+		render.Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UnkillFeature restores the evaluation of a killed feature.
+func (h Handler) UnkillFeature(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "featureId"))
+	if err != nil {
+		render.Error(w, r, render.NewBadRequest(fmt.Sprintf("parse feature id: %s", err)))
+		return
+	}
+
+	if err := h.service.unkillFeature(r.Context(), id); err != nil {
+		hlog.FromRequest(r).
+			Error().
+			Err(err).
+			Msg("failed to unkill feature")
+		render.Error(w, r, err)
 		return
 	}
 
@@ -184,7 +554,7 @@ func (h Handler) SaveArchivedFeature(w http.ResponseWriter, r *http.Request) {
 	dec := json.NewDecoder(r.Body)
 	dec.DisallowUnknownFields()
 	if err := dec.Decode(&req); err != nil {
-		render.Error(w, render.NewBadRequest(fmt.Sprintf("decode request body: %s", err)))
+		render.Error(w, r, render.TagBadRequest(fmt.Errorf("decode request body: %w", err)))
 		return
 	}
 
@@ -193,7 +563,7 @@ func (h Handler) SaveArchivedFeature(w http.ResponseWriter, r *http.Request) {
 			Error().
 			Err(err).
 			Msg("failed to archive feature")
-		render.Error(w, err)
+		render.Error(w, r, err)
 		return
 	}
 
@@ -204,12 +574,17 @@ type saveFeatureCustomersRequest struct {
 	CustomerIDs []string `json:"customerIds"`
 }
 
+type saveFeatureCustomersResponse struct {
+	Added []string `json:"added"`
+}
+
 // SaveFeatureCustomers persists the given customers to have access to the
-// feature.
+// feature, and renders the ones that didn't have access yet. Customers that
+// already have access are skipped, with 200 OK rendered if none are left.
 func (h Handler) SaveFeatureCustomers(w http.ResponseWriter, r *http.Request) {
 	featureID, err := uuid.Parse(chi.URLParam(r, "featureId"))
 	if err != nil {
-		render.Error(w, render.NewBadRequest(fmt.Sprintf("parse feature id: %s", err)))
+		render.Error(w, r, render.NewBadRequest(fmt.Sprintf("parse feature id: %s", err)))
 		return
 	}
 
@@ -218,20 +593,288 @@ func (h Handler) SaveFeatureCustomers(w http.ResponseWriter, r *http.Request) {
 	dec := json.NewDecoder(r.Body)
 	dec.DisallowUnknownFields()
 	if err := dec.Decode(&req); err != nil {
-		render.Error(w, render.NewBadRequest(fmt.Sprintf("decode request body: %s", err)))
+		render.Error(w, r, render.TagBadRequest(fmt.Errorf("decode request body: %w", err)))
 		return
 	}
 
-	if err := h.service.addCustomersToFeature(r.Context(), featureID, req.CustomerIDs); err != nil {
+	added, err := h.service.addCustomersToFeature(r.Context(), featureID, req.CustomerIDs)
+	if err != nil {
 		hlog.FromRequest(r).
 			Error().
 			Err(err).
 			Msg("failed to add customers to feature")
-		render.Error(w, err)
+		render.Error(w, r, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
+	if len(added) != 0 {
+		w.WriteHeader(http.StatusCreated)
+	}
+	render.JSON(w, saveFeatureCustomersResponse{Added: added})
+}
+
+type customerUploadResponse struct {
+	Added          int   `json:"added"`
+	AlreadyPresent int   `json:"alreadyPresent"`
+	Removed        int   `json:"removed"`
+	Invalid        int   `json:"invalid"`
+	InvalidLines   []int `json:"invalidLines"`
+}
+
+// UploadFeatureCustomers adds the customers listed in the request body to the
+// feature. The body is read as CSV if the Content-Type header says so, or as
+// one customer ID per line otherwise. With the 'mode' query parameter set to
+// replace, customers missing from the body are removed from the feature.
+func (h Handler) UploadFeatureCustomers(w http.ResponseWriter, r *http.Request) {
+	featureID, err := uuid.Parse(chi.URLParam(r, "featureId"))
+	if err != nil {
+		render.Error(w, r, render.NewBadRequest(fmt.Sprintf("parse feature id: %s", err)))
+		return
+	}
+
+	mode := UploadAppend
+	if v := r.URL.Query().Get("mode"); v != "" {
+		mode = CustomerUploadMode(v)
+	}
+
+	format := uploadFormatLines
+	if strings.Contains(r.Header.Get("Content-Type"), "csv") {
+		format = uploadFormatCSV
+	}
+
+	report, err := h.service.uploadCustomers(r.Context(), featureID, mode, format, r.Body)
+	if err != nil {
+		hlog.FromRequest(r).
+			Error().
+			Err(err).
+			Msg("failed to upload feature customers")
+		render.Error(w, r, err)
+		return
+	}
+
+	invalidLines := report.InvalidLines
+	if invalidLines == nil {
+		invalidLines = []int{}
+	}
+	render.JSON(w, customerUploadResponse{
+		Added:          report.Added,
+		AlreadyPresent: report.AlreadyPresent,
+		Removed:        report.Removed,
+		Invalid:        report.Invalid,
+		InvalidLines:   invalidLines,
+	})
+}
+
+type customersResponse struct {
+	Customers []customerResponse `json:"customers"`
+}
+
+type customerResponse struct {
+	ID          string            `json:"id"`
+	DisplayName *string           `json:"displayName"`
+	Attributes  map[string]string `json:"attributes"`
+	CreatedAt   int64             `json:"createdAt"`
+	UpdatedAt   int64             `json:"updatedAt"`
+}
+
+func responseFromCustomerProfile(p CustomerProfile) customerResponse {
+	return customerResponse{
+		ID:          p.ID,
+		DisplayName: p.DisplayName,
+		Attributes:  p.Attributes,
+		CreatedAt:   p.CreatedAt.UnixMilli(),
+		UpdatedAt:   p.UpdatedAt.UnixMilli(),
+	}
+}
+
+// ListCustomers renders the customers of the directory whose ID starts with
+// the 'q' query parameter, or whose display name contains it, for
+// autocompletion. At most 'limit' customers are rendered.
+func (h Handler) ListCustomers(w http.ResponseWriter, r *http.Request) {
+	var limit int
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			render.Error(w, r, render.NewBadRequest(fmt.Sprintf("parse limit: %s", err)))
+			return
+		}
+		limit = n
+	}
+
+	ps, err := h.service.findCustomerProfiles(r.Context(), r.URL.Query().Get("q"), limit)
+	if err != nil {
+		hlog.FromRequest(r).
+			Error().
+			Err(err).
+			Msg("failed to find customers")
+		render.Error(w, r, err)
+		return
+	}
+
+	render.JSON(w, customersResponse{
+		Customers: slices.Map(responseFromCustomerProfile, ps...),
+	})
+}
+
+// GetCustomer renders a customer of the directory.
+func (h Handler) GetCustomer(w http.ResponseWriter, r *http.Request) {
+	p, err := h.service.store.FindCustomerProfile(r.Context(), chi.URLParam(r, "customerId"))
+	if err != nil {
+		hlog.FromRequest(r).
+			Error().
+			Err(err).
+			Msg("failed to find customer")
+		render.Error(w, r, err)
+		return
+	}
+
+	render.JSON(w, responseFromCustomerProfile(*p))
+}
+
+type saveCustomerRequest struct {
+	DisplayName *string           `json:"displayName"`
+	Attributes  map[string]string `json:"attributes"`
+}
+
+// SaveCustomer creates a customer of the directory, or replaces its display
+// name and attributes.
+func (h Handler) SaveCustomer(w http.ResponseWriter, r *http.Request) {
+	var req saveCustomerRequest
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		render.Error(w, r, render.TagBadRequest(fmt.Errorf("decode request body: %w", err)))
+		return
+	}
+
+	p, err := h.service.saveCustomerProfile(r.Context(), CustomerProfile{
+		ID:          chi.URLParam(r, "customerId"),
+		DisplayName: req.DisplayName,
+		Attributes:  req.Attributes,
+	})
+	if err != nil {
+		hlog.FromRequest(r).
+			Error().
+			Err(err).
+			Msg("failed to save customer")
+		render.Error(w, r, err)
+		return
+	}
+
+	render.JSON(w, responseFromCustomerProfile(*p))
+}
+
+// DeleteCustomer removes a customer from the directory. The features of the
+// customer are left alone.
+func (h Handler) DeleteCustomer(w http.ResponseWriter, r *http.Request) {
+	if err := h.service.deleteCustomerProfile(r.Context(), chi.URLParam(r, "customerId")); err != nil {
+		hlog.FromRequest(r).
+			Error().
+			Err(err).
+			Msg("failed to delete customer")
+		render.Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type customerProfileUploadResponse struct {
+	Created      int   `json:"created"`
+	Updated      int   `json:"updated"`
+	Invalid      int   `json:"invalid"`
+	InvalidLines []int `json:"invalidLines"`
+}
+
+// UploadCustomers creates or replaces the customers of the directory listed in
+// the CSV request body. The header row names an 'id' column, an optional
+// 'displayName' column, and attribute columns.
+func (h Handler) UploadCustomers(w http.ResponseWriter, r *http.Request) {
+	report, err := h.service.uploadCustomerProfiles(r.Context(), r.Body)
+	if err != nil {
+		hlog.FromRequest(r).
+			Error().
+			Err(err).
+			Msg("failed to upload customers")
+		render.Error(w, r, err)
+		return
+	}
+
+	invalidLines := report.InvalidLines
+	if invalidLines == nil {
+		invalidLines = []int{}
+	}
+	render.JSON(w, customerProfileUploadResponse{
+		Created:      report.Created,
+		Updated:      report.Updated,
+		Invalid:      report.Invalid,
+		InvalidLines: invalidLines,
+	})
+}
+
+type renameCustomerRequest struct {
+	To    string `json:"to"`
+	Merge bool   `json:"merge"`
+}
+
+type renameCustomerResponse struct {
+	DryRun   bool                     `json:"dryRun"`
+	From     string                   `json:"from"`
+	To       string                   `json:"to"`
+	Features []renamedFeatureResponse `json:"features"`
+}
+
+type renamedFeatureResponse struct {
+	ID            uuid.UUID `json:"id"`
+	TechnicalName string    `json:"technicalName"`
+	Merged        bool      `json:"merged"`
+}
+
+// RenameCustomer replaces a customer ID with another one in every feature and
+// in the directory. With 'merge' set in the request body, the new ID may already
+// be in use. With the 'dryRun' query parameter set, the affected features are
+// only reported.
+func (h Handler) RenameCustomer(w http.ResponseWriter, r *http.Request) {
+	dryRun, err := parseBoolQuery(r, "dryRun")
+	if err != nil {
+		render.Error(w, r, err)
+		return
+	}
+
+	var req renameCustomerRequest
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		render.Error(w, r, render.TagBadRequest(fmt.Errorf("decode request body: %w", err)))
+		return
+	}
+
+	res, err := h.service.renameCustomer(r.Context(), chi.URLParam(r, "customerId"), req.To, req.Merge, dryRun)
+	if err != nil {
+		hlog.FromRequest(r).
+			Error().
+			Err(err).
+			Msg("failed to rename customer")
+		render.Error(w, r, err)
+		return
+	}
+
+	features := make([]renamedFeatureResponse, len(res.Features))
+	for i, f := range res.Features {
+		features[i] = renamedFeatureResponse{
+			ID:            f.ID,
+			TechnicalName: f.TechnicalName,
+			Merged:        f.Merged,
+		}
+	}
+	render.JSON(w, renameCustomerResponse{
+		DryRun:   res.DryRun,
+		From:     res.From,
+		To:       res.To,
+		Features: features,
+	})
 }
 
 type featureRequest struct {
@@ -251,13 +894,37 @@ func (r featureRequest) featureTechnicalNames() []string {
 	return res
 }
 
+// RequestFeaturesAsCustomer evaluates features for a customer. With the
+// 'explain' query parameter set, every evaluation is traced instead, including
+// features that don't exist, and isn't recorded as usage.
 func (h Handler) RequestFeaturesAsCustomer(w http.ResponseWriter, r *http.Request) {
+	explain, err := parseBoolQuery(r, "explain")
+	if err != nil {
+		render.Error(w, r, err)
+		return
+	}
+
 	var req featureRequest
 
 	dec := json.NewDecoder(r.Body)
 	dec.DisallowUnknownFields()
 	if err := dec.Decode(&req); err != nil {
-		render.Error(w, render.NewBadRequest(fmt.Sprintf("decode request body: %s", err)))
+		render.Error(w, r, render.TagBadRequest(fmt.Errorf("decode request body: %w", err)))
+		return
+	}
+
+	if explain {
+		e, err := h.service.explainCustomerFeatures(r.Context(), req.Request.CustomerID, req.featureTechnicalNames()...)
+		if err != nil {
+			hlog.FromRequest(r).
+				Error().
+				Err(err).
+				Msg("failed to explain features by technical names")
+			render.Error(w, r, err)
+			return
+		}
+
+		render.JSON(w, responseFromEvaluationExplanation(*e))
 		return
 	}
 
@@ -267,22 +934,27 @@ func (h Handler) RequestFeaturesAsCustomer(w http.ResponseWriter, r *http.Reques
 			Error().
 			Err(err).
 			Msg("failed to retrieve features by technical names")
-		render.Error(w, err)
+		render.Error(w, r, err)
 		return
 	}
 
-	render.JSON(w, responseFromCustomerFeatures(cfs))
+	render.CachedJSON(w, r, responseFromCustomerFeatures(cfs))
 }
 
-func responseFromCustomerFeatures(cfs []customerFeature) customerFeaturesResponse {
+func responseFromCustomerFeatures(cfs []CustomerFeature) customerFeaturesResponse {
 	features := make([]customerFeatureResponse, len(cfs))
 	for i, cf := range cfs {
 		features[i] = customerFeatureResponse{
 			Name:     cf.TechnicalName,
 			Active:   cf.isActive(),
 			Inverted: cf.Inverted,
+			Killed:   cf.Killed,
 			Expired:  cf.Expired,
 		}
+		if cf.RenamedTo != "" {
+			features[i].Deprecated = true
+			features[i].RenamedTo = ptr(cf.RenamedTo)
+		}
 	}
 	return customerFeaturesResponse{
 		Features: features,
@@ -297,5 +969,234 @@ type customerFeatureResponse struct {
 	Name     string `json:"name"`
 	Active   bool   `json:"active"`
 	Inverted bool   `json:"inverted"`
+	Killed   bool   `json:"killed"`
 	Expired  bool   `json:"expired"`
+	// Deprecated is set for features asked for by a former technical name,
+	// along with the name to use instead.
+	Deprecated bool    `json:"deprecated,omitempty"`
+	RenamedTo  *string `json:"renamedTo,omitempty"`
+}
+
+func responseFromEvaluationExplanation(e EvaluationExplanation) explainedFeaturesResponse {
+	features := make([]explainedFeatureResponse, len(e.Features))
+	for i, fe := range e.Features {
+		steps := make([]explanationStepResponse, len(fe.Steps))
+		for j, s := range fe.Steps {
+			steps[j] = explanationStepResponse(s)
+		}
+		features[i] = explainedFeatureResponse{
+			customerFeatureResponse: responseFromCustomerFeatures([]CustomerFeature{fe.CustomerFeature}).Features[0],
+			Found:                   fe.Found,
+			Steps:                   steps,
+		}
+	}
+	return explainedFeaturesResponse{
+		EvaluatedAt:      e.EvaluatedAt.UnixMilli(),
+		SnapshotLoadedAt: e.SnapshotLoadedAt.UnixMilli(),
+		Features:         features,
+	}
+}
+
+type explainedFeaturesResponse struct {
+	EvaluatedAt      int64                      `json:"evaluatedAt"`
+	SnapshotLoadedAt int64                      `json:"snapshotLoadedAt"`
+	Features         []explainedFeatureResponse `json:"features"`
+}
+
+type explainedFeatureResponse struct {
+	customerFeatureResponse
+	Found bool                      `json:"found"`
+	Steps []explanationStepResponse `json:"steps"`
+}
+
+type explanationStepResponse struct {
+	Check    string `json:"check"`
+	Result   bool   `json:"result"`
+	Decisive bool   `json:"decisive"`
+	Detail   string `json:"detail"`
+}
+
+// ListWebhooks renders all webhook subscriptions to the client. Secrets are
+// only ever rendered upon creation.
+func (h Handler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	whs, err := h.service.store.FindAllWebhooks(r.Context())
+	if err != nil {
+		hlog.FromRequest(r).
+			Error().
+			Err(err).
+			Msg("failed to find all webhooks")
+		render.Error(w, r, err)
+		return
+	}
+
+	render.JSON(w, slices.Map(func(wh Webhook) webhookResponse {
+		res := responseFromWebhook(wh)
+		res.Secret = ""
+		return res
+	}, whs...))
+}
+
+type saveWebhookRequest struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"`
+}
+
+// SaveWebhook subscribes a webhook to feature lifecycle events. If no secret is
+// given, one is generated. Either way, the secret is rendered back to the
+// client.
+func (h Handler) SaveWebhook(w http.ResponseWriter, r *http.Request) {
+	var req saveWebhookRequest
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		render.Error(w, r, render.TagBadRequest(fmt.Errorf("decode request body: %w", err)))
+		return
+	}
+
+	wh, err := h.service.saveWebhook(r.Context(), Webhook{
+		URL:    req.URL,
+		Secret: req.Secret,
+		Events: req.Events,
+	})
+	if err != nil {
+		hlog.FromRequest(r).
+			Error().
+			Err(err).
+			Msg("failed to save webhook")
+		render.Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	render.JSON(w, responseFromWebhook(*wh))
+}
+
+// DeleteWebhook unsubscribes a webhook. Its pending deliveries are discarded.
+func (h Handler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "webhookId"))
+	if err != nil {
+		render.Error(w, r, render.NewBadRequest(fmt.Sprintf("parse webhook id: %s", err)))
+		return
+	}
+
+	if err := h.service.deleteWebhook(r.Context(), id); err != nil {
+		hlog.FromRequest(r).
+			Error().
+			Err(err).
+			Msg("failed to delete webhook")
+		render.Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func responseFromWebhook(wh Webhook) webhookResponse {
+	res := webhookResponse{
+		ID:        wh.ID,
+		URL:       wh.URL,
+		Secret:    wh.Secret,
+		Events:    wh.Events,
+		CreatedAt: wh.CreatedAt.UnixMilli(),
+	}
+	if res.Events == nil {
+		res.Events = []string{}
+	}
+	return res
+}
+
+type webhookResponse struct {
+	ID        uuid.UUID `json:"id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret,omitempty"`
+	Events    []string  `json:"events"`
+	CreatedAt int64     `json:"createdAt"`
+}
+
+// ExportConfiguration renders all flag configuration as a Document. YAML is
+// rendered instead of JSON if requested via the 'format' query parameter or the
+// Accept header.
+func (h Handler) ExportConfiguration(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = FormatJSON
+		if strings.Contains(r.Header.Get("Accept"), "yaml") {
+			format = FormatYAML
+		}
+	}
+
+	d, err := h.service.Export(r.Context())
+	if err != nil {
+		hlog.FromRequest(r).
+			Error().
+			Err(err).
+			Msg("failed to export configuration")
+		render.Error(w, r, err)
+		return
+	}
+
+	buf := &bytes.Buffer{}
+	if err := EncodeDocument(buf, *d, format); err != nil {
+		hlog.FromRequest(r).
+			Error().
+			Err(err).
+			Msg("failed to encode configuration")
+		render.Error(w, r, err)
+		return
+	}
+
+	if format == FormatYAML {
+		w.Header().Set("Content-Type", "application/yaml; charset=utf-8")
+	} else {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	}
+	w.Write(buf.Bytes())
+}
+
+// ImportConfiguration applies the Document received via request body. The
+// document is read as YAML if the Content-Type header says so, or JSON
+// otherwise. With the 'dryRun' query parameter set, changes are only reported.
+func (h Handler) ImportConfiguration(w http.ResponseWriter, r *http.Request) {
+	dryRun, err := parseBoolQuery(r, "dryRun")
+	if err != nil {
+		render.Error(w, r, err)
+		return
+	}
+
+	format := FormatJSON
+	if strings.Contains(r.Header.Get("Content-Type"), "yaml") {
+		format = FormatYAML
+	}
+
+	d, err := DecodeDocument(r.Body, format)
+	if err != nil {
+		render.Error(w, r, fmt.Errorf("decode request body: %w", err))
+		return
+	}
+
+	report, err := h.service.Import(r.Context(), *d, dryRun)
+	if err != nil {
+		hlog.FromRequest(r).
+			Error().
+			Err(err).
+			Msg("failed to import configuration")
+		render.Error(w, r, err)
+		return
+	}
+
+	render.JSON(w, report)
+}
+
+func parseBoolQuery(r *http.Request, name string) (bool, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, render.NewBadRequest(fmt.Sprintf("parse %s: %s", name, err))
+	}
+	return b, nil
 }
@@ -0,0 +1,159 @@
+package feature
+
+import (
+	"context"
+	"database/sql"
+	"feature/pkg/config"
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeleteFeatureAlias(t *testing.T) {
+	viper.SetConfigFile("../test.env")
+	if err := viper.ReadInConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open(config.Driver(), config.DSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewStore(db)
+
+	var (
+		existingUUID = uuid.MustParse("bb7fe5b6-24a5-4218-bc61-b487bbad9580")
+		otherUUID    = uuid.MustParse("e2bd6e9c-04b5-4d2e-a4b7-2b2d0a87e1f5")
+		refTime      = time.Now().Truncate(time.Second).UTC()
+		monthAgo     = refTime.Add(-usageWindow).AddDate(0, 0, -1)
+
+		features = []Feature{
+			{ID: existingUUID, TechnicalName: "feature-2", CreatedAt: monthAgo, UpdatedAt: monthAgo},
+			{ID: otherUUID, TechnicalName: "feature-3", CreatedAt: monthAgo, UpdatedAt: monthAgo},
+		}
+		alias = Alias{TechnicalName: "feature-1", FeatureID: existingUUID, CreatedAt: monthAgo}
+	)
+
+	tests := map[string]struct {
+		usage   []UsageDelta
+		pending []string
+
+		featureId string
+		alias     string
+
+		wantStatus  int
+		wantBody    string
+		wantAliases []Alias
+	}{
+		"successfully delete alias no longer evaluated": {
+			usage: []UsageDelta{{TechnicalName: "feature-1", Day: monthAgo.Format(usageDayLayout), Count: 3, LastEvaluatedAt: monthAgo}},
+
+			featureId: existingUUID.String(),
+			alias:     "feature-1",
+
+			wantStatus: http.StatusNoContent,
+		},
+		"alias still evaluated": {
+			usage: []UsageDelta{{TechnicalName: "feature-1", Day: refTime.Format(usageDayLayout), Count: 3, LastEvaluatedAt: refTime}},
+
+			featureId: existingUUID.String(),
+			alias:     "feature-1",
+
+			wantStatus:  http.StatusConflict,
+			wantBody:    `{"type":"urn:feature:problem:alias-in-use","title":"Conflict","status":409,"detail":"alias \"feature-1\" is still in use, with 3 evaluations in the last 30 days","code":"alias-in-use"}`,
+			wantAliases: []Alias{alias},
+		},
+		"alias evaluated, but usage not yet flushed": {
+			pending: []string{"feature-1"},
+
+			featureId: existingUUID.String(),
+			alias:     "feature-1",
+
+			wantStatus:  http.StatusConflict,
+			wantBody:    `{"type":"urn:feature:problem:alias-in-use","title":"Conflict","status":409,"detail":"alias \"feature-1\" is still in use, with 1 evaluations in the last 30 days","code":"alias-in-use"}`,
+			wantAliases: []Alias{alias},
+		},
+		"alias of another feature": {
+			featureId: otherUUID.String(),
+			alias:     "feature-1",
+
+			wantStatus:  http.StatusNotFound,
+			wantBody:    `{"type":"urn:feature:problem:alias-not-found","title":"Not Found","status":404,"detail":"alias \"feature-1\" does not exist","code":"alias-not-found"}`,
+			wantAliases: []Alias{alias},
+		},
+		"alias doesn't exist": {
+			featureId: existingUUID.String(),
+			alias:     "feature-9",
+
+			wantStatus:  http.StatusNotFound,
+			wantBody:    `{"type":"urn:feature:problem:alias-not-found","title":"Not Found","status":404,"detail":"alias \"feature-9\" does not exist","code":"alias-not-found"}`,
+			wantAliases: []Alias{alias},
+		},
+		"invalid feature id": {
+			featureId: "foo",
+			alias:     "feature-1",
+
+			wantStatus:  http.StatusBadRequest,
+			wantBody:    `{"type":"urn:feature:problem:bad-request","title":"Bad Request","status":400,"detail":"parse feature id: invalid UUID length: 3","code":"bad-request"}`,
+			wantAliases: []Alias{alias},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tx, _, rollback, err := store.beginTx(context.Background(), &sql.TxOptions{
+				Isolation: sql.LevelReadCommitted,
+			})
+			if err != nil {
+				t.Fatalf("failed to begin transaction: %s\n", err)
+			}
+
+			t.Cleanup(func() {
+				if err := rollback(); err != nil {
+					t.Errorf("failed to rollback the transaction: %s\n", err)
+				}
+			})
+
+			setupFeatures(t, *tx, features...)
+			setupAliases(t, *tx, alias)
+			setupUsage(t, *tx, test.usage...)
+
+			service := NewService(*tx)
+			service.timeFunc = func() time.Time { return refTime }
+			service.usage.record(refTime, test.pending...)
+			handler := NewHandler(service)
+
+			r := chi.NewRouter()
+			r.Delete("/features/{featureId}/aliases/{alias}", handler.DeleteFeatureAlias)
+
+			req := httptest.NewRequest(
+				http.MethodDelete,
+				"/features/"+test.featureId+"/aliases/"+test.alias,
+				nil,
+			)
+			res := httptest.NewRecorder()
+
+			r.ServeHTTP(res, req)
+
+			if res.Code != test.wantStatus {
+				t.Errorf("Status codes not equal.\nwant: %d\ngot:  %d", test.wantStatus, res.Code)
+			}
+
+			resBody := strings.TrimSpace(res.Body.String())
+			if resBody != test.wantBody {
+				t.Errorf("Response bodies not equal.\nwant: %s\ngot:  %s", test.wantBody, resBody)
+			}
+
+			assertAliases(t, *tx, test.wantAliases...)
+		})
+	}
+}
@@ -0,0 +1,483 @@
+package feature
+
+import (
+	"context"
+	"database/sql"
+	"feature/pkg/config"
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestImportConfiguration(t *testing.T) {
+	viper.SetConfigFile("../test.env")
+	if err := viper.ReadInConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open(config.Driver(), config.DSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewStore(db)
+
+	var (
+		existingUUID  = uuid.MustParse("bb7fe5b6-24a5-4218-bc61-b487bbad9580")
+		otherUUID     = uuid.MustParse("0b8e2f9a-5d6c-4b0e-9f3c-2a1d7e6b5c4d")
+		generatedUUID = uuid.MustParse("44eeeacf-8d5d-4c68-bbe9-3e58c5ae6915")
+		refTime       = time.Now().Truncate(time.Second).UTC()
+		lastWeek      = refTime.AddDate(0, 0, -7)
+	)
+
+	existingFeatures := []Feature{
+		{
+			ID:            existingUUID,
+			TechnicalName: "feature-1",
+			CreatedAt:     lastWeek,
+			UpdatedAt:     lastWeek,
+		},
+		{
+			ID:            otherUUID,
+			TechnicalName: "feature-2",
+			CreatedAt:     lastWeek,
+			UpdatedAt:     lastWeek,
+		},
+	}
+
+	tests := map[string]struct {
+		features  []Feature
+		aliases   []Alias
+		customers []CustomerProfile
+
+		query       string
+		contentType string
+		body        string
+
+		wantStatus    int
+		wantBody      string
+		wantFeatures  []Feature
+		wantCustomers []CustomerProfile
+	}{
+		"dry run reports changes without making them": {
+			features: existingFeatures,
+
+			query: "?dryRun=true",
+			body:  `{"version":1,"features":[{"technicalName":"feature-1","inverted":true,"killed":false},{"technicalName":"feature-3","inverted":false,"killed":false}],"archivedFeatures":[]}`,
+
+			wantStatus:   http.StatusOK,
+			wantBody:     `{"dryRun":true,"creates":[{"kind":"feature","technicalName":"feature-3"}],"updates":[{"kind":"feature","technicalName":"feature-1","id":"bb7fe5b6-24a5-4218-bc61-b487bbad9580"}],"deletes":[{"kind":"feature","technicalName":"feature-2","id":"0b8e2f9a-5d6c-4b0e-9f3c-2a1d7e6b5c4d"}]}`,
+			wantFeatures: existingFeatures,
+		},
+		"dry run fails when a created feature takes a former name of a kept feature": {
+			features: existingFeatures,
+			aliases: []Alias{{
+				TechnicalName: "feature-0",
+				FeatureID:     existingUUID,
+				CreatedAt:     lastWeek,
+			}},
+
+			query: "?dryRun=true",
+			body:  `{"version":1,"features":[{"technicalName":"feature-0"},{"technicalName":"feature-1"},{"technicalName":"feature-2"}],"archivedFeatures":[]}`,
+
+			wantStatus:   http.StatusConflict,
+			wantBody:     `{"type":"urn:feature:problem:technical-name-taken","title":"Conflict","status":409,"detail":"technical name \"feature-0\" is an alias of feature bb7fe5b6-24a5-4218-bc61-b487bbad9580","code":"technical-name-taken"}`,
+			wantFeatures: existingFeatures,
+		},
+		"created feature takes a former name of an archived feature": {
+			features: existingFeatures,
+			aliases: []Alias{{
+				TechnicalName: "feature-0",
+				FeatureID:     otherUUID,
+				CreatedAt:     lastWeek,
+			}},
+
+			query: "?dryRun=true",
+			body:  `{"version":1,"features":[{"technicalName":"feature-0"},{"technicalName":"feature-1"}],"archivedFeatures":[]}`,
+
+			wantStatus:   http.StatusOK,
+			wantBody:     `{"dryRun":true,"creates":[{"kind":"feature","technicalName":"feature-0"}],"updates":[],"deletes":[{"kind":"feature","technicalName":"feature-2","id":"0b8e2f9a-5d6c-4b0e-9f3c-2a1d7e6b5c4d"}]}`,
+			wantFeatures: existingFeatures,
+		},
+		"successfully import a YAML document": {
+			features: existingFeatures,
+
+			contentType: "application/yaml",
+			body: `
+version: 1
+features:
+  - technicalName: feature-1
+    inverted: true
+    killed: true
+  - technicalName: feature-3
+    inverted: false
+    killed: false
+archivedFeatures: []
+`,
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"dryRun":false,"creates":[{"kind":"feature","technicalName":"feature-3"}],"updates":[{"kind":"feature","technicalName":"feature-1","id":"bb7fe5b6-24a5-4218-bc61-b487bbad9580"}],"deletes":[{"kind":"feature","technicalName":"feature-2","id":"0b8e2f9a-5d6c-4b0e-9f3c-2a1d7e6b5c4d"}]}`,
+			wantFeatures: []Feature{
+				{
+					ID:            existingUUID,
+					TechnicalName: "feature-1",
+					Inverted:      true,
+					Killed:        true,
+					KilledAt:      &refTime,
+					CreatedAt:     lastWeek,
+					UpdatedAt:     refTime,
+				},
+				{
+					ID:            generatedUUID,
+					TechnicalName: "feature-3",
+					CreatedAt:     refTime,
+					UpdatedAt:     refTime,
+				},
+			},
+		},
+		"successfully import killed features with the time they were killed": {
+			features: existingFeatures,
+
+			body: `{"version":1,"features":[{"technicalName":"feature-1","inverted":false,"killed":true,"killedAt":"2022-10-01T12:00:00Z"},{"technicalName":"feature-2","inverted":false,"killed":false},{"technicalName":"feature-3","inverted":false,"killed":true,"killedAt":"2022-10-02T12:00:00Z"}],"archivedFeatures":[]}`,
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"dryRun":false,"creates":[{"kind":"feature","technicalName":"feature-3"}],"updates":[{"kind":"feature","technicalName":"feature-1","id":"bb7fe5b6-24a5-4218-bc61-b487bbad9580"}],"deletes":[]}`,
+			wantFeatures: []Feature{
+				{
+					ID:            existingUUID,
+					TechnicalName: "feature-1",
+					Killed:        true,
+					KilledAt:      ptr(time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)),
+					CreatedAt:     lastWeek,
+					UpdatedAt:     refTime,
+				},
+				existingFeatures[1],
+				{
+					ID:            generatedUUID,
+					TechnicalName: "feature-3",
+					Killed:        true,
+					KilledAt:      ptr(time.Date(2022, 10, 2, 12, 0, 0, 0, time.UTC)),
+					CreatedAt:     refTime,
+					UpdatedAt:     refTime,
+				},
+			},
+		},
+		"successfully import customers": {
+			features: existingFeatures,
+			customers: []CustomerProfile{
+				{ID: "customer-1", Attributes: map[string]string{"plan": "free"}, CreatedAt: lastWeek, UpdatedAt: lastWeek},
+				{ID: "customer-2", DisplayName: ptr("Globex"), Attributes: map[string]string{}, CreatedAt: lastWeek, UpdatedAt: lastWeek},
+				{ID: "customer-3", Attributes: map[string]string{}, CreatedAt: lastWeek, UpdatedAt: lastWeek},
+			},
+
+			body: `{"version":1,"features":[{"technicalName":"feature-1","inverted":false,"killed":false},{"technicalName":"feature-2","inverted":false,"killed":false}],"archivedFeatures":[],"customers":[{"id":"customer-1","displayName":"Acme","attributes":{"plan":"enterprise"}},{"id":"customer-2","displayName":"Globex"},{"id":"customer-4","attributes":{"plan":"pro"}}]}`,
+
+			wantStatus:   http.StatusOK,
+			wantBody:     `{"dryRun":false,"creates":[{"kind":"customer","customerId":"customer-4"}],"updates":[{"kind":"customer","customerId":"customer-1"}],"deletes":[]}`,
+			wantFeatures: existingFeatures,
+			wantCustomers: []CustomerProfile{
+				{ID: "customer-1", DisplayName: ptr("Acme"), Attributes: map[string]string{"plan": "enterprise"}, CreatedAt: lastWeek, UpdatedAt: refTime},
+				{ID: "customer-2", DisplayName: ptr("Globex"), Attributes: map[string]string{}, CreatedAt: lastWeek, UpdatedAt: lastWeek},
+				{ID: "customer-3", Attributes: map[string]string{}, CreatedAt: lastWeek, UpdatedAt: lastWeek},
+				{ID: "customer-4", Attributes: map[string]string{"plan": "pro"}, CreatedAt: refTime, UpdatedAt: refTime},
+			},
+		},
+		"document contains invalid customers": {
+			features: existingFeatures,
+
+			body: `{"version":1,"features":[{"technicalName":"feature-1"},{"technicalName":"feature-2"}],"archivedFeatures":[],"customers":[{"id":"customer 1"}]}`,
+
+			wantStatus:   http.StatusBadRequest,
+			wantBody:     `{"type":"urn:feature:problem:validation-failed","title":"Bad Request","status":400,"detail":"customer \"customer 1\": 'id' must be 1 to 255 characters long, without whitespace","code":"validation-failed","errors":[{"detail":"customer \"customer 1\": 'id' must be 1 to 255 characters long, without whitespace"}]}`,
+			wantFeatures: existingFeatures,
+		},
+		"successfully import rules": {
+			features: existingFeatures,
+
+			body: `{"version":1,"features":[{"technicalName":"feature-1","inverted":false,"killed":false,"rules":[{"attribute":"plan","values":["pro","enterprise"]}]},{"technicalName":"feature-2","inverted":false,"killed":false}],"archivedFeatures":[]}`,
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"dryRun":false,"creates":[],"updates":[{"kind":"feature","technicalName":"feature-1","id":"bb7fe5b6-24a5-4218-bc61-b487bbad9580"}],"deletes":[]}`,
+			wantFeatures: []Feature{
+				{
+					ID:            existingUUID,
+					TechnicalName: "feature-1",
+					Rules:         []TargetingRule{{Attribute: "plan", Values: []string{"enterprise", "pro"}}},
+					CreatedAt:     lastWeek,
+					UpdatedAt:     refTime,
+				},
+				existingFeatures[1],
+			},
+		},
+		"successfully import a feature without its rules": {
+			features: []Feature{
+				{
+					ID:            existingUUID,
+					TechnicalName: "feature-1",
+					Rules:         []TargetingRule{{Attribute: "plan", Values: []string{"enterprise"}}},
+					CreatedAt:     lastWeek,
+					UpdatedAt:     lastWeek,
+				},
+				existingFeatures[1],
+			},
+
+			body: `{"version":1,"features":[{"technicalName":"feature-1","inverted":false,"killed":false},{"technicalName":"feature-2","inverted":false,"killed":false}],"archivedFeatures":[]}`,
+
+			wantStatus:   http.StatusOK,
+			wantBody:     `{"dryRun":false,"creates":[],"updates":[{"kind":"feature","technicalName":"feature-1","id":"bb7fe5b6-24a5-4218-bc61-b487bbad9580"}],"deletes":[]}`,
+			wantFeatures: []Feature{{ID: existingUUID, TechnicalName: "feature-1", CreatedAt: lastWeek, UpdatedAt: refTime}, existingFeatures[1]},
+		},
+		"importing an exported document changes nothing": {
+			features: existingFeatures,
+
+			body: `{"version":1,"features":[{"technicalName":"feature-1","inverted":false,"killed":false},{"technicalName":"feature-2","inverted":false,"killed":false}],"archivedFeatures":[]}`,
+
+			wantStatus:   http.StatusOK,
+			wantBody:     `{"dryRun":false,"creates":[],"updates":[],"deletes":[]}`,
+			wantFeatures: existingFeatures,
+		},
+		"document contains invalid features": {
+			features: existingFeatures,
+
+			body: `{"version":1,"features":[{"technicalName":"f"}],"archivedFeatures":[]}`,
+
+			wantStatus:   http.StatusBadRequest,
+			wantBody:     `{"type":"urn:feature:problem:validation-failed","title":"Bad Request","status":400,"detail":"feature \"f\": 'technicalName' must be at least 5 characters long","code":"validation-failed","errors":[{"detail":"feature \"f\": 'technicalName' must be at least 5 characters long"}]}`,
+			wantFeatures: existingFeatures,
+		},
+		"document has an unsupported version": {
+			body: `{"version":2,"features":[{"technicalName":"feature-1"},{"technicalName":"feature-1"}]}`,
+
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"type":"urn:feature:problem:validation-failed","title":"Bad Request","status":400,"detail":"'version' must be 1, 'features' contains technical name \"feature-1\" more than once","code":"validation-failed","errors":[{"field":"version","detail":"'version' must be 1"},{"field":"features","detail":"'features' contains technical name \"feature-1\" more than once"}]}`,
+		},
+		"request body contains unknown fields": {
+			body: `{"foo":"bar"}`,
+
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"type":"urn:feature:problem:bad-request","title":"Bad Request","status":400,"detail":"json: unknown field \"foo\"","code":"bad-request"}`,
+		},
+		"bad dry run": {
+			query: "?dryRun=maybe",
+
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"type":"urn:feature:problem:bad-request","title":"Bad Request","status":400,"detail":"parse dryRun: strconv.ParseBool: parsing \"maybe\": invalid syntax","code":"bad-request"}`,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tx, _, rollback, err := store.beginTx(context.Background(), &sql.TxOptions{
+				Isolation: sql.LevelReadCommitted,
+			})
+			if err != nil {
+				t.Fatalf("failed to begin transaction: %s\n", err)
+			}
+
+			t.Cleanup(func() {
+				if err := rollback(); err != nil {
+					t.Errorf("failed to rollback the transaction: %s\n", err)
+				}
+			})
+
+			setupFeatures(t, *tx, test.features...)
+			setupAliases(t, *tx, test.aliases...)
+			setupCustomerProfiles(t, *tx, test.customers...)
+
+			service := NewService(*tx)
+			service.timeFunc = func() time.Time { return refTime }
+			service.uuidFunc = func() (uuid.UUID, error) { return generatedUUID, nil }
+			handler := NewHandler(service)
+
+			r := chi.NewRouter()
+			r.Post("/import", handler.ImportConfiguration)
+
+			req := httptest.NewRequest(
+				http.MethodPost,
+				"/import"+test.query,
+				strings.NewReader(test.body),
+			)
+			if test.contentType != "" {
+				req.Header.Set("Content-Type", test.contentType)
+			}
+			res := httptest.NewRecorder()
+
+			r.ServeHTTP(res, req)
+
+			if res.Code != test.wantStatus {
+				t.Errorf("Status codes not equal.\nwant: %d\ngot:  %d", test.wantStatus, res.Code)
+			}
+
+			resBody := strings.TrimSpace(res.Body.String())
+			if resBody != test.wantBody {
+				t.Errorf("Response bodies not equal.\nwant: %s\ngot:  %s", test.wantBody, resBody)
+			}
+
+			assertFeatures(t, *tx, test.wantFeatures...)
+			if test.wantCustomers != nil {
+				assertCustomerProfiles(t, *tx, test.wantCustomers...)
+			}
+		})
+	}
+}
+
+func TestExportConfiguration(t *testing.T) {
+	viper.SetConfigFile("../test.env")
+	if err := viper.ReadInConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open(config.Driver(), config.DSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewStore(db)
+
+	var (
+		existingUUID = uuid.MustParse("bb7fe5b6-24a5-4218-bc61-b487bbad9580")
+		refTime      = time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	)
+
+	tests := map[string]struct {
+		query string
+
+		wantContentType string
+		wantBody        string
+	}{
+		"export as JSON": {
+			wantContentType: "application/json; charset=utf-8",
+			wantBody: `{
+  "version": 1,
+  "features": [
+    {
+      "technicalName": "feature-1",
+      "displayName": "Feature #1",
+      "expiresOn": "2022-10-01T12:00:00Z",
+      "inverted": false,
+      "killed": false,
+      "customerIds": [
+        "customer-1",
+        "customer-2"
+      ],
+      "rules": [
+        {
+          "attribute": "plan",
+          "values": [
+            "enterprise"
+          ]
+        }
+      ]
+    }
+  ],
+  "archivedFeatures": [],
+  "customers": [
+    {
+      "id": "customer-1",
+      "displayName": "Acme",
+      "attributes": {
+        "plan": "enterprise"
+      }
+    }
+  ]
+}`,
+		},
+		"export as YAML": {
+			query: "?format=yaml",
+
+			wantContentType: "application/yaml; charset=utf-8",
+			wantBody: `version: 1
+features:
+  - technicalName: feature-1
+    displayName: 'Feature #1'
+    expiresOn: 2022-10-01T12:00:00Z
+    inverted: false
+    killed: false
+    customerIds:
+      - customer-1
+      - customer-2
+    rules:
+      - attribute: plan
+        values:
+          - enterprise
+archivedFeatures: []
+customers:
+  - id: customer-1
+    displayName: Acme
+    attributes:
+      plan: enterprise`,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tx, _, rollback, err := store.beginTx(context.Background(), &sql.TxOptions{
+				Isolation: sql.LevelReadCommitted,
+			})
+			if err != nil {
+				t.Fatalf("failed to begin transaction: %s\n", err)
+			}
+
+			t.Cleanup(func() {
+				if err := rollback(); err != nil {
+					t.Errorf("failed to rollback the transaction: %s\n", err)
+				}
+			})
+
+			setupFeatures(t, *tx, Feature{
+				ID:            existingUUID,
+				DisplayName:   ptr("Feature #1"),
+				TechnicalName: "feature-1",
+				ExpiresOn:     &refTime,
+				Rules:         []TargetingRule{{Attribute: "plan", Values: []string{"enterprise"}}},
+				CreatedAt:     refTime,
+				UpdatedAt:     refTime,
+			})
+			setupCustomers(t, *tx,
+				Customer{ID: uuid.New(), FeatureID: existingUUID, CustomerID: "customer-2"},
+				Customer{ID: uuid.New(), FeatureID: existingUUID, CustomerID: "customer-1"},
+			)
+			setupCustomerProfiles(t, *tx, CustomerProfile{
+				ID:          "customer-1",
+				DisplayName: ptr("Acme"),
+				Attributes:  map[string]string{"plan": "enterprise"},
+				CreatedAt:   refTime,
+				UpdatedAt:   refTime,
+			})
+
+			handler := NewHandler(NewService(*tx))
+
+			r := chi.NewRouter()
+			r.Get("/export", handler.ExportConfiguration)
+
+			req := httptest.NewRequest(http.MethodGet, "/export"+test.query, nil)
+			res := httptest.NewRecorder()
+
+			r.ServeHTTP(res, req)
+
+			if res.Code != http.StatusOK {
+				t.Errorf("Status codes not equal.\nwant: %d\ngot:  %d", http.StatusOK, res.Code)
+			}
+
+			if ct := res.Header().Get("Content-Type"); ct != test.wantContentType {
+				t.Errorf("Content types not equal.\nwant: %s\ngot:  %s", test.wantContentType, ct)
+			}
+
+			resBody := strings.TrimSpace(res.Body.String())
+			if resBody != test.wantBody {
+				t.Errorf("Response bodies not equal.\nwant: %s\ngot:  %s", test.wantBody, resBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package feature
+
+import (
+	"context"
+	"database/sql"
+	"feature/pkg/config"
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKillFeature(t *testing.T) {
+	viper.SetConfigFile("../test.env")
+	if err := viper.ReadInConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open(config.Driver(), config.DSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewStore(db)
+
+	var (
+		existingUUID = uuid.MustParse("bb7fe5b6-24a5-4218-bc61-b487bbad9580")
+		refTime      = time.Now().Truncate(time.Second).UTC()
+		oneHourAgo   = refTime.Add(-time.Hour)
+	)
+
+	tests := map[string]struct {
+		features []Feature
+
+		path string
+
+		wantStatus   int
+		wantBody     string
+		wantFeatures []Feature
+	}{
+		"successfully kill a feature": {
+			features: []Feature{{
+				ID:            existingUUID,
+				TechnicalName: "feature-1",
+				CreatedAt:     refTime,
+				UpdatedAt:     refTime,
+			}},
+
+			path: "/features/" + existingUUID.String() + "/kill",
+
+			wantStatus: http.StatusNoContent,
+			wantFeatures: []Feature{{
+				ID:            existingUUID,
+				TechnicalName: "feature-1",
+				Killed:        true,
+				KilledAt:      &refTime,
+				CreatedAt:     refTime,
+				UpdatedAt:     refTime,
+			}},
+		},
+		"successfully unkill a feature": {
+			features: []Feature{{
+				ID:            existingUUID,
+				TechnicalName: "feature-1",
+				Killed:        true,
+				KilledAt:      &oneHourAgo,
+				CreatedAt:     refTime,
+				UpdatedAt:     refTime,
+			}},
+
+			path: "/features/" + existingUUID.String() + "/unkill",
+
+			wantStatus: http.StatusNoContent,
+			wantFeatures: []Feature{{
+				ID:            existingUUID,
+				TechnicalName: "feature-1",
+				CreatedAt:     refTime,
+				UpdatedAt:     refTime,
+			}},
+		},
+		"killing an already killed feature is a noop": {
+			features: []Feature{{
+				ID:            existingUUID,
+				TechnicalName: "feature-1",
+				Killed:        true,
+				KilledAt:      &oneHourAgo,
+				CreatedAt:     refTime,
+				UpdatedAt:     refTime,
+			}},
+
+			path: "/features/" + existingUUID.String() + "/kill",
+
+			wantStatus: http.StatusNoContent,
+			wantFeatures: []Feature{{
+				ID:            existingUUID,
+				TechnicalName: "feature-1",
+				Killed:        true,
+				KilledAt:      &oneHourAgo,
+				CreatedAt:     refTime,
+				UpdatedAt:     refTime,
+			}},
+		},
+		"killed feature doesn't exist": {
+			path: "/features/" + existingUUID.String() + "/kill",
+
+			wantStatus: http.StatusNotFound,
+			wantBody:   `{"type":"urn:feature:problem:feature-not-found","title":"Not Found","status":404,"detail":"feature bb7fe5b6-24a5-4218-bc61-b487bbad9580 does not exist","code":"feature-not-found"}`,
+		},
+		"bad feature id": {
+			path: "/features/bad/kill",
+
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"type":"urn:feature:problem:bad-request","title":"Bad Request","status":400,"detail":"parse feature id: invalid UUID length: 3","code":"bad-request"}`,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tx, _, rollback, err := store.beginTx(context.Background(), &sql.TxOptions{
+				Isolation: sql.LevelReadCommitted,
+			})
+			if err != nil {
+				t.Fatalf("failed to begin transaction: %s\n", err)
+			}
+
+			t.Cleanup(func() {
+				if err := rollback(); err != nil {
+					t.Errorf("failed to rollback the transaction: %s\n", err)
+				}
+			})
+
+			setupFeatures(t, *tx, test.features...)
+
+			service := NewService(*tx)
+			service.timeFunc = func() time.Time { return refTime }
+			handler := NewHandler(service)
+
+			r := chi.NewRouter()
+			r.Post("/features/{featureId}/kill", handler.KillFeature)
+			r.Post("/features/{featureId}/unkill", handler.UnkillFeature)
+
+			req := httptest.NewRequest(
+				http.MethodPost,
+				test.path,
+				strings.NewReader(""),
+			)
+			res := httptest.NewRecorder()
+
+			r.ServeHTTP(res, req)
+
+			if res.Code != test.wantStatus {
+				t.Errorf("Status codes not equal.\nwant: %d\ngot:  %d", test.wantStatus, res.Code)
+			}
+
+			resBody := strings.TrimSpace(res.Body.String())
+			if resBody != test.wantBody {
+				t.Errorf("Response bodies not equal.\nwant: %s\ngot:  %s", test.wantBody, resBody)
+			}
+
+			assertFeatures(t, *tx, test.wantFeatures...)
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package feature
+
+import (
+	"context"
+	"database/sql"
+	"feature/pkg/config"
+	"github.com/go-chi/chi"
+	"github.com/spf13/viper"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestListCustomers(t *testing.T) {
+	viper.SetConfigFile("../test.env")
+	if err := viper.ReadInConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open(config.Driver(), config.DSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewStore(db)
+
+	var (
+		refTime = time.Now().Truncate(time.Second).UTC()
+		ms      = strconv.FormatInt(refTime.UnixMilli(), 10)
+	)
+
+	profiles := []CustomerProfile{
+		{ID: "cust-9812", DisplayName: ptr("Acme Corp"), Attributes: map[string]string{"plan": "enterprise"}, CreatedAt: refTime, UpdatedAt: refTime},
+		{ID: "cust-9813", DisplayName: ptr("Globex"), Attributes: map[string]string{}, CreatedAt: refTime, UpdatedAt: refTime},
+		{ID: "initech", Attributes: map[string]string{"region": "us"}, CreatedAt: refTime, UpdatedAt: refTime},
+		{ID: "100%_off", Attributes: map[string]string{}, CreatedAt: refTime, UpdatedAt: refTime},
+	}
+
+	tests := map[string]struct {
+		query string
+
+		wantStatus int
+		wantBody   string
+	}{
+		"all customers ordered by id": {
+			query: "?limit=2",
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"customers":[{"id":"100%_off","displayName":null,"attributes":{},"createdAt":` + ms + `,"updatedAt":` + ms + `},{"id":"cust-9812","displayName":"Acme Corp","attributes":{"plan":"enterprise"},"createdAt":` + ms + `,"updatedAt":` + ms + `}]}`,
+		},
+		"customers by id prefix": {
+			query: "?q=CUST-981",
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"customers":[{"id":"cust-9812","displayName":"Acme Corp","attributes":{"plan":"enterprise"},"createdAt":` + ms + `,"updatedAt":` + ms + `},{"id":"cust-9813","displayName":"Globex","attributes":{},"createdAt":` + ms + `,"updatedAt":` + ms + `}]}`,
+		},
+		"customers by display name": {
+			query: "?q=acme",
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"customers":[{"id":"cust-9812","displayName":"Acme Corp","attributes":{"plan":"enterprise"},"createdAt":` + ms + `,"updatedAt":` + ms + `}]}`,
+		},
+		"wildcards are matched literally": {
+			query: "?q=%25_",
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"customers":[]}`,
+		},
+		"no match": {
+			query: "?q=umbrella",
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"customers":[]}`,
+		},
+		"limit out of range": {
+			query: "?limit=101",
+
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"type":"urn:feature:problem:bad-request","title":"Bad Request","status":400,"detail":"'limit' must be between 1 and 100","code":"bad-request"}`,
+		},
+		"bad limit": {
+			query: "?limit=ten",
+
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"type":"urn:feature:problem:bad-request","title":"Bad Request","status":400,"detail":"parse limit: strconv.Atoi: parsing \"ten\": invalid syntax","code":"bad-request"}`,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tx, _, rollback, err := store.beginTx(context.Background(), &sql.TxOptions{
+				Isolation: sql.LevelReadCommitted,
+			})
+			if err != nil {
+				t.Fatalf("failed to begin transaction: %s\n", err)
+			}
+
+			t.Cleanup(func() {
+				if err := rollback(); err != nil {
+					t.Errorf("failed to rollback the transaction: %s\n", err)
+				}
+			})
+
+			setupCustomerProfiles(t, *tx, profiles...)
+
+			handler := NewHandler(NewService(*tx))
+
+			r := chi.NewRouter()
+			r.Get("/customers", handler.ListCustomers)
+
+			req := httptest.NewRequest(http.MethodGet, "/customers"+test.query, nil)
+			res := httptest.NewRecorder()
+
+			r.ServeHTTP(res, req)
+
+			if res.Code != test.wantStatus {
+				t.Errorf("Status codes not equal.\nwant: %d\ngot:  %d", test.wantStatus, res.Code)
+			}
+
+			resBody := strings.TrimSpace(res.Body.String())
+			if resBody != test.wantBody {
+				t.Errorf("Response bodies not equal.\nwant: %s\ngot:  %s", test.wantBody, resBody)
+			}
+		})
+	}
+}
+
+func setupCustomerProfiles(t *testing.T, store Store, ps ...CustomerProfile) {
+	t.Helper()
+	if err := store.SaveCustomerProfiles(context.Background(), ps...); err != nil {
+		t.Fatalf("failed to set up customers table: %s\n", err)
+	}
+}
+
+func assertCustomerProfiles(t *testing.T, store Store, want ...CustomerProfile) {
+	t.Helper()
+	got, err := store.FindCustomerProfiles(context.Background(), "", maxCustomerPageSize)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Customers not equal.\nwant: %v\ngot:  %v", want, got)
+	}
+}
@@ -0,0 +1,140 @@
+package feature
+
+import (
+	"context"
+	"database/sql"
+	"feature/pkg/config"
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestListFeatureAliases(t *testing.T) {
+	viper.SetConfigFile("../test.env")
+	if err := viper.ReadInConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open(config.Driver(), config.DSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewStore(db)
+
+	var (
+		existingUUID = uuid.MustParse("bb7fe5b6-24a5-4218-bc61-b487bbad9580")
+		refTime      = time.Now().Truncate(time.Second).UTC()
+		oneDayAgo    = refTime.AddDate(0, 0, -1)
+		monthAgo     = refTime.Add(-usageWindow).AddDate(0, 0, -1)
+
+		features = []Feature{{
+			ID:            existingUUID,
+			TechnicalName: "feature-3",
+			CreatedAt:     monthAgo,
+			UpdatedAt:     monthAgo,
+		}}
+		aliases = []Alias{
+			{TechnicalName: "feature-1", FeatureID: existingUUID, CreatedAt: monthAgo},
+			{TechnicalName: "feature-2", FeatureID: existingUUID, CreatedAt: oneDayAgo},
+		}
+	)
+
+	tests := map[string]struct {
+		usage []UsageDelta
+
+		featureId string
+
+		wantStatus int
+		wantBody   string
+	}{
+		"aliases with their usage": {
+			usage: []UsageDelta{
+				{TechnicalName: "feature-1", Day: monthAgo.Format(usageDayLayout), Count: 3, LastEvaluatedAt: monthAgo},
+				{TechnicalName: "feature-2", Day: refTime.Format(usageDayLayout), Count: 2, LastEvaluatedAt: refTime},
+			},
+
+			featureId: existingUUID.String(),
+
+			wantStatus: http.StatusOK,
+			wantBody: `{"aliases":[` +
+				`{"technicalName":"feature-1","createdAt":` + strconv.FormatInt(monthAgo.UnixMilli(), 10) + `,"lastEvaluatedAt":` + strconv.FormatInt(monthAgo.UnixMilli(), 10) + `,"evaluationCount":0},` +
+				`{"technicalName":"feature-2","createdAt":` + strconv.FormatInt(oneDayAgo.UnixMilli(), 10) + `,"lastEvaluatedAt":` + strconv.FormatInt(refTime.UnixMilli(), 10) + `,"evaluationCount":2}]}`,
+		},
+		"aliases never evaluated": {
+			featureId: existingUUID.String(),
+
+			wantStatus: http.StatusOK,
+			wantBody: `{"aliases":[` +
+				`{"technicalName":"feature-1","createdAt":` + strconv.FormatInt(monthAgo.UnixMilli(), 10) + `,"lastEvaluatedAt":null,"evaluationCount":0},` +
+				`{"technicalName":"feature-2","createdAt":` + strconv.FormatInt(oneDayAgo.UnixMilli(), 10) + `,"lastEvaluatedAt":null,"evaluationCount":0}]}`,
+		},
+		"feature doesn't exist": {
+			featureId: "44eeeacf-8d5d-4c68-bbe9-3e58c5ae6915",
+
+			wantStatus: http.StatusNotFound,
+			wantBody:   `{"type":"urn:feature:problem:feature-not-found","title":"Not Found","status":404,"detail":"feature 44eeeacf-8d5d-4c68-bbe9-3e58c5ae6915 does not exist","code":"feature-not-found"}`,
+		},
+		"invalid feature id": {
+			featureId: "foo",
+
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"type":"urn:feature:problem:bad-request","title":"Bad Request","status":400,"detail":"parse feature id: invalid UUID length: 3","code":"bad-request"}`,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tx, _, rollback, err := store.beginTx(context.Background(), &sql.TxOptions{
+				Isolation: sql.LevelReadCommitted,
+			})
+			if err != nil {
+				t.Fatalf("failed to begin transaction: %s\n", err)
+			}
+
+			t.Cleanup(func() {
+				if err := rollback(); err != nil {
+					t.Errorf("failed to rollback the transaction: %s\n", err)
+				}
+			})
+
+			setupFeatures(t, *tx, features...)
+			setupAliases(t, *tx, aliases...)
+			setupUsage(t, *tx, test.usage...)
+
+			service := NewService(*tx)
+			service.timeFunc = func() time.Time { return refTime }
+			handler := NewHandler(service)
+
+			r := chi.NewRouter()
+			r.Get("/features/{featureId}/aliases", handler.ListFeatureAliases)
+
+			req := httptest.NewRequest(
+				http.MethodGet,
+				"/features/"+test.featureId+"/aliases",
+				nil,
+			)
+			res := httptest.NewRecorder()
+
+			r.ServeHTTP(res, req)
+
+			if res.Code != test.wantStatus {
+				t.Errorf("Status codes not equal.\nwant: %d\ngot:  %d", test.wantStatus, res.Code)
+			}
+
+			resBody := strings.TrimSpace(res.Body.String())
+			if resBody != test.wantBody {
+				t.Errorf("Response bodies not equal.\nwant: %s\ngot:  %s", test.wantBody, resBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,332 @@
+package feature
+
+import (
+	"context"
+	"database/sql"
+	"feature/pkg/config"
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestListFeatures(t *testing.T) {
+	viper.SetConfigFile("../test.env")
+	if err := viper.ReadInConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open(config.Driver(), config.DSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewStore(db)
+
+	var (
+		alphaUUID = uuid.MustParse("bb7fe5b6-24a5-4218-bc61-b487bbad9581")
+		betaUUID  = uuid.MustParse("bb7fe5b6-24a5-4218-bc61-b487bbad9582")
+		gammaUUID = uuid.MustParse("bb7fe5b6-24a5-4218-bc61-b487bbad9583")
+		refTime   = time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+		oneDayAgo = refTime.AddDate(0, 0, -1)
+		inOneDay  = refTime.AddDate(0, 0, 1)
+
+		features = []Feature{
+			{
+				ID:            alphaUUID,
+				TechnicalName: "feature-alpha",
+				Description:   ptr("Checkout flow"),
+				ExpiresOn:     &oneDayAgo,
+				CreatedAt:     refTime.AddDate(0, 0, -3),
+				UpdatedAt:     refTime.AddDate(0, 0, -3),
+				Tags:          []string{"checkout"},
+				Kind:          KindOps,
+			},
+			{
+				ID:            gammaUUID,
+				DisplayName:   ptr("Gamma"),
+				TechnicalName: "feature-gamma",
+				ExpiresOn:     &inOneDay,
+				CreatedAt:     oneDayAgo,
+				UpdatedAt:     oneDayAgo,
+				Tags:          []string{"checkout", "mobile"},
+				Owner:         ptr("Team-Payments"),
+				Kind:          KindRelease,
+			},
+			{
+				ID:            betaUUID,
+				TechnicalName: "feature-beta",
+				Inverted:      true,
+				CreatedAt:     refTime.AddDate(0, 0, -2),
+				UpdatedAt:     refTime.AddDate(0, 0, -2),
+			},
+		}
+		customers = []Customer{{
+			ID:         betaUUID,
+			FeatureID:  betaUUID,
+			CustomerID: "1234",
+		}}
+	)
+
+	const (
+		alphaJSON = `{"id":"bb7fe5b6-24a5-4218-bc61-b487bbad9581","technicalName":"feature-alpha","expiresOn":1664539200000,"description":"Checkout flow","inverted":false,"killed":false,"createdAt":1664366400000,"updatedAt":1664366400000,"tags":["checkout"],"kind":"ops"}`
+		betaJSON  = `{"id":"bb7fe5b6-24a5-4218-bc61-b487bbad9582","technicalName":"feature-beta","inverted":true,"killed":false,"createdAt":1664452800000,"updatedAt":1664452800000}`
+		gammaJSON = `{"id":"bb7fe5b6-24a5-4218-bc61-b487bbad9583","displayName":"Gamma","technicalName":"feature-gamma","expiresOn":1664712000000,"inverted":false,"killed":false,"createdAt":1664539200000,"updatedAt":1664539200000,"tags":["checkout","mobile"],"owner":"Team-Payments","kind":"release"}`
+	)
+
+	var (
+		nameCursor   = FeatureCursor{Sort: SortByName, Name: "feature-beta", ID: betaUUID}
+		expiryCursor = FeatureCursor{Sort: SortByExpiry, Descending: true, Time: &oneDayAgo, ID: alphaUUID}
+	)
+
+	tests := map[string]struct {
+		query string
+
+		wantStatus int
+		wantBody   string
+	}{
+		"list features sorted by name": {
+			wantStatus: http.StatusOK,
+			wantBody:   `{"features":[` + alphaJSON + `,` + betaJSON + `,` + gammaJSON + `],"total":3}`,
+		},
+		"list first page": {
+			query: "?limit=2",
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"features":[` + alphaJSON + `,` + betaJSON + `],"total":3,"nextCursor":"` + nameCursor.String() + `"}`,
+		},
+		"list next page": {
+			query: "?limit=2&cursor=" + nameCursor.String(),
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"features":[` + gammaJSON + `],"total":3}`,
+		},
+		"sort by creation time, descending": {
+			query: "?sort=-created",
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"features":[` + gammaJSON + `,` + betaJSON + `,` + alphaJSON + `],"total":3}`,
+		},
+		"sort by expiry, descending, features without expiry last": {
+			query: "?sort=-expiry&limit=2",
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"features":[` + gammaJSON + `,` + alphaJSON + `],"total":3,"nextCursor":"` + expiryCursor.String() + `"}`,
+		},
+		"list next page sorted by expiry": {
+			query: "?sort=-expiry&limit=2&cursor=" + expiryCursor.String(),
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"features":[` + betaJSON + `],"total":3}`,
+		},
+		"search ignoring case": {
+			query: "?q=CHECKOUT",
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"features":[` + alphaJSON + `],"total":1}`,
+		},
+		"search wildcards match literally": {
+			query: "?q=%25",
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"features":[],"total":0}`,
+		},
+		"filter expired features": {
+			query: "?expired=true",
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"features":[` + alphaJSON + `],"total":1}`,
+		},
+		"filter non-expired, non-inverted features": {
+			query: "?expired=false&inverted=false",
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"features":[` + gammaJSON + `],"total":1}`,
+		},
+		"filter features of customer": {
+			query: "?customerId=1234",
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"features":[` + betaJSON + `],"total":1}`,
+		},
+		"filter features by tag": {
+			query: "?tag=checkout",
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"features":[` + alphaJSON + `,` + gammaJSON + `],"total":2}`,
+		},
+		"filter features having all tags": {
+			query: "?tag=checkout&tag=mobile",
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"features":[` + gammaJSON + `],"total":1}`,
+		},
+		"filter features by owner, ignoring case": {
+			query: "?owner=team-payments",
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"features":[` + gammaJSON + `],"total":1}`,
+		},
+		"filter features by kind": {
+			query: "?kind=ops",
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"features":[` + alphaJSON + `],"total":1}`,
+		},
+		"unknown kind": {
+			query: "?kind=forever",
+
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"type":"urn:feature:problem:bad-request","title":"Bad Request","status":400,"detail":"unknown kind \"forever\"","code":"bad-request"}`,
+		},
+		"unknown sort": {
+			query: "?sort=size",
+
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"type":"urn:feature:problem:bad-request","title":"Bad Request","status":400,"detail":"unknown sort \"size\"","code":"bad-request"}`,
+		},
+		"limit too large": {
+			query: "?limit=501",
+
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"type":"urn:feature:problem:bad-request","title":"Bad Request","status":400,"detail":"'limit' must be between 1 and 500","code":"bad-request"}`,
+		},
+		"cursor of another sort": {
+			query: "?sort=created&cursor=" + nameCursor.String(),
+
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"type":"urn:feature:problem:bad-request","title":"Bad Request","status":400,"detail":"cursor does not match the sort","code":"bad-request"}`,
+		},
+		"bad cursor": {
+			query: "?cursor=bad",
+
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"type":"urn:feature:problem:bad-request","title":"Bad Request","status":400,"detail":"parse cursor: decode cursor: invalid character 'm' looking for beginning of value","code":"bad-request"}`,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tx, _, rollback, err := store.beginTx(context.Background(), &sql.TxOptions{
+				Isolation: sql.LevelReadCommitted,
+			})
+			if err != nil {
+				t.Fatalf("failed to begin transaction: %s\n", err)
+			}
+
+			t.Cleanup(func() {
+				if err := rollback(); err != nil {
+					t.Errorf("failed to rollback the transaction: %s\n", err)
+				}
+			})
+
+			setupFeatures(t, *tx, features...)
+			setupCustomers(t, *tx, customers...)
+
+			service := NewService(*tx)
+			service.timeFunc = func() time.Time { return refTime }
+			handler := NewHandler(service)
+
+			r := chi.NewRouter()
+			r.Get("/features", handler.ListFeatures)
+
+			req := httptest.NewRequest(
+				http.MethodGet,
+				"/features"+test.query,
+				nil,
+			)
+			res := httptest.NewRecorder()
+
+			r.ServeHTTP(res, req)
+
+			if res.Code != test.wantStatus {
+				t.Errorf("Status codes not equal.\nwant: %d\ngot:  %d", test.wantStatus, res.Code)
+			}
+
+			resBody := strings.TrimSpace(res.Body.String())
+			if resBody != test.wantBody {
+				t.Errorf("Response bodies not equal.\nwant: %s\ngot:  %s", test.wantBody, resBody)
+			}
+		})
+	}
+}
+
+func TestListFeaturesNotModified(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		refTime = time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+		now     = refTime
+		store   = NewMemoryStore()
+		service = NewService(store)
+	)
+	service.timeFunc = func() time.Time { return now }
+
+	expiresOn := refTime.AddDate(0, 0, 1)
+	f := Feature{TechnicalName: "feature-alpha", ExpiresOn: &expiresOn}
+	if err := service.saveFeature(ctx, f); err != nil {
+		t.Fatal(err)
+	}
+
+	r := chi.NewRouter()
+	r.Get("/features", NewHandler(service).ListFeatures)
+
+	get := func(t *testing.T, query, ifNoneMatch string, wantStatus int) string {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/features"+query, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		res := httptest.NewRecorder()
+
+		r.ServeHTTP(res, req)
+
+		if res.Code != wantStatus {
+			t.Errorf("Status codes not equal.\nwant: %d\ngot:  %d", wantStatus, res.Code)
+		}
+		if res.Code == http.StatusNotModified && res.Body.Len() != 0 {
+			t.Errorf("Expected no body, got: %s", res.Body.String())
+		}
+		if cc := res.Header().Get("Cache-Control"); cc != "no-cache" {
+			t.Errorf("Expected Cache-Control no-cache, got: %q", cc)
+		}
+		return res.Header().Get("ETag")
+	}
+
+	etag := get(t, "", "", http.StatusOK)
+	if etag == "" {
+		t.Fatal("Expected an ETag")
+	}
+
+	// Unchanged configuration is answered from the cache.
+	if got := get(t, "", etag, http.StatusNotModified); got != etag {
+		t.Errorf("ETags not equal.\nwant: %s\ngot:  %s", etag, got)
+	}
+	get(t, "", `"other", `+strings.TrimPrefix(etag, "W/"), http.StatusNotModified)
+
+	// Other queries get other pages.
+	get(t, "?limit=1", etag, http.StatusOK)
+
+	// Features expiring change the result of filters on expiry.
+	now = refTime.AddDate(0, 0, 2)
+	expiredETag := get(t, "", etag, http.StatusOK)
+	if expiredETag == etag {
+		t.Errorf("Expected a new ETag once the feature expired, got: %s", etag)
+	}
+
+	// So does every mutation.
+	page, err := store.FindFeatures(ctx, FeatureQuery{Limit: 1, Sort: SortByName}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := service.killFeature(ctx, page.Features[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	get(t, "", expiredETag, http.StatusOK)
+}
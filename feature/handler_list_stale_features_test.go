@@ -0,0 +1,390 @@
+package feature
+
+import (
+	"context"
+	"database/sql"
+	"feature/pkg/config"
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestListStaleFeatures(t *testing.T) {
+	viper.SetConfigFile("../test.env")
+	if err := viper.ReadInConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open(config.Driver(), config.DSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewStore(db)
+
+	var (
+		existingUUID = uuid.MustParse("bb7fe5b6-24a5-4218-bc61-b487bbad9580")
+		refTime      = time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+		oneDayAgo    = refTime.AddDate(0, 0, -1)
+		monthAgo     = refTime.AddDate(0, 0, -31)
+	)
+
+	tests := map[string]struct {
+		features  []Feature
+		customers []Customer
+		aliases   []Alias
+		usage     []UsageDelta
+
+		query string
+
+		wantStatus int
+		wantBody   string
+	}{
+		"report feature that has never been evaluated": {
+			features: []Feature{{
+				ID:            existingUUID,
+				TechnicalName: "feature-1",
+				CreatedAt:     monthAgo,
+				UpdatedAt:     oneDayAgo,
+			}},
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"features":[{"id":"bb7fe5b6-24a5-4218-bc61-b487bbad9580","technicalName":"feature-1","inverted":false,"killed":false,"createdAt":1661947200000,"updatedAt":1664539200000,"reasons":["unused"],"evaluationCount":0}]}`,
+		},
+		"report feature that hasn't been evaluated recently": {
+			features: []Feature{{
+				ID:            existingUUID,
+				TechnicalName: "feature-1",
+				CreatedAt:     monthAgo,
+				UpdatedAt:     oneDayAgo,
+			}},
+			usage: []UsageDelta{{
+				TechnicalName:   "feature-1",
+				Day:             monthAgo.Format(usageDayLayout),
+				Count:           3,
+				LastEvaluatedAt: monthAgo,
+			}},
+
+			query: "?days=7",
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"features":[{"id":"bb7fe5b6-24a5-4218-bc61-b487bbad9580","technicalName":"feature-1","inverted":false,"killed":false,"createdAt":1661947200000,"updatedAt":1664539200000,"reasons":["unused"],"lastEvaluatedAt":1661947200000,"evaluationCount":0}]}`,
+		},
+		"report expired feature that is still being evaluated": {
+			features: []Feature{{
+				ID:            existingUUID,
+				TechnicalName: "feature-1",
+				ExpiresOn:     &oneDayAgo,
+				CreatedAt:     monthAgo,
+				UpdatedAt:     oneDayAgo,
+			}},
+			usage: []UsageDelta{{
+				TechnicalName:   "feature-1",
+				Day:             refTime.Format(usageDayLayout),
+				Count:           5,
+				LastEvaluatedAt: refTime,
+			}},
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"features":[{"id":"bb7fe5b6-24a5-4218-bc61-b487bbad9580","technicalName":"feature-1","expiresOn":1664539200000,"inverted":false,"killed":false,"createdAt":1661947200000,"updatedAt":1664539200000,"reasons":["expired"],"lastEvaluatedAt":1664625600000,"evaluationCount":5}]}`,
+		},
+		"report feature that has been off for everyone for a long time": {
+			features: []Feature{{
+				ID:            existingUUID,
+				TechnicalName: "feature-1",
+				Inverted:      true,
+				CreatedAt:     monthAgo,
+				UpdatedAt:     monthAgo,
+			}},
+			customers: []Customer{{
+				ID:         existingUUID,
+				FeatureID:  existingUUID,
+				CustomerID: "customer-1",
+			}},
+			usage: []UsageDelta{{
+				TechnicalName:   "feature-1",
+				Day:             refTime.Format(usageDayLayout),
+				Count:           1,
+				LastEvaluatedAt: refTime,
+			}},
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"features":[{"id":"bb7fe5b6-24a5-4218-bc61-b487bbad9580","technicalName":"feature-1","inverted":true,"killed":false,"createdAt":1661947200000,"updatedAt":1661947200000,"reasons":["static"],"lastEvaluatedAt":1664625600000,"evaluationCount":1}]}`,
+		},
+		"report feature that has been killed for a long time": {
+			features: []Feature{{
+				ID:            existingUUID,
+				TechnicalName: "feature-1",
+				Killed:        true,
+				KilledAt:      &monthAgo,
+				CreatedAt:     monthAgo,
+				UpdatedAt:     monthAgo,
+			}},
+			customers: []Customer{{
+				ID:         existingUUID,
+				FeatureID:  existingUUID,
+				CustomerID: "customer-1",
+			}},
+			usage: []UsageDelta{{
+				TechnicalName:   "feature-1",
+				Day:             refTime.Format(usageDayLayout),
+				Count:           1,
+				LastEvaluatedAt: refTime,
+			}},
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"features":[{"id":"bb7fe5b6-24a5-4218-bc61-b487bbad9580","technicalName":"feature-1","inverted":false,"killed":true,"createdAt":1661947200000,"updatedAt":1661947200000,"reasons":["static"],"lastEvaluatedAt":1664625600000,"evaluationCount":1}]}`,
+		},
+		"don't report old feature that has been killed recently": {
+			features: []Feature{{
+				ID:            existingUUID,
+				TechnicalName: "feature-1",
+				Killed:        true,
+				KilledAt:      &oneDayAgo,
+				CreatedAt:     monthAgo,
+				UpdatedAt:     monthAgo,
+			}},
+			customers: []Customer{{
+				ID:         existingUUID,
+				FeatureID:  existingUUID,
+				CustomerID: "customer-1",
+			}},
+			usage: []UsageDelta{{
+				TechnicalName:   "feature-1",
+				Day:             refTime.Format(usageDayLayout),
+				Count:           1,
+				LastEvaluatedAt: refTime,
+			}},
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"features":[]}`,
+		},
+		"don't report feature that is in active use": {
+			features: []Feature{{
+				ID:            existingUUID,
+				TechnicalName: "feature-1",
+				CreatedAt:     monthAgo,
+				UpdatedAt:     monthAgo,
+			}},
+			customers: []Customer{{
+				ID:         existingUUID,
+				FeatureID:  existingUUID,
+				CustomerID: "customer-1",
+			}},
+			usage: []UsageDelta{{
+				TechnicalName:   "feature-1",
+				Day:             refTime.Format(usageDayLayout),
+				Count:           1,
+				LastEvaluatedAt: refTime,
+			}},
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"features":[]}`,
+		},
+		"don't report feature that targets customers by rules only": {
+			features: []Feature{{
+				ID:            existingUUID,
+				TechnicalName: "feature-1",
+				Rules:         []TargetingRule{{Attribute: "plan", Values: []string{"enterprise"}}},
+				CreatedAt:     monthAgo,
+				UpdatedAt:     monthAgo,
+			}},
+			usage: []UsageDelta{{
+				TechnicalName:   "feature-1",
+				Day:             refTime.Format(usageDayLayout),
+				Count:           1,
+				LastEvaluatedAt: refTime,
+			}},
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"features":[]}`,
+		},
+		"report usage of former names along with the feature": {
+			features: []Feature{{
+				ID:            existingUUID,
+				TechnicalName: "feature-2",
+				ExpiresOn:     &oneDayAgo,
+				CreatedAt:     monthAgo,
+				UpdatedAt:     oneDayAgo,
+			}},
+			aliases: []Alias{{
+				TechnicalName: "feature-1",
+				FeatureID:     existingUUID,
+				CreatedAt:     oneDayAgo,
+			}},
+			usage: []UsageDelta{
+				{
+					TechnicalName:   "feature-1",
+					Day:             refTime.Format(usageDayLayout),
+					Count:           4,
+					LastEvaluatedAt: refTime,
+				},
+				{
+					TechnicalName:   "feature-2",
+					Day:             oneDayAgo.Format(usageDayLayout),
+					Count:           1,
+					LastEvaluatedAt: oneDayAgo,
+				},
+			},
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"features":[{"id":"bb7fe5b6-24a5-4218-bc61-b487bbad9580","technicalName":"feature-2","expiresOn":1664539200000,"inverted":false,"killed":false,"createdAt":1661947200000,"updatedAt":1664539200000,"reasons":["expired"],"lastEvaluatedAt":1664625600000,"evaluationCount":5}]}`,
+		},
+		"don't report recently created feature that hasn't been evaluated yet": {
+			features: []Feature{{
+				ID:            existingUUID,
+				TechnicalName: "feature-1",
+				CreatedAt:     oneDayAgo,
+				UpdatedAt:     oneDayAgo,
+			}},
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"features":[]}`,
+		},
+		"non-positive days": {
+			query: "?days=0",
+
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"type":"urn:feature:problem:bad-request","title":"Bad Request","status":400,"detail":"'days' must be a positive integer","code":"bad-request"}`,
+		},
+		"bad days": {
+			query: "?days=bad",
+
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"type":"urn:feature:problem:bad-request","title":"Bad Request","status":400,"detail":"parse days: strconv.Atoi: parsing \"bad\": invalid syntax","code":"bad-request"}`,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tx, _, rollback, err := store.beginTx(context.Background(), &sql.TxOptions{
+				Isolation: sql.LevelReadCommitted,
+			})
+			if err != nil {
+				t.Fatalf("failed to begin transaction: %s\n", err)
+			}
+
+			t.Cleanup(func() {
+				if err := rollback(); err != nil {
+					t.Errorf("failed to rollback the transaction: %s\n", err)
+				}
+			})
+
+			setupFeatures(t, *tx, test.features...)
+			setupCustomers(t, *tx, test.customers...)
+			setupAliases(t, *tx, test.aliases...)
+			setupUsage(t, *tx, test.usage...)
+
+			service := NewService(*tx)
+			service.timeFunc = func() time.Time { return refTime }
+			handler := NewHandler(service)
+
+			r := chi.NewRouter()
+			r.Get("/features/stale", handler.ListStaleFeatures)
+
+			req := httptest.NewRequest(
+				http.MethodGet,
+				"/features/stale"+test.query,
+				nil,
+			)
+			res := httptest.NewRecorder()
+
+			r.ServeHTTP(res, req)
+
+			if res.Code != test.wantStatus {
+				t.Errorf("Status codes not equal.\nwant: %d\ngot:  %d", test.wantStatus, res.Code)
+			}
+
+			resBody := strings.TrimSpace(res.Body.String())
+			if resBody != test.wantBody {
+				t.Errorf("Response bodies not equal.\nwant: %s\ngot:  %s", test.wantBody, resBody)
+			}
+		})
+	}
+}
+
+func TestFlushUsage(t *testing.T) {
+	viper.SetConfigFile("../test.env")
+	if err := viper.ReadInConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open(config.Driver(), config.DSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewStore(db)
+
+	tx, _, rollback, err := store.beginTx(context.Background(), &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+	if err != nil {
+		t.Fatalf("failed to begin transaction: %s\n", err)
+	}
+
+	t.Cleanup(func() {
+		if err := rollback(); err != nil {
+			t.Errorf("failed to rollback the transaction: %s\n", err)
+		}
+	})
+
+	var (
+		refTime   = time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+		oneDayAgo = refTime.AddDate(0, 0, -1)
+	)
+
+	setupUsage(t, *tx, UsageDelta{
+		TechnicalName:   "feature-1",
+		Day:             oneDayAgo.Format(usageDayLayout),
+		Count:           2,
+		LastEvaluatedAt: oneDayAgo,
+	})
+
+	service := NewService(*tx)
+	service.timeFunc = func() time.Time { return refTime }
+
+	service.usage.record(refTime, "feature-1", "feature-2")
+	service.usage.record(refTime.Add(time.Second), "feature-1")
+
+	if err := service.flushUsage(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if ds := service.usage.drain(); len(ds) != 0 {
+		t.Errorf("Usage not drained after flush: %v", ds)
+	}
+
+	got, err := tx.FindUsage(context.Background(), refTime.Add(-usageWindow))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]Usage{
+		"feature-1": {TechnicalName: "feature-1", LastEvaluatedAt: refTime.Add(time.Second), EvaluationCount: 4},
+		"feature-2": {TechnicalName: "feature-2", LastEvaluatedAt: refTime, EvaluationCount: 1},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Usage not equal.\nwant: %v\ngot:  %v", want, got)
+	}
+	for _, u := range got {
+		if w := want[u.TechnicalName]; w.EvaluationCount != u.EvaluationCount || !w.LastEvaluatedAt.Equal(u.LastEvaluatedAt) {
+			t.Errorf("Usage of %s not equal.\nwant: %v\ngot:  %v", u.TechnicalName, w, u)
+		}
+	}
+}
+
+func setupUsage(t *testing.T, store Store, ds ...UsageDelta) {
+	t.Helper()
+	if err := store.SaveUsage(context.Background(), ds...); err != nil {
+		t.Fatalf("failed to set up feature usage tables: %s", err)
+	}
+}
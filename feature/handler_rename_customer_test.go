@@ -0,0 +1,221 @@
+package feature
+
+import (
+	"context"
+	"database/sql"
+	"feature/pkg/config"
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenameCustomer(t *testing.T) {
+	viper.SetConfigFile("../test.env")
+	if err := viper.ReadInConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open(config.Driver(), config.DSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewStore(db)
+
+	var (
+		feature1UUID = uuid.MustParse("bb7fe5b6-24a5-4218-bc61-b487bbad9580")
+		feature2UUID = uuid.MustParse("0b8e2f9a-5d6c-4b0e-9f3c-2a1d7e6b5c4d")
+		customer1    = uuid.MustParse("7b5b9b8e-2b4e-4c77-9d4b-5f0e8f3c6d01")
+		customer2    = uuid.MustParse("7b5b9b8e-2b4e-4c77-9d4b-5f0e8f3c6d02")
+		customer3    = uuid.MustParse("7b5b9b8e-2b4e-4c77-9d4b-5f0e8f3c6d03")
+		createdAt    = time.Now().AddDate(0, 0, -7).Truncate(time.Second).UTC()
+		refTime      = time.Now().Truncate(time.Second).UTC()
+	)
+
+	features := []Feature{
+		{ID: feature1UUID, TechnicalName: "feature-1", CreatedAt: refTime, UpdatedAt: refTime},
+		{ID: feature2UUID, TechnicalName: "feature-2", CreatedAt: refTime, UpdatedAt: refTime},
+	}
+
+	profile := CustomerProfile{
+		ID:          "old",
+		DisplayName: ptr("Acme"),
+		Attributes:  map[string]string{"plan": "free"},
+		CreatedAt:   createdAt,
+		UpdatedAt:   createdAt,
+	}
+
+	tests := map[string]struct {
+		customers []Customer
+		profiles  []CustomerProfile
+
+		customerID string
+		query      string
+		body       string
+
+		wantStatus    int
+		wantBody      string
+		wantCustomers []Customer
+		wantProfiles  []CustomerProfile
+	}{
+		"rename a customer in every feature": {
+			customers: []Customer{
+				{ID: customer1, FeatureID: feature1UUID, CustomerID: "old"},
+				{ID: customer2, FeatureID: feature2UUID, CustomerID: "old"},
+			},
+			profiles: []CustomerProfile{profile},
+
+			customerID: "old",
+			body:       `{"to":"new"}`,
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"dryRun":false,"from":"old","to":"new","features":[{"id":"bb7fe5b6-24a5-4218-bc61-b487bbad9580","technicalName":"feature-1","merged":false},{"id":"0b8e2f9a-5d6c-4b0e-9f3c-2a1d7e6b5c4d","technicalName":"feature-2","merged":false}]}`,
+			wantCustomers: []Customer{
+				{ID: customer1, FeatureID: feature1UUID, CustomerID: "new"},
+				{ID: customer2, FeatureID: feature2UUID, CustomerID: "new"},
+			},
+			wantProfiles: []CustomerProfile{{
+				ID:          "new",
+				DisplayName: ptr("Acme"),
+				Attributes:  map[string]string{"plan": "free"},
+				CreatedAt:   createdAt,
+				UpdatedAt:   refTime,
+			}},
+		},
+		"dry run reports features without renaming": {
+			customers: []Customer{
+				{ID: customer1, FeatureID: feature1UUID, CustomerID: "old"},
+			},
+			profiles: []CustomerProfile{profile},
+
+			customerID: "old",
+			query:      "?dryRun=true",
+			body:       `{"to":"new"}`,
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"dryRun":true,"from":"old","to":"new","features":[{"id":"bb7fe5b6-24a5-4218-bc61-b487bbad9580","technicalName":"feature-1","merged":false}]}`,
+			wantCustomers: []Customer{
+				{ID: customer1, FeatureID: feature1UUID, CustomerID: "old"},
+			},
+			wantProfiles: []CustomerProfile{profile},
+		},
+		"merge two customers": {
+			customers: []Customer{
+				{ID: customer1, FeatureID: feature1UUID, CustomerID: "old"},
+				{ID: customer2, FeatureID: feature2UUID, CustomerID: "old"},
+				{ID: customer3, FeatureID: feature2UUID, CustomerID: "new"},
+			},
+			profiles: []CustomerProfile{
+				{ID: "new", Attributes: map[string]string{}, CreatedAt: createdAt, UpdatedAt: createdAt},
+				profile,
+			},
+
+			customerID: "old",
+			body:       `{"to":"new","merge":true}`,
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"dryRun":false,"from":"old","to":"new","features":[{"id":"bb7fe5b6-24a5-4218-bc61-b487bbad9580","technicalName":"feature-1","merged":false},{"id":"0b8e2f9a-5d6c-4b0e-9f3c-2a1d7e6b5c4d","technicalName":"feature-2","merged":true}]}`,
+			wantCustomers: []Customer{
+				{ID: customer1, FeatureID: feature1UUID, CustomerID: "new"},
+				{ID: customer3, FeatureID: feature2UUID, CustomerID: "new"},
+			},
+			wantProfiles: []CustomerProfile{
+				{ID: "new", Attributes: map[string]string{}, CreatedAt: createdAt, UpdatedAt: createdAt},
+			},
+		},
+		"new customer id already in use": {
+			customers: []Customer{
+				{ID: customer1, FeatureID: feature1UUID, CustomerID: "old"},
+				{ID: customer3, FeatureID: feature2UUID, CustomerID: "new"},
+			},
+
+			customerID: "old",
+			body:       `{"to":"new"}`,
+
+			wantStatus: http.StatusConflict,
+			wantBody:   `{"type":"urn:feature:problem:customer-exists","title":"Conflict","status":409,"detail":"customer \"new\" already exists, merge instead","code":"customer-exists"}`,
+			wantCustomers: []Customer{
+				{ID: customer1, FeatureID: feature1UUID, CustomerID: "old"},
+				{ID: customer3, FeatureID: feature2UUID, CustomerID: "new"},
+			},
+		},
+		"unknown customer": {
+			customerID: "old",
+			body:       `{"to":"new"}`,
+
+			wantStatus: http.StatusNotFound,
+			wantBody:   `{"type":"urn:feature:problem:customer-not-found","title":"Not Found","status":404,"detail":"customer \"old\" does not exist","code":"customer-not-found"}`,
+		},
+		"rename a customer to itself": {
+			customerID: "old",
+			body:       `{"to":"old"}`,
+
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"type":"urn:feature:problem:bad-request","title":"Bad Request","status":400,"detail":"'to' must differ from the renamed customer id","code":"bad-request"}`,
+		},
+		"invalid new customer id": {
+			customerID: "old",
+			body:       `{"to":"new customer"}`,
+
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"type":"urn:feature:problem:bad-request","title":"Bad Request","status":400,"detail":"'to' must be 1 to 255 characters long, without whitespace","code":"bad-request"}`,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tx, _, rollback, err := store.beginTx(context.Background(), &sql.TxOptions{
+				Isolation: sql.LevelReadCommitted,
+			})
+			if err != nil {
+				t.Fatalf("failed to begin transaction: %s\n", err)
+			}
+
+			t.Cleanup(func() {
+				if err := rollback(); err != nil {
+					t.Errorf("failed to rollback the transaction: %s\n", err)
+				}
+			})
+
+			setupFeatures(t, *tx, features...)
+			setupCustomers(t, *tx, test.customers...)
+			setupCustomerProfiles(t, *tx, test.profiles...)
+
+			service := NewService(*tx)
+			service.timeFunc = func() time.Time { return refTime }
+			handler := NewHandler(service)
+
+			r := chi.NewRouter()
+			r.Post("/customers/{customerId}/rename", handler.RenameCustomer)
+
+			req := httptest.NewRequest(
+				http.MethodPost,
+				"/customers/"+test.customerID+"/rename"+test.query,
+				strings.NewReader(test.body),
+			)
+			res := httptest.NewRecorder()
+
+			r.ServeHTTP(res, req)
+
+			if res.Code != test.wantStatus {
+				t.Errorf("Status codes not equal.\nwant: %d\ngot:  %d", test.wantStatus, res.Code)
+			}
+
+			resBody := strings.TrimSpace(res.Body.String())
+			if resBody != test.wantBody {
+				t.Errorf("Response bodies not equal.\nwant: %s\ngot:  %s", test.wantBody, resBody)
+			}
+
+			assertCustomers(t, *tx, test.wantCustomers...)
+			assertCustomerProfiles(t, *tx, test.wantProfiles...)
+		})
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/spf13/viper"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -20,7 +21,7 @@ func TestRequestFeaturesAsCustomer(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	db, err := sql.Open("sqlite3", config.DSN())
+	db, err := sql.Open(config.Driver(), config.DSN())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -32,29 +33,33 @@ func TestRequestFeaturesAsCustomer(t *testing.T) {
 		//generatedUUID = uuid.MustParse("44eeeacf-8d5d-4c68-bbe9-3e58c5ae6915")
 		refTime   = time.Now().Truncate(time.Second).UTC()
 		oneDayAgo = time.Now().Truncate(time.Second).AddDate(0, 0, -1).UTC()
+		refMillis = strconv.FormatInt(refTime.UnixMilli(), 10)
 		//expiryDate    = time.Now().Truncate(time.Second).UTC()
 	)
 
 	tests := map[string]struct {
-		features  []feature
-		customers []customer
+		features  []Feature
+		customers []Customer
+		profiles  []CustomerProfile
+		aliases   []Alias
 		timeFunc  func() time.Time
 
-		body string
+		query string
+		body  string
 
 		wantStatus int
 		wantBody   string
 	}{
 		"successfully return inverted, non-expired feature the customer has": {
 			timeFunc: func() time.Time { return refTime },
-			features: []feature{{
+			features: []Feature{{
 				ID:            existingUUID,
 				TechnicalName: "feature-1",
 				Inverted:      true,
 				CreatedAt:     refTime,
 				UpdatedAt:     refTime,
 			}},
-			customers: []customer{{
+			customers: []Customer{{
 				ID:         existingUUID,
 				FeatureID:  existingUUID,
 				CustomerID: "1234",
@@ -63,17 +68,17 @@ func TestRequestFeaturesAsCustomer(t *testing.T) {
 			body: `{"featureRequest":{"customerId":"1234","features":[{"name":"feature-1"}]}}`,
 
 			wantStatus: http.StatusOK,
-			wantBody:   `{"features":[{"name":"feature-1","active":false,"inverted":true,"expired":false}]}`,
+			wantBody:   `{"features":[{"name":"feature-1","active":false,"inverted":true,"killed":false,"expired":false}]}`,
 		},
 		"successfully return non-inverted, non-expired feature the customer has": {
 			timeFunc: func() time.Time { return refTime },
-			features: []feature{{
+			features: []Feature{{
 				ID:            existingUUID,
 				TechnicalName: "feature-1",
 				CreatedAt:     refTime,
 				UpdatedAt:     refTime,
 			}},
-			customers: []customer{{
+			customers: []Customer{{
 				ID:         existingUUID,
 				FeatureID:  existingUUID,
 				CustomerID: "1234",
@@ -82,11 +87,11 @@ func TestRequestFeaturesAsCustomer(t *testing.T) {
 			body: `{"featureRequest":{"customerId":"1234","features":[{"name":"feature-1"}]}}`,
 
 			wantStatus: http.StatusOK,
-			wantBody:   `{"features":[{"name":"feature-1","active":true,"inverted":false,"expired":false}]}`,
+			wantBody:   `{"features":[{"name":"feature-1","active":true,"inverted":false,"killed":false,"expired":false}]}`,
 		},
 		"successfully return non-inverted, expired feature the customer doesn't have": {
 			timeFunc: func() time.Time { return refTime },
-			features: []feature{{
+			features: []Feature{{
 				ID:            existingUUID,
 				TechnicalName: "feature-1",
 				ExpiresOn:     &oneDayAgo,
@@ -101,11 +106,11 @@ func TestRequestFeaturesAsCustomer(t *testing.T) {
 			// {"name": "my-feature-d", "active": true, "inverted": false, "expired": true}
 			// -----------------------------------^^^^
 			// I assume this specification is false.
-			wantBody: `{"features":[{"name":"feature-1","active":false,"inverted":false,"expired":true}]}`,
+			wantBody: `{"features":[{"name":"feature-1","active":false,"inverted":false,"killed":false,"expired":true}]}`,
 		},
 		"successfully return inverted, non-expired feature the customer doesn't have": {
 			timeFunc: func() time.Time { return refTime },
-			features: []feature{{
+			features: []Feature{{
 				ID:            existingUUID,
 				TechnicalName: "feature-1",
 				Inverted:      true,
@@ -116,11 +121,11 @@ func TestRequestFeaturesAsCustomer(t *testing.T) {
 			body: `{"featureRequest":{"customerId":"1234","features":[{"name":"feature-1"}]}}`,
 
 			wantStatus: http.StatusOK,
-			wantBody:   `{"features":[{"name":"feature-1","active":false,"inverted":true,"expired":false}]}`,
+			wantBody:   `{"features":[{"name":"feature-1","active":false,"inverted":true,"killed":false,"expired":false}]}`,
 		},
 		"successfully return non-inverted, non-expired feature the customer doesn't have": {
 			timeFunc: func() time.Time { return refTime },
-			features: []feature{{
+			features: []Feature{{
 				ID:            existingUUID,
 				TechnicalName: "feature-1",
 				Inverted:      false,
@@ -131,7 +136,93 @@ func TestRequestFeaturesAsCustomer(t *testing.T) {
 			body: `{"featureRequest":{"customerId":"1234","features":[{"name":"feature-1"}]}}`,
 
 			wantStatus: http.StatusOK,
-			wantBody:   `{"features":[{"name":"feature-1","active":false,"inverted":false,"expired":false}]}`,
+			wantBody:   `{"features":[{"name":"feature-1","active":false,"inverted":false,"killed":false,"expired":false}]}`,
+		},
+		"successfully return killed, non-inverted feature the customer has": {
+			timeFunc: func() time.Time { return refTime },
+			features: []Feature{{
+				ID:            existingUUID,
+				TechnicalName: "feature-1",
+				Killed:        true,
+				CreatedAt:     refTime,
+				UpdatedAt:     refTime,
+			}},
+			customers: []Customer{{
+				ID:         existingUUID,
+				FeatureID:  existingUUID,
+				CustomerID: "1234",
+			}},
+
+			body: `{"featureRequest":{"customerId":"1234","features":[{"name":"feature-1"}]}}`,
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"features":[{"name":"feature-1","active":false,"inverted":false,"killed":true,"expired":false}]}`,
+		},
+		"successfully return feature requested by its former name as deprecated": {
+			timeFunc: func() time.Time { return refTime },
+			features: []Feature{{
+				ID:            existingUUID,
+				TechnicalName: "feature-1",
+				CreatedAt:     refTime,
+				UpdatedAt:     refTime,
+			}},
+			customers: []Customer{{
+				ID:         existingUUID,
+				FeatureID:  existingUUID,
+				CustomerID: "1234",
+			}},
+			aliases: []Alias{{
+				TechnicalName: "old-feature-1",
+				FeatureID:     existingUUID,
+				CreatedAt:     refTime,
+			}},
+
+			body: `{"featureRequest":{"customerId":"1234","features":[{"name":"old-feature-1"},{"name":"feature-1"}]}}`,
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"features":[{"name":"feature-1","active":true,"inverted":false,"killed":false,"expired":false},{"name":"old-feature-1","active":true,"inverted":false,"killed":false,"expired":false,"deprecated":true,"renamedTo":"feature-1"}]}`,
+		},
+		"successfully return feature targeting the customer by an attribute": {
+			timeFunc: func() time.Time { return refTime },
+			features: []Feature{{
+				ID:            existingUUID,
+				TechnicalName: "feature-1",
+				Rules:         []TargetingRule{{Attribute: "plan", Values: []string{"enterprise", "pro"}}},
+				CreatedAt:     refTime,
+				UpdatedAt:     refTime,
+			}},
+			profiles: []CustomerProfile{{
+				ID:         "1234",
+				Attributes: map[string]string{"plan": "pro"},
+				CreatedAt:  refTime,
+				UpdatedAt:  refTime,
+			}},
+
+			body: `{"featureRequest":{"customerId":"1234","features":[{"name":"feature-1"}]}}`,
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"features":[{"name":"feature-1","active":true,"inverted":false,"killed":false,"expired":false}]}`,
+		},
+		"successfully return feature whose rules don't match the attributes of the customer": {
+			timeFunc: func() time.Time { return refTime },
+			features: []Feature{{
+				ID:            existingUUID,
+				TechnicalName: "feature-1",
+				Rules:         []TargetingRule{{Attribute: "plan", Values: []string{"enterprise", "pro"}}},
+				CreatedAt:     refTime,
+				UpdatedAt:     refTime,
+			}},
+			profiles: []CustomerProfile{{
+				ID:         "1234",
+				Attributes: map[string]string{"plan": "free"},
+				CreatedAt:  refTime,
+				UpdatedAt:  refTime,
+			}},
+
+			body: `{"featureRequest":{"customerId":"1234","features":[{"name":"feature-1"}]}}`,
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"features":[{"name":"feature-1","active":false,"inverted":false,"killed":false,"expired":false}]}`,
 		},
 		"requested feature doesn't exist": {
 			timeFunc: func() time.Time { return refTime },
@@ -141,21 +232,152 @@ func TestRequestFeaturesAsCustomer(t *testing.T) {
 			wantStatus: http.StatusOK,
 			wantBody:   `{"features":[]}`,
 		},
+		"explain expired feature the customer has, and missing feature": {
+			timeFunc: func() time.Time { return refTime },
+			features: []Feature{{
+				ID:            existingUUID,
+				TechnicalName: "feature-1",
+				ExpiresOn:     &oneDayAgo,
+				CreatedAt:     refTime,
+				UpdatedAt:     refTime,
+			}},
+			customers: []Customer{{
+				ID:         existingUUID,
+				FeatureID:  existingUUID,
+				CustomerID: "1234",
+			}},
+
+			query: "?explain=true",
+			body:  `{"featureRequest":{"customerId":"1234","features":[{"name":"feature-2"},{"name":"feature-1"}]}}`,
+
+			wantStatus: http.StatusOK,
+			wantBody: `{"evaluatedAt":` + refMillis + `,"snapshotLoadedAt":` + refMillis + `,"features":[` +
+				`{"name":"feature-1","active":true,"inverted":false,"killed":false,"expired":true,"found":true,"steps":[` +
+				`{"check":"killed","result":false,"decisive":false,"detail":"the kill switch is off"},` +
+				`{"check":"membership","result":true,"decisive":true,"detail":"customer \"1234\" is listed in the customers of the feature"},` +
+				`{"check":"rules","result":false,"decisive":false,"detail":"the feature has no targeting rules"},` +
+				`{"check":"inversion","result":false,"decisive":false,"detail":"the feature is toggled on"},` +
+				`{"check":"expiry","result":true,"decisive":false,"detail":"the feature expired at ` + oneDayAgo.Format(time.RFC3339) + `, before the evaluation at ` + refTime.Format(time.RFC3339) + `; expiry is reported to clients, but doesn't turn the feature off"},` +
+				`{"check":"rollout","result":false,"decisive":false,"detail":"features have no percentage rollouts, so none was consulted"}]},` +
+				`{"name":"feature-2","active":false,"inverted":false,"killed":false,"expired":false,"found":false,"steps":[` +
+				`{"check":"exists","result":false,"decisive":true,"detail":"no feature is named \"feature-2\""}]}]}`,
+		},
+		"explain killed, inverted feature requested by its former name": {
+			timeFunc: func() time.Time { return refTime },
+			features: []Feature{{
+				ID:            existingUUID,
+				TechnicalName: "feature-1",
+				Inverted:      true,
+				Killed:        true,
+				CreatedAt:     refTime,
+				UpdatedAt:     refTime,
+			}},
+			aliases: []Alias{{
+				TechnicalName: "old-feature-1",
+				FeatureID:     existingUUID,
+				CreatedAt:     refTime,
+			}},
+
+			query: "?explain=true",
+			body:  `{"featureRequest":{"customerId":"1234","features":[{"name":"old-feature-1"}]}}`,
+
+			wantStatus: http.StatusOK,
+			wantBody: `{"evaluatedAt":` + refMillis + `,"snapshotLoadedAt":` + refMillis + `,"features":[` +
+				`{"name":"old-feature-1","active":false,"inverted":true,"killed":true,"expired":false,"deprecated":true,"renamedTo":"feature-1","found":true,"steps":[` +
+				`{"check":"alias","result":true,"decisive":false,"detail":"\"old-feature-1\" is a former name of \"feature-1\", which is evaluated instead"},` +
+				`{"check":"killed","result":true,"decisive":true,"detail":"the kill switch is on, so the feature is off for every customer"},` +
+				`{"check":"membership","result":false,"decisive":false,"detail":"customer \"1234\" is not listed in the customers of the feature"},` +
+				`{"check":"rules","result":false,"decisive":false,"detail":"the feature has no targeting rules"},` +
+				`{"check":"inversion","result":true,"decisive":false,"detail":"the feature is toggled off, so it is inactive even for listed customers"},` +
+				`{"check":"expiry","result":false,"decisive":false,"detail":"the feature doesn't expire"},` +
+				`{"check":"rollout","result":false,"decisive":false,"detail":"features have no percentage rollouts, so none was consulted"}]}]}`,
+		},
+		"explain feature targeting the customer by an attribute": {
+			timeFunc: func() time.Time { return refTime },
+			features: []Feature{{
+				ID:            existingUUID,
+				TechnicalName: "feature-1",
+				Rules: []TargetingRule{
+					{Attribute: "plan", Values: []string{"enterprise", "pro"}},
+					{Attribute: "region", Values: []string{"eu"}},
+				},
+				CreatedAt: refTime,
+				UpdatedAt: refTime,
+			}},
+			profiles: []CustomerProfile{{
+				ID:         "1234",
+				Attributes: map[string]string{"plan": "pro", "region": "us"},
+				CreatedAt:  refTime,
+				UpdatedAt:  refTime,
+			}},
+
+			query: "?explain=true",
+			body:  `{"featureRequest":{"customerId":"1234","features":[{"name":"feature-1"}]}}`,
+
+			wantStatus: http.StatusOK,
+			wantBody: `{"evaluatedAt":` + refMillis + `,"snapshotLoadedAt":` + refMillis + `,"features":[` +
+				`{"name":"feature-1","active":true,"inverted":false,"killed":false,"expired":false,"found":true,"steps":[` +
+				`{"check":"killed","result":false,"decisive":false,"detail":"the kill switch is off"},` +
+				`{"check":"membership","result":false,"decisive":false,"detail":"customer \"1234\" is not listed in the customers of the feature"},` +
+				`{"check":"rules","result":true,"decisive":true,"detail":"customer \"1234\" has plan \"pro\" in the customer directory, which the rule on plan targets"},` +
+				`{"check":"inversion","result":false,"decisive":false,"detail":"the feature is toggled on"},` +
+				`{"check":"expiry","result":false,"decisive":false,"detail":"the feature doesn't expire"},` +
+				`{"check":"rollout","result":false,"decisive":false,"detail":"features have no percentage rollouts, so none was consulted"}]}]}`,
+		},
+		"explain feature whose rules don't match the attributes of the customer": {
+			timeFunc: func() time.Time { return refTime },
+			features: []Feature{{
+				ID:            existingUUID,
+				TechnicalName: "feature-1",
+				Rules: []TargetingRule{
+					{Attribute: "plan", Values: []string{"enterprise", "pro"}},
+					{Attribute: "region", Values: []string{"eu"}},
+				},
+				CreatedAt: refTime,
+				UpdatedAt: refTime,
+			}},
+			profiles: []CustomerProfile{{
+				ID:         "1234",
+				Attributes: map[string]string{"plan": "free", "region": "us"},
+				CreatedAt:  refTime,
+				UpdatedAt:  refTime,
+			}},
+
+			query: "?explain=true",
+			body:  `{"featureRequest":{"customerId":"1234","features":[{"name":"feature-1"}]}}`,
+
+			wantStatus: http.StatusOK,
+			wantBody: `{"evaluatedAt":` + refMillis + `,"snapshotLoadedAt":` + refMillis + `,"features":[` +
+				`{"name":"feature-1","active":false,"inverted":false,"killed":false,"expired":false,"found":true,"steps":[` +
+				`{"check":"killed","result":false,"decisive":false,"detail":"the kill switch is off"},` +
+				`{"check":"membership","result":false,"decisive":true,"detail":"customer \"1234\" is not listed in the customers of the feature"},` +
+				`{"check":"rules","result":false,"decisive":true,"detail":"customer \"1234\" matches none of the rules, having plan \"free\", region \"us\" in the customer directory"},` +
+				`{"check":"inversion","result":false,"decisive":false,"detail":"the feature is toggled on"},` +
+				`{"check":"expiry","result":false,"decisive":false,"detail":"the feature doesn't expire"},` +
+				`{"check":"rollout","result":false,"decisive":false,"detail":"features have no percentage rollouts, so none was consulted"}]}]}`,
+		},
+		"bad explain": {
+			query: "?explain=maybe",
+			body:  `{"featureRequest":{"customerId":"1234","features":[{"name":"feature-1"}]}}`,
+
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"type":"urn:feature:problem:bad-request","title":"Bad Request","status":400,"detail":"parse explain: strconv.ParseBool: parsing \"maybe\": invalid syntax","code":"bad-request"}`,
+		},
 		"no features requested": {
 			body: `{"featureRequest":{"customerId":"1234"}}`,
 
 			wantStatus: http.StatusBadRequest,
-			wantBody:   `{"error":"no feature technical names given"}`,
+			wantBody:   `{"type":"urn:feature:problem:bad-request","title":"Bad Request","status":400,"detail":"no feature technical names given","code":"bad-request"}`,
 		},
 		"request body has unknown fields": {
 			body: `{"foo":"bar"}`,
 
 			wantStatus: http.StatusBadRequest,
-			wantBody:   `{"error":"decode request body: json: unknown field \"foo\""}`,
+			wantBody:   `{"type":"urn:feature:problem:bad-request","title":"Bad Request","status":400,"detail":"decode request body: json: unknown field \"foo\"","code":"bad-request"}`,
 		},
 		"missing request body": {
 			wantStatus: http.StatusBadRequest,
-			wantBody:   `{"error":"decode request body: EOF"}`,
+			wantBody:   `{"type":"urn:feature:problem:bad-request","title":"Bad Request","status":400,"detail":"decode request body: EOF","code":"bad-request"}`,
 		},
 	}
 
@@ -179,6 +401,8 @@ func TestRequestFeaturesAsCustomer(t *testing.T) {
 
 			setupFeatures(t, *tx, test.features...)
 			setupCustomers(t, *tx, test.customers...)
+			setupCustomerProfiles(t, *tx, test.profiles...)
+			setupAliases(t, *tx, test.aliases...)
 
 			service := NewService(*tx)
 			service.timeFunc = test.timeFunc
@@ -189,7 +413,7 @@ func TestRequestFeaturesAsCustomer(t *testing.T) {
 
 			req := httptest.NewRequest(
 				http.MethodPost,
-				"/",
+				"/"+test.query,
 				strings.NewReader(test.body),
 			)
 			res := httptest.NewRecorder()
@@ -207,3 +431,71 @@ func TestRequestFeaturesAsCustomer(t *testing.T) {
 		})
 	}
 }
+
+func TestRequestFeaturesAsCustomerNotModified(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		refTime = time.Now().Truncate(time.Second).UTC()
+		store   = NewMemoryStore()
+		service = NewService(store)
+	)
+	service.timeFunc = func() time.Time { return refTime }
+
+	if err := service.saveFeature(ctx, Feature{TechnicalName: "feature-1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	r := chi.NewRouter()
+	r.Post("/", NewHandler(service).RequestFeaturesAsCustomer)
+
+	request := func(t *testing.T, ifNoneMatch string, wantStatus int) string {
+		t.Helper()
+		req := httptest.NewRequest(
+			http.MethodPost,
+			"/",
+			strings.NewReader(`{"featureRequest":{"customerId":"1234","features":[{"name":"feature-1"}]}}`),
+		)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		res := httptest.NewRecorder()
+
+		r.ServeHTTP(res, req)
+
+		if res.Code != wantStatus {
+			t.Errorf("Status codes not equal.\nwant: %d\ngot:  %d", wantStatus, res.Code)
+		}
+		if res.Code == http.StatusNotModified && res.Body.Len() != 0 {
+			t.Errorf("Expected no body, got: %s", res.Body.String())
+		}
+		if cc := res.Header().Get("Cache-Control"); cc != "no-cache" {
+			t.Errorf("Expected Cache-Control no-cache, got: %q", cc)
+		}
+		return res.Header().Get("ETag")
+	}
+
+	etag := request(t, "", http.StatusOK)
+	if etag == "" {
+		t.Fatal("Expected an ETag")
+	}
+	if got := request(t, etag, http.StatusNotModified); got != etag {
+		t.Errorf("ETags not equal.\nwant: %s\ngot:  %s", etag, got)
+	}
+
+	// Evaluations answered with 304 still count as usage.
+	ds := service.usage.drain()
+	if len(ds) != 1 || ds[0].Count != 2 {
+		t.Errorf("Expected 2 evaluations of feature-1, got: %v", ds)
+	}
+
+	page, err := store.FindFeatures(ctx, FeatureQuery{Limit: 1, Sort: SortByName}, refTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.addCustomersToFeature(ctx, page.Features[0].ID, []string{"1234"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := request(t, etag, http.StatusOK); got == etag {
+		t.Errorf("Expected a new ETag once the customer was added, got: %s", got)
+	}
+}
@@ -21,7 +21,7 @@ func TestSaveArchivedFeature(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	db, err := sql.Open("sqlite3", config.DSN())
+	db, err := sql.Open(config.Driver(), config.DSN())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -37,17 +37,17 @@ func TestSaveArchivedFeature(t *testing.T) {
 	)
 
 	tests := map[string]struct {
-		features []feature
+		features []Feature
 		timeFunc func() time.Time
 
 		body string
 
 		wantStatus           int
 		wantBody             string
-		wantArchivedFeatures []archivedFeature
+		wantArchivedFeatures []ArchivedFeature
 	}{
 		"successfully archive a feature": {
-			features: []feature{{
+			features: []Feature{{
 				ID:            existingUUID,
 				DisplayName:   ptr("My Feature 1"),
 				TechnicalName: "my-feature-1",
@@ -62,7 +62,7 @@ func TestSaveArchivedFeature(t *testing.T) {
 			body: `{"featureId":"` + existingUUID.String() + `"}`,
 
 			wantStatus: http.StatusCreated,
-			wantArchivedFeatures: []archivedFeature{{
+			wantArchivedFeatures: []ArchivedFeature{{
 				ID:            existingUUID,
 				DisplayName:   ptr("My Feature 1"),
 				TechnicalName: "my-feature-1",
@@ -75,17 +75,17 @@ func TestSaveArchivedFeature(t *testing.T) {
 			body: `{"featureId":"` + existingUUID.String() + `"}`,
 
 			wantStatus: http.StatusNotFound,
-			wantBody:   `{"error":"find feature: feature bb7fe5b6-24a5-4218-bc61-b487bbad9580 does not exist"}`,
+			wantBody:   `{"type":"urn:feature:problem:feature-not-found","title":"Not Found","status":404,"detail":"feature bb7fe5b6-24a5-4218-bc61-b487bbad9580 does not exist","code":"feature-not-found"}`,
 		},
 		"request body contains unknown fields": {
 			body: `{"foo":"bar"}`,
 
 			wantStatus: http.StatusBadRequest,
-			wantBody:   `{"error":"decode request body: json: unknown field \"foo\""}`,
+			wantBody:   `{"type":"urn:feature:problem:bad-request","title":"Bad Request","status":400,"detail":"decode request body: json: unknown field \"foo\"","code":"bad-request"}`,
 		},
 		"missing request body": {
 			wantStatus: http.StatusBadRequest,
-			wantBody:   `{"error":"decode request body: EOF"}`,
+			wantBody:   `{"type":"urn:feature:problem:bad-request","title":"Bad Request","status":400,"detail":"decode request body: EOF","code":"bad-request"}`,
 		},
 	}
 
@@ -139,45 +139,9 @@ func TestSaveArchivedFeature(t *testing.T) {
 	}
 }
 
-func (s Store) findAllArchivedFeatures(ctx context.Context) ([]archivedFeature, error) {
-	rs, err := s.db.QueryContext(
-		ctx,
-		//language=sqlite
-		`SELECT id,display_name,technical_name,description,created_at,updated_at FROM archived_features`,
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	var afs []archivedFeature
-	for rs.Next() {
-		var af archivedFeature
-		if err := rs.Scan(
-			&af.ID,
-			&af.DisplayName,
-			&af.TechnicalName,
-			&af.Description,
-			&af.CreatedAt,
-			&af.UpdatedAt,
-		); err != nil {
-			return nil, err
-		}
-		afs = append(afs, af)
-	}
-
-	if err := rs.Err(); err != nil {
-		return nil, err
-	}
-
-	if err := rs.Close(); err != nil {
-		return nil, err
-	}
-	return afs, nil
-}
-
-func assertArchivedFeatures(t *testing.T, store Store, want ...archivedFeature) {
+func assertArchivedFeatures(t *testing.T, store Store, want ...ArchivedFeature) {
 	t.Helper()
-	got, err := store.findAllArchivedFeatures(context.Background())
+	got, err := store.FindAllArchivedFeatures(context.Background())
 	if err != nil {
 		t.Error(err)
 		return
@@ -0,0 +1,151 @@
+package feature
+
+import (
+	"context"
+	"database/sql"
+	"feature/pkg/config"
+	"github.com/go-chi/chi"
+	"github.com/spf13/viper"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSaveCustomer(t *testing.T) {
+	viper.SetConfigFile("../test.env")
+	if err := viper.ReadInConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open(config.Driver(), config.DSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewStore(db)
+
+	var (
+		createdAt = time.Now().AddDate(0, 0, -7).Truncate(time.Second).UTC()
+		refTime   = time.Now().Truncate(time.Second).UTC()
+	)
+
+	tests := map[string]struct {
+		profiles []CustomerProfile
+
+		customerID string
+		body       string
+
+		wantStatus   int
+		wantBody     string
+		wantProfiles []CustomerProfile
+	}{
+		"create a customer": {
+			customerID: "cust-9812",
+			body:       `{"displayName":" Acme Corp ","attributes":{"plan":"enterprise","region":" "}}`,
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"id":"cust-9812","displayName":"Acme Corp","attributes":{"plan":"enterprise"},"createdAt":` + strconv.FormatInt(refTime.UnixMilli(), 10) + `,"updatedAt":` + strconv.FormatInt(refTime.UnixMilli(), 10) + `}`,
+			wantProfiles: []CustomerProfile{{
+				ID:          "cust-9812",
+				DisplayName: ptr("Acme Corp"),
+				Attributes:  map[string]string{"plan": "enterprise"},
+				CreatedAt:   refTime,
+				UpdatedAt:   refTime,
+			}},
+		},
+		"replace a customer": {
+			profiles: []CustomerProfile{{
+				ID:          "cust-9812",
+				DisplayName: ptr("Acme"),
+				Attributes:  map[string]string{"plan": "free", "region": "eu"},
+				CreatedAt:   createdAt,
+				UpdatedAt:   createdAt,
+			}},
+
+			customerID: "cust-9812",
+			body:       `{"attributes":{"plan":"enterprise"}}`,
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"id":"cust-9812","displayName":null,"attributes":{"plan":"enterprise"},"createdAt":` + strconv.FormatInt(createdAt.UnixMilli(), 10) + `,"updatedAt":` + strconv.FormatInt(refTime.UnixMilli(), 10) + `}`,
+			wantProfiles: []CustomerProfile{{
+				ID:         "cust-9812",
+				Attributes: map[string]string{"plan": "enterprise"},
+				CreatedAt:  createdAt,
+				UpdatedAt:  refTime,
+			}},
+		},
+		"customer id with whitespace": {
+			customerID: "cust%209812",
+			body:       `{}`,
+
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"type":"urn:feature:problem:bad-request","title":"Bad Request","status":400,"detail":"'id' must be 1 to 255 characters long, without whitespace","code":"bad-request"}`,
+		},
+		"empty attribute name": {
+			customerID: "cust-9812",
+			body:       `{"attributes":{" ":"enterprise"}}`,
+
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"type":"urn:feature:problem:bad-request","title":"Bad Request","status":400,"detail":"attribute names must not be empty","code":"bad-request"}`,
+		},
+		"request body contains unknown fields": {
+			customerID: "cust-9812",
+			body:       `{"foo":"bar"}`,
+
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"type":"urn:feature:problem:bad-request","title":"Bad Request","status":400,"detail":"decode request body: json: unknown field \"foo\"","code":"bad-request"}`,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tx, _, rollback, err := store.beginTx(context.Background(), &sql.TxOptions{
+				Isolation: sql.LevelReadCommitted,
+			})
+			if err != nil {
+				t.Fatalf("failed to begin transaction: %s\n", err)
+			}
+
+			t.Cleanup(func() {
+				if err := rollback(); err != nil {
+					t.Errorf("failed to rollback the transaction: %s\n", err)
+				}
+			})
+
+			setupCustomerProfiles(t, *tx, test.profiles...)
+
+			service := NewService(*tx)
+			service.timeFunc = func() time.Time { return refTime }
+			handler := NewHandler(service)
+
+			r := chi.NewRouter()
+			r.Put("/customers/{customerId}", handler.SaveCustomer)
+
+			req := httptest.NewRequest(
+				http.MethodPut,
+				"/customers/"+test.customerID,
+				strings.NewReader(test.body),
+			)
+			res := httptest.NewRecorder()
+
+			r.ServeHTTP(res, req)
+
+			if res.Code != test.wantStatus {
+				t.Errorf("Status codes not equal.\nwant: %d\ngot:  %d", test.wantStatus, res.Code)
+			}
+
+			resBody := strings.TrimSpace(res.Body.String())
+			if resBody != test.wantBody {
+				t.Errorf("Response bodies not equal.\nwant: %s\ngot:  %s", test.wantBody, resBody)
+			}
+
+			assertCustomerProfiles(t, *tx, test.wantProfiles...)
+		})
+	}
+}
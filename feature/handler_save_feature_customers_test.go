@@ -21,7 +21,7 @@ func TestSaveFeatureCustomers(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	db, err := sql.Open("sqlite3", config.DSN())
+	db, err := sql.Open(config.Driver(), config.DSN())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -35,8 +35,8 @@ func TestSaveFeatureCustomers(t *testing.T) {
 	)
 
 	tests := map[string]struct {
-		features  []feature
-		customers []customer
+		features  []Feature
+		customers []Customer
 		uuidFunc  func() (uuid.UUID, error)
 
 		featureID string
@@ -44,11 +44,11 @@ func TestSaveFeatureCustomers(t *testing.T) {
 
 		wantStatus    int
 		wantBody      string
-		wantCustomers []customer
+		wantCustomers []Customer
 	}{
 		"successfully add a customer to a feature": {
 			uuidFunc: func() (uuid.UUID, error) { return generatedUUID, nil },
-			features: []feature{{
+			features: []Feature{{
 				ID:            existingUUID,
 				TechnicalName: "my-feature-1",
 				CreatedAt:     refTime,
@@ -59,21 +59,22 @@ func TestSaveFeatureCustomers(t *testing.T) {
 			body:      `{"customerIds":["customer-1"]}`,
 
 			wantStatus: http.StatusCreated,
-			wantCustomers: []customer{{
+			wantBody:   `{"added":["customer-1"]}`,
+			wantCustomers: []Customer{{
 				ID:         generatedUUID,
 				FeatureID:  existingUUID,
 				CustomerID: "customer-1",
 			}},
 		},
-		"attempt to add a customer to a feature twice": {
+		"add a customer to a feature twice": {
 			uuidFunc: func() (uuid.UUID, error) { return generatedUUID, nil },
-			features: []feature{{
+			features: []Feature{{
 				ID:            existingUUID,
 				TechnicalName: "my-feature-1",
 				CreatedAt:     refTime,
 				UpdatedAt:     refTime,
 			}},
-			customers: []customer{{
+			customers: []Customer{{
 				ID:         existingUUID,
 				FeatureID:  existingUUID,
 				CustomerID: "customer-1",
@@ -82,46 +83,78 @@ func TestSaveFeatureCustomers(t *testing.T) {
 			featureID: existingUUID.String(),
 			body:      `{"customerIds":["customer-1"]}`,
 
-			wantStatus: http.StatusInternalServerError,
-			wantBody:   `{"error":"save customers: UNIQUE constraint failed: customer_features.customer_id, customer_features.feature_id"}`,
-			wantCustomers: []customer{{
+			wantStatus: http.StatusOK,
+			wantBody:   `{"added":[]}`,
+			wantCustomers: []Customer{{
 				ID:         existingUUID,
 				FeatureID:  existingUUID,
 				CustomerID: "customer-1",
 			}},
 		},
+		"only add customers without access": {
+			uuidFunc: func() (uuid.UUID, error) { return generatedUUID, nil },
+			features: []Feature{{
+				ID:            existingUUID,
+				TechnicalName: "my-feature-1",
+				CreatedAt:     refTime,
+				UpdatedAt:     refTime,
+			}},
+			customers: []Customer{{
+				ID:         existingUUID,
+				FeatureID:  existingUUID,
+				CustomerID: "customer-1",
+			}},
+
+			featureID: existingUUID.String(),
+			body:      `{"customerIds":["customer-1","customer-2","customer-1","customer-2"]}`,
+
+			wantStatus: http.StatusCreated,
+			wantBody:   `{"added":["customer-2"]}`,
+			wantCustomers: []Customer{
+				{
+					ID:         existingUUID,
+					FeatureID:  existingUUID,
+					CustomerID: "customer-1",
+				},
+				{
+					ID:         generatedUUID,
+					FeatureID:  existingUUID,
+					CustomerID: "customer-2",
+				},
+			},
+		},
 		"attempt to add customer to non-existing feature": {
 			uuidFunc: func() (uuid.UUID, error) { return generatedUUID, nil },
 
 			featureID: existingUUID.String(),
 			body:      `{"customerIds":["customer-1"]}`,
 
-			wantStatus: http.StatusInternalServerError,
-			wantBody:   `{"error":"save customers: FOREIGN KEY constraint failed"}`,
+			wantStatus: http.StatusNotFound,
+			wantBody:   `{"type":"urn:feature:problem:feature-not-found","title":"Not Found","status":404,"detail":"feature ` + existingUUID.String() + ` does not exist","code":"feature-not-found"}`,
 		},
 		"request body contains no customer ids": {
 			featureID: existingUUID.String(),
 			body:      `{}`,
 
 			wantStatus: http.StatusBadRequest,
-			wantBody:   `{"error":"no customer IDs given"}`,
+			wantBody:   `{"type":"urn:feature:problem:bad-request","title":"Bad Request","status":400,"detail":"no customer IDs given","code":"bad-request"}`,
 		},
 		"request body contains unknown fields": {
 			featureID: existingUUID.String(),
 			body:      `{"foo":"bar"}`,
 
 			wantStatus: http.StatusBadRequest,
-			wantBody:   `{"error":"decode request body: json: unknown field \"foo\""}`,
+			wantBody:   `{"type":"urn:feature:problem:bad-request","title":"Bad Request","status":400,"detail":"decode request body: json: unknown field \"foo\"","code":"bad-request"}`,
 		},
 		"missing request body": {
 			featureID: existingUUID.String(),
 
 			wantStatus: http.StatusBadRequest,
-			wantBody:   `{"error":"decode request body: EOF"}`,
+			wantBody:   `{"type":"urn:feature:problem:bad-request","title":"Bad Request","status":400,"detail":"decode request body: EOF","code":"bad-request"}`,
 		},
 		"bad feature id": {
 			wantStatus: http.StatusBadRequest,
-			wantBody:   `{"error":"parse feature id: invalid UUID length: 0"}`,
+			wantBody:   `{"type":"urn:feature:problem:bad-request","title":"Bad Request","status":400,"detail":"parse feature id: invalid UUID length: 0","code":"bad-request"}`,
 		},
 	}
 
@@ -176,39 +209,51 @@ func TestSaveFeatureCustomers(t *testing.T) {
 	}
 }
 
-func (s Store) findAllCustomerFeatures(ctx context.Context) ([]customer, error) {
-	rs, err := s.db.QueryContext(
-		ctx,
-		//language=sqlite
-		`SELECT id,feature_id,customer_id FROM customer_features`,
+// racingRepository misses the customers of features when reading them, as a
+// unit of work would when another one adds them concurrently.
+type racingRepository struct {
+	MemoryStore
+}
+
+func (r racingRepository) Begin(ctx context.Context, opts *sql.TxOptions) (UnitOfWork, error) {
+	uow, err := r.MemoryStore.Begin(ctx, opts)
+	return racingUnitOfWork{UnitOfWork: uow}, err
+}
+
+type racingUnitOfWork struct {
+	UnitOfWork
+}
+
+func (u racingUnitOfWork) FindCustomerIDsByFeatureID(context.Context, uuid.UUID) ([]string, error) {
+	return nil, nil
+}
+
+func TestAddCustomersToFeatureConcurrently(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		store = NewMemoryStore()
+		svc   = NewService(racingRepository{MemoryStore: store})
 	)
-	if err != nil {
-		return nil, err
+	if err := NewService(store).saveFeature(ctx, Feature{TechnicalName: "feature-1", CustomerIDs: []string{"customer-1"}}); err != nil {
+		t.Fatal(err)
 	}
-
-	var cs []customer
-	for rs.Next() {
-		var c customer
-		if err := rs.Scan(&c.ID, &c.FeatureID, &c.CustomerID); err != nil {
-			return nil, err
-		}
-		cs = append(cs, c)
+	fs, err := store.FindAllFeatures(ctx)
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	if err := rs.Err(); err != nil {
-		return nil, err
+	added, err := svc.addCustomersToFeature(ctx, fs[0].ID, []string{"customer-1", "customer-2"})
+	if err != nil {
+		t.Fatalf("Expected the customer added concurrently to be skipped, got: %v", err)
 	}
-
-	if err := rs.Close(); err != nil {
-		return nil, err
+	if !reflect.DeepEqual([]string{"customer-2"}, added) {
+		t.Errorf("Added customers not equal.\nwant: [customer-2]\ngot:  %v", added)
 	}
-
-	return cs, nil
 }
 
-func assertCustomers(t *testing.T, store Store, want ...customer) {
+func assertCustomers(t *testing.T, store Store, want ...Customer) {
 	t.Helper()
-	got, err := store.findAllCustomerFeatures(context.Background())
+	got, err := store.FindAllCustomerFeatures(context.Background())
 	if err != nil {
 		t.Error(err)
 		return
@@ -218,9 +263,9 @@ func assertCustomers(t *testing.T, store Store, want ...customer) {
 	}
 }
 
-func setupCustomers(t *testing.T, store Store, cs ...customer) {
+func setupCustomers(t *testing.T, store Store, cs ...Customer) {
 	t.Helper()
-	if err := store.saveCustomers(context.Background(), cs...); err != nil {
+	if err := store.SaveCustomers(context.Background(), cs...); err != nil {
 		t.Errorf("failed to set up customer_features table: %s", err)
 	}
 }
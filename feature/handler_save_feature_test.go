@@ -15,6 +15,7 @@ import (
 	"time"
 
 	"github.com/go-chi/chi"
+	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
 
 	"feature/pkg/config"
@@ -26,7 +27,7 @@ func TestSaveFeature(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	db, err := sql.Open("sqlite3", config.DSN())
+	db, err := sql.Open(config.Driver(), config.DSN())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -41,7 +42,7 @@ func TestSaveFeature(t *testing.T) {
 	)
 
 	tests := map[string]struct {
-		features []feature
+		features []Feature
 		timeFunc func() time.Time
 		uuidFunc func() (uuid.UUID, error)
 
@@ -49,7 +50,7 @@ func TestSaveFeature(t *testing.T) {
 
 		wantStatus   int
 		wantBody     string
-		wantFeatures []feature
+		wantFeatures []Feature
 	}{
 		"successfully persist the feature": {
 			timeFunc: func() time.Time { return refTime },
@@ -58,7 +59,7 @@ func TestSaveFeature(t *testing.T) {
 			body: `{"displayName":"My Feature 1","technicalName":"my-feature-1","expiresOn":` + strconv.FormatInt(expiryDate.UnixMilli(), 10) + `,"description":"Placeholder text for feature description."}`,
 
 			wantStatus: http.StatusCreated,
-			wantFeatures: []feature{{
+			wantFeatures: []Feature{{
 				ID:            generatedUUID,
 				DisplayName:   ptr("My Feature 1"),
 				TechnicalName: "my-feature-1",
@@ -69,9 +70,61 @@ func TestSaveFeature(t *testing.T) {
 				UpdatedAt:     refTime,
 			}},
 		},
+		"successfully persist the feature with metadata": {
+			timeFunc: func() time.Time { return refTime },
+			uuidFunc: func() (uuid.UUID, error) { return generatedUUID, nil },
+
+			body: `{"technicalName":"my-feature-1","expiresOn":` + strconv.FormatInt(expiryDate.UnixMilli(), 10) + `,"tags":["checkout","mobile"," checkout"],"owner":" team-payments ","ticketUrl":"https://tickets.example.com/PAY-1","kind":"release"}`,
+
+			wantStatus: http.StatusCreated,
+			wantFeatures: []Feature{{
+				ID:            generatedUUID,
+				TechnicalName: "my-feature-1",
+				ExpiresOn:     &expiryDate,
+				CreatedAt:     refTime,
+				UpdatedAt:     refTime,
+				Tags:          []string{"checkout", "mobile"},
+				Owner:         ptr("team-payments"),
+				TicketURL:     ptr("https://tickets.example.com/PAY-1"),
+				Kind:          KindRelease,
+			}},
+		},
+		"successfully persist the feature with rules": {
+			timeFunc: func() time.Time { return refTime },
+			uuidFunc: func() (uuid.UUID, error) { return generatedUUID, nil },
+
+			body: `{"technicalName":"my-feature-1","rules":[{"attribute":" plan ","values":["pro","enterprise"," pro",""]}]}`,
+
+			wantStatus: http.StatusCreated,
+			wantFeatures: []Feature{{
+				ID:            generatedUUID,
+				TechnicalName: "my-feature-1",
+				CreatedAt:     refTime,
+				UpdatedAt:     refTime,
+				Rules:         []TargetingRule{{Attribute: "plan", Values: []string{"enterprise", "pro"}}},
+			}},
+		},
+		"request body contains invalid rules": {
+			body: `{"technicalName":"my-feature-1","rules":[{"attribute":"plan","values":["pro"]},{"attribute":"plan","values":[" "]},{"attribute":"","values":["eu"]}]}`,
+
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"type":"urn:feature:problem:validation-failed","title":"Bad Request","status":400,"detail":"'rules' must not target attribute \"plan\" more than once, 'rules' must give values, missing in rule 2, 'rules' must name an attribute, missing in rule 3","code":"validation-failed","errors":[{"field":"rules","detail":"'rules' must not target attribute \"plan\" more than once"},{"field":"rules","detail":"'rules' must give values, missing in rule 2"},{"field":"rules","detail":"'rules' must name an attribute, missing in rule 3"}]}`,
+		},
+		"release feature without expiry": {
+			body: `{"technicalName":"my-feature-1","kind":"release"}`,
+
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"type":"urn:feature:problem:validation-failed","title":"Bad Request","status":400,"detail":"'expiresOn' must be set for release features","code":"validation-failed","errors":[{"field":"expiresOn","detail":"'expiresOn' must be set for release features"}]}`,
+		},
+		"request body contains invalid metadata": {
+			body: `{"technicalName":"my-feature-1","tags":["two words"],"ticketUrl":"PAY-1","kind":"forever"}`,
+
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"type":"urn:feature:problem:validation-failed","title":"Bad Request","status":400,"detail":"'tags' must not be empty or contain whitespace, got \"two words\", 'ticketUrl' must be an http or https URL, 'kind' must be one of release, experiment, ops or permission","code":"validation-failed","errors":[{"field":"tags","detail":"'tags' must not be empty or contain whitespace, got \"two words\""},{"field":"ticketUrl","detail":"'ticketUrl' must be an http or https URL"},{"field":"kind","detail":"'kind' must be one of release, experiment, ops or permission"}]}`,
+		},
 		"feature with the same technical name already exists": {
 			timeFunc: func() time.Time { return refTime },
-			features: []feature{{
+			features: []Feature{{
 				ID:            existingUUID,
 				TechnicalName: "my-feature-1",
 			}},
@@ -79,9 +132,9 @@ func TestSaveFeature(t *testing.T) {
 
 			body: `{"displayName":"My Feature 1","technicalName":"my-feature-1","expiresOn":` + strconv.FormatInt(expiryDate.UnixMilli(), 10) + `,"description":"Placeholder text for feature description."}`,
 
-			wantStatus: http.StatusInternalServerError,
-			wantBody:   `{"error":"save feature: UNIQUE constraint failed: features.technical_name"}`,
-			wantFeatures: []feature{{
+			wantStatus: http.StatusConflict,
+			wantBody:   `{"type":"urn:feature:problem:constraint-violation","title":"Conflict","status":409,"detail":"constraint failed: features.technical_name is not unique","code":"constraint-violation"}`,
+			wantFeatures: []Feature{{
 				ID:            existingUUID,
 				TechnicalName: "my-feature-1",
 			}},
@@ -92,23 +145,23 @@ func TestSaveFeature(t *testing.T) {
 			body: `{"technicalName":"my-feature-1"}`,
 
 			wantStatus: http.StatusInternalServerError,
-			wantBody:   `{"error":"generate feature id: test error"}`,
+			wantBody:   `{"type":"urn:feature:problem:internal-server-error","title":"Internal Server Error","status":500,"code":"internal-server-error"}`,
 		},
 		"request body contains invalid field values": {
 			body: `{}`,
 
 			wantStatus: http.StatusBadRequest,
-			wantBody:   `{"error":"validate feature: 'technicalName' must be at least 5 characters long"}`,
+			wantBody:   `{"type":"urn:feature:problem:validation-failed","title":"Bad Request","status":400,"detail":"'technicalName' must be at least 5 characters long","code":"validation-failed","errors":[{"field":"technicalName","detail":"'technicalName' must be at least 5 characters long"}]}`,
 		},
 		"request body contains unknown fields": {
 			body: `{"foo":"bar"}`,
 
 			wantStatus: http.StatusBadRequest,
-			wantBody:   `{"error":"decode request body: json: unknown field \"foo\""}`,
+			wantBody:   `{"type":"urn:feature:problem:bad-request","title":"Bad Request","status":400,"detail":"decode request body: json: unknown field \"foo\"","code":"bad-request"}`,
 		},
 		"missing request body": {
 			wantStatus: http.StatusBadRequest,
-			wantBody:   `{"error":"decode request body: EOF"}`,
+			wantBody:   `{"type":"urn:feature:problem:bad-request","title":"Bad Request","status":400,"detail":"decode request body: EOF","code":"bad-request"}`,
 		},
 	}
 
@@ -163,9 +216,9 @@ func TestSaveFeature(t *testing.T) {
 	}
 }
 
-func assertFeatures(t *testing.T, store Store, want ...feature) {
+func assertFeatures(t *testing.T, store Store, want ...Feature) {
 	t.Helper()
-	got, err := store.findAllFeatures(context.Background())
+	got, err := store.FindAllFeatures(context.Background())
 	if err != nil {
 		t.Error(err)
 		return
@@ -175,12 +228,10 @@ func assertFeatures(t *testing.T, store Store, want ...feature) {
 	}
 }
 
-func ptr[T any](t T) *T { return &t }
-
-func setupFeatures(t *testing.T, store Store, features ...feature) {
+func setupFeatures(t *testing.T, store Store, features ...Feature) {
 	t.Helper()
 	for _, f := range features {
-		if err := store.saveFeature(context.Background(), f); err != nil {
+		if err := store.SaveFeature(context.Background(), f); err != nil {
 			t.Fatalf("failed to set up features table: %s\n", err)
 		}
 	}
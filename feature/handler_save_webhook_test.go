@@ -0,0 +1,156 @@
+package feature
+
+import (
+	"context"
+	"database/sql"
+	"feature/pkg/config"
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSaveWebhook(t *testing.T) {
+	viper.SetConfigFile("../test.env")
+	if err := viper.ReadInConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open(config.Driver(), config.DSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewStore(db)
+
+	var (
+		generatedUUID = uuid.MustParse("44eeeacf-8d5d-4c68-bbe9-3e58c5ae6915")
+		refTime       = time.Now().Truncate(time.Second).UTC()
+	)
+
+	tests := map[string]struct {
+		body string
+
+		wantStatus   int
+		wantBody     string
+		wantWebhooks []Webhook
+	}{
+		"successfully subscribe a webhook to some events": {
+			body: `{"url":"https://example.com/hook","secret":"s3cr3t","events":["feature.created","feature.expired"]}`,
+
+			wantStatus: http.StatusCreated,
+			wantBody:   `{"id":"44eeeacf-8d5d-4c68-bbe9-3e58c5ae6915","url":"https://example.com/hook","secret":"s3cr3t","events":["feature.created","feature.expired"],"createdAt":` + strconv.FormatInt(refTime.UnixMilli(), 10) + `}`,
+			wantWebhooks: []Webhook{{
+				ID:        generatedUUID,
+				URL:       "https://example.com/hook",
+				Secret:    "s3cr3t",
+				Events:    []string{"feature.created", "feature.expired"},
+				CreatedAt: refTime,
+			}},
+		},
+		"successfully subscribe a webhook to all events": {
+			body: `{"url":"https://example.com/hook","secret":"s3cr3t"}`,
+
+			wantStatus: http.StatusCreated,
+			wantBody:   `{"id":"44eeeacf-8d5d-4c68-bbe9-3e58c5ae6915","url":"https://example.com/hook","secret":"s3cr3t","events":[],"createdAt":` + strconv.FormatInt(refTime.UnixMilli(), 10) + `}`,
+			wantWebhooks: []Webhook{{
+				ID:        generatedUUID,
+				URL:       "https://example.com/hook",
+				Secret:    "s3cr3t",
+				Events:    []string{},
+				CreatedAt: refTime,
+			}},
+		},
+		"request body contains invalid field values": {
+			body: `{"url":"example.com","events":["feature.renamed"]}`,
+
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"type":"urn:feature:problem:validation-failed","title":"Bad Request","status":400,"detail":"'url' must be an absolute http(s) URL, 'events' contains unknown event \"feature.renamed\"","code":"validation-failed","errors":[{"field":"url","detail":"'url' must be an absolute http(s) URL"},{"field":"events","detail":"'events' contains unknown event \"feature.renamed\""}]}`,
+		},
+		"request body contains unknown fields": {
+			body: `{"foo":"bar"}`,
+
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"type":"urn:feature:problem:bad-request","title":"Bad Request","status":400,"detail":"decode request body: json: unknown field \"foo\"","code":"bad-request"}`,
+		},
+		"missing request body": {
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"type":"urn:feature:problem:bad-request","title":"Bad Request","status":400,"detail":"decode request body: EOF","code":"bad-request"}`,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tx, _, rollback, err := store.beginTx(context.Background(), &sql.TxOptions{
+				Isolation: sql.LevelReadCommitted,
+			})
+			if err != nil {
+				t.Fatalf("failed to begin transaction: %s\n", err)
+			}
+
+			t.Cleanup(func() {
+				if err := rollback(); err != nil {
+					t.Errorf("failed to rollback the transaction: %s\n", err)
+				}
+			})
+
+			service := NewService(*tx)
+			service.timeFunc = func() time.Time { return refTime }
+			service.uuidFunc = func() (uuid.UUID, error) { return generatedUUID, nil }
+			handler := NewHandler(service)
+
+			r := chi.NewRouter()
+			r.Post("/webhooks", handler.SaveWebhook)
+
+			req := httptest.NewRequest(
+				http.MethodPost,
+				"/webhooks",
+				strings.NewReader(test.body),
+			)
+			res := httptest.NewRecorder()
+
+			r.ServeHTTP(res, req)
+
+			if res.Code != test.wantStatus {
+				t.Errorf("Status codes not equal.\nwant: %d\ngot:  %d", test.wantStatus, res.Code)
+			}
+
+			resBody := strings.TrimSpace(res.Body.String())
+			if resBody != test.wantBody {
+				t.Errorf("Response bodies not equal.\nwant: %s\ngot:  %s", test.wantBody, resBody)
+			}
+
+			assertWebhooks(t, *tx, test.wantWebhooks...)
+		})
+	}
+}
+
+func assertWebhooks(t *testing.T, store Store, want ...Webhook) {
+	t.Helper()
+	got, err := store.FindAllWebhooks(context.Background())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Webhooks not equal.\nwant: %v\ngot:  %v", want, got)
+	}
+}
+
+func setupWebhooks(t *testing.T, store Store, whs ...Webhook) {
+	t.Helper()
+	for _, wh := range whs {
+		if err := store.SaveWebhook(context.Background(), wh); err != nil {
+			t.Fatalf("failed to set up webhooks table: %s\n", err)
+		}
+	}
+}
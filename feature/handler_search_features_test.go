@@ -0,0 +1,183 @@
+package feature
+
+import (
+	"context"
+	"database/sql"
+	"feature/pkg/config"
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSearchFeatures(t *testing.T) {
+	viper.SetConfigFile("../test.env")
+	if err := viper.ReadInConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open(config.Driver(), config.DSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewStore(db)
+
+	var (
+		checkoutUUID = uuid.MustParse("bb7fe5b6-24a5-4218-bc61-b487bbad9581")
+		paymentUUID  = uuid.MustParse("bb7fe5b6-24a5-4218-bc61-b487bbad9582")
+		archivedUUID = uuid.MustParse("bb7fe5b6-24a5-4218-bc61-b487bbad9583")
+		refTime      = time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+
+		features = []Feature{
+			{
+				ID:            checkoutUUID,
+				DisplayName:   ptr("New checkout"),
+				TechnicalName: "checkout-redesign",
+				Description:   ptr("Redesigned payment & checkout page <beta>"),
+				CreatedAt:     refTime,
+				UpdatedAt:     refTime,
+			},
+			{
+				ID:            paymentUUID,
+				TechnicalName: "payment-providers",
+				Description:   ptr("Adds more checkout options"),
+				CreatedAt:     refTime,
+				UpdatedAt:     refTime,
+				Tags:          []string{"billing"},
+			},
+		}
+		customers = []Customer{{
+			ID:         checkoutUUID,
+			FeatureID:  checkoutUUID,
+			CustomerID: "acme-corp",
+		}}
+		archived = Feature{
+			ID:            archivedUUID,
+			TechnicalName: "legacy-checkout",
+			CreatedAt:     refTime,
+			UpdatedAt:     refTime,
+		}
+	)
+
+	const (
+		checkoutJSON = `{"id":"bb7fe5b6-24a5-4218-bc61-b487bbad9581","technicalName":"checkout-redesign","displayName":"New checkout","archived":false,"snippet":"\u003cmark\u003echeckout\u003c/mark\u003e-redesign"}`
+		paymentJSON  = `{"id":"bb7fe5b6-24a5-4218-bc61-b487bbad9582","technicalName":"payment-providers","archived":false,"snippet":"Adds more \u003cmark\u003echeckout\u003c/mark\u003e options"}`
+		archivedJSON = `{"id":"bb7fe5b6-24a5-4218-bc61-b487bbad9583","technicalName":"legacy-checkout","archived":true,"snippet":"\u003cmark\u003elegacy\u003c/mark\u003e-checkout"}`
+	)
+
+	tests := map[string]struct {
+		query string
+
+		wantStatus int
+		wantBody   string
+	}{
+		"best matches first": {
+			query: "?q=checkout",
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"results":[` + checkoutJSON + `,` + paymentJSON + `]}`,
+		},
+		"limit results": {
+			query: "?q=checkout&limit=1",
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"results":[` + checkoutJSON + `]}`,
+		},
+		"exclude archived features": {
+			query: "?q=legacy",
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"results":[]}`,
+		},
+		"include archived features": {
+			query: "?q=legacy&archived=true",
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"results":[` + archivedJSON + `]}`,
+		},
+		"match every word by prefix, escaping snippets": {
+			query: "?q=REDESIGN+pag",
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"results":[{"id":"bb7fe5b6-24a5-4218-bc61-b487bbad9581","technicalName":"checkout-redesign","displayName":"New checkout","archived":false,"snippet":"\u003cmark\u003eRedesigned\u003c/mark\u003e payment \u0026amp; checkout \u003cmark\u003epage\u003c/mark\u003e \u0026lt;beta\u0026gt;"}]}`,
+		},
+		"match tag": {
+			query: "?q=bill",
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"results":[{"id":"bb7fe5b6-24a5-4218-bc61-b487bbad9582","technicalName":"payment-providers","archived":false,"snippet":"\u003cmark\u003ebilling\u003c/mark\u003e"}]}`,
+		},
+		"customers are not matched": {
+			query: "?q=acme",
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"results":[]}`,
+		},
+		"no text": {
+			query: "?q=+",
+
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"type":"urn:feature:problem:bad-request","title":"Bad Request","status":400,"detail":"no search text given","code":"bad-request"}`,
+		},
+		"limit too large": {
+			query: "?q=checkout&limit=101",
+
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"type":"urn:feature:problem:bad-request","title":"Bad Request","status":400,"detail":"'limit' must be between 1 and 100","code":"bad-request"}`,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tx, _, rollback, err := store.beginTx(context.Background(), &sql.TxOptions{
+				Isolation: sql.LevelReadCommitted,
+			})
+			if err != nil {
+				t.Fatalf("failed to begin transaction: %s\n", err)
+			}
+
+			t.Cleanup(func() {
+				if err := rollback(); err != nil {
+					t.Errorf("failed to rollback the transaction: %s\n", err)
+				}
+			})
+
+			setupFeatures(t, *tx, features...)
+			setupCustomers(t, *tx, customers...)
+			if err := tx.SaveArchivedFeature(context.Background(), archived); err != nil {
+				t.Fatalf("failed to set up archived_features table: %s\n", err)
+			}
+
+			handler := NewHandler(NewService(*tx))
+
+			r := chi.NewRouter()
+			r.Get("/features/search", handler.SearchFeatures)
+
+			req := httptest.NewRequest(
+				http.MethodGet,
+				"/features/search"+test.query,
+				nil,
+			)
+			res := httptest.NewRecorder()
+
+			r.ServeHTTP(res, req)
+
+			if res.Code != test.wantStatus {
+				t.Errorf("Status codes not equal.\nwant: %d\ngot:  %d", test.wantStatus, res.Code)
+			}
+
+			resBody := strings.TrimSpace(res.Body.String())
+			if resBody != test.wantBody {
+				t.Errorf("Response bodies not equal.\nwant: %s\ngot:  %s", test.wantBody, resBody)
+			}
+		})
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/spf13/viper"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strconv"
 	"strings"
 	"testing"
@@ -21,7 +22,7 @@ func TestUpdateFeature(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	db, err := sql.Open("sqlite3", config.DSN())
+	db, err := sql.Open(config.Driver(), config.DSN())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -30,24 +31,30 @@ func TestUpdateFeature(t *testing.T) {
 
 	var (
 		existingUUID  = uuid.MustParse("bb7fe5b6-24a5-4218-bc61-b487bbad9580")
+		otherUUID     = uuid.MustParse("e2bd6e9c-04b5-4d2e-a4b7-2b2d0a87e1f5")
+		generatedUUID = uuid.MustParse("44eeeacf-8d5d-4c68-bbe9-3e58c5ae6915")
 		lastUpdatedAt = time.Now().AddDate(0, 0, -7).Truncate(time.Second).UTC()
 		refTime       = time.Now().Truncate(time.Second).UTC()
 		expiryDate    = time.Now().Truncate(time.Second).UTC()
 	)
 
 	tests := map[string]struct {
-		features []feature
-		timeFunc func() time.Time
+		features  []Feature
+		customers []Customer
+		aliases   []Alias
+		timeFunc  func() time.Time
 
 		featureId string
 		body      string
 
-		wantStatus   int
-		wantBody     string
-		wantFeatures []feature
+		wantStatus    int
+		wantBody      string
+		wantFeatures  []Feature
+		wantCustomers []Customer
+		wantAliases   []Alias
 	}{
 		"successfully update feature": {
-			features: []feature{{
+			features: []Feature{{
 				ID:            existingUUID,
 				DisplayName:   ptr("Feature #1"),
 				TechnicalName: "feature-1",
@@ -62,7 +69,7 @@ func TestUpdateFeature(t *testing.T) {
 			body:      `{"lastUpdatedAt":` + strconv.FormatInt(lastUpdatedAt.UnixMilli(), 10) + `,"feature":{"displayName":"My Feature 1","technicalName":"my-feature-1","expiresOn":` + strconv.FormatInt(expiryDate.UnixMilli(), 10) + `,"description":"Placeholder text for feature description."}}`,
 
 			wantStatus: http.StatusNoContent,
-			wantFeatures: []feature{{
+			wantFeatures: []Feature{{
 				ID:            existingUUID,
 				DisplayName:   ptr("My Feature 1"),
 				TechnicalName: "my-feature-1",
@@ -71,9 +78,183 @@ func TestUpdateFeature(t *testing.T) {
 				CreatedAt:     lastUpdatedAt,
 				UpdatedAt:     refTime,
 			}},
+			wantAliases: []Alias{{
+				TechnicalName: "feature-1",
+				FeatureID:     existingUUID,
+				CreatedAt:     refTime,
+			}},
+		},
+		"rules left out of the request are kept": {
+			features: []Feature{{
+				ID:            existingUUID,
+				TechnicalName: "feature-1",
+				Rules:         []TargetingRule{{Attribute: "plan", Values: []string{"enterprise"}}},
+				CreatedAt:     lastUpdatedAt,
+				UpdatedAt:     lastUpdatedAt,
+			}},
+			timeFunc: func() time.Time { return refTime },
+
+			featureId: existingUUID.String(),
+			body:      `{"lastUpdatedAt":` + strconv.FormatInt(lastUpdatedAt.UnixMilli(), 10) + `,"feature":{"technicalName":"feature-1","description":"Lorem ipsum."}}`,
+
+			wantStatus: http.StatusNoContent,
+			wantFeatures: []Feature{{
+				ID:            existingUUID,
+				TechnicalName: "feature-1",
+				Description:   ptr("Lorem ipsum."),
+				Rules:         []TargetingRule{{Attribute: "plan", Values: []string{"enterprise"}}},
+				CreatedAt:     lastUpdatedAt,
+				UpdatedAt:     refTime,
+			}},
+		},
+		"empty rules remove the rules": {
+			features: []Feature{{
+				ID:            existingUUID,
+				TechnicalName: "feature-1",
+				Rules:         []TargetingRule{{Attribute: "plan", Values: []string{"enterprise"}}},
+				CreatedAt:     lastUpdatedAt,
+				UpdatedAt:     lastUpdatedAt,
+			}},
+			timeFunc: func() time.Time { return refTime },
+
+			featureId: existingUUID.String(),
+			body:      `{"lastUpdatedAt":` + strconv.FormatInt(lastUpdatedAt.UnixMilli(), 10) + `,"feature":{"technicalName":"feature-1","rules":[]}}`,
+
+			wantStatus: http.StatusNoContent,
+			wantFeatures: []Feature{{
+				ID:            existingUUID,
+				TechnicalName: "feature-1",
+				CreatedAt:     lastUpdatedAt,
+				UpdatedAt:     refTime,
+			}},
+		},
+		"renaming back to an alias drops it": {
+			features: []Feature{{
+				ID:            existingUUID,
+				TechnicalName: "my-feature-1",
+				CreatedAt:     lastUpdatedAt,
+				UpdatedAt:     lastUpdatedAt,
+			}},
+			aliases: []Alias{{
+				TechnicalName: "feature-1",
+				FeatureID:     existingUUID,
+				CreatedAt:     lastUpdatedAt,
+			}},
+			timeFunc: func() time.Time { return refTime },
+
+			featureId: existingUUID.String(),
+			body:      `{"lastUpdatedAt":` + strconv.FormatInt(lastUpdatedAt.UnixMilli(), 10) + `,"feature":{"technicalName":"feature-1"}}`,
+
+			wantStatus: http.StatusNoContent,
+			wantFeatures: []Feature{{
+				ID:            existingUUID,
+				TechnicalName: "feature-1",
+				CreatedAt:     lastUpdatedAt,
+				UpdatedAt:     refTime,
+			}},
+			wantAliases: []Alias{{
+				TechnicalName: "my-feature-1",
+				FeatureID:     existingUUID,
+				CreatedAt:     refTime,
+			}},
+		},
+		"renaming to an alias of another feature": {
+			features: []Feature{
+				{
+					ID:            existingUUID,
+					TechnicalName: "feature-1",
+					CreatedAt:     lastUpdatedAt,
+					UpdatedAt:     lastUpdatedAt,
+				},
+				{
+					ID:            otherUUID,
+					TechnicalName: "feature-2",
+					CreatedAt:     lastUpdatedAt,
+					UpdatedAt:     lastUpdatedAt,
+				},
+			},
+			aliases: []Alias{{
+				TechnicalName: "old-feature-2",
+				FeatureID:     otherUUID,
+				CreatedAt:     lastUpdatedAt,
+			}},
+			timeFunc: func() time.Time { return refTime },
+
+			featureId: existingUUID.String(),
+			body:      `{"lastUpdatedAt":` + strconv.FormatInt(lastUpdatedAt.UnixMilli(), 10) + `,"feature":{"technicalName":"old-feature-2"}}`,
+
+			wantStatus: http.StatusConflict,
+			wantBody:   `{"type":"urn:feature:problem:technical-name-taken","title":"Conflict","status":409,"detail":"technical name \"old-feature-2\" is an alias of feature e2bd6e9c-04b5-4d2e-a4b7-2b2d0a87e1f5","code":"technical-name-taken"}`,
+			wantFeatures: []Feature{
+				{
+					ID:            existingUUID,
+					TechnicalName: "feature-1",
+					CreatedAt:     lastUpdatedAt,
+					UpdatedAt:     lastUpdatedAt,
+				},
+				{
+					ID:            otherUUID,
+					TechnicalName: "feature-2",
+					CreatedAt:     lastUpdatedAt,
+					UpdatedAt:     lastUpdatedAt,
+				},
+			},
+			wantAliases: []Alias{{
+				TechnicalName: "old-feature-2",
+				FeatureID:     otherUUID,
+				CreatedAt:     lastUpdatedAt,
+			}},
+		},
+		"only customers of the updated feature are removed": {
+			features: []Feature{
+				{
+					ID:            existingUUID,
+					TechnicalName: "feature-1",
+					CreatedAt:     lastUpdatedAt,
+					UpdatedAt:     lastUpdatedAt,
+				},
+				{
+					ID:            otherUUID,
+					TechnicalName: "feature-2",
+					CreatedAt:     lastUpdatedAt,
+					UpdatedAt:     lastUpdatedAt,
+				},
+			},
+			customers: []Customer{
+				{ID: uuid.MustParse("0d0e1f6c-7c49-4a4e-9f54-0e5b5c0b1d01"), FeatureID: existingUUID, CustomerID: "customer-1"},
+				{ID: uuid.MustParse("0d0e1f6c-7c49-4a4e-9f54-0e5b5c0b1d02"), FeatureID: existingUUID, CustomerID: "customer-2"},
+				{ID: uuid.MustParse("0d0e1f6c-7c49-4a4e-9f54-0e5b5c0b1d03"), FeatureID: otherUUID, CustomerID: "customer-1"},
+				{ID: uuid.MustParse("0d0e1f6c-7c49-4a4e-9f54-0e5b5c0b1d04"), FeatureID: otherUUID, CustomerID: "customer-2"},
+			},
+			timeFunc: func() time.Time { return refTime },
+
+			featureId: existingUUID.String(),
+			body:      `{"lastUpdatedAt":` + strconv.FormatInt(lastUpdatedAt.UnixMilli(), 10) + `,"feature":{"technicalName":"feature-1","customerIds":["customer-1","customer-3"]}}`,
+
+			wantStatus: http.StatusNoContent,
+			wantFeatures: []Feature{
+				{
+					ID:            existingUUID,
+					TechnicalName: "feature-1",
+					CreatedAt:     lastUpdatedAt,
+					UpdatedAt:     refTime,
+				},
+				{
+					ID:            otherUUID,
+					TechnicalName: "feature-2",
+					CreatedAt:     lastUpdatedAt,
+					UpdatedAt:     lastUpdatedAt,
+				},
+			},
+			wantCustomers: []Customer{
+				{ID: uuid.MustParse("0d0e1f6c-7c49-4a4e-9f54-0e5b5c0b1d01"), FeatureID: existingUUID, CustomerID: "customer-1"},
+				{ID: uuid.MustParse("0d0e1f6c-7c49-4a4e-9f54-0e5b5c0b1d03"), FeatureID: otherUUID, CustomerID: "customer-1"},
+				{ID: uuid.MustParse("0d0e1f6c-7c49-4a4e-9f54-0e5b5c0b1d04"), FeatureID: otherUUID, CustomerID: "customer-2"},
+				{ID: generatedUUID, FeatureID: existingUUID, CustomerID: "customer-3"},
+			},
 		},
 		"updated feature exists, but client is sending a stale update": {
-			features: []feature{{
+			features: []Feature{{
 				ID:            existingUUID,
 				DisplayName:   ptr("Feature #1"),
 				TechnicalName: "feature-1",
@@ -88,8 +269,8 @@ func TestUpdateFeature(t *testing.T) {
 			body:      `{"lastUpdatedAt":` + strconv.FormatInt(lastUpdatedAt.UnixMilli(), 10) + `,"feature":{"displayName":"My Feature 1","technicalName":"my-feature-1","expiresOn":` + strconv.FormatInt(expiryDate.UnixMilli(), 10) + `,"description":"Placeholder text for feature description."}}`,
 
 			wantStatus: http.StatusNotFound,
-			wantBody:   `{"error":"update feature: feature bb7fe5b6-24a5-4218-bc61-b487bbad9580 does not exist"}`,
-			wantFeatures: []feature{{
+			wantBody:   `{"type":"urn:feature:problem:feature-not-found","title":"Not Found","status":404,"detail":"feature bb7fe5b6-24a5-4218-bc61-b487bbad9580 does not exist","code":"feature-not-found"}`,
+			wantFeatures: []Feature{{
 				ID:            existingUUID,
 				DisplayName:   ptr("Feature #1"),
 				TechnicalName: "feature-1",
@@ -106,33 +287,33 @@ func TestUpdateFeature(t *testing.T) {
 			body:      `{"lastUpdatedAt":` + strconv.FormatInt(lastUpdatedAt.UnixMilli(), 10) + `,"feature":{"displayName":"My Feature 1","technicalName":"my-feature-1","expiresOn":` + strconv.FormatInt(expiryDate.UnixMilli(), 10) + `,"description":"Placeholder text for feature description."}}`,
 
 			wantStatus: http.StatusNotFound,
-			wantBody:   `{"error":"update feature: feature bb7fe5b6-24a5-4218-bc61-b487bbad9580 does not exist"}`,
+			wantBody:   `{"type":"urn:feature:problem:feature-not-found","title":"Not Found","status":404,"detail":"feature bb7fe5b6-24a5-4218-bc61-b487bbad9580 does not exist","code":"feature-not-found"}`,
 		},
 		"invalid request body": {
 			featureId: existingUUID.String(),
 			body:      `{}`,
 
 			wantStatus: http.StatusBadRequest,
-			wantBody:   `{"error":"validate feature: 'technicalName' must be at least 5 characters long"}`,
+			wantBody:   `{"type":"urn:feature:problem:validation-failed","title":"Bad Request","status":400,"detail":"'technicalName' must be at least 5 characters long","code":"validation-failed","errors":[{"field":"technicalName","detail":"'technicalName' must be at least 5 characters long"}]}`,
 		},
 		"request body contains unknown fields": {
 			featureId: existingUUID.String(),
 			body:      `{"foo":"bar"}`,
 
 			wantStatus: http.StatusBadRequest,
-			wantBody:   `{"error":"decode request body: json: unknown field \"foo\""}`,
+			wantBody:   `{"type":"urn:feature:problem:bad-request","title":"Bad Request","status":400,"detail":"decode request body: json: unknown field \"foo\"","code":"bad-request"}`,
 		},
 		"missing request body": {
 			featureId: existingUUID.String(),
 
 			wantStatus: http.StatusBadRequest,
-			wantBody:   `{"error":"decode request body: EOF"}`,
+			wantBody:   `{"type":"urn:feature:problem:bad-request","title":"Bad Request","status":400,"detail":"decode request body: EOF","code":"bad-request"}`,
 		},
 		"bad feature id": {
 			featureId: "bad",
 
 			wantStatus: http.StatusBadRequest,
-			wantBody:   `{"error":"parse feature id: invalid UUID length: 3"}`,
+			wantBody:   `{"type":"urn:feature:problem:bad-request","title":"Bad Request","status":400,"detail":"parse feature id: invalid UUID length: 3","code":"bad-request"}`,
 		},
 	}
 
@@ -155,9 +336,12 @@ func TestUpdateFeature(t *testing.T) {
 			})
 
 			setupFeatures(t, *tx, test.features...)
+			setupCustomers(t, *tx, test.customers...)
+			setupAliases(t, *tx, test.aliases...)
 
 			service := NewService(*tx)
 			service.timeFunc = test.timeFunc
+			service.uuidFunc = func() (uuid.UUID, error) { return generatedUUID, nil }
 			handler := NewHandler(service)
 
 			r := chi.NewRouter()
@@ -182,6 +366,29 @@ func TestUpdateFeature(t *testing.T) {
 			}
 
 			assertFeatures(t, *tx, test.wantFeatures...)
+			assertCustomers(t, *tx, test.wantCustomers...)
+			assertAliases(t, *tx, test.wantAliases...)
 		})
 	}
 }
+
+func assertAliases(t *testing.T, store Store, want ...Alias) {
+	t.Helper()
+	got, err := store.FindAllAliases(context.Background())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Aliases not equal.\nwant: %v\ngot:  %v", want, got)
+	}
+}
+
+func setupAliases(t *testing.T, store Store, aliases ...Alias) {
+	t.Helper()
+	for _, a := range aliases {
+		if err := store.SaveAlias(context.Background(), a); err != nil {
+			t.Fatalf("failed to set up feature_aliases table: %s\n", err)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package feature
+
+import (
+	"context"
+	"database/sql"
+	"feature/pkg/config"
+	"github.com/go-chi/chi"
+	"github.com/spf13/viper"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUploadCustomers(t *testing.T) {
+	viper.SetConfigFile("../test.env")
+	if err := viper.ReadInConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open(config.Driver(), config.DSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewStore(db)
+
+	var (
+		createdAt = time.Now().AddDate(0, 0, -7).Truncate(time.Second).UTC()
+		refTime   = time.Now().Truncate(time.Second).UTC()
+	)
+
+	existing := CustomerProfile{
+		ID:          "cust-9812",
+		DisplayName: ptr("Acme"),
+		Attributes:  map[string]string{"plan": "free"},
+		CreatedAt:   createdAt,
+		UpdatedAt:   createdAt,
+	}
+
+	tests := map[string]struct {
+		body        string
+		maxBodySize int64
+
+		wantStatus   int
+		wantBody     string
+		wantProfiles []CustomerProfile
+	}{
+		"create and update customers": {
+			body: "id,displayName,plan,region\n" +
+				"cust-9812,Acme Corp,enterprise,eu\n" +
+				"\n" +
+				"cust-9813,,free,\n" +
+				"cust 9814,Initech,free,us\n" +
+				",Umbrella,free,us\n",
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"created":1,"updated":1,"invalid":2,"invalidLines":[5,6]}`,
+			wantProfiles: []CustomerProfile{
+				{
+					ID:          "cust-9812",
+					DisplayName: ptr("Acme Corp"),
+					Attributes:  map[string]string{"plan": "enterprise", "region": "eu"},
+					CreatedAt:   createdAt,
+					UpdatedAt:   refTime,
+				},
+				{
+					ID:         "cust-9813",
+					Attributes: map[string]string{"plan": "free"},
+					CreatedAt:  refTime,
+					UpdatedAt:  refTime,
+				},
+			},
+		},
+		"later rows replace earlier ones": {
+			body: "customer_id,plan\ncust-9813,free\ncust-9813,enterprise\n",
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"created":1,"updated":0,"invalid":0,"invalidLines":[]}`,
+			wantProfiles: []CustomerProfile{
+				existing,
+				{
+					ID:         "cust-9813",
+					Attributes: map[string]string{"plan": "enterprise"},
+					CreatedAt:  refTime,
+					UpdatedAt:  refTime,
+				},
+			},
+		},
+		"missing id column": {
+			body: "name,plan\nAcme,free\n",
+
+			wantStatus:   http.StatusBadRequest,
+			wantBody:     `{"type":"urn:feature:problem:bad-request","title":"Bad Request","status":400,"detail":"header row must have an 'id' column","code":"bad-request"}`,
+			wantProfiles: []CustomerProfile{existing},
+		},
+		"body over the size limit": {
+			body:        "id,plan\ncust-9813,free\n",
+			maxBodySize: 10,
+
+			wantStatus:   http.StatusRequestEntityTooLarge,
+			wantBody:     `{"type":"urn:feature:problem:request-entity-too-large","title":"Request Entity Too Large","status":413,"detail":"request body exceeds the limit of 10 bytes","code":"request-entity-too-large"}`,
+			wantProfiles: []CustomerProfile{existing},
+		},
+		"empty body": {
+			wantStatus:   http.StatusBadRequest,
+			wantBody:     `{"type":"urn:feature:problem:bad-request","title":"Bad Request","status":400,"detail":"header row must have an 'id' column","code":"bad-request"}`,
+			wantProfiles: []CustomerProfile{existing},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tx, _, rollback, err := store.beginTx(context.Background(), &sql.TxOptions{
+				Isolation: sql.LevelReadCommitted,
+			})
+			if err != nil {
+				t.Fatalf("failed to begin transaction: %s\n", err)
+			}
+
+			t.Cleanup(func() {
+				if err := rollback(); err != nil {
+					t.Errorf("failed to rollback the transaction: %s\n", err)
+				}
+			})
+
+			setupCustomerProfiles(t, *tx, existing)
+
+			service := NewService(*tx)
+			service.timeFunc = func() time.Time { return refTime }
+			handler := NewHandler(service)
+
+			r := chi.NewRouter()
+			r.Post("/customers/upload", handler.UploadCustomers)
+
+			req := httptest.NewRequest(
+				http.MethodPost,
+				"/customers/upload",
+				strings.NewReader(test.body),
+			)
+			req.Header.Set("Content-Type", "text/csv")
+			res := httptest.NewRecorder()
+			if test.maxBodySize > 0 {
+				req.Body = http.MaxBytesReader(res, req.Body, test.maxBodySize)
+			}
+
+			r.ServeHTTP(res, req)
+
+			if res.Code != test.wantStatus {
+				t.Errorf("Status codes not equal.\nwant: %d\ngot:  %d", test.wantStatus, res.Code)
+			}
+
+			resBody := strings.TrimSpace(res.Body.String())
+			if resBody != test.wantBody {
+				t.Errorf("Response bodies not equal.\nwant: %s\ngot:  %s", test.wantBody, resBody)
+			}
+
+			assertCustomerProfiles(t, *tx, test.wantProfiles...)
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package feature
+
+import (
+	"context"
+	"database/sql"
+	"feature/pkg/config"
+	"fmt"
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUploadFeatureCustomers(t *testing.T) {
+	viper.SetConfigFile("../test.env")
+	if err := viper.ReadInConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open(config.Driver(), config.DSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewStore(db)
+
+	var (
+		existingUUID = uuid.MustParse("bb7fe5b6-24a5-4218-bc61-b487bbad9580")
+		otherUUID    = uuid.MustParse("6f9ae2fd-5e9a-4a3d-a1a8-6a2bb5e5e0a1")
+		refTime      = time.Now().Truncate(time.Second)
+	)
+
+	features := []Feature{
+		{
+			ID:            existingUUID,
+			TechnicalName: "my-feature-1",
+			CreatedAt:     refTime,
+			UpdatedAt:     refTime,
+		},
+		{
+			ID:            otherUUID,
+			TechnicalName: "my-feature-2",
+			CreatedAt:     refTime,
+			UpdatedAt:     refTime,
+		},
+	}
+
+	customers := []Customer{
+		{
+			ID:         uuid.MustParse("0d0e1f6c-7c49-4a4e-9f54-0e5b5c0b1d01"),
+			FeatureID:  existingUUID,
+			CustomerID: "customer-1",
+		},
+		{
+			ID:         uuid.MustParse("0d0e1f6c-7c49-4a4e-9f54-0e5b5c0b1d02"),
+			FeatureID:  existingUUID,
+			CustomerID: "customer-2",
+		},
+		{
+			ID:         uuid.MustParse("0d0e1f6c-7c49-4a4e-9f54-0e5b5c0b1d03"),
+			FeatureID:  otherUUID,
+			CustomerID: "customer-2",
+		},
+	}
+
+	// An upload of more customers than are saved at once, repeating one
+	// across chunks.
+	var (
+		manyBody        strings.Builder
+		manyCustomerIDs = []string{"customer-1", "customer-2"}
+	)
+	for i := 0; i < customerChunkSize+10; i++ {
+		id := fmt.Sprintf("customer-x%04d", i)
+		fmt.Fprintln(&manyBody, id)
+		manyCustomerIDs = append(manyCustomerIDs, id)
+	}
+	fmt.Fprintln(&manyBody, "customer-x0000")
+
+	tests := map[string]struct {
+		featureID   string
+		query       string
+		contentType string
+		body        string
+
+		wantStatus      int
+		wantBody        string
+		wantCustomerIDs []string
+	}{
+		"append newline-delimited customer ids": {
+			featureID: existingUUID.String(),
+			body:      "customer-2\n\ncustomer-3\r\ncustomer-4\ncustomer-3\n",
+
+			wantStatus:      http.StatusOK,
+			wantBody:        `{"added":2,"alreadyPresent":2,"removed":0,"invalid":0,"invalidLines":[]}`,
+			wantCustomerIDs: []string{"customer-1", "customer-2", "customer-3", "customer-4"},
+		},
+		"append customer ids from csv with a header": {
+			featureID:   existingUUID.String(),
+			contentType: "text/csv",
+			body:        "customerId,name\ncustomer-3,\"Acme, Inc.\"\n\"customer-4\",Globex\n",
+
+			wantStatus:      http.StatusOK,
+			wantBody:        `{"added":2,"alreadyPresent":0,"removed":0,"invalid":0,"invalidLines":[]}`,
+			wantCustomerIDs: []string{"customer-1", "customer-2", "customer-3", "customer-4"},
+		},
+		"append more customer ids than are saved at once": {
+			featureID: existingUUID.String(),
+			body:      manyBody.String(),
+
+			wantStatus:      http.StatusOK,
+			wantBody:        fmt.Sprintf(`{"added":%d,"alreadyPresent":1,"removed":0,"invalid":0,"invalidLines":[]}`, customerChunkSize+10),
+			wantCustomerIDs: manyCustomerIDs,
+		},
+		"replace customers": {
+			featureID: existingUUID.String(),
+			query:     "?mode=replace",
+			body:      "customer-2\ncustomer-3\n",
+
+			wantStatus:      http.StatusOK,
+			wantBody:        `{"added":1,"alreadyPresent":1,"removed":1,"invalid":0,"invalidLines":[]}`,
+			wantCustomerIDs: []string{"customer-2", "customer-3"},
+		},
+		"replace customers with an empty file": {
+			featureID: existingUUID.String(),
+			query:     "?mode=replace",
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"added":0,"alreadyPresent":0,"removed":2,"invalid":0,"invalidLines":[]}`,
+		},
+		"skip invalid customer ids": {
+			featureID: existingUUID.String(),
+			body:      "customer-3\ncustomer 4\n" + strings.Repeat("x", 256) + "\ncustomer-5\n",
+
+			wantStatus:      http.StatusOK,
+			wantBody:        `{"added":2,"alreadyPresent":0,"removed":0,"invalid":2,"invalidLines":[2,3]}`,
+			wantCustomerIDs: []string{"customer-1", "customer-2", "customer-3", "customer-5"},
+		},
+		"skip invalid customer ids in csv": {
+			featureID:   existingUUID.String(),
+			contentType: "text/csv; charset=utf-8",
+			body:        "customer-3\n,orphan\ncustomer-4\n",
+
+			wantStatus:      http.StatusOK,
+			wantBody:        `{"added":2,"alreadyPresent":0,"removed":0,"invalid":1,"invalidLines":[2]}`,
+			wantCustomerIDs: []string{"customer-1", "customer-2", "customer-3", "customer-4"},
+		},
+		"unknown mode": {
+			featureID: existingUUID.String(),
+			query:     "?mode=merge",
+			body:      "customer-3\n",
+
+			wantStatus:      http.StatusBadRequest,
+			wantBody:        `{"type":"urn:feature:problem:bad-request","title":"Bad Request","status":400,"detail":"'mode' must be one of append or replace","code":"bad-request"}`,
+			wantCustomerIDs: []string{"customer-1", "customer-2"},
+		},
+		"feature not found": {
+			featureID: "c5fd4b4b-2ba8-4b0e-a7a2-3f1f4c0d5e3a",
+			body:      "customer-3\n",
+
+			wantStatus: http.StatusNotFound,
+			wantBody:   `{"type":"urn:feature:problem:feature-not-found","title":"Not Found","status":404,"detail":"feature c5fd4b4b-2ba8-4b0e-a7a2-3f1f4c0d5e3a does not exist","code":"feature-not-found"}`,
+		},
+		"bad feature id": {
+			featureID: "foo",
+
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"type":"urn:feature:problem:bad-request","title":"Bad Request","status":400,"detail":"parse feature id: invalid UUID length: 3","code":"bad-request"}`,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tx, _, rollback, err := store.beginTx(context.Background(), &sql.TxOptions{
+				Isolation: sql.LevelReadCommitted,
+			})
+			if err != nil {
+				t.Fatalf("failed to begin transaction: %s\n", err)
+			}
+
+			t.Cleanup(func() {
+				if err := rollback(); err != nil {
+					t.Errorf("failed to rollback the transaction: %s\n", err)
+				}
+			})
+
+			setupFeatures(t, *tx, features...)
+			setupCustomers(t, *tx, customers...)
+
+			service := NewService(*tx)
+			service.uuidFunc = func() (uuid.UUID, error) { return uuid.New(), nil }
+			handler := NewHandler(service)
+
+			r := chi.NewRouter()
+			r.Post("/features/{featureId}/customers/upload", handler.UploadFeatureCustomers)
+
+			req := httptest.NewRequest(
+				http.MethodPost,
+				"/features/"+test.featureID+"/customers/upload"+test.query,
+				strings.NewReader(test.body),
+			)
+			if test.contentType != "" {
+				req.Header.Set("Content-Type", test.contentType)
+			}
+			res := httptest.NewRecorder()
+
+			r.ServeHTTP(res, req)
+
+			if res.Code != test.wantStatus {
+				t.Errorf("Status codes not equal.\nwant: %d\ngot:  %d", test.wantStatus, res.Code)
+			}
+
+			resBody := strings.TrimSpace(res.Body.String())
+			if resBody != test.wantBody {
+				t.Errorf("Response bodies not equal.\nwant: %s\ngot:  %s", test.wantBody, resBody)
+			}
+
+			if test.wantStatus == http.StatusOK || test.wantCustomerIDs != nil {
+				gotCustomerIDs, err := tx.FindCustomerIDsByFeatureID(context.Background(), existingUUID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				sort.Strings(gotCustomerIDs)
+				if !reflect.DeepEqual(test.wantCustomerIDs, gotCustomerIDs) {
+					t.Errorf("Customer IDs not equal.\nwant: %v\ngot:  %v", test.wantCustomerIDs, gotCustomerIDs)
+				}
+			}
+
+			// Customers of other features are left alone.
+			otherCustomerIDs, err := tx.FindCustomerIDsByFeatureID(context.Background(), otherUUID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual([]string{"customer-2"}, otherCustomerIDs) {
+				t.Errorf("Customer IDs of other feature not equal.\nwant: [customer-2]\ngot:  %v", otherCustomerIDs)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package feature
+
+import (
+	"github.com/go-chi/chi"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestValidateFeature(t *testing.T) {
+	strict, err := NewValidationPolicy(`^[a-z][a-z0-9]*(-[a-z0-9]+)*$`, 5, 20, []string{"sys-"}, []string{"description", "owner"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		policy *ValidationPolicy
+		body   string
+
+		wantStatus int
+		wantBody   string
+	}{
+		"valid draft": {
+			body: `{"technicalName":"my-feature-1"}`,
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"valid":true,"errors":[]}`,
+		},
+		"default policy": {
+			body: `{"technicalName":"my"}`,
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"valid":false,"errors":["'technicalName' must be at least 5 characters long"]}`,
+		},
+		"valid draft under a strict policy": {
+			policy: &strict,
+			body:   `{"technicalName":"my-feature-1","description":"Does things.","owner":"team-payments"}`,
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"valid":true,"errors":[]}`,
+		},
+		"name violating a strict policy": {
+			policy: &strict,
+			body:   `{"technicalName":"sys-My Feature 🚀 with a long name","description":"Does things.","owner":"team-payments"}`,
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"valid":false,"errors":["'technicalName' must be at most 20 characters long","'technicalName' must match ^[a-z][a-z0-9]*(-[a-z0-9]+)*$","'technicalName' must not start with reserved prefix \"sys-\""]}`,
+		},
+		"missing required fields": {
+			policy: &strict,
+			body:   `{"technicalName":"my-feature-1","description":" ","owner":" ","kind":"release"}`,
+
+			wantStatus: http.StatusOK,
+			wantBody:   `{"valid":false,"errors":["'description' must be set","'owner' must be set","'expiresOn' must be set for release features"]}`,
+		},
+		"request body contains unknown fields": {
+			body: `{"foo":"bar"}`,
+
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"type":"urn:feature:problem:bad-request","title":"Bad Request","status":400,"detail":"decode request body: json: unknown field \"foo\"","code":"bad-request"}`,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			service := NewService(NewMemoryStore())
+			if test.policy != nil {
+				service = service.WithValidationPolicy(*test.policy)
+			}
+			handler := NewHandler(service)
+
+			r := chi.NewRouter()
+			r.Post("/features/validate", handler.ValidateFeature)
+
+			req := httptest.NewRequest(
+				http.MethodPost,
+				"/features/validate",
+				strings.NewReader(test.body),
+			)
+			res := httptest.NewRecorder()
+
+			r.ServeHTTP(res, req)
+
+			if res.Code != test.wantStatus {
+				t.Errorf("Status codes not equal.\nwant: %d\ngot:  %d", test.wantStatus, res.Code)
+			}
+
+			resBody := strings.TrimSpace(res.Body.String())
+			if resBody != test.wantBody {
+				t.Errorf("Response bodies not equal.\nwant: %s\ngot:  %s", test.wantBody, resBody)
+			}
+		})
+	}
+}
+
+func TestNewValidationPolicy(t *testing.T) {
+	tests := map[string]struct {
+		pattern        string
+		min, max       int
+		requiredFields []string
+
+		wantErr string
+	}{
+		"bad pattern": {
+			pattern: `[a-z`,
+			wantErr: "compile name pattern: error parsing regexp: missing closing ]: `[a-z`",
+		},
+		"maximum below minimum": {
+			min: 5, max: 4,
+			wantErr: "maximum name length 4 is less than minimum 5",
+		},
+		"unknown required field": {
+			requiredFields: []string{"colour"},
+			wantErr:        `unknown required field "colour", must be one of description, displayName, expiresOn, kind, owner, tags, ticketUrl`,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			_, err := NewValidationPolicy(test.pattern, test.min, test.max, nil, test.requiredFields)
+			if err == nil || err.Error() != test.wantErr {
+				t.Errorf("Errors not equal.\nwant: %s\ngot:  %v", test.wantErr, err)
+			}
+		})
+	}
+}
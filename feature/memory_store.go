@@ -0,0 +1,1021 @@
+package feature
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"feature/pkg/slices"
+)
+
+// NewMemoryStore initializes and returns a new, empty MemoryStore.
+func NewMemoryStore() MemoryStore {
+	return MemoryStore{root: &memoryRoot{state: newMemoryState()}}
+}
+
+// MemoryStore is a Repository keeping all data in memory, for tests and for
+// running without a database. It mimics the constraints of the SQL schema.
+//
+// Units of work operate on a copy of the data, which replaces the original on
+// Commit. They are serialized: Begin blocks while another unit of work is in
+// progress, and so do writes outside of units of work. Reads outside of units
+// of work see the committed data.
+type MemoryStore struct {
+	root *memoryRoot
+	// tx is the unit of work the store belongs to, if any.
+	tx *memoryTx
+}
+
+type memoryRoot struct {
+	// txMu is held for the duration of a unit of work, or a write outside one.
+	txMu sync.Mutex
+	// mu guards state.
+	mu    sync.RWMutex
+	state *memoryState
+}
+
+type memoryTx struct {
+	state *memoryState
+	// parent is the unit of work this one is nested in, if any.
+	parent *memoryTx
+	done   bool
+}
+
+type memoryState struct {
+	features            []Feature
+	customers           []Customer
+	profiles            []CustomerProfile
+	aliases             []Alias
+	archivedFeatures    []ArchivedFeature
+	usage               map[string]time.Time
+	usageDaily          map[usageKey]int
+	webhooks            []Webhook
+	deliveries          []WebhookDelivery
+	expiryNotifications map[uuid.UUID]time.Time
+}
+
+func newMemoryState() *memoryState {
+	return &memoryState{
+		usage:               make(map[string]time.Time),
+		usageDaily:          make(map[usageKey]int),
+		expiryNotifications: make(map[uuid.UUID]time.Time),
+	}
+}
+
+// clone returns a copy of st. Elements are never modified in place, so copying
+// the containers suffices.
+func (st *memoryState) clone() *memoryState {
+	res := &memoryState{
+		features:            append([]Feature(nil), st.features...),
+		customers:           append([]Customer(nil), st.customers...),
+		profiles:            append([]CustomerProfile(nil), st.profiles...),
+		aliases:             append([]Alias(nil), st.aliases...),
+		archivedFeatures:    append([]ArchivedFeature(nil), st.archivedFeatures...),
+		usage:               make(map[string]time.Time, len(st.usage)),
+		usageDaily:          make(map[usageKey]int, len(st.usageDaily)),
+		webhooks:            append([]Webhook(nil), st.webhooks...),
+		deliveries:          append([]WebhookDelivery(nil), st.deliveries...),
+		expiryNotifications: make(map[uuid.UUID]time.Time, len(st.expiryNotifications)),
+	}
+	for k, v := range st.usage {
+		res.usage[k] = v
+	}
+	for k, v := range st.usageDaily {
+		res.usageDaily[k] = v
+	}
+	for k, v := range st.expiryNotifications {
+		res.expiryNotifications[k] = v
+	}
+	return res
+}
+
+// view calls fn with the data visible to the store.
+func (s MemoryStore) view(fn func(st *memoryState)) {
+	if s.tx != nil {
+		fn(s.tx.state)
+		return
+	}
+	s.root.mu.RLock()
+	defer s.root.mu.RUnlock()
+	fn(s.root.state)
+}
+
+// update calls fn with the data visible to the store, for modification. fn must
+// check for constraint violations before modifying anything, so that failed
+// writes have no effect, like failed statements.
+func (s MemoryStore) update(fn func(st *memoryState) error) error {
+	if s.tx != nil {
+		return fn(s.tx.state)
+	}
+	s.root.txMu.Lock()
+	defer s.root.txMu.Unlock()
+	s.root.mu.Lock()
+	defer s.root.mu.Unlock()
+	return fn(s.root.state)
+}
+
+// Begin implements Repository. opts are ignored, as units of work are
+// serialized anyway.
+func (s MemoryStore) Begin(_ context.Context, _ *sql.TxOptions) (UnitOfWork, error) {
+	tx := &memoryTx{parent: s.tx}
+	if s.tx == nil {
+		s.root.txMu.Lock()
+		s.root.mu.RLock()
+		tx.state = s.root.state.clone()
+		s.root.mu.RUnlock()
+	} else {
+		tx.state = s.tx.state.clone()
+	}
+	return memoryUnitOfWork{MemoryStore: MemoryStore{root: s.root, tx: tx}}, nil
+}
+
+type memoryUnitOfWork struct {
+	MemoryStore
+}
+
+func (u memoryUnitOfWork) Commit() error {
+	tx := u.tx
+	if tx.done {
+		return nil
+	}
+	tx.done = true
+
+	if tx.parent != nil {
+		tx.parent.state = tx.state
+		return nil
+	}
+
+	u.root.mu.Lock()
+	u.root.state = tx.state
+	u.root.mu.Unlock()
+	u.root.txMu.Unlock()
+	return nil
+}
+
+func (u memoryUnitOfWork) Rollback() error {
+	tx := u.tx
+	if tx.done {
+		return nil
+	}
+	tx.done = true
+
+	if tx.parent == nil {
+		u.root.txMu.Unlock()
+	}
+	return nil
+}
+
+func (st *memoryState) featureIndex(id uuid.UUID) int {
+	for i, f := range st.features {
+		if f.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (st *memoryState) customerIDs(featureID uuid.UUID) []string {
+	var ids []string
+	for _, c := range st.customers {
+		if c.FeatureID == featureID {
+			ids = append(ids, c.CustomerID)
+		}
+	}
+	return ids
+}
+
+// storedFeature returns f the way it is stored: in UTC and without customers.
+func storedFeature(f Feature) Feature {
+	f.CreatedAt, f.UpdatedAt = f.CreatedAt.UTC(), f.UpdatedAt.UTC()
+	if f.ExpiresOn != nil {
+		f.ExpiresOn = ptr(f.ExpiresOn.UTC())
+	}
+	if f.KilledAt != nil {
+		f.KilledAt = ptr(f.KilledAt.UTC())
+	}
+	f.CustomerIDs = nil
+	return f
+}
+
+func (s MemoryStore) FindAllFeatures(_ context.Context) ([]Feature, error) {
+	var fs []Feature
+	s.view(func(st *memoryState) {
+		fs = append(fs, st.features...)
+	})
+	return fs, nil
+}
+
+func (s MemoryStore) FindAllFeaturesWithCustomers(_ context.Context) ([]Feature, error) {
+	var fs []Feature
+	s.view(func(st *memoryState) {
+		for _, f := range st.features {
+			f.CustomerIDs = st.customerIDs(f.ID)
+			fs = append(fs, f)
+		}
+	})
+	return fs, nil
+}
+
+func (s MemoryStore) FindFeatures(_ context.Context, q FeatureQuery, t time.Time) (*FeaturePage, error) {
+	var fs []Feature
+	s.view(func(st *memoryState) {
+		for _, f := range st.features {
+			if q.matches(f, st.customerIDs(f.ID), t) {
+				fs = append(fs, f)
+			}
+		}
+	})
+	sort.Slice(fs, func(i, j int) bool { return q.compare(fs[i], fs[j]) < 0 })
+
+	res := FeaturePage{Total: len(fs)}
+	for _, f := range fs {
+		if q.After != nil && q.compare(q.After.feature(), f) >= 0 {
+			continue
+		}
+		if len(res.Features) == q.Limit {
+			res.Next = cursorOf(q, res.Features[q.Limit-1])
+			break
+		}
+		res.Features = append(res.Features, f)
+	}
+	return &res, nil
+}
+
+func (s MemoryStore) SearchFeatures(_ context.Context, q SearchQuery) ([]SearchResult, error) {
+	var ds []searchDocument
+	s.view(func(st *memoryState) {
+		for _, f := range st.features {
+			ds = append(ds, searchDocument{
+				FeatureID:     f.ID,
+				TechnicalName: f.TechnicalName,
+				DisplayName:   f.DisplayName,
+				Description:   f.Description,
+				Tags:          strings.Join(f.Tags, " "),
+			})
+		}
+		if !q.IncludeArchived {
+			return
+		}
+		for _, af := range st.archivedFeatures {
+			ds = append(ds, searchDocument{
+				FeatureID:     af.ID,
+				Archived:      true,
+				TechnicalName: af.TechnicalName,
+				DisplayName:   af.DisplayName,
+				Description:   af.Description,
+			})
+		}
+	})
+	return rankDocuments(ds, searchTerms(q.Text), q.Limit), nil
+}
+
+func (s MemoryStore) FindFeature(_ context.Context, id uuid.UUID) (*Feature, error) {
+	var res *Feature
+	s.view(func(st *memoryState) {
+		if i := st.featureIndex(id); i != -1 {
+			res = ptr(st.features[i])
+		}
+	})
+	if res == nil {
+		return nil, errFeatureNotFound{id: id}
+	}
+	return res, nil
+}
+
+func (s MemoryStore) FindFeatureWithCustomers(_ context.Context, id uuid.UUID) (*Feature, error) {
+	var res *Feature
+	s.view(func(st *memoryState) {
+		if i := st.featureIndex(id); i != -1 {
+			res = ptr(st.features[i])
+			res.CustomerIDs = st.customerIDs(id)
+		}
+	})
+	if res == nil {
+		return nil, errFeatureNotFound{id: id}
+	}
+	return res, nil
+}
+
+func (s MemoryStore) CountFeatures(_ context.Context, t time.Time) (total, expired int, err error) {
+	s.view(func(st *memoryState) {
+		total = len(st.features)
+		for _, f := range st.features {
+			if f.ExpiresOn != nil && f.ExpiresOn.Before(t) {
+				expired++
+			}
+		}
+	})
+	return total, expired, nil
+}
+
+func (s MemoryStore) SaveFeature(_ context.Context, f Feature) error {
+	return s.update(func(st *memoryState) error {
+		for _, other := range st.features {
+			if other.ID == f.ID {
+				return errConstraint("features.id is not unique")
+			}
+			if other.TechnicalName == f.TechnicalName {
+				return errConstraint("features.technical_name is not unique")
+			}
+		}
+		st.features = append(st.features, storedFeature(f))
+		return nil
+	})
+}
+
+func (s MemoryStore) UpdateFeature(_ context.Context, lastUpdatedAt time.Time, f Feature) error {
+	return s.update(func(st *memoryState) error {
+		i := st.featureIndex(f.ID)
+		if i == -1 || st.features[i].UpdatedAt.Unix() != lastUpdatedAt.Unix() {
+			return errFeatureNotFound{id: f.ID}
+		}
+		for _, other := range st.features {
+			if other.ID != f.ID && other.TechnicalName == f.TechnicalName {
+				return errConstraint("features.technical_name is not unique")
+			}
+		}
+
+		updated := storedFeature(f)
+		updated.Killed, updated.KilledAt = st.features[i].Killed, st.features[i].KilledAt
+		updated.CreatedAt = st.features[i].CreatedAt
+		st.features[i] = updated
+		return nil
+	})
+}
+
+func (s MemoryStore) SetFeatureKilled(_ context.Context, id uuid.UUID, killed bool, t time.Time) error {
+	return s.update(func(st *memoryState) error {
+		i := st.featureIndex(id)
+		if i == -1 {
+			return errFeatureNotFound{id: id}
+		}
+		switch {
+		case !killed:
+			st.features[i].KilledAt = nil
+		case st.features[i].KilledAt == nil:
+			st.features[i].KilledAt = ptr(t.UTC())
+		}
+		st.features[i].Killed = killed
+		return nil
+	})
+}
+
+func (s MemoryStore) DeleteFeature(_ context.Context, featureID uuid.UUID) error {
+	return s.update(func(st *memoryState) error {
+		i := st.featureIndex(featureID)
+		if i == -1 {
+			return nil
+		}
+		st.features = append(st.features[:i:i], st.features[i+1:]...)
+
+		var cs []Customer
+		for _, c := range st.customers {
+			if c.FeatureID != featureID {
+				cs = append(cs, c)
+			}
+		}
+		st.customers = cs
+
+		var as []Alias
+		for _, a := range st.aliases {
+			if a.FeatureID != featureID {
+				as = append(as, a)
+			}
+		}
+		st.aliases = as
+
+		delete(st.expiryNotifications, featureID)
+		return nil
+	})
+}
+
+func (s MemoryStore) SaveCustomers(_ context.Context, cs ...Customer) error {
+	type key struct {
+		featureID  uuid.UUID
+		customerID string
+	}
+	return s.update(func(st *memoryState) error {
+		var (
+			ids  = make(map[uuid.UUID]struct{}, len(st.customers)+len(cs))
+			keys = make(map[key]struct{}, len(st.customers)+len(cs))
+		)
+		for _, c := range st.customers {
+			ids[c.ID] = struct{}{}
+			keys[key{c.FeatureID, c.CustomerID}] = struct{}{}
+		}
+		for _, c := range cs {
+			if st.featureIndex(c.FeatureID) == -1 {
+				return errConstraint("customer_features.feature_id refers to missing feature")
+			}
+			if _, ok := ids[c.ID]; ok {
+				return errConstraint("customer_features.id is not unique")
+			}
+			if _, ok := keys[key{c.FeatureID, c.CustomerID}]; ok {
+				return errConstraint("customer_features.customer_id, customer_features.feature_id is not unique")
+			}
+			ids[c.ID] = struct{}{}
+			keys[key{c.FeatureID, c.CustomerID}] = struct{}{}
+		}
+		st.customers = append(st.customers, cs...)
+		return nil
+	})
+}
+
+func (s MemoryStore) SaveMissingCustomers(_ context.Context, featureID uuid.UUID, cs ...Customer) ([]string, error) {
+	var saved []string
+	err := s.update(func(st *memoryState) error {
+		if len(cs) > 0 && st.featureIndex(featureID) == -1 {
+			return errConstraint("customer_features.feature_id refers to missing feature")
+		}
+
+		ids := make(map[uuid.UUID]struct{}, len(st.customers))
+		present := make(map[string]struct{})
+		for _, c := range st.customers {
+			ids[c.ID] = struct{}{}
+			if c.FeatureID == featureID {
+				present[c.CustomerID] = struct{}{}
+			}
+		}
+
+		var added []Customer
+		for _, c := range cs {
+			if _, ok := ids[c.ID]; ok {
+				return errConstraint("customer_features.id is not unique")
+			}
+			if _, ok := present[c.CustomerID]; ok {
+				continue
+			}
+			c.FeatureID = featureID
+			ids[c.ID], present[c.CustomerID] = struct{}{}, struct{}{}
+			added = append(added, c)
+		}
+		st.customers = append(st.customers, added...)
+		saved = slices.Map(func(c Customer) string { return c.CustomerID }, added...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
+
+func (s MemoryStore) DeleteFeatureCustomers(_ context.Context, featureID uuid.UUID, customerIDs ...string) error {
+	if len(customerIDs) == 0 {
+		return nil
+	}
+
+	remove := make(map[string]struct{}, len(customerIDs))
+	for _, id := range customerIDs {
+		remove[id] = struct{}{}
+	}
+
+	return s.update(func(st *memoryState) error {
+		var cs []Customer
+		for _, c := range st.customers {
+			if _, ok := remove[c.CustomerID]; !ok || c.FeatureID != featureID {
+				cs = append(cs, c)
+			}
+		}
+		st.customers = cs
+		return nil
+	})
+}
+
+func (s MemoryStore) FindAllCustomerFeatures(_ context.Context) ([]Customer, error) {
+	var cs []Customer
+	s.view(func(st *memoryState) {
+		cs = append(cs, st.customers...)
+	})
+	return cs, nil
+}
+
+func (s MemoryStore) DeleteCustomersByCustomerIDs(_ context.Context, customerIDs ...string) error {
+	if len(customerIDs) == 0 {
+		return nil
+	}
+
+	remove := make(map[string]struct{}, len(customerIDs))
+	for _, id := range customerIDs {
+		remove[id] = struct{}{}
+	}
+
+	return s.update(func(st *memoryState) error {
+		var cs []Customer
+		for _, c := range st.customers {
+			if _, ok := remove[c.CustomerID]; !ok {
+				cs = append(cs, c)
+			}
+		}
+		st.customers = cs
+		return nil
+	})
+}
+
+func (s MemoryStore) FindCustomerIDsByFeatureID(_ context.Context, featureID uuid.UUID) ([]string, error) {
+	var ids []string
+	s.view(func(st *memoryState) {
+		ids = st.customerIDs(featureID)
+	})
+	return ids, nil
+}
+
+func (s MemoryStore) FindFeaturesByCustomerID(_ context.Context, customerID string) ([]Feature, error) {
+	var fs []Feature
+	s.view(func(st *memoryState) {
+		has := make(map[uuid.UUID]struct{})
+		for _, c := range st.customers {
+			if c.CustomerID == customerID {
+				has[c.FeatureID] = struct{}{}
+			}
+		}
+		for _, f := range st.features {
+			if _, ok := has[f.ID]; ok {
+				fs = append(fs, f)
+			}
+		}
+	})
+	sort.Slice(fs, func(i, j int) bool {
+		return fs[i].TechnicalName < fs[j].TechnicalName
+	})
+	return fs, nil
+}
+
+func (s MemoryStore) RenameCustomerID(_ context.Context, from, to string) error {
+	return s.update(func(st *memoryState) error {
+		hasTo := make(map[uuid.UUID]struct{})
+		for _, c := range st.customers {
+			if c.CustomerID == to {
+				hasTo[c.FeatureID] = struct{}{}
+			}
+		}
+
+		var cs []Customer
+		for _, c := range st.customers {
+			if c.CustomerID == from {
+				if _, ok := hasTo[c.FeatureID]; ok {
+					continue
+				}
+				c.CustomerID = to
+			}
+			cs = append(cs, c)
+		}
+		st.customers = cs
+		return nil
+	})
+}
+
+func (s MemoryStore) CountCustomersByFeature(_ context.Context) (map[uuid.UUID]int, error) {
+	res := make(map[uuid.UUID]int)
+	s.view(func(st *memoryState) {
+		for _, c := range st.customers {
+			res[c.FeatureID]++
+		}
+	})
+	return res, nil
+}
+
+func (s MemoryStore) FindCustomerFeaturesByTechnicalNames(_ context.Context, customerID string, t time.Time, technicalNames ...string) ([]CustomerFeature, error) {
+	if len(technicalNames) == 0 {
+		return nil, nil
+	}
+
+	names := make(map[string]struct{}, len(technicalNames))
+	for _, n := range technicalNames {
+		names[n] = struct{}{}
+	}
+
+	var cfs []CustomerFeature
+	s.view(func(st *memoryState) {
+		for _, f := range st.features {
+			if _, ok := names[f.TechnicalName]; !ok {
+				continue
+			}
+
+			cf := CustomerFeature{
+				TechnicalName: f.TechnicalName,
+				Inverted:      f.Inverted,
+				Killed:        f.Killed,
+				Expired:       f.ExpiresOn != nil && f.ExpiresOn.Before(t),
+			}
+			for _, c := range st.customers {
+				if c.FeatureID == f.ID && c.CustomerID == customerID {
+					cf.HasFeature = true
+					break
+				}
+			}
+			if !cf.HasFeature && len(f.Rules) != 0 {
+				for _, p := range st.profiles {
+					if p.ID == customerID {
+						cf.HasFeature = matchingRule(f.Rules, p.Attributes) != nil
+						break
+					}
+				}
+			}
+			cfs = append(cfs, cf)
+		}
+	})
+	return cfs, nil
+}
+
+// copyProfile returns p with its own attributes, so that callers can't modify
+// the stored ones.
+func copyProfile(p CustomerProfile) CustomerProfile {
+	attributes := make(map[string]string, len(p.Attributes))
+	for k, v := range p.Attributes {
+		attributes[k] = v
+	}
+	p.Attributes = attributes
+	return p
+}
+
+func (s MemoryStore) FindCustomerProfiles(_ context.Context, text string, limit int) ([]CustomerProfile, error) {
+	text = strings.ToLower(text)
+	var res []CustomerProfile
+	s.view(func(st *memoryState) {
+		for _, p := range st.profiles {
+			if len(res) == limit {
+				break
+			}
+			if strings.HasPrefix(strings.ToLower(p.ID), text) ||
+				(p.DisplayName != nil && strings.Contains(strings.ToLower(*p.DisplayName), text)) {
+				res = append(res, copyProfile(p))
+			}
+		}
+	})
+	return res, nil
+}
+
+func (s MemoryStore) FindAllCustomerProfiles(_ context.Context) ([]CustomerProfile, error) {
+	var res []CustomerProfile
+	s.view(func(st *memoryState) {
+		for _, p := range st.profiles {
+			res = append(res, copyProfile(p))
+		}
+	})
+	return res, nil
+}
+
+func (s MemoryStore) FindCustomerProfilesByIDs(_ context.Context, ids ...string) ([]CustomerProfile, error) {
+	wanted := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		wanted[id] = struct{}{}
+	}
+
+	var res []CustomerProfile
+	s.view(func(st *memoryState) {
+		for _, p := range st.profiles {
+			if _, ok := wanted[p.ID]; ok {
+				res = append(res, copyProfile(p))
+			}
+		}
+	})
+	return res, nil
+}
+
+func (s MemoryStore) FindCustomerProfile(_ context.Context, id string) (*CustomerProfile, error) {
+	var res *CustomerProfile
+	s.view(func(st *memoryState) {
+		for _, p := range st.profiles {
+			if p.ID == id {
+				res = ptr(copyProfile(p))
+			}
+		}
+	})
+	if res == nil {
+		return nil, errCustomerNotFound{id: id}
+	}
+	return res, nil
+}
+
+func (s MemoryStore) SaveCustomerProfiles(_ context.Context, ps ...CustomerProfile) error {
+	return s.update(func(st *memoryState) error {
+		for _, p := range ps {
+			p = copyProfile(p)
+			p.CreatedAt, p.UpdatedAt = p.CreatedAt.UTC(), p.UpdatedAt.UTC()
+
+			i := sort.Search(len(st.profiles), func(i int) bool { return st.profiles[i].ID >= p.ID })
+			if i < len(st.profiles) && st.profiles[i].ID == p.ID {
+				p.CreatedAt = st.profiles[i].CreatedAt
+				st.profiles[i] = p
+				continue
+			}
+			st.profiles = append(st.profiles[:i:i], append([]CustomerProfile{p}, st.profiles[i:]...)...)
+		}
+		return nil
+	})
+}
+
+func (s MemoryStore) DeleteCustomerProfile(_ context.Context, id string) error {
+	return s.update(func(st *memoryState) error {
+		for i, p := range st.profiles {
+			if p.ID == id {
+				st.profiles = append(st.profiles[:i:i], st.profiles[i+1:]...)
+				return nil
+			}
+		}
+		return errCustomerNotFound{id: id}
+	})
+}
+
+func (s MemoryStore) FindAllAliases(_ context.Context) ([]Alias, error) {
+	var as []Alias
+	s.view(func(st *memoryState) {
+		as = append(as, st.aliases...)
+	})
+	sortAliases(as)
+	return as, nil
+}
+
+func (s MemoryStore) FindAliasesByFeatureID(_ context.Context, featureID uuid.UUID) ([]Alias, error) {
+	var as []Alias
+	s.view(func(st *memoryState) {
+		for _, a := range st.aliases {
+			if a.FeatureID == featureID {
+				as = append(as, a)
+			}
+		}
+	})
+	sortAliases(as)
+	return as, nil
+}
+
+// sortAliases orders aliases like the SQL queries do.
+func sortAliases(as []Alias) {
+	sort.SliceStable(as, func(i, j int) bool {
+		if !as[i].CreatedAt.Equal(as[j].CreatedAt) {
+			return as[i].CreatedAt.Before(as[j].CreatedAt)
+		}
+		return as[i].TechnicalName < as[j].TechnicalName
+	})
+}
+
+func (s MemoryStore) FindAlias(_ context.Context, technicalName string) (*Alias, error) {
+	var res *Alias
+	s.view(func(st *memoryState) {
+		for _, a := range st.aliases {
+			if a.TechnicalName == technicalName {
+				res = ptr(a)
+			}
+		}
+	})
+	if res == nil {
+		return nil, errAliasNotFound{technicalName: technicalName}
+	}
+	return res, nil
+}
+
+func (s MemoryStore) SaveAlias(_ context.Context, a Alias) error {
+	a.CreatedAt = a.CreatedAt.UTC()
+
+	return s.update(func(st *memoryState) error {
+		if st.featureIndex(a.FeatureID) == -1 {
+			return errConstraint("feature_aliases.feature_id refers to missing feature")
+		}
+		for _, other := range st.aliases {
+			if other.TechnicalName == a.TechnicalName {
+				return errConstraint("feature_aliases.technical_name is not unique")
+			}
+		}
+		st.aliases = append(st.aliases, a)
+		return nil
+	})
+}
+
+func (s MemoryStore) DeleteAlias(_ context.Context, technicalName string) error {
+	return s.update(func(st *memoryState) error {
+		for i, a := range st.aliases {
+			if a.TechnicalName == technicalName {
+				st.aliases = append(st.aliases[:i:i], st.aliases[i+1:]...)
+				return nil
+			}
+		}
+		return errAliasNotFound{technicalName: technicalName}
+	})
+}
+
+func (s MemoryStore) SaveArchivedFeature(_ context.Context, f Feature) error {
+	f = storedFeature(f)
+	return s.update(func(st *memoryState) error {
+		for _, other := range st.archivedFeatures {
+			if other.ID == f.ID {
+				return errConstraint("archived_features.id is not unique")
+			}
+		}
+		st.archivedFeatures = append(st.archivedFeatures, ArchivedFeature{
+			ID:            f.ID,
+			DisplayName:   f.DisplayName,
+			TechnicalName: f.TechnicalName,
+			Description:   f.Description,
+			CreatedAt:     f.CreatedAt,
+			UpdatedAt:     f.UpdatedAt,
+		})
+		return nil
+	})
+}
+
+func (s MemoryStore) FindAllArchivedFeatures(_ context.Context) ([]ArchivedFeature, error) {
+	var afs []ArchivedFeature
+	s.view(func(st *memoryState) {
+		afs = append(afs, st.archivedFeatures...)
+	})
+	return afs, nil
+}
+
+func (s MemoryStore) SaveUsage(_ context.Context, ds ...UsageDelta) error {
+	return s.update(func(st *memoryState) error {
+		for _, d := range ds {
+			if last, ok := st.usage[d.TechnicalName]; !ok || d.LastEvaluatedAt.After(last) {
+				st.usage[d.TechnicalName] = d.LastEvaluatedAt.UTC()
+			}
+			st.usageDaily[usageKey{technicalName: d.TechnicalName, day: d.Day}] += d.Count
+		}
+		return nil
+	})
+}
+
+func (s MemoryStore) DeleteUsageBefore(_ context.Context, t time.Time) error {
+	day := t.UTC().Format(usageDayLayout)
+	return s.update(func(st *memoryState) error {
+		for k := range st.usageDaily {
+			if k.day < day {
+				delete(st.usageDaily, k)
+			}
+		}
+		return nil
+	})
+}
+
+func (s MemoryStore) FindUsage(_ context.Context, t time.Time) ([]Usage, error) {
+	day := t.UTC().Format(usageDayLayout)
+
+	var us []Usage
+	s.view(func(st *memoryState) {
+		for name, last := range st.usage {
+			u := Usage{TechnicalName: name, LastEvaluatedAt: last}
+			for k, n := range st.usageDaily {
+				if k.technicalName == name && day <= k.day {
+					u.EvaluationCount += n
+				}
+			}
+			us = append(us, u)
+		}
+	})
+	sort.Slice(us, func(i, j int) bool { return us[i].TechnicalName < us[j].TechnicalName })
+	return us, nil
+}
+
+func (s MemoryStore) FindAllWebhooks(_ context.Context) ([]Webhook, error) {
+	var whs []Webhook
+	s.view(func(st *memoryState) {
+		whs = append(whs, st.webhooks...)
+	})
+	sort.SliceStable(whs, func(i, j int) bool { return whs[i].CreatedAt.Before(whs[j].CreatedAt) })
+	return whs, nil
+}
+
+func (s MemoryStore) SaveWebhook(_ context.Context, wh Webhook) error {
+	wh.CreatedAt = wh.CreatedAt.UTC()
+	// Stored as JSON array, no events come back as an empty array.
+	wh.Events = append([]string{}, wh.Events...)
+
+	return s.update(func(st *memoryState) error {
+		for _, other := range st.webhooks {
+			if other.ID == wh.ID {
+				return errConstraint("webhooks.id is not unique")
+			}
+		}
+		st.webhooks = append(st.webhooks, wh)
+		return nil
+	})
+}
+
+func (s MemoryStore) DeleteWebhook(_ context.Context, id uuid.UUID) error {
+	return s.update(func(st *memoryState) error {
+		i := -1
+		for j, wh := range st.webhooks {
+			if wh.ID == id {
+				i = j
+			}
+		}
+		if i == -1 {
+			return errWebhookNotFound{id: id}
+		}
+		st.webhooks = append(st.webhooks[:i:i], st.webhooks[i+1:]...)
+
+		var ds []WebhookDelivery
+		for _, d := range st.deliveries {
+			if d.WebhookID != id {
+				ds = append(ds, d)
+			}
+		}
+		st.deliveries = ds
+		return nil
+	})
+}
+
+func (s MemoryStore) SaveWebhookDelivery(_ context.Context, d WebhookDelivery) error {
+	d.NextAttemptAt, d.CreatedAt = d.NextAttemptAt.UTC(), d.CreatedAt.UTC()
+	d.DeliveredAt, d.FailedAt, d.LastError = nil, nil, nil
+	d.URL, d.Secret = "", ""
+
+	return s.update(func(st *memoryState) error {
+		found := false
+		for _, wh := range st.webhooks {
+			found = found || wh.ID == d.WebhookID
+		}
+		if !found {
+			return errConstraint("webhook_outbox.webhook_id refers to missing webhook")
+		}
+		for _, other := range st.deliveries {
+			if other.ID == d.ID {
+				return errConstraint("webhook_outbox.id is not unique")
+			}
+		}
+		st.deliveries = append(st.deliveries, d)
+		return nil
+	})
+}
+
+func (s MemoryStore) ClaimPendingWebhookDeliveries(_ context.Context, t, leaseUntil time.Time, limit int) ([]WebhookDelivery, error) {
+	var ds []WebhookDelivery
+	err := s.update(func(st *memoryState) error {
+		var due []int
+		for i, d := range st.deliveries {
+			if d.DeliveredAt == nil && d.FailedAt == nil && !d.NextAttemptAt.After(t) {
+				due = append(due, i)
+			}
+		}
+		sort.SliceStable(due, func(i, j int) bool {
+			return st.deliveries[due[i]].NextAttemptAt.Before(st.deliveries[due[j]].NextAttemptAt)
+		})
+		if len(due) > limit {
+			due = due[:limit]
+		}
+
+		whs := make(map[uuid.UUID]Webhook, len(st.webhooks))
+		for _, wh := range st.webhooks {
+			whs[wh.ID] = wh
+		}
+		for _, i := range due {
+			st.deliveries[i].NextAttemptAt = leaseUntil.UTC()
+			d := st.deliveries[i]
+			d.URL, d.Secret = whs[d.WebhookID].URL, whs[d.WebhookID].Secret
+			d.LastError = nil
+			ds = append(ds, d)
+		}
+		return nil
+	})
+	return ds, err
+}
+
+func (s MemoryStore) UpdateWebhookDelivery(_ context.Context, d WebhookDelivery) error {
+	return s.update(func(st *memoryState) error {
+		for i, other := range st.deliveries {
+			if other.ID != d.ID {
+				continue
+			}
+			other.Attempts = d.Attempts
+			other.NextAttemptAt = d.NextAttemptAt.UTC()
+			other.DeliveredAt, other.FailedAt, other.LastError = nil, nil, d.LastError
+			if d.DeliveredAt != nil {
+				other.DeliveredAt = ptr(d.DeliveredAt.UTC())
+			}
+			if d.FailedAt != nil {
+				other.FailedAt = ptr(d.FailedAt.UTC())
+			}
+			st.deliveries[i] = other
+		}
+		return nil
+	})
+}
+
+func (s MemoryStore) FindUnpublishedExpiredFeatures(_ context.Context, t time.Time) ([]Feature, error) {
+	var fs []Feature
+	s.view(func(st *memoryState) {
+		for _, f := range st.features {
+			if f.ExpiresOn == nil || !f.ExpiresOn.Before(t) {
+				continue
+			}
+			if n, ok := st.expiryNotifications[f.ID]; ok && n.Equal(*f.ExpiresOn) {
+				continue
+			}
+			fs = append(fs, f)
+		}
+	})
+	return fs, nil
+}
+
+func (s MemoryStore) SaveExpiryNotification(_ context.Context, featureID uuid.UUID, expiresOn time.Time) error {
+	return s.update(func(st *memoryState) error {
+		if st.featureIndex(featureID) == -1 {
+			return errConstraint("feature_expiry_notifications.feature_id refers to missing feature")
+		}
+		st.expiryNotifications[featureID] = expiresOn.UTC()
+		return nil
+	})
+}
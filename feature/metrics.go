@@ -0,0 +1,129 @@
+package feature
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	storeQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "feature",
+		Subsystem: "store",
+		Name:      "query_duration_seconds",
+		Help:      "Latency of database queries, partitioned by store method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+
+	evaluationsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "feature",
+		Name:      "evaluations_total",
+		Help:      "Number of feature evaluations, partitioned by technical name and result.",
+	}, []string{"technical_name", "result"})
+
+	evaluationCacheRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "feature",
+		Subsystem: "evaluation_cache",
+		Name:      "requests_total",
+		Help:      "Number of evaluation cache lookups, partitioned by result (hit or miss).",
+	}, []string{"result"})
+)
+
+// observeQuery starts timing a store method. The returned func must be called
+// once the query completes, usually via defer.
+func observeQuery(method string) func() {
+	start := time.Now()
+	return func() {
+		storeQueryDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+	}
+}
+
+func observeEvaluations(cfs []CustomerFeature) {
+	for _, cf := range cfs {
+		result := "inactive"
+		if cf.isActive() {
+			result = "active"
+		}
+		evaluationsTotal.WithLabelValues(cf.TechnicalName, result).Inc()
+	}
+}
+
+// NewStateCollector initializes a Prometheus collector, which reports the
+// number of features by state each time metrics are scraped.
+func NewStateCollector(store Repository) prometheus.Collector {
+	return stateCollector{
+		store:    store,
+		timeFunc: time.Now,
+		desc: prometheus.NewDesc(
+			"feature_features",
+			"Number of features, partitioned by state.",
+			[]string{"state"},
+			nil,
+		),
+	}
+}
+
+type stateCollector struct {
+	store    Repository
+	timeFunc func() time.Time
+	desc     *prometheus.Desc
+}
+
+// Describe implements the prometheus.Collector interface.
+func (c stateCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+// Collect implements the prometheus.Collector interface.
+func (c stateCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	total, expired, err := c.store.CountFeatures(ctx, c.timeFunc())
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.desc, err)
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(total-expired), "active")
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(expired), "expired")
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(total), "total")
+}
+
+// NewEvaluationCacheCollector initializes a Prometheus collector, which reports
+// the age of the evaluation snapshot of svc each time metrics are scraped. No
+// age is reported while no snapshot is loaded.
+func NewEvaluationCacheCollector(svc Service) prometheus.Collector {
+	return evaluationCacheCollector{
+		cache:    svc.cache,
+		timeFunc: time.Now,
+		desc: prometheus.NewDesc(
+			"feature_evaluation_cache_age_seconds",
+			"Time since the evaluation snapshot was loaded from the store.",
+			nil,
+			nil,
+		),
+	}
+}
+
+type evaluationCacheCollector struct {
+	cache    *evaluationCache
+	timeFunc func() time.Time
+	desc     *prometheus.Desc
+}
+
+// Describe implements the prometheus.Collector interface.
+func (c evaluationCacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+// Collect implements the prometheus.Collector interface.
+func (c evaluationCacheCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.cache.current()
+	if s == nil {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, c.timeFunc().Sub(s.loadedAt).Seconds())
+}
@@ -0,0 +1,66 @@
+package feature
+
+import (
+	"context"
+	"database/sql"
+	"feature/pkg/config"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/spf13/viper"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStateCollector(t *testing.T) {
+	viper.SetConfigFile("../test.env")
+	if err := viper.ReadInConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open(config.Driver(), config.DSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewStore(db)
+
+	tx, _, rollback, err := store.beginTx(context.Background(), &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+	if err != nil {
+		t.Fatalf("failed to begin transaction: %s\n", err)
+	}
+
+	t.Cleanup(func() {
+		if err := rollback(); err != nil {
+			t.Errorf("failed to rollback the transaction: %s\n", err)
+		}
+	})
+
+	var (
+		refTime   = time.Now().Truncate(time.Second).UTC()
+		oneDayAgo = refTime.AddDate(0, 0, -1)
+		inOneDay  = refTime.AddDate(0, 0, 1)
+	)
+
+	setupFeatures(t, *tx,
+		Feature{ID: uuid.New(), TechnicalName: "feature-1", CreatedAt: refTime, UpdatedAt: refTime},
+		Feature{ID: uuid.New(), TechnicalName: "feature-2", ExpiresOn: &inOneDay, CreatedAt: refTime, UpdatedAt: refTime},
+		Feature{ID: uuid.New(), TechnicalName: "feature-3", ExpiresOn: &oneDayAgo, CreatedAt: refTime, UpdatedAt: refTime},
+	)
+
+	c := NewStateCollector(*tx).(stateCollector)
+	c.timeFunc = func() time.Time { return refTime }
+
+	want := `
+# HELP feature_features Number of features, partitioned by state.
+# TYPE feature_features gauge
+feature_features{state="active"} 2
+feature_features{state="expired"} 1
+feature_features{state="total"} 3
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+}
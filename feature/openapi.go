@@ -0,0 +1,71 @@
+package feature
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/rs/zerolog/hlog"
+	"gopkg.in/yaml.v3"
+
+	"feature/pkg/render"
+)
+
+// openAPISpec is the OpenAPI document describing the routes of Handler. It is
+// kept in YAML for editing, and served as JSON.
+//
+//go:embed openapi.yaml
+var openAPISpec []byte
+
+// loadOpenAPISpec decodes openAPISpec into values that encode as JSON.
+func loadOpenAPISpec() (map[string]interface{}, error) {
+	var res map[string]interface{}
+	if err := yaml.Unmarshal(openAPISpec, &res); err != nil {
+		return nil, fmt.Errorf("decode OpenAPI spec: %w", err)
+	}
+	return res, nil
+}
+
+// OpenAPI renders the OpenAPI document describing the API.
+func (h Handler) OpenAPI(w http.ResponseWriter, r *http.Request) {
+	spec, err := loadOpenAPISpec()
+	if err != nil {
+		hlog.FromRequest(r).
+			Error().
+			Err(err).
+			Msg("failed to load OpenAPI spec")
+		render.Error(w, r, err)
+		return
+	}
+
+	render.CachedJSON(w, r, spec)
+}
+
+// apiDocs holds the page to browse the OpenAPI document with, along with a
+// vendored copy of Swagger UI, so that it works without reaching out to a CDN.
+//
+//go:embed apidocs
+var apiDocs embed.FS
+
+// APIDocs renders a page to browse the OpenAPI document of the API.
+func (h Handler) APIDocs(w http.ResponseWriter, r *http.Request) {
+	serveAPIDocsFile(w, r, "index.html")
+}
+
+// APIDocsAsset renders the scripts and styles of the page rendered by APIDocs.
+func (h Handler) APIDocsAsset(w http.ResponseWriter, r *http.Request) {
+	serveAPIDocsFile(w, r, chi.URLParam(r, "file"))
+}
+
+func serveAPIDocsFile(w http.ResponseWriter, r *http.Request, name string) {
+	b, err := apiDocs.ReadFile(path.Join("apidocs", name))
+	if err != nil {
+		render.Error(w, r, render.NewNotFound(fmt.Sprintf("file %q does not exist", name)))
+		return
+	}
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(b))
+}
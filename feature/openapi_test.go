@@ -0,0 +1,283 @@
+package feature
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+
+	"feature/pkg/render"
+	"feature/pkg/set"
+)
+
+// TestOpenAPISchemas fails when the request and response bodies of Handler
+// drift apart from the schemas of the OpenAPI document: every property must be
+// a JSON field of the same type, and the other way around.
+func TestOpenAPISchemas(t *testing.T) {
+	spec, err := loadOpenAPISpec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	schemas := spec["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+
+	types := map[string]reflect.Type{
+		"Feature":                       reflect.TypeOf(featureResponse{}),
+		"FeaturePage":                   reflect.TypeOf(featurePageResponse{}),
+		"SaveFeature":                   reflect.TypeOf(saveFeatureRequest{}),
+		"TargetingRule":                 reflect.TypeOf(TargetingRule{}),
+		"UpdateFeature":                 reflect.TypeOf(updateFeatureRequest{}),
+		"ValidationResult":              reflect.TypeOf(validateFeatureResponse{}),
+		"SearchResults":                 reflect.TypeOf(searchResultsResponse{}),
+		"SearchResult":                  reflect.TypeOf(searchResultResponse{}),
+		"StaleFeatures":                 reflect.TypeOf(staleFeaturesResponse{}),
+		"StaleFeature":                  reflect.TypeOf(staleFeatureResponse{}),
+		"Aliases":                       reflect.TypeOf(aliasesResponse{}),
+		"Alias":                         reflect.TypeOf(aliasResponse{}),
+		"ArchiveFeature":                reflect.TypeOf(createArchivedFeatureRequest{}),
+		"AddCustomers":                  reflect.TypeOf(saveFeatureCustomersRequest{}),
+		"AddedCustomers":                reflect.TypeOf(saveFeatureCustomersResponse{}),
+		"CustomerUploadReport":          reflect.TypeOf(customerUploadResponse{}),
+		"Customers":                     reflect.TypeOf(customersResponse{}),
+		"Customer":                      reflect.TypeOf(customerResponse{}),
+		"SaveCustomer":                  reflect.TypeOf(saveCustomerRequest{}),
+		"CustomerDirectoryUploadReport": reflect.TypeOf(customerProfileUploadResponse{}),
+		"RenameCustomer":                reflect.TypeOf(renameCustomerRequest{}),
+		"CustomerRename":                reflect.TypeOf(renameCustomerResponse{}),
+		"RenamedFeature":                reflect.TypeOf(renamedFeatureResponse{}),
+		"FeatureRequest":                reflect.TypeOf(featureRequest{}),
+		"CustomerFeatures":              reflect.TypeOf(customerFeaturesResponse{}),
+		"CustomerFeature":               reflect.TypeOf(customerFeatureResponse{}),
+		"EvaluationExplanation":         reflect.TypeOf(explainedFeaturesResponse{}),
+		"ExplainedFeature":              reflect.TypeOf(explainedFeatureResponse{}),
+		"ExplanationStep":               reflect.TypeOf(explanationStepResponse{}),
+		"SaveWebhook":                   reflect.TypeOf(saveWebhookRequest{}),
+		"Webhook":                       reflect.TypeOf(webhookResponse{}),
+		"Document":                      reflect.TypeOf(Document{}),
+		"DocumentFeature":               reflect.TypeOf(DocumentFeature{}),
+		"DocumentArchivedFeature":       reflect.TypeOf(DocumentArchivedFeature{}),
+		"DocumentCustomer":              reflect.TypeOf(DocumentCustomer{}),
+		"ImportReport":                  reflect.TypeOf(ImportReport{}),
+		"ImportChange":                  reflect.TypeOf(ImportChange{}),
+		"Problem":                       reflect.TypeOf(render.Problem{}),
+		"FieldError":                    reflect.TypeOf(render.FieldError{}),
+	}
+
+	for name, schema := range schemas {
+		if _, ok := types[name]; !ok && schema.(map[string]interface{})["type"] != "string" {
+			t.Errorf("Schema %s is not checked against the type of a body", name)
+		}
+	}
+
+	for name, typ := range types {
+		name, typ := name, typ
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			schema, ok := schemas[name].(map[string]interface{})
+			if !ok {
+				t.Fatalf("Schema %s is missing", name)
+			}
+			assertSchema(t, schemas, name, schema, typ)
+		})
+	}
+}
+
+// TestAPIDocs fails when the docs page refers to scripts or styles that aren't
+// served along with it.
+func TestAPIDocs(t *testing.T) {
+	handler := NewHandler(NewService(NewMemoryStore()))
+
+	r := chi.NewRouter()
+	r.Get("/docs", handler.APIDocs)
+	r.Get("/docs/{file}", handler.APIDocsAsset)
+
+	res := httptest.NewRecorder()
+	r.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	if res.Code != http.StatusOK {
+		t.Fatalf("Status codes not equal.\nwant: %d\ngot:  %d", http.StatusOK, res.Code)
+	}
+
+	refs := regexp.MustCompile(`(?:src|href)="([^"]+)"`).FindAllStringSubmatch(res.Body.String(), -1)
+	if len(refs) == 0 {
+		t.Fatal("Expected the docs page to refer to scripts and styles")
+	}
+	for _, ref := range refs {
+		res := httptest.NewRecorder()
+		r.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/"+ref[1], nil))
+		if res.Code != http.StatusOK {
+			t.Errorf("%s: status codes not equal.\nwant: %d\ngot:  %d", ref[1], http.StatusOK, res.Code)
+		}
+	}
+
+	res = httptest.NewRecorder()
+	r.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/docs/missing.js", nil))
+	if res.Code != http.StatusNotFound {
+		t.Errorf("Status codes not equal.\nwant: %d\ngot:  %d", http.StatusNotFound, res.Code)
+	}
+}
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	uuidType = reflect.TypeOf(uuid.UUID{})
+)
+
+// assertSchema reports the differences between schema and the JSON encoding
+// of typ, at the given path of the schema.
+func assertSchema(t *testing.T, schemas map[string]interface{}, path string, schema map[string]interface{}, typ reflect.Type) {
+	t.Helper()
+
+	if ref, ok := schema["$ref"].(string); ok {
+		name := strings.TrimPrefix(ref, "#/components/schemas/")
+		resolved, ok := schemas[name].(map[string]interface{})
+		if !ok {
+			t.Errorf("%s: unknown schema %s", path, ref)
+			return
+		}
+		schema = resolved
+	}
+
+	nullable, _ := schema["nullable"].(bool)
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+		if !nullable {
+			t.Errorf("%s: must be nullable, like %s", path, typ)
+		}
+	} else if nullable {
+		t.Errorf("%s: must not be nullable, like %s", path, typ)
+	}
+
+	assertType := func(want string) bool {
+		if got := schemaType(schema); got != want {
+			t.Errorf("%s: type not equal.\nwant: %s\ngot:  %s", path, want, got)
+			return false
+		}
+		return true
+	}
+
+	switch {
+	case typ == timeType:
+		if assertType("string") && schema["format"] != "date-time" {
+			t.Errorf("%s: format must be date-time", path)
+		}
+	case typ == uuidType:
+		if assertType("string") && schema["format"] != "uuid" {
+			t.Errorf("%s: format must be uuid", path)
+		}
+	case typ.Kind() == reflect.String:
+		assertType("string")
+	case typ.Kind() == reflect.Bool:
+		assertType("boolean")
+	case typ.Kind() == reflect.Int || typ.Kind() == reflect.Int64:
+		assertType("integer")
+	case typ.Kind() == reflect.Slice:
+		if assertType("array") {
+			items, _ := schema["items"].(map[string]interface{})
+			assertSchema(t, schemas, path+"[]", items, typ.Elem())
+		}
+	case typ.Kind() == reflect.Map:
+		if assertType("object") {
+			values, _ := schema["additionalProperties"].(map[string]interface{})
+			assertSchema(t, schemas, path+"{}", values, typ.Elem())
+		}
+	case typ.Kind() == reflect.Struct:
+		if !assertType("object") {
+			return
+		}
+
+		fields := jsonFields(typ)
+		props := schemaProperties(schemas, schema)
+
+		var names []string
+		for name := range fields {
+			names = append(names, name)
+		}
+		for name := range props {
+			names = append(names, name)
+		}
+		names = set.Of(names...).ToSlice()
+		sort.Strings(names)
+
+		for _, name := range names {
+			f, hasField := fields[name]
+			p, hasProp := props[name]
+			switch {
+			case !hasProp:
+				t.Errorf("%s: property %s is missing", path, name)
+			case !hasField:
+				t.Errorf("%s: property %s is not a field of %s", path, name, typ)
+			default:
+				assertSchema(t, schemas, path+"."+name, p, f.Type)
+			}
+		}
+	default:
+		t.Errorf("%s: no schema type for %s", path, typ)
+	}
+}
+
+// schemaType returns the type of schema, or object for compositions.
+func schemaType(schema map[string]interface{}) string {
+	if _, ok := schema["allOf"]; ok {
+		return "object"
+	}
+	typ, _ := schema["type"].(string)
+	return typ
+}
+
+// schemaProperties returns the properties of an object schema, including the
+// ones of the schemas it is composed of with allOf.
+func schemaProperties(schemas map[string]interface{}, schema map[string]interface{}) map[string]map[string]interface{} {
+	res := map[string]map[string]interface{}{}
+	if ref, ok := schema["$ref"].(string); ok {
+		schema, _ = schemas[strings.TrimPrefix(ref, "#/components/schemas/")].(map[string]interface{})
+	}
+
+	props, _ := schema["properties"].(map[string]interface{})
+	for name, p := range props {
+		res[name], _ = p.(map[string]interface{})
+	}
+
+	parts, _ := schema["allOf"].([]interface{})
+	for _, part := range parts {
+		part, _ := part.(map[string]interface{})
+		for name, p := range schemaProperties(schemas, part) {
+			res[name] = p
+		}
+	}
+	return res
+}
+
+// jsonFields returns the fields of a struct type by the names encoding/json
+// gives them, including the fields of embedded structs.
+func jsonFields(typ reflect.Type) map[string]reflect.StructField {
+	res := map[string]reflect.StructField{}
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			for n, ef := range jsonFields(f.Type) {
+				res[n] = ef
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		res[name] = f
+	}
+	return res
+}
@@ -0,0 +1,118 @@
+package feature
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ValidationPolicy holds the configurable rules features are validated against,
+// on top of the fixed ones.
+type ValidationPolicy struct {
+	// NamePattern must match technical names. Nil if any name is allowed.
+	NamePattern *regexp.Regexp
+	// MinNameLength and MaxNameLength bound the length of technical names, in
+	// characters. A MaxNameLength of 0 means no maximum.
+	MinNameLength, MaxNameLength int
+	// ReservedPrefixes are prefixes technical names must not start with.
+	ReservedPrefixes []string
+	// RequiredFields are the JSON names of optional fields every feature must
+	// set, see requiredFields.
+	RequiredFields []string
+}
+
+// DefaultValidationPolicy only requires technical names to be at least 5
+// characters long.
+func DefaultValidationPolicy() ValidationPolicy {
+	return ValidationPolicy{MinNameLength: 5}
+}
+
+// NewValidationPolicy compiles a policy from its configuration. An empty
+// pattern allows any name.
+func NewValidationPolicy(pattern string, minNameLength, maxNameLength int, reservedPrefixes, requiredFields []string) (ValidationPolicy, error) {
+	res := ValidationPolicy{
+		MinNameLength:    minNameLength,
+		MaxNameLength:    maxNameLength,
+		ReservedPrefixes: reservedPrefixes,
+		RequiredFields:   requiredFields,
+	}
+
+	if pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return ValidationPolicy{}, fmt.Errorf("compile name pattern: %w", err)
+		}
+		res.NamePattern = re
+	}
+
+	if maxNameLength != 0 && maxNameLength < minNameLength {
+		return ValidationPolicy{}, fmt.Errorf("maximum name length %d is less than minimum %d", maxNameLength, minNameLength)
+	}
+
+	for _, f := range requiredFields {
+		if _, ok := fieldIsSet[f]; !ok {
+			return ValidationPolicy{}, fmt.Errorf("unknown required field %q, must be one of %s", f, strings.Join(requirableFields(), ", "))
+		}
+	}
+
+	return res, nil
+}
+
+// fieldIsSet tells for the fields that can be required whether a feature sets
+// them.
+var fieldIsSet = map[string]func(f Feature) bool{
+	"displayName": func(f Feature) bool { return f.DisplayName != nil && strings.TrimSpace(*f.DisplayName) != "" },
+	"description": func(f Feature) bool { return f.Description != nil && strings.TrimSpace(*f.Description) != "" },
+	"expiresOn":   func(f Feature) bool { return f.ExpiresOn != nil },
+	"tags":        func(f Feature) bool { return len(f.Tags) != 0 },
+	"owner":       func(f Feature) bool { return f.Owner != nil },
+	"ticketUrl":   func(f Feature) bool { return f.TicketURL != nil },
+	"kind":        func(f Feature) bool { return f.Kind != "" },
+}
+
+func requirableFields() []string {
+	res := make([]string, 0, len(fieldIsSet))
+	for f := range fieldIsSet {
+		res = append(res, f)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// validateName returns the violations of the policy by the technical name of a
+// feature.
+func (p ValidationPolicy) validateName(name string) []string {
+	var res []string
+
+	if n := utf8.RuneCountInString(name); n < p.MinNameLength {
+		res = append(res, fmt.Sprintf("'technicalName' must be at least %d characters long", p.MinNameLength))
+	} else if p.MaxNameLength != 0 && n > p.MaxNameLength {
+		res = append(res, fmt.Sprintf("'technicalName' must be at most %d characters long", p.MaxNameLength))
+	}
+
+	if p.NamePattern != nil && !p.NamePattern.MatchString(name) {
+		res = append(res, fmt.Sprintf("'technicalName' must match %s", p.NamePattern))
+	}
+
+	for _, prefix := range p.ReservedPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			res = append(res, fmt.Sprintf("'technicalName' must not start with reserved prefix %q", prefix))
+		}
+	}
+
+	return res
+}
+
+// validateRequired returns the violations of the required fields of the policy
+// by a feature.
+func (p ValidationPolicy) validateRequired(f Feature) []string {
+	var res []string
+	for _, field := range p.RequiredFields {
+		if isSet, ok := fieldIsSet[field]; ok && !isSet(f) {
+			res = append(res, fmt.Sprintf("'%s' must be set", field))
+		}
+	}
+	return res
+}
@@ -0,0 +1,164 @@
+package feature
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Repository persists feature data. Store keeps it in SQLite or PostgreSQL,
+// MemoryStore in memory.
+type Repository interface {
+	FeatureRepository
+	CustomerRepository
+	DirectoryRepository
+	AliasRepository
+	ArchiveRepository
+	UsageRepository
+	WebhookRepository
+
+	// Begin starts a unit of work. Calling Begin on a unit of work nests: the
+	// nested unit of work is persisted along with the outer one, but can be
+	// rolled back on its own. Implementations may ignore opts.
+	Begin(ctx context.Context, opts *sql.TxOptions) (UnitOfWork, error)
+}
+
+// UnitOfWork is a Repository whose changes are persisted atomically.
+type UnitOfWork interface {
+	Repository
+
+	// Commit persists the changes made through the unit of work.
+	Commit() error
+
+	// Rollback discards the changes made through the unit of work. It has no
+	// effect after Commit, so that it can be deferred.
+	Rollback() error
+}
+
+// FeatureRepository persists features.
+type FeatureRepository interface {
+	FindAllFeatures(ctx context.Context) ([]Feature, error)
+	FindAllFeaturesWithCustomers(ctx context.Context) ([]Feature, error)
+	// FindFeatures returns the page of features selected by q, with expiry
+	// judged at time t.
+	FindFeatures(ctx context.Context, q FeatureQuery, t time.Time) (*FeaturePage, error)
+	// SearchFeatures returns the features, and archived features if asked for,
+	// matching q.Text, best matches first.
+	SearchFeatures(ctx context.Context, q SearchQuery) ([]SearchResult, error)
+	FindFeature(ctx context.Context, id uuid.UUID) (*Feature, error)
+	FindFeatureWithCustomers(ctx context.Context, id uuid.UUID) (*Feature, error)
+	CountFeatures(ctx context.Context, t time.Time) (total, expired int, err error)
+	SaveFeature(ctx context.Context, f Feature) error
+	// UpdateFeature fails with a not found error if the feature doesn't exist,
+	// or has been updated since lastUpdatedAt.
+	UpdateFeature(ctx context.Context, lastUpdatedAt time.Time, f Feature) error
+	SetFeatureKilled(ctx context.Context, id uuid.UUID, killed bool, t time.Time) error
+	// DeleteFeature deletes the feature along with its customers.
+	DeleteFeature(ctx context.Context, featureID uuid.UUID) error
+}
+
+// CustomerRepository persists the customers of features.
+type CustomerRepository interface {
+	SaveCustomers(ctx context.Context, cs ...Customer) error
+	// SaveMissingCustomers saves the customers the feature doesn't have yet,
+	// ignoring the others, and returns the customer IDs of the ones saved.
+	// The feature IDs of cs are ignored.
+	SaveMissingCustomers(ctx context.Context, featureID uuid.UUID, cs ...Customer) ([]string, error)
+	// DeleteFeatureCustomers deletes the given customers of a single feature.
+	DeleteFeatureCustomers(ctx context.Context, featureID uuid.UUID, customerIDs ...string) error
+	FindAllCustomerFeatures(ctx context.Context) ([]Customer, error)
+	// DeleteCustomersByCustomerIDs deletes the given customers from every
+	// feature. Use DeleteFeatureCustomers to only delete them from one.
+	DeleteCustomersByCustomerIDs(ctx context.Context, customerIDs ...string) error
+	FindCustomerIDsByFeatureID(ctx context.Context, featureID uuid.UUID) ([]string, error)
+	// FindFeaturesByCustomerID returns the features the customer has, without
+	// their customers, ordered by technical name.
+	FindFeaturesByCustomerID(ctx context.Context, customerID string) ([]Feature, error)
+	// RenameCustomerID replaces the customer ID from with to in every feature.
+	// Features that already have to lose from instead.
+	RenameCustomerID(ctx context.Context, from, to string) error
+	CountCustomersByFeature(ctx context.Context) (map[uuid.UUID]int, error)
+	FindCustomerFeaturesByTechnicalNames(ctx context.Context, customerID string, t time.Time, technicalNames ...string) ([]CustomerFeature, error)
+}
+
+// DirectoryRepository persists the customer directory.
+type DirectoryRepository interface {
+	// FindCustomerProfiles returns up to limit profiles whose ID starts with
+	// text, or whose display name contains it, ignoring case. They are ordered
+	// by ID.
+	FindCustomerProfiles(ctx context.Context, text string, limit int) ([]CustomerProfile, error)
+	// FindAllCustomerProfiles returns every profile, ordered by ID.
+	FindAllCustomerProfiles(ctx context.Context) ([]CustomerProfile, error)
+	FindCustomerProfilesByIDs(ctx context.Context, ids ...string) ([]CustomerProfile, error)
+	FindCustomerProfile(ctx context.Context, id string) (*CustomerProfile, error)
+	// SaveCustomerProfiles creates the given profiles, or replaces the ones
+	// that exist, keeping their CreatedAt.
+	SaveCustomerProfiles(ctx context.Context, ps ...CustomerProfile) error
+	DeleteCustomerProfile(ctx context.Context, id string) error
+}
+
+// AliasRepository persists the former technical names of features.
+type AliasRepository interface {
+	FindAllAliases(ctx context.Context) ([]Alias, error)
+	FindAliasesByFeatureID(ctx context.Context, featureID uuid.UUID) ([]Alias, error)
+	// FindAlias fails with a not found error if there is no such alias.
+	FindAlias(ctx context.Context, technicalName string) (*Alias, error)
+	SaveAlias(ctx context.Context, a Alias) error
+	// DeleteAlias fails with a not found error if there is no such alias.
+	DeleteAlias(ctx context.Context, technicalName string) error
+}
+
+// ArchiveRepository persists archived features.
+type ArchiveRepository interface {
+	SaveArchivedFeature(ctx context.Context, f Feature) error
+	FindAllArchivedFeatures(ctx context.Context) ([]ArchivedFeature, error)
+}
+
+// UsageRepository persists the usage of features by evaluations.
+type UsageRepository interface {
+	SaveUsage(ctx context.Context, ds ...UsageDelta) error
+	DeleteUsageBefore(ctx context.Context, t time.Time) error
+	FindUsage(ctx context.Context, t time.Time) ([]Usage, error)
+}
+
+// WebhookRepository persists webhook subscriptions and their outbox.
+type WebhookRepository interface {
+	FindAllWebhooks(ctx context.Context) ([]Webhook, error)
+	SaveWebhook(ctx context.Context, wh Webhook) error
+	DeleteWebhook(ctx context.Context, id uuid.UUID) error
+	SaveWebhookDelivery(ctx context.Context, d WebhookDelivery) error
+	// ClaimPendingWebhookDeliveries returns at most limit deliveries that are
+	// due for an attempt at time t, along with the URL and secret of their
+	// webhooks. The deliveries are leased until leaseUntil: they aren't due
+	// before, unless updated, so that concurrent callers claim others.
+	ClaimPendingWebhookDeliveries(ctx context.Context, t, leaseUntil time.Time, limit int) ([]WebhookDelivery, error)
+	UpdateWebhookDelivery(ctx context.Context, d WebhookDelivery) error
+	FindUnpublishedExpiredFeatures(ctx context.Context, t time.Time) ([]Feature, error)
+	SaveExpiryNotification(ctx context.Context, featureID uuid.UUID, expiresOn time.Time) error
+}
+
+var (
+	_ Repository = Store{}
+	_ Repository = MemoryStore{}
+)
+
+// errConstraint is returned when a write violates a unique or foreign key
+// constraint of the SQL schema. Store translates the errors of the drivers to
+// it, and MemoryStore mimics them.
+type errConstraint string
+
+func (e errConstraint) Error() string {
+	return fmt.Sprintf("constraint failed: %s", string(e))
+}
+
+func (e errConstraint) Code() int {
+	return http.StatusConflict
+}
+
+func (e errConstraint) ErrorCode() string {
+	return "constraint-violation"
+}
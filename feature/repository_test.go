@@ -0,0 +1,581 @@
+package feature
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"feature/pkg/config"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestRepository runs the same scenarios against every Repository
+// implementation, so that MemoryStore keeps behaving like Store.
+func TestRepository(t *testing.T) {
+	viper.SetConfigFile("../test.env")
+	if err := viper.ReadInConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open(config.Driver(), config.DSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewStore(db)
+
+	var (
+		featureUUID = uuid.MustParse("bb7fe5b6-24a5-4218-bc61-b487bbad9580")
+		otherUUID   = uuid.MustParse("e2bd6e9c-04b5-4d2e-a4b7-2b2d0a87e1f5")
+		refTime     = time.Now().Truncate(time.Second).UTC()
+		ctx         = context.Background()
+
+		feature = Feature{
+			ID:            featureUUID,
+			TechnicalName: "feature-1",
+			CreatedAt:     refTime,
+			UpdatedAt:     refTime,
+		}
+		customers = []Customer{
+			{ID: uuid.MustParse("7b5b9b8e-2b4e-4c77-9d4b-5f0e8f3c6d01"), FeatureID: featureUUID, CustomerID: "customer-1"},
+			{ID: uuid.MustParse("7b5b9b8e-2b4e-4c77-9d4b-5f0e8f3c6d02"), FeatureID: featureUUID, CustomerID: "customer-2"},
+		}
+	)
+
+	tests := map[string]func(t *testing.T, repo Repository){
+		"save and find a feature with its customers": func(t *testing.T, repo Repository) {
+			mustSave(t, repo, feature, customers...)
+
+			got, err := repo.FindFeatureWithCustomers(ctx, featureUUID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := feature
+			want.CustomerIDs = []string{"customer-1", "customer-2"}
+			if !reflect.DeepEqual(&want, got) {
+				t.Errorf("Features not equal.\nwant: %v\ngot:  %v", &want, got)
+			}
+		},
+		"missing feature is not found": func(t *testing.T, repo Repository) {
+			_, err := repo.FindFeature(ctx, featureUUID)
+			if _, ok := err.(errFeatureNotFound); !ok {
+				t.Errorf("Expected errFeatureNotFound, got: %v", err)
+			}
+		},
+		"duplicate technical name is rejected": func(t *testing.T, repo Repository) {
+			mustSave(t, repo, feature)
+
+			other := feature
+			other.ID = otherUUID
+			if err := repo.SaveFeature(ctx, other); !errors.As(err, new(errConstraint)) {
+				t.Errorf("Expected errConstraint saving a duplicate technical name, got: %v", err)
+			}
+		},
+		"customer of a missing feature is rejected": func(t *testing.T, repo Repository) {
+			if err := repo.SaveCustomers(ctx, customers[0]); !errors.As(err, new(errConstraint)) {
+				t.Errorf("Expected errConstraint saving a customer of a missing feature, got: %v", err)
+			}
+		},
+		"stale update is rejected": func(t *testing.T, repo Repository) {
+			mustSave(t, repo, feature)
+
+			updated := feature
+			updated.DisplayName = ptr("Feature 1")
+			updated.UpdatedAt = refTime.Add(time.Minute)
+			if err := repo.UpdateFeature(ctx, refTime.Add(-time.Minute), updated); err == nil {
+				t.Error("Expected an error updating with a stale timestamp")
+			}
+			if err := repo.UpdateFeature(ctx, refTime, updated); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := repo.FindFeature(ctx, featureUUID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(&updated, got) {
+				t.Errorf("Features not equal.\nwant: %v\ngot:  %v", &updated, got)
+			}
+		},
+		"deleting a feature deletes its customers": func(t *testing.T, repo Repository) {
+			mustSave(t, repo, feature, customers...)
+
+			if err := repo.DeleteFeature(ctx, featureUUID); err != nil {
+				t.Fatal(err)
+			}
+
+			cs, err := repo.FindCustomerIDsByFeatureID(ctx, featureUUID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(cs) != 0 {
+				t.Errorf("Expected no customers, got: %v", cs)
+			}
+		},
+		"missing customers are saved and present ones skipped": func(t *testing.T, repo Repository) {
+			mustSave(t, repo, feature, customers[0])
+			other := feature
+			other.ID = otherUUID
+			other.TechnicalName = "feature-2"
+			mustSave(t, repo, other, Customer{ID: uuid.MustParse("7b5b9b8e-2b4e-4c77-9d4b-5f0e8f3c6d03"), FeatureID: otherUUID, CustomerID: "customer-2"})
+
+			duplicate := customers[0]
+			duplicate.ID = uuid.MustParse("7b5b9b8e-2b4e-4c77-9d4b-5f0e8f3c6d04")
+			saved, err := repo.SaveMissingCustomers(ctx, featureUUID, duplicate, customers[1])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual([]string{"customer-2"}, saved) {
+				t.Errorf("Saved customers not equal.\nwant: [customer-2]\ngot:  %v", saved)
+			}
+
+			if err := repo.DeleteFeatureCustomers(ctx, featureUUID, "customer-1", "customer-2"); err != nil {
+				t.Fatal(err)
+			}
+			cs, err := repo.FindCustomerIDsByFeatureID(ctx, featureUUID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(cs) != 0 {
+				t.Errorf("Expected no customers, got: %v", cs)
+			}
+			cs, err = repo.FindCustomerIDsByFeatureID(ctx, otherUUID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual([]string{"customer-2"}, cs) {
+				t.Errorf("Customers of other feature not equal.\nwant: [customer-2]\ngot:  %v", cs)
+			}
+		},
+		"customers beyond the bind parameter limits are saved and deleted": func(t *testing.T, repo Repository) {
+			// More rows than SQLite binds the columns of in a single statement.
+			const n = 12000
+			mustSave(t, repo, feature)
+			var (
+				cs  = make([]Customer, n)
+				ids = make([]string, n)
+			)
+			for i := range cs {
+				ids[i] = fmt.Sprintf("customer-%d", i)
+				cs[i] = Customer{ID: uuid.New(), FeatureID: featureUUID, CustomerID: ids[i]}
+			}
+
+			if err := repo.SaveCustomers(ctx, cs...); err != nil {
+				t.Fatal(err)
+			}
+			got, err := repo.FindCustomerIDsByFeatureID(ctx, featureUUID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != n {
+				t.Errorf("Expected %d customers, got: %d", n, len(got))
+			}
+
+			if err := repo.DeleteCustomersByCustomerIDs(ctx, ids...); err != nil {
+				t.Fatal(err)
+			}
+			got, err = repo.FindCustomerIDsByFeatureID(ctx, featureUUID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != 0 {
+				t.Errorf("Expected no customers, got: %d", len(got))
+			}
+		},
+		"customer ids are renamed and merged in every feature": func(t *testing.T, repo Repository) {
+			mustSave(t, repo, feature, customers[0])
+			other := feature
+			other.ID = otherUUID
+			other.TechnicalName = "feature-0"
+			mustSave(t, repo, other,
+				Customer{ID: uuid.MustParse("7b5b9b8e-2b4e-4c77-9d4b-5f0e8f3c6d03"), FeatureID: otherUUID, CustomerID: "customer-1"},
+				Customer{ID: uuid.MustParse("7b5b9b8e-2b4e-4c77-9d4b-5f0e8f3c6d04"), FeatureID: otherUUID, CustomerID: "customer-2"},
+			)
+
+			if err := repo.RenameCustomerID(ctx, "customer-1", "customer-2"); err != nil {
+				t.Fatal(err)
+			}
+
+			fs, err := repo.FindFeaturesByCustomerID(ctx, "customer-2")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(fs) != 2 || fs[0].ID != otherUUID || fs[1].ID != featureUUID {
+				t.Errorf("Expected features %s and %s, got: %v", otherUUID, featureUUID, fs)
+			}
+			for _, id := range []uuid.UUID{featureUUID, otherUUID} {
+				cs, err := repo.FindCustomerIDsByFeatureID(ctx, id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual([]string{"customer-2"}, cs) {
+					t.Errorf("Customers of feature %s not equal.\nwant: [customer-2]\ngot:  %v", id, cs)
+				}
+			}
+		},
+		"customer profiles are replaced keeping their creation time": func(t *testing.T, repo Repository) {
+			p := CustomerProfile{
+				ID:          "customer-1",
+				DisplayName: ptr("Acme"),
+				Attributes:  map[string]string{"plan": "free"},
+				CreatedAt:   refTime.Add(-time.Hour),
+				UpdatedAt:   refTime.Add(-time.Hour),
+			}
+			if err := repo.SaveCustomerProfiles(ctx, p); err != nil {
+				t.Fatal(err)
+			}
+
+			want := CustomerProfile{
+				ID:         "customer-1",
+				Attributes: map[string]string{"plan": "enterprise"},
+				CreatedAt:  p.CreatedAt,
+				UpdatedAt:  refTime,
+			}
+			if err := repo.SaveCustomerProfiles(ctx, CustomerProfile{
+				ID:         "customer-1",
+				Attributes: map[string]string{"plan": "enterprise"},
+				CreatedAt:  refTime,
+				UpdatedAt:  refTime,
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := repo.FindCustomerProfile(ctx, "customer-1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(want, *got) {
+				t.Errorf("Customer profiles not equal.\nwant: %v\ngot:  %v", want, *got)
+			}
+
+			if err := repo.DeleteCustomerProfile(ctx, "customer-1"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := repo.FindCustomerProfile(ctx, "customer-1"); !errors.As(err, &errCustomerNotFound{}) {
+				t.Errorf("Expected errCustomerNotFound, got: %v", err)
+			}
+		},
+		"rules target customers by their directory attributes": func(t *testing.T, repo Repository) {
+			f := feature
+			f.Rules = []TargetingRule{{Attribute: "plan", Values: []string{"enterprise", "pro"}}}
+			mustSave(t, repo, f)
+			if err := repo.SaveCustomerProfiles(ctx,
+				CustomerProfile{ID: "customer-1", Attributes: map[string]string{"plan": "pro"}, CreatedAt: refTime, UpdatedAt: refTime},
+				CustomerProfile{ID: "customer-2", Attributes: map[string]string{"plan": "free"}, CreatedAt: refTime, UpdatedAt: refTime},
+			); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := repo.FindFeature(ctx, featureUUID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(f.Rules, got.Rules) {
+				t.Errorf("Rules not equal.\nwant: %v\ngot:  %v", f.Rules, got.Rules)
+			}
+
+			svc := NewService(repo)
+			for customerID, want := range map[string]bool{"customer-1": true, "customer-2": false, "customer-3": false} {
+				cfs, err := repo.FindCustomerFeaturesByTechnicalNames(ctx, customerID, refTime, "feature-1")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(cfs) != 1 || cfs[0].HasFeature != want {
+					t.Errorf("%s: expected the store to find the feature with HasFeature %t, got: %v", customerID, want, cfs)
+				}
+
+				cfs, err = svc.findCustomerFeaturesByTechnicalNames(ctx, customerID, "feature-1")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(cfs) != 1 || cfs[0].HasFeature != want {
+					t.Errorf("%s: expected the evaluation to find the feature with HasFeature %t, got: %v", customerID, want, cfs)
+				}
+			}
+
+			if err := svc.deleteCustomerProfile(ctx, "customer-1"); err != nil {
+				t.Fatal(err)
+			}
+			cfs, err := svc.findCustomerFeaturesByTechnicalNames(ctx, "customer-1", "feature-1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(cfs) != 1 || cfs[0].HasFeature {
+				t.Errorf("Expected the deleted profile to no longer be targeted, got: %v", cfs)
+			}
+		},
+		"aliases are deleted along with their feature": func(t *testing.T, repo Repository) {
+			mustSave(t, repo, feature)
+			alias := Alias{TechnicalName: "old-feature-1", FeatureID: featureUUID, CreatedAt: refTime}
+			if err := repo.SaveAlias(ctx, alias); err != nil {
+				t.Fatal(err)
+			}
+			if err := repo.SaveAlias(ctx, alias); err == nil {
+				t.Error("Expected an error saving a duplicate alias")
+			}
+
+			got, err := repo.FindAlias(ctx, "old-feature-1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(&alias, got) {
+				t.Errorf("Aliases not equal.\nwant: %v\ngot:  %v", &alias, got)
+			}
+
+			if err := repo.DeleteFeature(ctx, featureUUID); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := repo.FindAlias(ctx, "old-feature-1"); !errors.As(err, &errAliasNotFound{}) {
+				t.Errorf("Expected errAliasNotFound, got: %v", err)
+			}
+		},
+		"former name of an archived feature can be reused": func(t *testing.T, repo Repository) {
+			svc := NewService(repo)
+			mustSave(t, repo, feature)
+
+			renamed := feature
+			renamed.TechnicalName = "feature-2"
+			if err := svc.updateFeature(ctx, feature.UpdatedAt, renamed); err != nil {
+				t.Fatal(err)
+			}
+			if err := svc.archiveFeature(ctx, featureUUID); err != nil {
+				t.Fatal(err)
+			}
+
+			as, err := repo.FindAllAliases(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(as) != 0 {
+				t.Errorf("Expected no aliases left, got: %v", as)
+			}
+
+			if err := svc.saveFeature(ctx, Feature{TechnicalName: "feature-1"}); err != nil {
+				t.Errorf("Expected the former name to be free again, got: %v", err)
+			}
+		},
+		"page through features": func(t *testing.T, repo Repository) {
+			inOneDay, inTwoDays := refTime.AddDate(0, 0, 1), refTime.AddDate(0, 0, 2)
+			mustSave(t, repo, Feature{ID: otherUUID, TechnicalName: "feature-2", CreatedAt: refTime, UpdatedAt: refTime})
+			mustSave(t, repo, Feature{ID: uuid.New(), TechnicalName: "feature-3", ExpiresOn: &inOneDay, CreatedAt: refTime, UpdatedAt: refTime})
+			mustSave(t, repo, Feature{ID: uuid.New(), TechnicalName: "feature-4", ExpiresOn: &inTwoDays, CreatedAt: refTime, UpdatedAt: refTime})
+			mustSave(t, repo, feature)
+
+			q := FeatureQuery{Sort: SortByExpiry, Descending: true, Limit: 1}
+			var names []string
+			for {
+				page, err := repo.FindFeatures(ctx, q, refTime)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if page.Total != 4 {
+					t.Errorf("Totals not equal.\nwant: %d\ngot:  %d", 4, page.Total)
+				}
+				for _, f := range page.Features {
+					names = append(names, f.TechnicalName)
+				}
+				if page.Next == nil {
+					break
+				}
+				q.After = page.Next
+			}
+
+			// Without expiry, feature-1 and feature-2 are ordered by descending ID.
+			want := []string{"feature-4", "feature-3", "feature-2", "feature-1"}
+			if !reflect.DeepEqual(want, names) {
+				t.Errorf("Features not equal.\nwant: %v\ngot:  %v", want, names)
+			}
+		},
+		"filter features by metadata": func(t *testing.T, repo Repository) {
+			tagged := feature
+			tagged.Tags = []string{"checkout", "mobile"}
+			tagged.Owner = ptr("team-payments")
+			tagged.Kind = KindOps
+			mustSave(t, repo, tagged)
+			mustSave(t, repo, Feature{ID: otherUUID, TechnicalName: "feature-2", Tags: []string{"mobile"}, CreatedAt: refTime, UpdatedAt: refTime})
+
+			for name, test := range map[string]struct {
+				q    FeatureQuery
+				want int
+			}{
+				"tag":      {q: FeatureQuery{Tags: []string{"mobile"}}, want: 2},
+				"all tags": {q: FeatureQuery{Tags: []string{"mobile", "checkout"}}, want: 1},
+				"owner":    {q: FeatureQuery{Owner: "Team-Payments"}, want: 1},
+				"kind":     {q: FeatureQuery{Kind: KindRelease}, want: 0},
+			} {
+				test.q.Sort, test.q.Limit = SortByName, 10
+				page, err := repo.FindFeatures(ctx, test.q, refTime)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if page.Total != test.want {
+					t.Errorf("Totals filtering by %s not equal.\nwant: %d\ngot:  %d", name, test.want, page.Total)
+				}
+			}
+
+			got, err := repo.FindFeature(ctx, featureUUID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(&tagged, got) {
+				t.Errorf("Features not equal.\nwant: %v\ngot:  %v", &tagged, got)
+			}
+		},
+		"search features after changes": func(t *testing.T, repo Repository) {
+			mustSave(t, repo, feature, customers...)
+
+			updated := feature
+			updated.Description = ptr("Faster checkout")
+			updated.UpdatedAt = refTime.Add(time.Minute)
+			if err := repo.UpdateFeature(ctx, refTime, updated); err != nil {
+				t.Fatal(err)
+			}
+
+			for text, want := range map[string]int{"faster checkout": 1, "customer": 0} {
+				rs, err := repo.SearchFeatures(ctx, SearchQuery{Text: text, Limit: 10})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(rs) != want {
+					t.Errorf("Results for %q not equal.\nwant: %d\ngot:  %v", text, want, rs)
+				}
+			}
+		},
+		"claimed webhook deliveries are leased": func(t *testing.T, repo Repository) {
+			wh := Webhook{ID: otherUUID, URL: "https://example.com/hook", Secret: "s3cr3t", CreatedAt: refTime}
+			if err := repo.SaveWebhook(ctx, wh); err != nil {
+				t.Fatal(err)
+			}
+			for i, id := range []uuid.UUID{featureUUID, uuid.MustParse("bb7fe5b6-24a5-4218-bc61-b487bbad9581")} {
+				if err := repo.SaveWebhookDelivery(ctx, WebhookDelivery{
+					ID:            id,
+					WebhookID:     wh.ID,
+					Event:         eventFeatureCreated,
+					Payload:       []byte(`{}`),
+					NextAttemptAt: refTime.Add(time.Duration(i) * time.Second),
+					CreatedAt:     refTime,
+				}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			leaseUntil := refTime.Add(time.Minute)
+			for _, want := range [][]uuid.UUID{{featureUUID}, {uuid.MustParse("bb7fe5b6-24a5-4218-bc61-b487bbad9581")}, nil} {
+				ds, err := repo.ClaimPendingWebhookDeliveries(ctx, refTime.Add(time.Second), leaseUntil, 1)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var got []uuid.UUID
+				for _, d := range ds {
+					got = append(got, d.ID)
+					if d.URL != wh.URL || d.Secret != wh.Secret || !d.NextAttemptAt.Equal(leaseUntil) {
+						t.Errorf("Claimed deliveries not equal.\nwant: %s %s %s\ngot:  %s %s %s", wh.URL, wh.Secret, leaseUntil, d.URL, d.Secret, d.NextAttemptAt)
+					}
+				}
+				if !reflect.DeepEqual(want, got) {
+					t.Errorf("Claimed deliveries not equal.\nwant: %v\ngot:  %v", want, got)
+				}
+			}
+
+			ds, err := repo.ClaimPendingWebhookDeliveries(ctx, leaseUntil, leaseUntil.Add(time.Minute), 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(ds) != 2 {
+				t.Errorf("Deliveries claimed after the lease not equal.\nwant: %d\ngot:  %d", 2, len(ds))
+			}
+		},
+		"rolled back unit of work is discarded": func(t *testing.T, repo Repository) {
+			tx, err := repo.Begin(ctx, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			mustSave(t, tx, feature)
+			if err := tx.Rollback(); err != nil {
+				t.Fatal(err)
+			}
+
+			fs, err := repo.FindAllFeatures(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(fs) != 0 {
+				t.Errorf("Expected no features, got: %v", fs)
+			}
+		},
+		"committed unit of work is persisted": func(t *testing.T, repo Repository) {
+			tx, err := repo.Begin(ctx, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer tx.Rollback()
+
+			mustSave(t, tx, feature)
+
+			// Rolling back a nested unit of work leaves the outer one intact.
+			nested, err := tx.Begin(ctx, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			mustSave(t, nested, Feature{ID: otherUUID, TechnicalName: "feature-2", CreatedAt: refTime, UpdatedAt: refTime})
+			if err := nested.Rollback(); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := tx.Commit(); err != nil {
+				t.Fatal(err)
+			}
+
+			fs, err := repo.FindAllFeatures(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual([]Feature{feature}, fs) {
+				t.Errorf("Features not equal.\nwant: %v\ngot:  %v", []Feature{feature}, fs)
+			}
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			t.Run("memory", func(t *testing.T) {
+				test(t, NewMemoryStore())
+			})
+
+			t.Run("sql", func(t *testing.T) {
+				tx, _, rollback, err := store.beginTx(ctx, &sql.TxOptions{
+					Isolation: sql.LevelReadCommitted,
+				})
+				if err != nil {
+					t.Fatalf("failed to begin transaction: %s\n", err)
+				}
+
+				t.Cleanup(func() {
+					if err := rollback(); err != nil {
+						t.Errorf("failed to rollback the transaction: %s\n", err)
+					}
+				})
+
+				test(t, *tx)
+			})
+		})
+	}
+}
+
+func mustSave(t *testing.T, repo Repository, f Feature, cs ...Customer) {
+	t.Helper()
+
+	if err := repo.SaveFeature(context.Background(), f); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.SaveCustomers(context.Background(), cs...); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,255 @@
+package feature
+
+import (
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+// SearchQuery selects features by full-text search.
+type SearchQuery struct {
+	// Text is matched word by word: every word must prefix a word of the
+	// technical name, display name, description or tags of a feature.
+	Text            string
+	IncludeArchived bool
+	Limit           int
+}
+
+// SearchResult is a feature found by a search, with a snippet of its text
+// showing why it matched.
+type SearchResult struct {
+	ID            uuid.UUID
+	TechnicalName string
+	DisplayName   *string
+	Archived      bool
+	// Snippet is an HTML excerpt of the text matching best, with the matching
+	// words enclosed in <mark> tags.
+	Snippet string
+}
+
+// searchDocument is the searchable text of a feature or archived feature.
+type searchDocument struct {
+	FeatureID     uuid.UUID
+	Archived      bool
+	TechnicalName string
+	DisplayName   *string
+	Description   *string
+	Tags          string
+}
+
+// searchWeights weigh matches in the columns of a document when ranking, in the
+// order of searchDocument.columns.
+var searchWeights = []float64{10, 5, 1, 3}
+
+func (d searchDocument) columns() []string {
+	return []string{d.TechnicalName, deref(d.DisplayName), deref(d.Description), d.Tags}
+}
+
+func (d searchDocument) result(terms []string) SearchResult {
+	return SearchResult{
+		ID:            d.FeatureID,
+		TechnicalName: d.TechnicalName,
+		DisplayName:   d.DisplayName,
+		Archived:      d.Archived,
+		Snippet:       d.snippet(terms),
+	}
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// searchTerms splits text into lower case words, the way documents are split.
+func searchTerms(text string) []string {
+	var res []string
+	for _, t := range tokenize(text) {
+		res = append(res, strings.ToLower(text[t.start:t.end]))
+	}
+	return res
+}
+
+type token struct {
+	start, end int
+}
+
+// tokenize returns the positions of the words in s, words being runs of letters
+// and digits.
+func tokenize(s string) []token {
+	var (
+		res   []token
+		start = -1
+	)
+	for i, r := range s {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case isWord && start == -1:
+			start = i
+		case !isWord && start != -1:
+			res = append(res, token{start: start, end: i})
+			start = -1
+		}
+	}
+	if start != -1 {
+		res = append(res, token{start: start, end: len(s)})
+	}
+	return res
+}
+
+// matchingTerms returns the number of words of s prefixed by a term, and marks
+// the terms that did in found.
+func matchingTerms(s string, terms []string, found []bool) int {
+	var n int
+	for _, t := range tokenize(s) {
+		word := strings.ToLower(s[t.start:t.end])
+		for i, term := range terms {
+			if strings.HasPrefix(word, term) {
+				found[i] = true
+				n++
+				break
+			}
+		}
+	}
+	return n
+}
+
+// rank scores how well d matches terms, for stores without a full-text index.
+// Documents not matching every term score 0.
+func (d searchDocument) rank(terms []string) float64 {
+	var (
+		found = make([]bool, len(terms))
+		res   float64
+	)
+	for i, c := range d.columns() {
+		res += searchWeights[i] * float64(matchingTerms(c, terms, found))
+	}
+	for _, f := range found {
+		if !f {
+			return 0
+		}
+	}
+	return res
+}
+
+// rankDocuments returns the results for the documents matching terms, best
+// first, at most limit.
+func rankDocuments(ds []searchDocument, terms []string, limit int) []SearchResult {
+	type ranked struct {
+		searchDocument
+		rank float64
+	}
+
+	var rs []ranked
+	for _, d := range ds {
+		if r := d.rank(terms); r > 0 {
+			rs = append(rs, ranked{searchDocument: d, rank: r})
+		}
+	}
+	sort.SliceStable(rs, func(i, j int) bool {
+		if rs[i].rank != rs[j].rank {
+			return rs[i].rank > rs[j].rank
+		}
+		return rs[i].TechnicalName < rs[j].TechnicalName
+	})
+	if len(rs) > limit {
+		rs = rs[:limit]
+	}
+
+	res := make([]SearchResult, 0, len(rs))
+	for _, r := range rs {
+		res = append(res, r.result(terms))
+	}
+	return res
+}
+
+// snippetWords is the maximum number of words in a snippet.
+const snippetWords = 12
+
+// snippet excerpts the column of d matching the most terms, highlighting the
+// matching words. Ties go to the earlier column.
+func (d searchDocument) snippet(terms []string) string {
+	var (
+		best      string
+		bestCount = -1
+	)
+	for _, c := range d.columns() {
+		found := make([]bool, len(terms))
+		matchingTerms(c, terms, found)
+
+		var n int
+		for _, f := range found {
+			if f {
+				n++
+			}
+		}
+		if n > bestCount {
+			best, bestCount = c, n
+		}
+	}
+	return highlight(best, terms)
+}
+
+// highlight returns an HTML excerpt of s of at most snippetWords words, starting
+// shortly before the first word prefixed by a term. Such words are enclosed in
+// <mark> tags.
+func highlight(s string, terms []string) string {
+	ts := tokenize(s)
+	if len(ts) == 0 {
+		return html.EscapeString(s)
+	}
+
+	matches := make([]bool, len(ts))
+	first := -1
+	for i, t := range ts {
+		word := strings.ToLower(s[t.start:t.end])
+		for _, term := range terms {
+			if strings.HasPrefix(word, term) {
+				matches[i] = true
+				break
+			}
+		}
+		if matches[i] && first == -1 {
+			first = i
+		}
+	}
+
+	from := 0
+	if first > 2 {
+		from = first - 2
+	}
+	if len(ts)-from < snippetWords && len(ts) > snippetWords {
+		from = len(ts) - snippetWords
+	}
+	to := from + snippetWords
+	if to > len(ts) {
+		to = len(ts)
+	}
+
+	var b strings.Builder
+	if from == 0 {
+		b.WriteString(html.EscapeString(s[:ts[0].start]))
+	} else {
+		b.WriteString("…")
+	}
+	for i := from; i < to; i++ {
+		if i > from {
+			b.WriteString(html.EscapeString(s[ts[i-1].end:ts[i].start]))
+		}
+		word := html.EscapeString(s[ts[i].start:ts[i].end])
+		if matches[i] {
+			word = "<mark>" + word + "</mark>"
+		}
+		b.WriteString(word)
+	}
+	if to == len(ts) {
+		b.WriteString(html.EscapeString(s[ts[len(ts)-1].end:]))
+	} else {
+		b.WriteString("…")
+	}
+	return b.String()
+}
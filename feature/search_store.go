@@ -0,0 +1,159 @@
+package feature
+
+import (
+	"context"
+	"database/sql"
+	"feature/pkg/sqlx"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// SearchFeatures implements FeatureRepository. On PostgreSQL, features are
+// found through a text search index, on SQLite through an FTS5 index.
+func (s Store) SearchFeatures(ctx context.Context, q SearchQuery) ([]SearchResult, error) {
+	defer observeQuery("searchFeatures")()
+
+	terms := searchTerms(q.Text)
+	if len(terms) == 0 {
+		return []SearchResult{}, nil
+	}
+
+	var (
+		ds  []searchDocument
+		err error
+	)
+	if s.dialect == sqlx.Postgres {
+		ds, err = s.searchTSVector(ctx, q, terms)
+	} else {
+		ds, err = s.searchFTS5(ctx, q, terms)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]SearchResult, 0, len(ds))
+	for _, d := range ds {
+		res = append(res, d.result(terms))
+	}
+	return res, nil
+}
+
+const searchDocumentColumns = `s.feature_id, s.archived, s.technical_name, s.display_name, s.description, s.tags`
+
+// tsDocument is the text search vector of a document on PostgreSQL. It must
+// match the expression of the feature_search_document_idx index.
+const tsDocument = `to_tsvector('simple', s.technical_name || ' ' || COALESCE(s.display_name, '') || ' ' || COALESCE(s.description, '') || ' ' || s.tags)`
+
+// tsRank weighs the columns of a document like searchWeights.
+const tsRank = `ts_rank(
+	setweight(to_tsvector('simple', s.technical_name), 'A') ||
+	setweight(to_tsvector('simple', COALESCE(s.display_name, '')), 'B') ||
+	setweight(to_tsvector('simple', COALESCE(s.description, '')), 'D') ||
+	setweight(to_tsvector('simple', s.tags), 'C'),
+	to_tsquery('simple', ?)
+)`
+
+func (s Store) searchTSVector(ctx context.Context, q SearchQuery, terms []string) ([]searchDocument, error) {
+	prefixes := make([]string, 0, len(terms))
+	for _, t := range terms {
+		prefixes = append(prefixes, t+":*")
+	}
+	query := strings.Join(prefixes, " & ")
+
+	rs, err := s.db.QueryContext(
+		ctx,
+		`SELECT `+searchDocumentColumns+` FROM feature_search s WHERE `+tsDocument+` @@ to_tsquery('simple', ?) AND (? OR NOT s.archived) ORDER BY `+tsRank+` DESC, s.technical_name LIMIT ?`,
+		query, q.IncludeArchived, query, q.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanSearchDocuments(rs)
+}
+
+func (s Store) searchFTS5(ctx context.Context, q SearchQuery, terms []string) ([]searchDocument, error) {
+	// Terms only consist of letters and digits, so they need no escaping.
+	prefixes := make([]string, 0, len(terms))
+	for _, t := range terms {
+		prefixes = append(prefixes, `"`+t+`"*`)
+	}
+
+	rs, err := s.db.QueryContext(
+		ctx,
+		//language=sqlite
+		`SELECT `+searchDocumentColumns+` FROM feature_search_fts JOIN feature_search s ON s.id = feature_search_fts.rowid WHERE feature_search_fts MATCH ? AND (? OR NOT s.archived) ORDER BY bm25(feature_search_fts, 10.0, 5.0, 1.0, 3.0), s.technical_name LIMIT ?`,
+		strings.Join(prefixes, " "), q.IncludeArchived, q.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanSearchDocuments(rs)
+}
+
+func scanSearchDocuments(rs *sql.Rows) ([]searchDocument, error) {
+	var ds []searchDocument
+	for rs.Next() {
+		var (
+			d                        searchDocument
+			displayName, description sql.NullString
+		)
+		if err := rs.Scan(&d.FeatureID, &d.Archived, &d.TechnicalName, &displayName, &description, &d.Tags); err != nil {
+			return nil, err
+		}
+		if displayName.Valid {
+			d.DisplayName = &displayName.String
+		}
+		if description.Valid {
+			d.Description = &description.String
+		}
+		ds = append(ds, d)
+	}
+
+	if err := rs.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := rs.Close(); err != nil {
+		return nil, err
+	}
+
+	return ds, nil
+}
+
+// saveSearchDocument adds the search document of a feature, or of an archived
+// feature. Archived features only keep their names and description.
+func (s Store) saveSearchDocument(ctx context.Context, r featureRow, archived bool) error {
+	var tags string
+	if !archived {
+		tags = strings.Join(r.Tags, " ")
+	}
+
+	_, err := s.db.ExecContext(
+		ctx,
+		//language=sqlite
+		`INSERT INTO feature_search (feature_id,archived,technical_name,display_name,description,tags) VALUES (?,?,?,?,?,?)`,
+		r.ID, archived, r.TechnicalName, r.DisplayName, r.Description, tags,
+	)
+	return err
+}
+
+func (s Store) updateSearchDocument(ctx context.Context, r featureRow) error {
+	_, err := s.db.ExecContext(
+		ctx,
+		//language=sqlite
+		`UPDATE feature_search SET technical_name=?, display_name=?, description=?, tags=? WHERE feature_id=? AND archived=?`,
+		r.TechnicalName, r.DisplayName, r.Description, strings.Join(r.Tags, " "), r.ID, false,
+	)
+	return err
+}
+
+func (s Store) deleteSearchDocument(ctx context.Context, featureID uuid.UUID) error {
+	_, err := s.db.ExecContext(
+		ctx,
+		//language=sqlite
+		`DELETE FROM feature_search WHERE feature_id=? AND archived=?`,
+		featureID, false,
+	)
+	return err
+}
@@ -3,33 +3,53 @@ package feature
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"feature/pkg/render"
 	"feature/pkg/set"
+	"feature/pkg/slices"
 	"fmt"
 	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"strings"
 	"time"
 )
 
 // NewService initializes and returns a new Service.
-func NewService(store Store) Service {
+func NewService(store Repository) Service {
 	return Service{
-		store:    store,
-		timeFunc: time.Now,
-		uuidFunc: uuid.NewRandom,
+		store:      store,
+		usage:      newUsageTracker(),
+		cache:      newEvaluationCache(),
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		policy:     DefaultValidationPolicy(),
+		timeFunc:   time.Now,
+		uuidFunc:   uuid.NewRandom,
 	}
 }
 
+// WithValidationPolicy returns a copy of svc validating features against p.
+func (svc Service) WithValidationPolicy(p ValidationPolicy) Service {
+	svc.policy = p
+	return svc
+}
+
 // Service exposes business functionality related to feature toggling and
 // querying.
 type Service struct {
-	store Store
+	store      Repository
+	usage      *usageTracker
+	cache      *evaluationCache
+	httpClient *http.Client
+	policy     ValidationPolicy
 
 	timeFunc func() time.Time
 	uuidFunc func() (uuid.UUID, error)
 }
 
-func (svc Service) saveFeature(ctx context.Context, f feature) error {
-	if err := f.validate(); err != nil {
+func (svc Service) saveFeature(ctx context.Context, f Feature) error {
+	f.normalize()
+	if err := f.validate(svc.policy); err != nil {
 		return fmt.Errorf("validate feature: %w", err)
 	}
 
@@ -42,62 +62,108 @@ func (svc Service) saveFeature(ctx context.Context, f feature) error {
 	now := svc.timeFunc()
 	f.CreatedAt, f.UpdatedAt = now, now
 
-	tx, commit, rollback, err := svc.store.beginTx(ctx, &sql.TxOptions{
+	tx, err := svc.store.Begin(ctx, &sql.TxOptions{
 		Isolation: sql.LevelDefault,
 	})
 	if err != nil {
 		return fmt.Errorf("begin transaction: %w", err)
 	}
-	defer rollback()
+	defer tx.Rollback()
+
+	if err := claimTechnicalName(ctx, tx, f.ID, f.TechnicalName); err != nil {
+		return fmt.Errorf("claim technical name: %w", err)
+	}
 
-	if err := tx.saveFeature(ctx, f); err != nil {
+	if err := tx.SaveFeature(ctx, f); err != nil {
 		return fmt.Errorf("save feature: %w", err)
 	}
 
-	var cs []customer
+	var cs []Customer
 	for _, cid := range f.CustomerIDs {
 		id, err := svc.uuidFunc()
 		if err != nil {
 			return fmt.Errorf("generate customer feature join table id: %w", err)
 		}
-		cs = append(cs, customer{
+		cs = append(cs, Customer{
 			ID:         id,
 			FeatureID:  f.ID,
 			CustomerID: cid,
 		})
 	}
 
-	if err := tx.saveCustomers(ctx, cs...); err != nil {
+	if err := tx.SaveCustomers(ctx, cs...); err != nil {
 		return fmt.Errorf("save customers: %w", err)
 	}
 
-	if err := commit(); err != nil {
+	if err := svc.publish(ctx, tx, eventFeatureCreated, f, f.CustomerIDs...); err != nil {
+		return fmt.Errorf("publish feature created: %w", err)
+	}
+
+	if err := svc.commit(tx); err != nil {
 		return fmt.Errorf("commit transaction: %w", err)
 	}
 
 	return nil
 }
 
-func (svc Service) updateFeature(ctx context.Context, lastUpdatedAt time.Time, f feature) error {
-	if err := f.validate(); err != nil {
+// validateFeature checks a draft feature like saveFeature would, without saving
+// it. It returns the violations found, if any.
+func (svc Service) validateFeature(f Feature) []string {
+	f.normalize()
+	var errs errFeatureInvalid
+	if errors.As(f.validate(svc.policy), &errs) {
+		return errs
+	}
+	return []string{}
+}
+
+func (svc Service) updateFeature(ctx context.Context, lastUpdatedAt time.Time, f Feature) error {
+	// Clients unaware of rules leave them out, which must not drop them. An
+	// empty list of rules removes them.
+	keepRules := f.Rules == nil
+	f.normalize()
+	if err := f.validate(svc.policy); err != nil {
 		return fmt.Errorf("validate feature: %w", err)
 	}
 
-	tx, commit, rollback, err := svc.store.beginTx(ctx, &sql.TxOptions{
+	tx, err := svc.store.Begin(ctx, &sql.TxOptions{
 		Isolation: sql.LevelReadCommitted,
 		ReadOnly:  false,
 	})
 	if err != nil {
 		return fmt.Errorf("begin transaction: %w", err)
 	}
-	defer rollback()
+	defer tx.Rollback()
+
+	current, err := tx.FindFeature(ctx, f.ID)
+	if err != nil {
+		return fmt.Errorf("update feature: %w", err)
+	}
+
+	if keepRules {
+		f.Rules = current.Rules
+	}
+
+	renamed := current.TechnicalName != f.TechnicalName
+	if renamed {
+		if err := claimTechnicalName(ctx, tx, f.ID, f.TechnicalName); err != nil {
+			return fmt.Errorf("claim technical name: %w", err)
+		}
+	}
 
 	f.UpdatedAt = svc.timeFunc()
-	if err := tx.updateFeature(ctx, lastUpdatedAt, f); err != nil {
+	if err := tx.UpdateFeature(ctx, lastUpdatedAt, f); err != nil {
 		return fmt.Errorf("update feature: %w", err)
 	}
 
-	ids, err := tx.findCustomerIDsByFeatureID(ctx, f.ID)
+	// Keep the former name resolving, for clients not yet using the new one.
+	if renamed {
+		if err := tx.SaveAlias(ctx, Alias{TechnicalName: current.TechnicalName, FeatureID: f.ID, CreatedAt: f.UpdatedAt}); err != nil {
+			return fmt.Errorf("save alias: %w", err)
+		}
+	}
+
+	ids, err := tx.FindCustomerIDsByFeatureID(ctx, f.ID)
 	if err != nil {
 		return fmt.Errorf("find customer ids by feature id: %w", err)
 	}
@@ -110,28 +176,49 @@ func (svc Service) updateFeature(ctx context.Context, lastUpdatedAt time.Time, f
 		toDelete   = set.Sub(currentIDs, common)
 	)
 
-	var newCustomers []customer
+	var newCustomers []Customer
 	for _, s := range toSave.ToSlice() {
 		id, err := svc.uuidFunc()
 		if err != nil {
 			return fmt.Errorf("generate customer feature join table id: %w", err)
 		}
-		newCustomers = append(newCustomers, customer{
+		newCustomers = append(newCustomers, Customer{
 			ID:         id,
 			FeatureID:  f.ID,
 			CustomerID: s,
 		})
 	}
 
-	if err := tx.saveCustomers(ctx, newCustomers...); err != nil {
+	if err := tx.SaveCustomers(ctx, newCustomers...); err != nil {
 		return fmt.Errorf("save new customers: %w", err)
 	}
 
-	if err := tx.deleteCustomersByCustomerIDs(ctx, toDelete.ToSlice()...); err != nil {
+	if err := tx.DeleteFeatureCustomers(ctx, f.ID, toDelete.ToSlice()...); err != nil {
 		return fmt.Errorf("delete removed customers: %w", err)
 	}
 
-	if err := commit(); err != nil {
+	updated, err := tx.FindFeature(ctx, f.ID)
+	if err != nil {
+		return fmt.Errorf("find updated feature: %w", err)
+	}
+
+	if err := svc.publish(ctx, tx, eventFeatureUpdated, *updated, f.CustomerIDs...); err != nil {
+		return fmt.Errorf("publish feature updated: %w", err)
+	}
+
+	if 0 < len(toSave) {
+		if err := svc.publish(ctx, tx, eventFeatureCustomersAdded, *updated, toSave.ToSlice()...); err != nil {
+			return fmt.Errorf("publish feature customers added: %w", err)
+		}
+	}
+
+	if 0 < len(toDelete) {
+		if err := svc.publish(ctx, tx, eventFeatureCustomersRemoved, *updated, toDelete.ToSlice()...); err != nil {
+			return fmt.Errorf("publish feature customers removed: %w", err)
+		}
+	}
+
+	if err := svc.commit(tx); err != nil {
 		return fmt.Errorf("commit transaction: %w", err)
 	}
 
@@ -139,15 +226,15 @@ func (svc Service) updateFeature(ctx context.Context, lastUpdatedAt time.Time, f
 }
 
 func (svc Service) archiveFeature(ctx context.Context, featureID uuid.UUID) error {
-	tx, commit, rollback, err := svc.store.beginTx(ctx, &sql.TxOptions{
+	tx, err := svc.store.Begin(ctx, &sql.TxOptions{
 		Isolation: sql.LevelReadCommitted,
 	})
 	if err != nil {
 		return fmt.Errorf("begin transaction: %w", err)
 	}
-	defer rollback()
+	defer tx.Rollback()
 
-	f, err := tx.findFeature(ctx, featureID)
+	f, err := tx.FindFeature(ctx, featureID)
 	if err != nil {
 		return fmt.Errorf("find feature: %w", err)
 	}
@@ -155,15 +242,67 @@ func (svc Service) archiveFeature(ctx context.Context, featureID uuid.UUID) erro
 	now := svc.timeFunc()
 	f.CreatedAt, f.UpdatedAt = now, now
 
-	if err := tx.saveArchivedFeature(ctx, *f); err != nil {
+	if err := tx.SaveArchivedFeature(ctx, *f); err != nil {
 		return fmt.Errorf("save archived feature: %w", err)
 	}
 
-	if err := tx.deleteFeature(ctx, featureID); err != nil {
+	if err := tx.DeleteFeature(ctx, featureID); err != nil {
 		return fmt.Errorf("delete feature: %w", err)
 	}
 
-	if err := commit(); err != nil {
+	if err := svc.publish(ctx, tx, eventFeatureArchived, *f); err != nil {
+		return fmt.Errorf("publish feature archived: %w", err)
+	}
+
+	if err := svc.commit(tx); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// killFeature forces the feature off for every customer, without altering its
+// targeting.
+func (svc Service) killFeature(ctx context.Context, featureID uuid.UUID) error {
+	if err := svc.setFeatureKilled(ctx, featureID, true, svc.timeFunc()); err != nil {
+		return fmt.Errorf("kill feature: %w", err)
+	}
+	return nil
+}
+
+// unkillFeature restores the evaluation of a previously killed feature.
+func (svc Service) unkillFeature(ctx context.Context, featureID uuid.UUID) error {
+	if err := svc.setFeatureKilled(ctx, featureID, false, svc.timeFunc()); err != nil {
+		return fmt.Errorf("unkill feature: %w", err)
+	}
+	return nil
+}
+
+// setFeatureKilled toggles the kill switch of the feature. Killing it records t
+// as the time it was killed, unless it already is.
+func (svc Service) setFeatureKilled(ctx context.Context, featureID uuid.UUID, killed bool, t time.Time) error {
+	tx, err := svc.store.Begin(ctx, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := tx.SetFeatureKilled(ctx, featureID, killed, t); err != nil {
+		return err
+	}
+
+	f, err := tx.FindFeature(ctx, featureID)
+	if err != nil {
+		return fmt.Errorf("find feature: %w", err)
+	}
+
+	if err := svc.publish(ctx, tx, eventFeatureUpdated, *f); err != nil {
+		return fmt.Errorf("publish feature updated: %w", err)
+	}
+
+	if err := svc.commit(tx); err != nil {
 		return fmt.Errorf("commit transaction: %w", err)
 	}
 
@@ -172,41 +311,335 @@ func (svc Service) archiveFeature(ctx context.Context, featureID uuid.UUID) erro
 
 var errNoCustomers = render.NewBadRequest("no customer IDs given")
 
-func (svc Service) addCustomersToFeature(ctx context.Context, featureID uuid.UUID, customerIDs []string) error {
+// addCustomersToFeature gives the customers access to the feature. Customers
+// that already have access are skipped, so that adding is idempotent. It
+// returns the customers that were newly added, in the order given.
+func (svc Service) addCustomersToFeature(ctx context.Context, featureID uuid.UUID, customerIDs []string) ([]string, error) {
 	if len(customerIDs) == 0 {
-		return errNoCustomers
+		return nil, errNoCustomers
+	}
+
+	tx, err := svc.store.Begin(ctx, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	f, err := tx.FindFeature(ctx, featureID)
+	if err != nil {
+		return nil, fmt.Errorf("find feature: %w", err)
+	}
+
+	ids, err := tx.FindCustomerIDsByFeatureID(ctx, featureID)
+	if err != nil {
+		return nil, fmt.Errorf("find customer ids by feature id: %w", err)
 	}
 
-	var customers []customer
+	var (
+		present   = set.Of(ids...)
+		customers []Customer
+	)
 	for _, customerID := range customerIDs {
+		if _, ok := present[customerID]; ok {
+			continue
+		}
+		present[customerID] = struct{}{}
+
 		id, err := svc.uuidFunc()
 		if err != nil {
-			return fmt.Errorf("generate customer feature id: %w", err)
+			return nil, fmt.Errorf("generate customer feature id: %w", err)
 		}
-		customers = append(customers, customer{
+		customers = append(customers, Customer{
 			ID:         id,
 			FeatureID:  featureID,
 			CustomerID: customerID,
 		})
 	}
 
-	if err := svc.store.saveCustomers(ctx, customers...); err != nil {
-		return fmt.Errorf("save customers: %w", err)
+	// Customers added concurrently since reading the present ones are skipped
+	// by the store, and aren't reported as added.
+	added, err := tx.SaveMissingCustomers(ctx, featureID, customers...)
+	if err != nil {
+		return nil, fmt.Errorf("save customers: %w", err)
+	}
+	if len(added) == 0 {
+		return []string{}, nil
 	}
 
-	return nil
+	if err := svc.publish(ctx, tx, eventFeatureCustomersAdded, *f, added...); err != nil {
+		return nil, fmt.Errorf("publish feature customers added: %w", err)
+	}
+
+	if err := svc.commit(tx); err != nil {
+		return nil, fmt.Errorf("commit transaction: %w", err)
+	}
+
+	return added, nil
+}
+
+// Page sizes of feature listings.
+const (
+	defaultFeaturePageSize = 50
+	maxFeaturePageSize     = 500
+)
+
+var errBadFeaturePageSize = render.NewBadRequest(fmt.Sprintf("'limit' must be between 1 and %d", maxFeaturePageSize))
+
+// findFeatures returns the page of features selected by q. Unset sort and limit
+// are defaulted.
+func (svc Service) findFeatures(ctx context.Context, q FeatureQuery) (*FeaturePage, error) {
+	if q.Sort == "" {
+		q.Sort = SortByName
+	}
+	if !q.Sort.valid() {
+		return nil, render.NewBadRequest(fmt.Sprintf("unknown sort %q", q.Sort))
+	}
+
+	if !q.Kind.valid() {
+		return nil, render.NewBadRequest(fmt.Sprintf("unknown kind %q", q.Kind))
+	}
+
+	if q.Limit == 0 {
+		q.Limit = defaultFeaturePageSize
+	}
+	if q.Limit < 1 || maxFeaturePageSize < q.Limit {
+		return nil, errBadFeaturePageSize
+	}
+
+	if q.After != nil && (q.After.Sort != q.Sort || q.After.Descending != q.Descending) {
+		return nil, render.NewBadRequest("cursor does not match the sort")
+	}
+
+	page, err := svc.store.FindFeatures(ctx, q, svc.timeFunc())
+	if err != nil {
+		return nil, fmt.Errorf("find features: %w", err)
+	}
+	return page, nil
+}
+
+// Sizes of search results.
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+var (
+	errNoSearchText   = render.NewBadRequest("no search text given")
+	errBadSearchLimit = render.NewBadRequest(fmt.Sprintf("'limit' must be between 1 and %d", maxSearchLimit))
+)
+
+// searchFeatures returns the features matching q, best matches first. An unset
+// limit is defaulted.
+func (svc Service) searchFeatures(ctx context.Context, q SearchQuery) ([]SearchResult, error) {
+	if strings.TrimSpace(q.Text) == "" {
+		return nil, errNoSearchText
+	}
+
+	if q.Limit == 0 {
+		q.Limit = defaultSearchLimit
+	}
+	if q.Limit < 1 || maxSearchLimit < q.Limit {
+		return nil, errBadSearchLimit
+	}
+
+	rs, err := svc.store.SearchFeatures(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("search features: %w", err)
+	}
+	return rs, nil
 }
 
 var errNoFeatureNames = render.NewBadRequest("no feature technical names given")
 
-func (svc Service) findCustomerFeaturesByTechnicalNames(ctx context.Context, customerID string, technicalNames ...string) ([]customerFeature, error) {
+func (svc Service) findCustomerFeaturesByTechnicalNames(ctx context.Context, customerID string, technicalNames ...string) ([]CustomerFeature, error) {
 	if len(technicalNames) == 0 {
 		return nil, errNoFeatureNames
 	}
 
-	cfs, err := svc.store.findCustomerFeaturesByTechnicalNames(ctx, customerID, svc.timeFunc(), technicalNames...)
+	snapshot, err := svc.evaluationSnapshot(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("find customer features by technical names: %w", err)
+		return nil, fmt.Errorf("load evaluation snapshot: %w", err)
 	}
+
+	now := svc.timeFunc()
+	cfs := snapshot.evaluate(customerID, now, technicalNames...)
+
+	observeEvaluations(cfs)
+	svc.usage.record(now, slices.Map(func(cf CustomerFeature) string { return cf.TechnicalName }, cfs...)...)
 	return cfs, nil
 }
+
+// TrackUsage periodically persists the feature usage recorded by evaluations,
+// until ctx is cancelled. Usage still buffered at that point is flushed before
+// returning.
+func (svc Service) TrackUsage(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := svc.flushUsage(context.Background()); err != nil {
+				log.Error().
+					Err(err).
+					Msg("failed to flush feature usage")
+			}
+			return
+		case <-ticker.C:
+			if err := svc.flushUsage(ctx); err != nil {
+				log.Error().
+					Err(err).
+					Msg("failed to flush feature usage")
+			}
+		}
+	}
+}
+
+func (svc Service) flushUsage(ctx context.Context) (err error) {
+	ds := svc.usage.drain()
+	if len(ds) == 0 {
+		return nil
+	}
+
+	defer func() {
+		if err != nil {
+			// Keep the usage around for the next flush, rather than losing it.
+			svc.usage.requeue(ds...)
+		}
+	}()
+
+	tx, err := svc.store.Begin(ctx, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+	})
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := tx.SaveUsage(ctx, ds...); err != nil {
+		return fmt.Errorf("save usage: %w", err)
+	}
+
+	if err := tx.DeleteUsageBefore(ctx, svc.timeFunc().Add(-usageWindow)); err != nil {
+		return fmt.Errorf("delete outdated usage: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// Reasons for a feature to be reported as stale.
+const (
+	staleReasonUnused  = "unused"
+	staleReasonExpired = "expired"
+	staleReasonStatic  = "static"
+)
+
+// staleFeature is a feature that is a likely candidate for removal.
+type staleFeature struct {
+	Feature
+	Reasons         []string
+	LastEvaluatedAt *time.Time
+	EvaluationCount int
+}
+
+var errBadStaleDays = render.NewBadRequest("'days' must be a positive integer")
+
+// findStaleFeatures reports the features that either haven't been evaluated
+// for the given number of days, have expired, or have resolved the same way for
+// every customer for the given number of days.
+func (svc Service) findStaleFeatures(ctx context.Context, days int) ([]staleFeature, error) {
+	if days < 1 {
+		return nil, errBadStaleDays
+	}
+
+	var (
+		now    = svc.timeFunc()
+		cutoff = now.AddDate(0, 0, -days)
+	)
+
+	fs, err := svc.store.FindAllFeatures(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("find all features: %w", err)
+	}
+
+	usageByName, err := findUsageByName(ctx, svc.store, now.Add(-usageWindow))
+	if err != nil {
+		return nil, err
+	}
+
+	// Evaluations of aliases count as evaluations of the renamed feature.
+	as, err := svc.store.FindAllAliases(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("find all aliases: %w", err)
+	}
+	featureNames := make(map[uuid.UUID]string, len(fs))
+	for _, f := range fs {
+		featureNames[f.ID] = f.TechnicalName
+	}
+	for _, a := range as {
+		au, ok := usageByName[a.TechnicalName]
+		name, found := featureNames[a.FeatureID]
+		if !ok || !found {
+			continue
+		}
+		u, ok := usageByName[name]
+		if !ok || u.LastEvaluatedAt.Before(au.LastEvaluatedAt) {
+			u.LastEvaluatedAt = au.LastEvaluatedAt
+		}
+		u.TechnicalName = name
+		u.EvaluationCount += au.EvaluationCount
+		usageByName[name] = u
+	}
+
+	customerCounts, err := svc.store.CountCustomersByFeature(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("count customers by feature: %w", err)
+	}
+
+	var res []staleFeature
+	for _, f := range fs {
+		sf := staleFeature{Feature: f}
+
+		u, ok := usageByName[f.TechnicalName]
+		if ok {
+			sf.LastEvaluatedAt = &u.LastEvaluatedAt
+			sf.EvaluationCount = u.EvaluationCount
+		}
+
+		switch {
+		case ok && u.LastEvaluatedAt.Before(cutoff):
+			sf.Reasons = append(sf.Reasons, staleReasonUnused)
+		case !ok && f.CreatedAt.Before(cutoff):
+			// Features created recently get a grace period before they are
+			// expected to be evaluated.
+			sf.Reasons = append(sf.Reasons, staleReasonUnused)
+		}
+
+		if f.ExpiresOn != nil && f.ExpiresOn.Before(now) {
+			sf.Reasons = append(sf.Reasons, staleReasonExpired)
+		}
+
+		// A feature that is killed, inverted or targets no customer, neither by
+		// ID nor by a rule, is off for everyone. Left like that long enough, it
+		// is probably no longer needed.
+		changedAt := f.UpdatedAt
+		if f.KilledAt != nil && f.KilledAt.After(changedAt) {
+			changedAt = *f.KilledAt
+		}
+		if changedAt.Before(cutoff) && (f.Killed || f.Inverted || (customerCounts[f.ID] == 0 && len(f.Rules) == 0)) {
+			sf.Reasons = append(sf.Reasons, staleReasonStatic)
+		}
+
+		if len(sf.Reasons) != 0 {
+			res = append(res, sf)
+		}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,92 @@
+package feature
+
+import (
+	"sync"
+	"time"
+)
+
+// usageWindow is the span of days over which rolling evaluation counts are
+// reported. Daily buckets older than this are discarded.
+const usageWindow = 30 * 24 * time.Hour
+
+// usageDayLayout is the layout of the daily usage bucket keys.
+const usageDayLayout = "2006-01-02"
+
+// Usage of a single feature, as reported in the stale feature report.
+type Usage struct {
+	TechnicalName   string
+	LastEvaluatedAt time.Time
+	EvaluationCount int
+}
+
+// UsageDelta is the not yet persisted usage of a technical name on a given day.
+type UsageDelta struct {
+	TechnicalName   string
+	Day             string
+	Count           int
+	LastEvaluatedAt time.Time
+}
+
+type usageKey struct {
+	technicalName string
+	day           string
+}
+
+// usageTracker buffers feature evaluations in memory, so that they can be
+// persisted in batches outside the evaluation hot path.
+type usageTracker struct {
+	mu      sync.Mutex
+	pending map[usageKey]*UsageDelta
+}
+
+func newUsageTracker() *usageTracker {
+	return &usageTracker{pending: make(map[usageKey]*UsageDelta)}
+}
+
+func (t *usageTracker) record(at time.Time, technicalNames ...string) {
+	at = at.UTC()
+	day := at.Format(usageDayLayout)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, name := range technicalNames {
+		t.add(UsageDelta{TechnicalName: name, Day: day, Count: 1, LastEvaluatedAt: at})
+	}
+}
+
+// drain returns all pending deltas and resets the tracker.
+func (t *usageTracker) drain() []UsageDelta {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	res := make([]UsageDelta, 0, len(t.pending))
+	for _, d := range t.pending {
+		res = append(res, *d)
+	}
+	t.pending = make(map[usageKey]*UsageDelta)
+	return res
+}
+
+// requeue merges deltas that failed to persist back into the tracker.
+func (t *usageTracker) requeue(ds ...UsageDelta) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, d := range ds {
+		t.add(d)
+	}
+}
+
+func (t *usageTracker) add(d UsageDelta) {
+	k := usageKey{technicalName: d.TechnicalName, day: d.Day}
+	p, ok := t.pending[k]
+	if !ok {
+		t.pending[k] = &d
+		return
+	}
+	p.Count += d.Count
+	if p.LastEvaluatedAt.Before(d.LastEvaluatedAt) {
+		p.LastEvaluatedAt = d.LastEvaluatedAt
+	}
+}
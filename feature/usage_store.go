@@ -0,0 +1,92 @@
+package feature
+
+import (
+	"context"
+	"time"
+)
+
+func (s Store) SaveUsage(ctx context.Context, ds ...UsageDelta) error {
+	defer observeQuery("saveUsage")()
+
+	for _, d := range ds {
+		if _, err := s.db.ExecContext(
+			ctx,
+			//language=sqlite
+			`INSERT INTO feature_usage (technical_name,last_evaluated_at) VALUES (?,?)
+			ON CONFLICT (technical_name) DO UPDATE SET last_evaluated_at=excluded.last_evaluated_at
+			WHERE excluded.last_evaluated_at > feature_usage.last_evaluated_at`,
+			d.TechnicalName, d.LastEvaluatedAt.UTC(),
+		); err != nil {
+			return err
+		}
+
+		if _, err := s.db.ExecContext(
+			ctx,
+			//language=sqlite
+			`INSERT INTO feature_usage_daily (technical_name,day,evaluation_count) VALUES (?,?,?)
+			ON CONFLICT (technical_name,day) DO UPDATE SET evaluation_count=feature_usage_daily.evaluation_count+excluded.evaluation_count`,
+			d.TechnicalName, d.Day, d.Count,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteUsageBefore discards the daily usage buckets older than t.
+func (s Store) DeleteUsageBefore(ctx context.Context, t time.Time) error {
+	defer observeQuery("deleteUsageBefore")()
+
+	_, err := s.db.ExecContext(
+		ctx,
+		//language=sqlite
+		`DELETE FROM feature_usage_daily WHERE day < ?`,
+		t.UTC().Format(usageDayLayout),
+	)
+	return err
+}
+
+// FindUsage returns the usage of every technical name that has ever been
+// evaluated, with evaluation counts summed since t.
+func (s Store) FindUsage(ctx context.Context, t time.Time) ([]Usage, error) {
+	defer observeQuery("findUsage")()
+
+	rs, err := s.db.QueryContext(
+		ctx,
+		//language=sqlite
+		`
+		SELECT
+			u.technical_name,
+			u.last_evaluated_at,
+			COALESCE((
+				SELECT SUM(d.evaluation_count)
+				FROM feature_usage_daily d
+				WHERE d.technical_name = u.technical_name AND d.day >= ?
+			), 0)
+		FROM feature_usage u`,
+		t.UTC().Format(usageDayLayout),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var us []Usage
+	for rs.Next() {
+		var u Usage
+		if err := rs.Scan(&u.TechnicalName, &u.LastEvaluatedAt, &u.EvaluationCount); err != nil {
+			return nil, err
+		}
+		u.LastEvaluatedAt = u.LastEvaluatedAt.UTC()
+		us = append(us, u)
+	}
+
+	if err := rs.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := rs.Close(); err != nil {
+		return nil, err
+	}
+
+	return us, nil
+}
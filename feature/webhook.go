@@ -0,0 +1,395 @@
+package feature
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// Events webhooks can subscribe to.
+const (
+	eventFeatureCreated          = "feature.created"
+	eventFeatureUpdated          = "feature.updated"
+	eventFeatureArchived         = "feature.archived"
+	eventFeatureCustomersAdded   = "feature.customers_added"
+	eventFeatureCustomersRemoved = "feature.customers_removed"
+	eventFeatureExpired          = "feature.expired"
+)
+
+var knownEvents = map[string]struct{}{
+	eventFeatureCreated:          {},
+	eventFeatureUpdated:          {},
+	eventFeatureArchived:         {},
+	eventFeatureCustomersAdded:   {},
+	eventFeatureCustomersRemoved: {},
+	eventFeatureExpired:          {},
+}
+
+const (
+	// webhookMaxAttempts is the number of delivery attempts after which a
+	// delivery is given up on.
+	webhookMaxAttempts = 10
+
+	// webhookBatchSize is the maximum number of deliveries claimed at once.
+	webhookBatchSize = 100
+
+	// webhookConcurrency is the maximum number of deliveries sent at once.
+	webhookConcurrency = 10
+
+	// webhookTimeout is the deadline of a single delivery attempt.
+	webhookTimeout = 10 * time.Second
+
+	// webhookLease is the time claimed deliveries are kept from other
+	// senders. It exceeds the time a batch takes to send, so that only
+	// deliveries of crashed senders are claimed again.
+	webhookLease = 5 * time.Minute
+
+	// webhookSignatureHeader carries the hex encoded HMAC-SHA256 of the
+	// timestamp, a dot and the request body, keyed with the webhook's secret.
+	webhookSignatureHeader = "X-Feature-Signature"
+
+	// webhookTimestampHeader carries the time of sending in seconds since the
+	// Unix epoch. Receivers reject old timestamps to prevent replays.
+	webhookTimestampHeader = "X-Feature-Timestamp"
+)
+
+// A Webhook subscription.
+type Webhook struct {
+	ID        uuid.UUID
+	URL       string
+	Secret    string
+	Events    []string
+	CreatedAt time.Time
+}
+
+func (wh Webhook) validate() error {
+	var errs errFeatureInvalid
+
+	if u, err := url.Parse(wh.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, "'url' must be an absolute http(s) URL")
+	}
+
+	for _, e := range wh.Events {
+		if _, ok := knownEvents[e]; !ok {
+			errs = append(errs, fmt.Sprintf("'events' contains unknown event %q", e))
+		}
+	}
+
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+// subscribedTo reports whether the webhook wants to receive the given event.
+func (wh Webhook) subscribedTo(event string) bool {
+	if len(wh.Events) == 0 {
+		return true
+	}
+	for _, e := range wh.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is an outbox entry, representing a single event to be sent
+// to a single webhook.
+type WebhookDelivery struct {
+	ID            uuid.UUID
+	WebhookID     uuid.UUID
+	Event         string
+	Payload       []byte
+	Attempts      int
+	NextAttemptAt time.Time
+	DeliveredAt   *time.Time
+	FailedAt      *time.Time
+	LastError     *string
+	CreatedAt     time.Time
+
+	// URL and Secret of the webhook, populated when deliveries are retrieved for
+	// sending.
+	URL    string
+	Secret string
+}
+
+type webhookPayload struct {
+	ID          uuid.UUID       `json:"id"`
+	Event       string          `json:"event"`
+	OccurredAt  int64           `json:"occurredAt"`
+	Feature     featureResponse `json:"feature"`
+	CustomerIDs []string        `json:"customerIds,omitempty"`
+}
+
+// webhookBackoff returns the delay before the next attempt of a delivery that
+// has failed the given number of times.
+func webhookBackoff(attempts int) time.Duration {
+	d := 10 * time.Second
+	for i := 1; i < attempts && d < time.Hour; i++ {
+		d *= 2
+	}
+	if d > time.Hour {
+		d = time.Hour
+	}
+	return d
+}
+
+// signWebhookPayload signs the payload along with the time it is sent at, so
+// that signed requests can't be replayed later on.
+func signWebhookPayload(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func generateWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// publish writes an event about the feature to the outbox of every webhook
+// subscribed to it. It is expected to be called with the transaction of the
+// change that caused the event, so that the event is persisted if and only if
+// the change is.
+func (svc Service) publish(ctx context.Context, tx Repository, event string, f Feature, customerIDs ...string) error {
+	whs, err := tx.FindAllWebhooks(ctx)
+	if err != nil {
+		return fmt.Errorf("find all webhooks: %w", err)
+	}
+
+	now := svc.timeFunc()
+	for _, wh := range whs {
+		if !wh.subscribedTo(event) {
+			continue
+		}
+
+		id, err := svc.uuidFunc()
+		if err != nil {
+			return fmt.Errorf("generate webhook delivery id: %w", err)
+		}
+
+		payload, err := json.Marshal(webhookPayload{
+			ID:          id,
+			Event:       event,
+			OccurredAt:  now.UnixMilli(),
+			Feature:     responseFromFeature(f),
+			CustomerIDs: customerIDs,
+		})
+		if err != nil {
+			return fmt.Errorf("marshal webhook payload: %w", err)
+		}
+
+		if err := tx.SaveWebhookDelivery(ctx, WebhookDelivery{
+			ID:            id,
+			WebhookID:     wh.ID,
+			Event:         event,
+			Payload:       payload,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+		}); err != nil {
+			return fmt.Errorf("save webhook delivery: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (svc Service) saveWebhook(ctx context.Context, wh Webhook) (*Webhook, error) {
+	if err := wh.validate(); err != nil {
+		return nil, fmt.Errorf("validate webhook: %w", err)
+	}
+
+	id, err := svc.uuidFunc()
+	if err != nil {
+		return nil, fmt.Errorf("generate webhook id: %w", err)
+	}
+	wh.ID = id
+	wh.CreatedAt = svc.timeFunc()
+
+	if wh.Secret == "" {
+		if wh.Secret, err = generateWebhookSecret(); err != nil {
+			return nil, fmt.Errorf("generate webhook secret: %w", err)
+		}
+	}
+
+	if err := svc.store.SaveWebhook(ctx, wh); err != nil {
+		return nil, fmt.Errorf("save webhook: %w", err)
+	}
+
+	return &wh, nil
+}
+
+func (svc Service) deleteWebhook(ctx context.Context, id uuid.UUID) error {
+	if err := svc.store.DeleteWebhook(ctx, id); err != nil {
+		return fmt.Errorf("delete webhook: %w", err)
+	}
+	return nil
+}
+
+// DeliverWebhooks periodically sends pending webhook deliveries, and publishes
+// the expiry of features, until ctx is cancelled. Deliveries are sent at least
+// once: a crash between sending and recording the delivery results in a
+// repeated delivery.
+func (svc Service) DeliverWebhooks(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := svc.publishExpiredFeatures(ctx); err != nil {
+				log.Error().
+					Err(err).
+					Msg("failed to publish expired features")
+			}
+			if err := svc.deliverWebhooks(ctx); err != nil {
+				log.Error().
+					Err(err).
+					Msg("failed to deliver webhooks")
+			}
+		}
+	}
+}
+
+func (svc Service) publishExpiredFeatures(ctx context.Context) error {
+	tx, err := svc.store.Begin(ctx, &sql.TxOptions{
+		Isolation: sql.LevelDefault,
+	})
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	fs, err := tx.FindUnpublishedExpiredFeatures(ctx, svc.timeFunc())
+	if err != nil {
+		return fmt.Errorf("find unpublished expired features: %w", err)
+	}
+
+	for _, f := range fs {
+		if err := svc.publish(ctx, tx, eventFeatureExpired, f); err != nil {
+			return fmt.Errorf("publish expired feature: %w", err)
+		}
+		if err := tx.SaveExpiryNotification(ctx, f.ID, *f.ExpiresOn); err != nil {
+			return fmt.Errorf("save expiry notification: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// deliverWebhooks claims a batch of pending deliveries and sends them
+// concurrently, each within webhookTimeout. Claimed deliveries are leased,
+// keeping other replicas from sending them too.
+func (svc Service) deliverWebhooks(ctx context.Context) error {
+	now := svc.timeFunc()
+	ds, err := svc.store.ClaimPendingWebhookDeliveries(ctx, now, now.Add(webhookLease), webhookBatchSize)
+	if err != nil {
+		return fmt.Errorf("claim pending webhook deliveries: %w", err)
+	}
+
+	var (
+		wg       sync.WaitGroup
+		sem      = make(chan struct{}, webhookConcurrency)
+		mu       sync.Mutex
+		firstErr error
+	)
+	for _, d := range ds {
+		d := d
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			if err := svc.deliverWebhook(ctx, d); err != nil {
+				mu.Lock()
+				defer mu.Unlock()
+				if firstErr == nil {
+					firstErr = err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return firstErr
+}
+
+// deliverWebhook attempts to send the delivery, and records the outcome.
+func (svc Service) deliverWebhook(ctx context.Context, d WebhookDelivery) error {
+	sendCtx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+	sendErr := svc.sendWebhook(sendCtx, d)
+
+	now := svc.timeFunc()
+	d.Attempts++
+	switch {
+	case sendErr == nil:
+		d.DeliveredAt = &now
+		d.LastError = nil
+	case d.Attempts >= webhookMaxAttempts:
+		d.FailedAt = &now
+		d.LastError = ptr(sendErr.Error())
+	default:
+		d.NextAttemptAt = now.Add(webhookBackoff(d.Attempts))
+		d.LastError = ptr(sendErr.Error())
+	}
+
+	if err := svc.store.UpdateWebhookDelivery(ctx, d); err != nil {
+		return fmt.Errorf("update webhook delivery: %w", err)
+	}
+	return nil
+}
+
+func (svc Service) sendWebhook(ctx context.Context, d WebhookDelivery) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	timestamp := svc.timeFunc().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Feature-Event", d.Event)
+	req.Header.Set("X-Feature-Delivery", d.ID.String())
+	req.Header.Set(webhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(webhookSignatureHeader, signWebhookPayload(d.Secret, timestamp, d.Payload))
+
+	res, err := svc.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("send request: %w", err)
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 1<<16))
+
+	if res.StatusCode < 200 || 299 < res.StatusCode {
+		return fmt.Errorf("unexpected response status: %s", res.Status)
+	}
+	return nil
+}
@@ -0,0 +1,258 @@
+package feature
+
+import (
+	"context"
+	"database/sql"
+	"feature/pkg/sqlx"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func (s Store) FindAllWebhooks(ctx context.Context) ([]Webhook, error) {
+	defer observeQuery("findAllWebhooks")()
+
+	rs, err := s.db.QueryContext(
+		ctx,
+		//language=sqlite
+		`SELECT id,url,secret,events,created_at FROM webhooks ORDER BY created_at`,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var whs []Webhook
+	for rs.Next() {
+		var (
+			wh     Webhook
+			events sqlx.JSONArray[string]
+		)
+		if err := rs.Scan(&wh.ID, &wh.URL, &wh.Secret, &events, &wh.CreatedAt); err != nil {
+			return nil, err
+		}
+		wh.Events = events
+		wh.CreatedAt = wh.CreatedAt.UTC()
+		whs = append(whs, wh)
+	}
+
+	if err := rs.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := rs.Close(); err != nil {
+		return nil, err
+	}
+
+	return whs, nil
+}
+
+func (s Store) SaveWebhook(ctx context.Context, wh Webhook) error {
+	defer observeQuery("saveWebhook")()
+
+	_, err := s.db.ExecContext(
+		ctx,
+		//language=sqlite
+		`INSERT INTO webhooks (id,url,secret,events,created_at) VALUES (?,?,?,?,?)`,
+		wh.ID, wh.URL, wh.Secret, sqlx.JSONArray[string](wh.Events), wh.CreatedAt.UTC(),
+	)
+	return err
+}
+
+func (s Store) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+	defer observeQuery("deleteWebhook")()
+
+	res, err := s.db.ExecContext(
+		ctx,
+		//language=sqlite
+		`DELETE FROM webhooks WHERE id=?`,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	rs, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rs == 0 {
+		return errWebhookNotFound{id: id}
+	}
+	return nil
+}
+
+type errWebhookNotFound struct {
+	id uuid.UUID
+}
+
+func (e errWebhookNotFound) Error() string {
+	return fmt.Sprintf("webhook %s does not exist", e.id)
+}
+
+func (e errWebhookNotFound) Code() int {
+	return http.StatusNotFound
+}
+
+func (e errWebhookNotFound) ErrorCode() string {
+	return "webhook-not-found"
+}
+
+func (s Store) SaveWebhookDelivery(ctx context.Context, d WebhookDelivery) error {
+	defer observeQuery("saveWebhookDelivery")()
+
+	_, err := s.db.ExecContext(
+		ctx,
+		//language=sqlite
+		`INSERT INTO webhook_outbox (id,webhook_id,event,payload,attempts,next_attempt_at,created_at) VALUES (?,?,?,?,?,?,?)`,
+		d.ID, d.WebhookID, d.Event, string(d.Payload), d.Attempts, d.NextAttemptAt.UTC(), d.CreatedAt.UTC(),
+	)
+	return err
+}
+
+// ClaimPendingWebhookDeliveries implements WebhookRepository. The deliveries
+// are leased by moving their next attempt to leaseUntil. On PostgreSQL,
+// deliveries claimed by concurrent transactions are skipped, SQLite serializes
+// the claims anyway.
+func (s Store) ClaimPendingWebhookDeliveries(ctx context.Context, t, leaseUntil time.Time, limit int) ([]WebhookDelivery, error) {
+	defer observeQuery("claimPendingWebhookDeliveries")()
+
+	rs, err := s.db.QueryContext(
+		ctx,
+		//language=sqlite
+		`
+		UPDATE webhook_outbox SET next_attempt_at = ?
+		WHERE id IN (
+			SELECT id FROM webhook_outbox
+			WHERE delivered_at IS NULL AND failed_at IS NULL AND next_attempt_at <= ?
+			ORDER BY next_attempt_at
+			LIMIT ?`+s.dialect.SkipLocked()+`
+		)
+		RETURNING id, webhook_id, event, payload, attempts, next_attempt_at, created_at,
+			(SELECT w.url FROM webhooks w WHERE w.id = webhook_outbox.webhook_id),
+			(SELECT w.secret FROM webhooks w WHERE w.id = webhook_outbox.webhook_id)`,
+		leaseUntil.UTC(), t.UTC(), limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var ds []WebhookDelivery
+	for rs.Next() {
+		var (
+			d       WebhookDelivery
+			payload string
+		)
+		if err := rs.Scan(&d.ID, &d.WebhookID, &d.Event, &payload, &d.Attempts, &d.NextAttemptAt, &d.CreatedAt, &d.URL, &d.Secret); err != nil {
+			return nil, err
+		}
+		d.Payload = []byte(payload)
+		d.NextAttemptAt, d.CreatedAt = d.NextAttemptAt.UTC(), d.CreatedAt.UTC()
+		ds = append(ds, d)
+	}
+
+	if err := rs.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := rs.Close(); err != nil {
+		return nil, err
+	}
+
+	return ds, nil
+}
+
+func (s Store) UpdateWebhookDelivery(ctx context.Context, d WebhookDelivery) error {
+	defer observeQuery("updateWebhookDelivery")()
+
+	var deliveredAt, failedAt sql.NullTime
+	if d.DeliveredAt != nil {
+		deliveredAt = sql.NullTime{Time: d.DeliveredAt.UTC(), Valid: true}
+	}
+	if d.FailedAt != nil {
+		failedAt = sql.NullTime{Time: d.FailedAt.UTC(), Valid: true}
+	}
+
+	var lastError sql.NullString
+	if d.LastError != nil {
+		lastError = sql.NullString{String: *d.LastError, Valid: true}
+	}
+
+	_, err := s.db.ExecContext(
+		ctx,
+		//language=sqlite
+		`UPDATE webhook_outbox SET attempts=?, next_attempt_at=?, delivered_at=?, failed_at=?, last_error=? WHERE id=?`,
+		d.Attempts, d.NextAttemptAt.UTC(), deliveredAt, failedAt, lastError, d.ID,
+	)
+	return err
+}
+
+// FindUnpublishedExpiredFeatures returns the features that have expired at time
+// t, but whose current expiry date hasn't been published yet.
+func (s Store) FindUnpublishedExpiredFeatures(ctx context.Context, t time.Time) ([]Feature, error) {
+	defer observeQuery("findUnpublishedExpiredFeatures")()
+
+	rs, err := s.db.QueryContext(
+		ctx,
+		//language=sqlite
+		`
+		SELECT f.id,f.display_name,f.technical_name,f.expires_on,f.description,f.inverted,f.killed,f.killed_at,f.created_at,f.updated_at,f.tags,f.owner,f.ticket_url,f.kind,f.rules
+		FROM features f
+		LEFT JOIN feature_expiry_notifications n ON n.feature_id = f.id AND n.expires_on = f.expires_on
+		WHERE f.expires_on IS NOT NULL AND f.expires_on < ? AND n.feature_id IS NULL`,
+		t.UTC(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var fs []Feature
+	for rs.Next() {
+		var fr featureRow
+		if err := rs.Scan(
+			&fr.ID,
+			&fr.DisplayName,
+			&fr.TechnicalName,
+			&fr.ExpiresOn,
+			&fr.Description,
+			&fr.Inverted,
+			&fr.Killed,
+			&fr.KilledAt,
+			&fr.CreatedAt,
+			&fr.UpdatedAt,
+			&fr.Tags,
+			&fr.Owner,
+			&fr.TicketURL,
+			&fr.Kind,
+			&fr.Rules,
+		); err != nil {
+			return nil, err
+		}
+		fs = append(fs, fr.toFeature())
+	}
+
+	if err := rs.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := rs.Close(); err != nil {
+		return nil, err
+	}
+
+	return fs, nil
+}
+
+func (s Store) SaveExpiryNotification(ctx context.Context, featureID uuid.UUID, expiresOn time.Time) error {
+	defer observeQuery("saveExpiryNotification")()
+
+	_, err := s.db.ExecContext(
+		ctx,
+		//language=sqlite
+		`INSERT INTO feature_expiry_notifications (feature_id,expires_on) VALUES (?,?)
+		ON CONFLICT (feature_id) DO UPDATE SET expires_on=excluded.expires_on`,
+		featureID, expiresOn.UTC(),
+	)
+	return err
+}
@@ -0,0 +1,189 @@
+package feature
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"feature/pkg/config"
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDeliverWebhooks(t *testing.T) {
+	viper.SetConfigFile("../test.env")
+	if err := viper.ReadInConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open(config.Driver(), config.DSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewStore(db)
+
+	tx, _, rollback, err := store.beginTx(context.Background(), &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+	if err != nil {
+		t.Fatalf("failed to begin transaction: %s\n", err)
+	}
+
+	t.Cleanup(func() {
+		if err := rollback(); err != nil {
+			t.Errorf("failed to rollback the transaction: %s\n", err)
+		}
+	})
+
+	type delivery struct {
+		event     string
+		timestamp string
+		signature string
+		payload   webhookPayload
+		body      []byte
+	}
+
+	var (
+		mu         sync.Mutex
+		deliveries []delivery
+		failing    = true
+	)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if failing {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		d := delivery{
+			event:     r.Header.Get("X-Feature-Event"),
+			timestamp: r.Header.Get(webhookTimestampHeader),
+			signature: r.Header.Get(webhookSignatureHeader),
+			body:      body,
+		}
+		if err := json.Unmarshal(body, &d.payload); err != nil {
+			t.Errorf("failed to unmarshal webhook payload: %s", err)
+		}
+		deliveries = append(deliveries, d)
+	}))
+	t.Cleanup(receiver.Close)
+
+	var (
+		refTime   = time.Now().Truncate(time.Second).UTC()
+		oneDayAgo = refTime.AddDate(0, 0, -1)
+		now       = refTime
+	)
+
+	setupWebhooks(t, *tx,
+		Webhook{ID: uuid.New(), URL: receiver.URL, Secret: "s3cr3t", CreatedAt: refTime},
+		Webhook{ID: uuid.New(), URL: receiver.URL, Secret: "other", Events: []string{eventFeatureArchived}, CreatedAt: refTime},
+	)
+
+	service := NewService(*tx)
+	service.timeFunc = func() time.Time { return now }
+	service.httpClient = receiver.Client()
+
+	if err := service.saveFeature(context.Background(), Feature{
+		TechnicalName: "feature-1",
+		ExpiresOn:     &oneDayAgo,
+		CustomerIDs:   []string{"customer-1"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The receiver is down, so the delivery must be retried after a backoff.
+	if err := service.deliverWebhooks(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ds, err := tx.ClaimPendingWebhookDeliveries(context.Background(), now, now.Add(webhookLease), webhookBatchSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ds) != 0 {
+		t.Errorf("Failed delivery retried without backoff: %v", ds)
+	}
+
+	mu.Lock()
+	failing = false
+	mu.Unlock()
+
+	now = now.Add(webhookBackoff(1))
+	if err := service.publishExpiredFeatures(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.deliverWebhooks(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Expiry must only be published once.
+	if err := service.publishExpiredFeatures(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.deliverWebhooks(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(deliveries) != 2 {
+		t.Fatalf("Unexpected number of deliveries.\nwant: %d\ngot:  %d", 2, len(deliveries))
+	}
+
+	// The retried creation and the expiry are sent concurrently.
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].payload.OccurredAt < deliveries[j].payload.OccurredAt })
+
+	wantEvents := []string{eventFeatureCreated, eventFeatureExpired}
+	for i, d := range deliveries {
+		if d.event != wantEvents[i] || d.payload.Event != wantEvents[i] {
+			t.Errorf("Events not equal.\nwant: %s\ngot:  %s (payload %s)", wantEvents[i], d.event, d.payload.Event)
+		}
+		if d.payload.Feature.TechnicalName != "feature-1" {
+			t.Errorf("Feature technical names not equal.\nwant: %s\ngot:  %s", "feature-1", d.payload.Feature.TechnicalName)
+		}
+		if want := strconv.FormatInt(now.Unix(), 10); d.timestamp != want {
+			t.Errorf("Timestamps not equal.\nwant: %s\ngot:  %s", want, d.timestamp)
+		}
+		if want := signWebhookPayload("s3cr3t", now.Unix(), d.body); d.signature != want {
+			t.Errorf("Signatures not equal.\nwant: %s\ngot:  %s", want, d.signature)
+		}
+	}
+}
+
+func TestSignWebhookPayload(t *testing.T) {
+	payload := []byte(`{"event":"feature.created"}`)
+	signature := signWebhookPayload("s3cr3t", 1664625600, payload)
+
+	// A replayed request must not be accepted with a new timestamp.
+	if other := signWebhookPayload("s3cr3t", 1664625601, payload); other == signature {
+		t.Errorf("Signatures of other timestamps equal: %s", signature)
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	tests := map[int]time.Duration{
+		1:  10 * time.Second,
+		2:  20 * time.Second,
+		3:  40 * time.Second,
+		9:  2560 * time.Second,
+		10: time.Hour,
+	}
+
+	for attempts, want := range tests {
+		if got := webhookBackoff(attempts); got != want {
+			t.Errorf("Backoff after %d attempts not equal.\nwant: %s\ngot:  %s", attempts, want, got)
+		}
+	}
+}
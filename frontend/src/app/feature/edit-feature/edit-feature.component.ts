@@ -3,6 +3,8 @@ import {Location} from "@angular/common";
 import {ActivatedRoute, Router} from "@angular/router";
 import {switchMap} from "rxjs";
 import {FeatureService} from "../services/feature.service";
+import {CustomerService} from "../services/customer.service";
+import {Customer} from "../services/customer";
 import {Feature} from "../services/feature";
 
 @Component({
@@ -17,18 +19,22 @@ export class EditFeatureComponent implements OnInit {
     description: null,
     expiresOn: null,
     inverted: false,
+    killed: false,
     createdAt: 0,
     updatedAt: 0,
     customerIds: [],
   };
 
+  customerSuggestions: Customer[] = [];
+
   loading = false;
 
   constructor(
     private location: Location,
     private route: ActivatedRoute,
     private router: Router,
-    private featureService: FeatureService
+    private featureService: FeatureService,
+    private customerService: CustomerService
   ) {
   }
 
@@ -87,6 +93,16 @@ export class EditFeatureComponent implements OnInit {
     }
   }
 
+  suggestCustomers(e: any): void {
+    const q = e.target.value.trim();
+    if (q === '') {
+      this.customerSuggestions = [];
+      return;
+    }
+    this.customerService.searchCustomers(q)
+      .subscribe(customers => this.customerSuggestions = customers);
+  }
+
   changeCustomer(e: any, i: number): void {
     this.feature.customerIds![i] = e.target.value;
   }
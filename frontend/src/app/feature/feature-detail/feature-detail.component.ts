@@ -4,7 +4,7 @@ import {switchMap} from "rxjs";
 import {Location} from "@angular/common";
 
 import {FeatureService} from "../services/feature.service";
-import {Feature} from "../services/feature";
+import {ExplainedFeature, Feature} from "../services/feature";
 
 @Component({
   selector: 'app-feature-detail',
@@ -18,6 +18,7 @@ export class FeatureDetailComponent implements OnInit {
     description: null,
     expiresOn: null,
     inverted: false,
+    killed: false,
     createdAt: 0,
     updatedAt: 0,
     customerIds: null,
@@ -28,6 +29,11 @@ export class FeatureDetailComponent implements OnInit {
 
   showModal = false;
 
+  testCustomerId = '';
+  testing = false;
+  explanation: ExplainedFeature | null = null;
+  explainedAt: number | null = null;
+
   constructor(
     private route: ActivatedRoute,
     private router: Router,
@@ -51,6 +57,50 @@ export class FeatureDetailComponent implements OnInit {
     this.location.back();
   }
 
+  toggleKill(): void {
+    this.loading = true;
+    const killed = !this.feature.killed;
+    const request = killed
+      ? this.featureService.killFeature(this.feature.id!)
+      : this.featureService.unkillFeature(this.feature.id!);
+
+    const that = this;
+    request.subscribe({
+      complete() {
+        that.feature.killed = killed;
+        that.loading = false;
+      },
+      error(e) {
+        console.log(e);
+        that.loading = false;
+      }
+    });
+  }
+
+  testCustomer(): void {
+    const customerId = this.testCustomerId.trim();
+    if (customerId === '') {
+      return;
+    }
+
+    this.testing = true;
+    const that = this;
+    this.featureService.explainFeatures(customerId, [this.feature.technicalName])
+      .subscribe({
+        next(res) {
+          that.explanation = res.features[0] ?? null;
+          that.explainedAt = res.evaluatedAt;
+        },
+        complete() {
+          that.testing = false;
+        },
+        error(e) {
+          console.log(e);
+          that.testing = false;
+        }
+      });
+  }
+
   startArchive(): void {
     this.showModal = true;
   }
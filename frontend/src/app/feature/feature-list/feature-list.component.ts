@@ -1,5 +1,5 @@
 import {Component, OnInit} from '@angular/core';
-import {Feature} from "../services/feature";
+import {Feature, FeatureQuery, StaleFeature} from "../services/feature";
 import {FeatureService} from "../services/feature.service";
 
 @Component({
@@ -8,8 +8,16 @@ import {FeatureService} from "../services/feature.service";
 })
 export class FeatureListComponent implements OnInit {
   features: Feature[] = [];
+  total = 0;
+  nextCursor?: string;
+  staleFeatures = new Map<string, StaleFeature>();
+
+  search = '';
+  status = '';
+  sort = 'name';
 
   initialLoading = true;
+  loadingMore = false;
 
   constructor(
     private featureService: FeatureService,
@@ -18,13 +26,74 @@ export class FeatureListComponent implements OnInit {
 
   ngOnInit(): void {
     this.getFeatures();
+    this.getStaleFeatures();
   }
 
   getFeatures(): void {
-    this.featureService.getFeatures()
-      .subscribe(features => {
+    this.featureService.getFeatures(this.query())
+      .subscribe(({features, total, nextCursor}) => {
         this.initialLoading = false;
         this.features = features;
+        this.total = total;
+        this.nextCursor = nextCursor;
+      });
+  }
+
+  getMoreFeatures(): void {
+    this.loadingMore = true;
+    this.featureService.getFeatures({...this.query(), cursor: this.nextCursor})
+      .subscribe(({features, total, nextCursor}) => {
+        this.loadingMore = false;
+        this.features = this.features.concat(features);
+        this.total = total;
+        this.nextCursor = nextCursor;
+      });
+  }
+
+  private query(): FeatureQuery {
+    const query: FeatureQuery = {q: this.search, sort: this.sort};
+    switch (this.status) {
+      case 'expired':
+        query.expired = true;
+        break;
+      case 'active':
+        query.expired = false;
+        query.inverted = false;
+        break;
+      case 'inverted':
+        query.inverted = true;
+        break;
+    }
+    return query;
+  }
+
+  getStaleFeatures(): void {
+    this.featureService.getStaleFeatures()
+      .subscribe(({features}) => {
+        this.staleFeatures = new Map(features.map(f => [f.id!, f]));
       });
   }
+
+  staleReasons(feature: Feature): string | null {
+    const stale = this.staleFeatures.get(feature.id!);
+    if (!stale) {
+      return null;
+    }
+
+    const reasons = stale.reasons.map(reason => {
+      switch (reason) {
+        case 'unused':
+          return stale.lastEvaluatedAt
+            ? `not evaluated since ${new Date(stale.lastEvaluatedAt).toLocaleDateString()}`
+            : 'never evaluated';
+        case 'expired':
+          return 'expired';
+        case 'static':
+          return 'off for everyone for a long time';
+        default:
+          return reason;
+      }
+    });
+    return `Stale: ${reasons.join(', ')}`;
+  }
 }
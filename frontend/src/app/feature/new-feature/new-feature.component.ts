@@ -2,6 +2,8 @@ import {Component, OnInit} from '@angular/core';
 import {Location} from "@angular/common";
 import {Feature} from "../services/feature";
 import {FeatureService} from "../services/feature.service";
+import {CustomerService} from "../services/customer.service";
+import {Customer} from "../services/customer";
 import {Router} from "@angular/router";
 
 @Component({
@@ -16,15 +18,19 @@ export class NewFeatureComponent implements OnInit {
     description: null,
     expiresOn: null,
     inverted: false,
+    killed: false,
     createdAt: 0,
     updatedAt: 0,
     customerIds: [],
   };
 
+  customerSuggestions: Customer[] = [];
+
   loading = false;
 
   constructor(
     private featureService: FeatureService,
+    private customerService: CustomerService,
     private location: Location,
     private router: Router
   ) {
@@ -75,6 +81,16 @@ export class NewFeatureComponent implements OnInit {
     this.feature.customerIds = this.feature.customerIds!.concat("");
   }
 
+  suggestCustomers(e: any): void {
+    const q = e.target.value.trim();
+    if (q === '') {
+      this.customerSuggestions = [];
+      return;
+    }
+    this.customerService.searchCustomers(q)
+      .subscribe(customers => this.customerSuggestions = customers);
+  }
+
   changeCustomer(e: any, i: number): void {
     this.feature.customerIds![i] = e.target.value;
   }
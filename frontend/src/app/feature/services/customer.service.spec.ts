@@ -0,0 +1,16 @@
+import { TestBed } from '@angular/core/testing';
+
+import { CustomerService } from './customer.service';
+
+describe('CustomerService', () => {
+  let service: CustomerService;
+
+  beforeEach(() => {
+    TestBed.configureTestingModule({});
+    service = TestBed.inject(CustomerService);
+  });
+
+  it('should be created', () => {
+    expect(service).toBeTruthy();
+  });
+});
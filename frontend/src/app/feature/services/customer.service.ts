@@ -0,0 +1,22 @@
+import {Injectable} from '@angular/core';
+import {HttpClient} from "@angular/common/http";
+import {map, Observable} from "rxjs";
+import {Customer} from "./customer";
+import {environment} from "../../../environments/environment.prod";
+
+@Injectable({
+  providedIn: 'root'
+})
+export class CustomerService {
+  private customersUrl = `${environment.apiHost}/api/v1/customers`;
+
+  constructor(
+    private http: HttpClient,
+  ) {
+  }
+
+  searchCustomers(q: string, limit = 10): Observable<Customer[]> {
+    return this.http.get<{ customers: Customer[] }>(this.customersUrl, {params: {q, limit}})
+      .pipe(map(res => res.customers));
+  }
+}
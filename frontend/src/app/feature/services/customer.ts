@@ -0,0 +1,7 @@
+export interface Customer {
+  id: string,
+  displayName: string | null,
+  attributes: { [name: string]: string },
+  createdAt: number,
+  updatedAt: number,
+}
@@ -1,7 +1,7 @@
 import {Injectable} from '@angular/core';
 import {HttpClient, HttpResponse} from "@angular/common/http";
 import {Observable} from "rxjs";
-import {Feature} from "./feature";
+import {EvaluationExplanation, Feature, FeaturePage, FeatureQuery, StaleFeature} from "./feature";
 import {environment} from "../../../environments/environment.prod";
 
 @Injectable({
@@ -16,8 +16,19 @@ export class FeatureService {
   ) {
   }
 
-  getFeatures(): Observable<Feature[]> {
-    return this.http.get<Feature[]>(this.featuresUrl);
+  getFeatures(query: FeatureQuery = {}): Observable<FeaturePage> {
+    const params: { [param: string]: string | number | boolean | ReadonlyArray<string> } = {};
+    for (const [key, value] of Object.entries(query)) {
+      if (value !== undefined && value !== '') {
+        params[key] = value;
+      }
+    }
+    return this.http.get<FeaturePage>(this.featuresUrl, {params});
+  }
+
+  getStaleFeatures(days?: number): Observable<{ features: StaleFeature[] }> {
+    const params: { [param: string]: number } = days === undefined ? {} : {days};
+    return this.http.get<{ features: StaleFeature[] }>(this.featuresUrl + '/stale', {params});
   }
 
   getFeature(id: string): Observable<Feature> {
@@ -30,7 +41,12 @@ export class FeatureService {
                 description,
                 inverted,
                 expiresOn,
-                customerIds
+                customerIds,
+                tags,
+                owner,
+                ticketUrl,
+                kind,
+                rules,
               }: Feature): Observable<HttpResponse<void>> {
     const expiresOnRFC3339 = expiresOn === null
       ? null
@@ -42,6 +58,11 @@ export class FeatureService {
       description,
       inverted,
       customerIds,
+      tags,
+      owner,
+      ticketUrl,
+      kind,
+      rules,
       expiresOn: expiresOn === null ? undefined : new Date(expiresOn).valueOf()
     });
   }
@@ -55,6 +76,11 @@ export class FeatureService {
                   description,
                   inverted,
                   customerIds,
+                  tags,
+                  owner,
+                  ticketUrl,
+                  kind,
+                  rules,
                 }: Feature): Observable<HttpResponse<void>> {
     return this.http.put<HttpResponse<void>>(this.featuresUrl + `/${id}`, {
       lastUpdatedAt: updatedAt,
@@ -65,11 +91,33 @@ export class FeatureService {
         description,
         inverted,
         customerIds,
+        tags,
+        owner,
+        ticketUrl,
+        kind,
+        rules: rules ?? [],
       }
     })
   }
 
+  killFeature(id: string): Observable<HttpResponse<void>> {
+    return this.http.post<HttpResponse<void>>(this.featuresUrl + `/${id}/kill`, null);
+  }
+
+  unkillFeature(id: string): Observable<HttpResponse<void>> {
+    return this.http.post<HttpResponse<void>>(this.featuresUrl + `/${id}/unkill`, null);
+  }
+
   archiveFeature(featureId: string): Observable<HttpResponse<void>> {
     return this.http.post<HttpResponse<void>>(this.archivedFeaturesUrl, {featureId})
   }
+
+  explainFeatures(customerId: string, names: string[]): Observable<EvaluationExplanation> {
+    return this.http.post<EvaluationExplanation>(this.featuresUrl + '/request', {
+      featureRequest: {
+        customerId,
+        features: names.map(name => ({name})),
+      },
+    }, {params: {explain: true}});
+  }
 }
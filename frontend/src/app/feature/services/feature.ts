@@ -5,7 +5,70 @@ export interface Feature {
   description: string | null,
   expiresOn: number | null,
   inverted: boolean,
+  killed: boolean,
   createdAt: number,
   updatedAt: number,
   customerIds: string[] | null,
+  tags?: string[],
+  owner?: string | null,
+  ticketUrl?: string | null,
+  kind?: FeatureKind,
+  rules?: TargetingRule[],
+}
+
+export interface TargetingRule {
+  attribute: string,
+  values: string[],
+}
+
+export type FeatureKind = '' | 'release' | 'experiment' | 'ops' | 'permission';
+
+export interface StaleFeature extends Feature {
+  reasons: string[],
+  lastEvaluatedAt: number | null,
+  evaluationCount: number,
+}
+
+export interface FeaturePage {
+  features: Feature[],
+  total: number,
+  nextCursor?: string,
+}
+
+export interface FeatureQuery {
+  q?: string,
+  expired?: boolean,
+  inverted?: boolean,
+  customerId?: string,
+  tag?: string[],
+  owner?: string,
+  kind?: FeatureKind,
+  sort?: string,
+  limit?: number,
+  cursor?: string,
+}
+
+export interface ExplainedFeature {
+  name: string,
+  active: boolean,
+  inverted: boolean,
+  killed: boolean,
+  expired: boolean,
+  deprecated?: boolean,
+  renamedTo?: string,
+  found: boolean,
+  steps: ExplanationStep[],
+}
+
+export interface ExplanationStep {
+  check: string,
+  result: boolean,
+  decisive: boolean,
+  detail: string,
+}
+
+export interface EvaluationExplanation {
+  evaluatedAt: number,
+  snapshotLoadedAt: number,
+  features: ExplainedFeature[],
 }
@@ -0,0 +1,29 @@
+// Package migrations contains the database schema, as a sequence of numbered
+// migrations per supported database. Every migration NN_name consists of
+// NN_name.up.sql, applying it, and NN_name.down.sql, reverting it. Applied
+// migrations must not be edited; add a new one instead, to every database.
+package migrations
+
+import (
+	"embed"
+	"io/fs"
+
+	"feature/pkg/sqlx"
+)
+
+//go:embed sqlite/*.sql postgres/*.sql
+var files embed.FS
+
+// For returns the migration files for the given dialect.
+func For(d sqlx.Dialect) fs.FS {
+	dir := "sqlite"
+	if d == sqlx.Postgres {
+		dir = "postgres"
+	}
+	sub, err := fs.Sub(files, dir)
+	if err != nil {
+		// Only fails for invalid paths, and dir is known to be valid.
+		panic(err)
+	}
+	return sub
+}
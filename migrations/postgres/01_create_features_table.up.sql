@@ -0,0 +1,11 @@
+CREATE TABLE features
+(
+    id             UUID PRIMARY KEY,
+    display_name   TEXT,
+    technical_name TEXT        NOT NULL UNIQUE,
+    expires_on     TIMESTAMPTZ,
+    description    TEXT,
+    inverted       BOOLEAN     NOT NULL DEFAULT FALSE,
+    created_at     TIMESTAMPTZ NOT NULL,
+    updated_at     TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,9 @@
+CREATE TABLE archived_features
+(
+    id             UUID PRIMARY KEY,
+    display_name   TEXT,
+    technical_name TEXT        NOT NULL,
+    description    TEXT,
+    created_at     TIMESTAMPTZ NOT NULL,
+    updated_at     TIMESTAMPTZ NOT NULL
+);
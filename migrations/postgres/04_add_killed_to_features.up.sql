@@ -0,0 +1,6 @@
+-- A killed feature is forced off for every customer, regardless of its
+-- customer list or inversion. The flag is kept separate from the rest of
+-- the feature's configuration, so that killing and reviving a feature does
+-- not lose any targeting.
+
+ALTER TABLE features ADD COLUMN killed BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,2 @@
+DROP TABLE feature_usage_daily;
+DROP TABLE feature_usage;
@@ -0,0 +1,20 @@
+-- Feature usage: technical names are tracked rather than feature IDs,
+-- since clients ask for features by name and may well ask for names
+-- that no longer (or never did) exist.
+
+CREATE TABLE feature_usage
+(
+    technical_name    TEXT PRIMARY KEY,
+    last_evaluated_at TIMESTAMPTZ NOT NULL
+);
+
+-- Evaluation counts are bucketed per day, so that a rolling count over
+-- a window of days can be derived, and old buckets can be discarded.
+
+CREATE TABLE feature_usage_daily
+(
+    technical_name   TEXT    NOT NULL,
+    day              TEXT    NOT NULL,
+    evaluation_count INTEGER NOT NULL,
+    PRIMARY KEY (technical_name, day)
+);
@@ -0,0 +1,44 @@
+-- Webhook subscriptions: an empty event filter subscribes to every event.
+
+CREATE TABLE webhooks
+(
+    id         UUID PRIMARY KEY,
+    url        TEXT        NOT NULL,
+    secret     TEXT        NOT NULL,
+    events     TEXT        NOT NULL DEFAULT '[]',
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+-- Webhook outbox: a row per subscription and event, written in the same
+-- transaction as the change that caused the event. Rows are delivered
+-- asynchronously, and kept afterwards for inspection.
+
+CREATE TABLE webhook_outbox
+(
+    id              UUID PRIMARY KEY,
+    webhook_id      UUID        NOT NULL,
+    event           TEXT        NOT NULL,
+    payload         TEXT        NOT NULL,
+    attempts        INTEGER     NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL,
+    delivered_at    TIMESTAMPTZ,
+    failed_at       TIMESTAMPTZ,
+    last_error      TEXT,
+    created_at      TIMESTAMPTZ NOT NULL,
+    FOREIGN KEY (webhook_id) REFERENCES webhooks (id) ON DELETE CASCADE
+);
+
+CREATE INDEX webhook_outbox_pending_idx ON webhook_outbox (next_attempt_at)
+    WHERE delivered_at IS NULL AND failed_at IS NULL;
+
+-- Expiry notifications: features are not changed when they expire, so the
+-- expiry date of which subscribers were last notified is kept separately.
+-- Moving the expiry date of a feature makes it eligible for notification
+-- again.
+
+CREATE TABLE feature_expiry_notifications
+(
+    feature_id UUID PRIMARY KEY,
+    expires_on TIMESTAMPTZ NOT NULL,
+    FOREIGN KEY (feature_id) REFERENCES features (id) ON DELETE CASCADE
+);
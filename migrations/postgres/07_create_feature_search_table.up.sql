@@ -0,0 +1,32 @@
+-- Search documents: a row per feature and archived feature, holding the text
+-- it can be found by. Archived features keep their ID, hence the archived flag
+-- in the key. Rows are kept in sync by the store.
+
+CREATE TABLE feature_search
+(
+    feature_id     UUID    NOT NULL,
+    archived       BOOLEAN NOT NULL,
+    technical_name TEXT    NOT NULL,
+    display_name   TEXT,
+    description    TEXT,
+    customer_ids   TEXT    NOT NULL DEFAULT '',
+    PRIMARY KEY (feature_id, archived)
+);
+
+-- The expression must match the one searched by the store.
+CREATE INDEX feature_search_document_idx ON feature_search USING GIN (
+    to_tsvector('simple', technical_name || ' ' || COALESCE(display_name, '') || ' ' || COALESCE(description, '') || ' ' || customer_ids)
+);
+
+INSERT INTO feature_search (feature_id, archived, technical_name, display_name, description, customer_ids)
+SELECT f.id,
+       FALSE,
+       f.technical_name,
+       f.display_name,
+       f.description,
+       COALESCE((SELECT string_agg(cf.customer_id, ' ') FROM customer_features cf WHERE cf.feature_id = f.id), '')
+FROM features f;
+
+INSERT INTO feature_search (feature_id, archived, technical_name, display_name, description)
+SELECT id, TRUE, technical_name, display_name, description
+FROM archived_features;
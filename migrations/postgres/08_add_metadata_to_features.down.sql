@@ -0,0 +1,11 @@
+DROP INDEX feature_search_document_idx;
+CREATE INDEX feature_search_document_idx ON feature_search USING GIN (
+    to_tsvector('simple', technical_name || ' ' || COALESCE(display_name, '') || ' ' || COALESCE(description, '') || ' ' || customer_ids)
+);
+
+ALTER TABLE feature_search DROP COLUMN tags;
+
+ALTER TABLE features DROP COLUMN kind;
+ALTER TABLE features DROP COLUMN ticket_url;
+ALTER TABLE features DROP COLUMN owner;
+ALTER TABLE features DROP COLUMN tags;
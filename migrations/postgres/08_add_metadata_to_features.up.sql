@@ -0,0 +1,16 @@
+-- Metadata describing what a feature is for and who is responsible for it.
+-- Tags are kept as a JSON array, and indexed for search alongside the other
+-- text of a feature.
+
+ALTER TABLE features ADD COLUMN tags TEXT NOT NULL DEFAULT '[]';
+ALTER TABLE features ADD COLUMN owner TEXT;
+ALTER TABLE features ADD COLUMN ticket_url TEXT;
+ALTER TABLE features ADD COLUMN kind TEXT;
+
+ALTER TABLE feature_search ADD COLUMN tags TEXT NOT NULL DEFAULT '';
+
+-- The expression must match the one searched by the store.
+DROP INDEX feature_search_document_idx;
+CREATE INDEX feature_search_document_idx ON feature_search USING GIN (
+    to_tsvector('simple', technical_name || ' ' || COALESCE(display_name, '') || ' ' || COALESCE(description, '') || ' ' || tags || ' ' || customer_ids)
+);
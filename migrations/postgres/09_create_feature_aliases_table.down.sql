@@ -0,0 +1 @@
+DROP TABLE feature_aliases;
@@ -0,0 +1,13 @@
+-- Feature aliases: renaming a feature keeps its former technical name as
+-- an alias, which clients not yet migrated to the new name can still ask
+-- for. Aliases are removed explicitly once they are no longer evaluated.
+
+CREATE TABLE feature_aliases
+(
+    technical_name TEXT PRIMARY KEY,
+    feature_id     UUID        NOT NULL,
+    created_at     TIMESTAMPTZ NOT NULL,
+    FOREIGN KEY (feature_id) REFERENCES features (id) ON DELETE CASCADE
+);
+
+CREATE INDEX feature_aliases_feature_id_idx ON feature_aliases (feature_id);
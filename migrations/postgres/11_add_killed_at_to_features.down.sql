@@ -0,0 +1 @@
+ALTER TABLE features DROP COLUMN killed_at;
@@ -0,0 +1,5 @@
+-- When a feature was killed, so that a recent kill is not mistaken for a
+-- feature that has been off for everyone for long. Reviving the feature clears
+-- it.
+
+ALTER TABLE features ADD COLUMN killed_at TIMESTAMPTZ;
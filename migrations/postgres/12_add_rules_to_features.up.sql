@@ -0,0 +1,4 @@
+-- Targeting rules of a feature, kept as a JSON array of objects naming an
+-- attribute of the customer directory and the values it targets.
+
+ALTER TABLE features ADD COLUMN rules TEXT NOT NULL DEFAULT '[]';
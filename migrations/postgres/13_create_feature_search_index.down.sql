@@ -0,0 +1,11 @@
+DROP INDEX feature_search_document_idx;
+
+ALTER TABLE feature_search ADD COLUMN customer_ids TEXT NOT NULL DEFAULT '';
+
+UPDATE feature_search
+SET customer_ids = COALESCE((SELECT string_agg(cf.customer_id, ' ') FROM customer_features cf WHERE cf.feature_id = feature_search.feature_id), '')
+WHERE NOT archived;
+
+CREATE INDEX feature_search_document_idx ON feature_search USING GIN (
+    to_tsvector('simple', technical_name || ' ' || COALESCE(display_name, '') || ' ' || COALESCE(description, '') || ' ' || tags || ' ' || customer_ids)
+);
@@ -0,0 +1,9 @@
+-- Customer IDs aren't searchable anymore.
+
+DROP INDEX feature_search_document_idx;
+ALTER TABLE feature_search DROP COLUMN customer_ids;
+
+-- The expression must match the one searched by the store.
+CREATE INDEX feature_search_document_idx ON feature_search USING GIN (
+    to_tsvector('simple', technical_name || ' ' || COALESCE(display_name, '') || ' ' || COALESCE(description, '') || ' ' || tags)
+);
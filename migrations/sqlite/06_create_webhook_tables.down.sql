@@ -0,0 +1,3 @@
+DROP TABLE feature_expiry_notifications;
+DROP TABLE webhook_outbox;
+DROP TABLE webhooks;
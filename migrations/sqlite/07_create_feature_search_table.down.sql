@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS feature_search_fts_insert;
+DROP TRIGGER IF EXISTS feature_search_fts_delete;
+DROP TRIGGER IF EXISTS feature_search_fts_update;
+DROP TABLE IF EXISTS feature_search_fts;
+DROP TABLE feature_search;
@@ -0,0 +1,34 @@
+-- Search documents: a row per feature and archived feature, holding the text
+-- it can be found by. Archived features keep their ID, hence the archived flag
+-- in the key. Rows are kept in sync by the store. The explicit rowid keeps
+-- rowids stable across VACUUM, as the full-text index refers to them.
+--
+-- Builds with FTS5 (the sqlite_fts5 build tag) index the documents in the
+-- feature_search_fts table, which the store creates on first use, along with
+-- the triggers keeping it in sync. It can't be created here, as builds without
+-- FTS5 could no longer migrate.
+
+CREATE TABLE feature_search
+(
+    id             INTEGER PRIMARY KEY,
+    feature_id     BLOB    NOT NULL,
+    archived       TINYINT NOT NULL,
+    technical_name TEXT    NOT NULL,
+    display_name   TEXT,
+    description    TEXT,
+    customer_ids   TEXT    NOT NULL DEFAULT '',
+    UNIQUE (feature_id, archived)
+);
+
+INSERT INTO feature_search (feature_id, archived, technical_name, display_name, description, customer_ids)
+SELECT f.id,
+       FALSE,
+       f.technical_name,
+       f.display_name,
+       f.description,
+       COALESCE((SELECT group_concat(cf.customer_id, ' ') FROM customer_features cf WHERE cf.feature_id = f.id), '')
+FROM features f;
+
+INSERT INTO feature_search (feature_id, archived, technical_name, display_name, description)
+SELECT id, TRUE, technical_name, display_name, description
+FROM archived_features;
@@ -0,0 +1,6 @@
+ALTER TABLE feature_search DROP COLUMN tags;
+
+ALTER TABLE features DROP COLUMN kind;
+ALTER TABLE features DROP COLUMN ticket_url;
+ALTER TABLE features DROP COLUMN owner;
+ALTER TABLE features DROP COLUMN tags;
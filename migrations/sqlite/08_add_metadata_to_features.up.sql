@@ -0,0 +1,10 @@
+-- Metadata describing what a feature is for and who is responsible for it.
+-- Tags are kept as a JSON array, and indexed for search alongside the other
+-- text of a feature.
+
+ALTER TABLE features ADD COLUMN tags TEXT NOT NULL DEFAULT '[]';
+ALTER TABLE features ADD COLUMN owner TEXT;
+ALTER TABLE features ADD COLUMN ticket_url TEXT;
+ALTER TABLE features ADD COLUMN kind TEXT;
+
+ALTER TABLE feature_search ADD COLUMN tags TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,13 @@
+-- The customer directory: display names and attributes of the customers
+-- referenced by customer_features, so that they can be told apart from
+-- typos. Customers of features need not be in the directory. Attributes are
+-- kept as a JSON object of strings.
+
+CREATE TABLE customers
+(
+    id           TEXT PRIMARY KEY,
+    display_name TEXT,
+    attributes   TEXT      NOT NULL DEFAULT '{}',
+    created_at   TIMESTAMP NOT NULL,
+    updated_at   TIMESTAMP NOT NULL
+);
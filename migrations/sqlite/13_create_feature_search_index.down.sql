@@ -0,0 +1,10 @@
+DROP TRIGGER feature_search_fts_insert;
+DROP TRIGGER feature_search_fts_delete;
+DROP TRIGGER feature_search_fts_update;
+DROP TABLE feature_search_fts;
+
+ALTER TABLE feature_search ADD COLUMN customer_ids TEXT NOT NULL DEFAULT '';
+
+UPDATE feature_search
+SET customer_ids = COALESCE((SELECT group_concat(cf.customer_id, ' ') FROM customer_features cf WHERE cf.feature_id = feature_search.feature_id), '')
+WHERE NOT archived;
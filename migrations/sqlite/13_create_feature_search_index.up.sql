@@ -0,0 +1,40 @@
+-- Full-text index of the search documents, kept in sync by triggers. It needs
+-- FTS5, which go-sqlite3 only includes with the sqlite_fts5 build tag, so every
+-- binary using an SQLite database must be built with it. Earlier versions
+-- created the index on the first search, with customer IDs, which aren't
+-- searchable anymore.
+
+DROP TRIGGER IF EXISTS feature_search_fts_insert;
+DROP TRIGGER IF EXISTS feature_search_fts_delete;
+DROP TRIGGER IF EXISTS feature_search_fts_update;
+DROP TABLE IF EXISTS feature_search_fts;
+
+ALTER TABLE feature_search DROP COLUMN customer_ids;
+
+CREATE VIRTUAL TABLE feature_search_fts USING fts5(
+    technical_name,
+    display_name,
+    description,
+    tags,
+    content='feature_search',
+    content_rowid='id'
+);
+
+CREATE TRIGGER feature_search_fts_insert AFTER INSERT ON feature_search BEGIN
+    INSERT INTO feature_search_fts (rowid, technical_name, display_name, description, tags)
+    VALUES (new.id, new.technical_name, new.display_name, new.description, new.tags);
+END;
+
+CREATE TRIGGER feature_search_fts_delete AFTER DELETE ON feature_search BEGIN
+    INSERT INTO feature_search_fts (feature_search_fts, rowid, technical_name, display_name, description, tags)
+    VALUES ('delete', old.id, old.technical_name, old.display_name, old.description, old.tags);
+END;
+
+CREATE TRIGGER feature_search_fts_update AFTER UPDATE ON feature_search BEGIN
+    INSERT INTO feature_search_fts (feature_search_fts, rowid, technical_name, display_name, description, tags)
+    VALUES ('delete', old.id, old.technical_name, old.display_name, old.description, old.tags);
+    INSERT INTO feature_search_fts (rowid, technical_name, display_name, description, tags)
+    VALUES (new.id, new.technical_name, new.display_name, new.description, new.tags);
+END;
+
+INSERT INTO feature_search_fts (feature_search_fts) VALUES ('rebuild');
@@ -0,0 +1,17 @@
+package config
+
+import (
+	"github.com/spf13/viper"
+	"time"
+)
+
+func init() {
+	viper.BindEnv("EVALUATION_CACHE_REFRESH_INTERVAL")
+	viper.SetDefault("EVALUATION_CACHE_REFRESH_INTERVAL", 30*time.Second)
+}
+
+// EvaluationCacheRefreshInterval retrieves the interval at which the evaluation
+// cache is reloaded from the database from system env.
+func EvaluationCacheRefreshInterval() time.Duration {
+	return viper.GetDuration("EVALUATION_CACHE_REFRESH_INTERVAL")
+}
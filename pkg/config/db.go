@@ -1,12 +1,34 @@
 package config
 
-import "github.com/spf13/viper"
+import (
+	"strings"
+
+	"github.com/spf13/viper"
+)
 
 func init() {
 	viper.BindEnv("DSN")
+	viper.BindEnv("AUTO_MIGRATE")
 }
 
 // DSN retrieves the data source name from system env.
 func DSN() string {
 	return viper.GetString("DSN")
 }
+
+// Driver returns the name of the database/sql driver for the DSN. DSNs with a
+// postgres:// or postgresql:// scheme are served by PostgreSQL, everything else
+// by SQLite.
+func Driver() string {
+	dsn := DSN()
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		return "postgres"
+	}
+	return "sqlite3"
+}
+
+// AutoMigrate retrieves whether pending migrations are applied at startup from
+// system env.
+func AutoMigrate() bool {
+	return viper.GetBool("AUTO_MIGRATE")
+}
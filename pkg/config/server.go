@@ -9,6 +9,12 @@ func init() {
 	viper.BindEnv("SERVER_ADDR")
 	viper.BindEnv("SERVER_READ_TIMEOUT")
 	viper.BindEnv("SERVER_WRITE_TIMEOUT")
+	viper.BindEnv("SERVER_MAX_BODY_SIZE")
+	viper.SetDefault("SERVER_MAX_BODY_SIZE", "1MB")
+	viper.BindEnv("SERVER_MAX_UPLOAD_BODY_SIZE")
+	viper.SetDefault("SERVER_MAX_UPLOAD_BODY_SIZE", "32MB")
+	viper.BindEnv("SERVER_MAX_IMPORT_BODY_SIZE")
+	viper.SetDefault("SERVER_MAX_IMPORT_BODY_SIZE", "32MB")
 }
 
 // ServerAddr retrieves the HTTP server host address from system env.
@@ -25,3 +31,21 @@ func ServerReadTimeout() time.Duration {
 func ServerWriteTimeout() time.Duration {
 	return viper.GetDuration("SERVER_WRITE_TIMEOUT")
 }
+
+// ServerMaxBodySize retrieves the maximum size in bytes of API request bodies
+// from system env. Sizes may be given with a unit, like 512KB.
+func ServerMaxBodySize() int64 {
+	return int64(viper.GetSizeInBytes("SERVER_MAX_BODY_SIZE"))
+}
+
+// ServerMaxUploadBodySize retrieves the maximum size in bytes of customer
+// uploads from system env.
+func ServerMaxUploadBodySize() int64 {
+	return int64(viper.GetSizeInBytes("SERVER_MAX_UPLOAD_BODY_SIZE"))
+}
+
+// ServerMaxImportBodySize retrieves the maximum size in bytes of imported
+// configuration documents from system env.
+func ServerMaxImportBodySize() int64 {
+	return int64(viper.GetSizeInBytes("SERVER_MAX_IMPORT_BODY_SIZE"))
+}
@@ -0,0 +1,17 @@
+package config
+
+import (
+	"github.com/spf13/viper"
+	"time"
+)
+
+func init() {
+	viper.BindEnv("USAGE_FLUSH_INTERVAL")
+	viper.SetDefault("USAGE_FLUSH_INTERVAL", 10*time.Second)
+}
+
+// UsageFlushInterval retrieves the interval at which feature usage is persisted
+// from system env.
+func UsageFlushInterval() time.Duration {
+	return viper.GetDuration("USAGE_FLUSH_INTERVAL")
+}
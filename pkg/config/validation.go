@@ -0,0 +1,56 @@
+package config
+
+import (
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+func init() {
+	viper.BindEnv("FEATURE_NAME_PATTERN")
+	viper.BindEnv("FEATURE_NAME_MIN_LENGTH")
+	viper.SetDefault("FEATURE_NAME_MIN_LENGTH", 5)
+	viper.BindEnv("FEATURE_NAME_MAX_LENGTH")
+	viper.BindEnv("FEATURE_NAME_RESERVED_PREFIXES")
+	viper.BindEnv("FEATURE_REQUIRED_FIELDS")
+}
+
+// FeatureNamePattern retrieves the regular expression technical names of
+// features must match from system env. Empty if any name is allowed.
+func FeatureNamePattern() string {
+	return viper.GetString("FEATURE_NAME_PATTERN")
+}
+
+// FeatureNameMinLength retrieves the minimum length of technical names of
+// features from system env.
+func FeatureNameMinLength() int {
+	return viper.GetInt("FEATURE_NAME_MIN_LENGTH")
+}
+
+// FeatureNameMaxLength retrieves the maximum length of technical names of
+// features from system env. 0 if there is none.
+func FeatureNameMaxLength() int {
+	return viper.GetInt("FEATURE_NAME_MAX_LENGTH")
+}
+
+// FeatureNameReservedPrefixes retrieves the comma separated prefixes technical
+// names of features must not start with from system env.
+func FeatureNameReservedPrefixes() []string {
+	return splitList(viper.GetString("FEATURE_NAME_RESERVED_PREFIXES"))
+}
+
+// FeatureRequiredFields retrieves the comma separated fields every feature must
+// set from system env.
+func FeatureRequiredFields() []string {
+	return splitList(viper.GetString("FEATURE_REQUIRED_FIELDS"))
+}
+
+func splitList(s string) []string {
+	var res []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			res = append(res, v)
+		}
+	}
+	return res
+}
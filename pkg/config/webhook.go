@@ -0,0 +1,17 @@
+package config
+
+import (
+	"github.com/spf13/viper"
+	"time"
+)
+
+func init() {
+	viper.BindEnv("WEBHOOK_DELIVERY_INTERVAL")
+	viper.SetDefault("WEBHOOK_DELIVERY_INTERVAL", 5*time.Second)
+}
+
+// WebhookDeliveryInterval retrieves the interval at which pending webhook
+// deliveries are sent from system env.
+func WebhookDeliveryInterval() time.Duration {
+	return viper.GetDuration("WEBHOOK_DELIVERY_INTERVAL")
+}
@@ -0,0 +1,65 @@
+// Package metrics provides Prometheus instrumentation for HTTP servers.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	requestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "http",
+		Name:      "requests_total",
+		Help:      "Number of HTTP requests served, partitioned by method, route and status.",
+	}, []string{"method", "route", "status"})
+
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of HTTP requests, partitioned by method, route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+)
+
+// Middleware records the count and latency of requests served by next. Requests
+// are labeled by their chi route pattern rather than URL path, to keep the
+// cardinality of the metrics bounded.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		start := time.Now()
+
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			// Handler never wrote a header explicitly, net/http defaults to 200.
+			status = http.StatusOK
+		}
+
+		labels := prometheus.Labels{
+			"method": r.Method,
+			"route":  routePattern(r),
+			"status": strconv.Itoa(status),
+		}
+		requestsTotal.With(labels).Inc()
+		requestDuration.With(labels).Observe(time.Since(start).Seconds())
+	})
+}
+
+func routePattern(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil {
+		return "unmatched"
+	}
+	if p := rctx.RoutePattern(); p != "" {
+		return p
+	}
+	return "unmatched"
+}
@@ -0,0 +1,428 @@
+// Package migrate applies and reverts numbered SQL migrations, keeping track of
+// the applied ones in the schema_migrations table.
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"feature/pkg/sqlx"
+)
+
+// Migration is a single step of the schema.
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// Status of a migration.
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+var fileNameRegexp = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Load reads the migrations from the root of fsys. Files are expected to be
+// named NN_name.up.sql and NN_name.down.sql, where NN is the version.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("read directory: %w", err)
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, e := range entries {
+		m := fileNameRegexp.FindStringSubmatch(e.Name())
+		if e.IsDir() || m == nil {
+			continue
+		}
+
+		version, _ := strconv.Atoi(m[1])
+		b, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, fmt.Errorf("read file: %w", err)
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		}
+		if mig.Name != m[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, mig.Name, m[2])
+		}
+
+		if m[3] == "up" {
+			mig.Up = string(b)
+			sum := sha256.Sum256(b)
+			mig.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			mig.Down = string(b)
+		}
+	}
+
+	res := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		res = append(res, *m)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Version < res[j].Version })
+
+	return res, nil
+}
+
+// ErrChecksumMismatch is returned when an applied migration has been edited
+// afterwards.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// Migrator migrates a database.
+type Migrator struct {
+	db         *sql.DB
+	dialect    sqlx.Dialect
+	migrations []Migration
+}
+
+// New returns a Migrator for the migrations in fsys.
+func New(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	ms, err := Load(fsys)
+	if err != nil {
+		return nil, fmt.Errorf("load migrations: %w", err)
+	}
+	return &Migrator{db: db, dialect: sqlx.DialectOf(db), migrations: ms}, nil
+}
+
+// Latest returns the version of the newest migration, or 0 if there are none.
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Status returns all known migrations, along with the time they have been
+// applied at. It fails if applied migrations are unknown or have been edited.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx, m.db)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		s := Status{Migration: mig}
+		if a, ok := applied[mig.Version]; ok {
+			s.AppliedAt = &a.appliedAt
+		}
+		res = append(res, s)
+	}
+	return res, nil
+}
+
+// Up applies all pending migrations.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.To(ctx, m.Latest())
+}
+
+// Down reverts the most recently applied migration, if any.
+func (m *Migrator) Down(ctx context.Context) error {
+	current, err := m.Current(ctx)
+	if err != nil {
+		return err
+	}
+	if current == 0 {
+		return nil
+	}
+
+	target := 0
+	for _, mig := range m.migrations {
+		if mig.Version < current {
+			target = mig.Version
+		}
+	}
+	return m.To(ctx, target)
+}
+
+// Current returns the version of the most recently applied migration, or 0 if
+// none has been applied.
+func (m *Migrator) Current(ctx context.Context) (int, error) {
+	applied, err := m.applied(ctx, m.db)
+	if err != nil {
+		return 0, err
+	}
+
+	var current int
+	for v := range applied {
+		if v > current {
+			current = v
+		}
+	}
+	return current, nil
+}
+
+// To applies or reverts migrations until version is the most recently applied
+// one. Version 0 reverts all migrations. Every migration runs in a transaction
+// of its own, along with its bookkeeping, while the migrator holds the lock
+// keeping concurrent migrators out.
+func (m *Migrator) To(ctx context.Context, version int) error {
+	if version != 0 && m.find(version) == nil {
+		return fmt.Errorf("unknown migration %d", version)
+	}
+
+	return m.locked(ctx, func(s *session) error {
+		applied, err := m.applied(ctx, s.conn)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range m.migrations {
+			if _, ok := applied[mig.Version]; ok || mig.Version > version {
+				continue
+			}
+			if err := s.step(ctx, func(q querier) error { return m.apply(ctx, q, mig) }); err != nil {
+				return fmt.Errorf("apply migration %d_%s: %w", mig.Version, mig.Name, err)
+			}
+		}
+
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.Version]; !ok || mig.Version <= version {
+				continue
+			}
+			if err := s.step(ctx, func(q querier) error { return m.revert(ctx, q, mig) }); err != nil {
+				return fmt.Errorf("revert migration %d_%s: %w", mig.Version, mig.Name, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// Baseline records all migrations up to version as applied, without running
+// them. It allows adopting databases created before migrations were tracked.
+func (m *Migrator) Baseline(ctx context.Context, version int) error {
+	if m.find(version) == nil {
+		return fmt.Errorf("unknown migration %d", version)
+	}
+
+	return m.locked(ctx, func(s *session) error {
+		applied, err := m.applied(ctx, s.conn)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range m.migrations {
+			if _, ok := applied[mig.Version]; ok || mig.Version > version {
+				continue
+			}
+			if err := m.record(ctx, s.conn, mig); err != nil {
+				return fmt.Errorf("record migration %d_%s: %w", mig.Version, mig.Name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// lockID identifies the advisory lock taken on PostgreSQL. It is arbitrary,
+// but must not change, so that migrators of different versions exclude each
+// other.
+const lockID = 4_812_657_091
+
+// session is a connection holding the migration lock.
+type session struct {
+	conn    *sql.Conn
+	dialect sqlx.Dialect
+}
+
+// locked calls fn with a session holding the migration lock, waiting for
+// concurrent migrators to finish first. On PostgreSQL, the lock is an advisory
+// lock of the session. SQLite has none, so the session holds an exclusive
+// transaction instead, and steps run in savepoints of it.
+func (m *Migrator) locked(ctx context.Context, fn func(s *session) error) (err error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("get connection: %w", err)
+	}
+	defer conn.Close()
+
+	s := &session{conn: conn, dialect: m.dialect}
+	if m.dialect == sqlx.Postgres {
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockID); err != nil {
+			return fmt.Errorf("acquire lock: %w", err)
+		}
+		defer func() {
+			if _, unlockErr := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockID); unlockErr != nil && err == nil {
+				err = fmt.Errorf("release lock: %w", unlockErr)
+			}
+		}()
+		return fn(s)
+	}
+
+	// An immediate transaction takes the write lock right away. Concurrent
+	// migrators wait for it for the busy timeout of the connection.
+	//language=sqlite
+	if _, err := conn.ExecContext(ctx, `BEGIN IMMEDIATE`); err != nil {
+		return fmt.Errorf("acquire lock: %w", err)
+	}
+	// Steps that succeeded are kept even if a later one fails, like they are
+	// on PostgreSQL.
+	err = fn(s)
+	//language=sqlite
+	if _, commitErr := conn.ExecContext(context.Background(), `COMMIT`); commitErr != nil {
+		// The connection returns to the pool, which it must not do within a
+		// transaction.
+		//language=sqlite
+		_, _ = conn.ExecContext(context.Background(), `ROLLBACK`)
+		if err == nil {
+			err = fmt.Errorf("commit: %w", commitErr)
+		}
+	}
+	return err
+}
+
+// step calls fn within a transaction of its own, which is committed unless fn
+// fails.
+func (s *session) step(ctx context.Context, fn func(q querier) error) error {
+	if s.dialect == sqlx.Postgres {
+		tx, err := s.conn.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("begin transaction: %w", err)
+		}
+		defer tx.Rollback()
+
+		if err := fn(tx); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+
+	//language=sqlite
+	if _, err := s.conn.ExecContext(ctx, `SAVEPOINT migration`); err != nil {
+		return fmt.Errorf("begin savepoint: %w", err)
+	}
+	if err := fn(s.conn); err != nil {
+		//language=sqlite
+		if _, rollbackErr := s.conn.ExecContext(context.Background(), `ROLLBACK TO migration; RELEASE migration`); rollbackErr != nil {
+			return fmt.Errorf("%w, and roll back savepoint: %s", err, rollbackErr)
+		}
+		return err
+	}
+	//language=sqlite
+	_, err := s.conn.ExecContext(ctx, `RELEASE migration`)
+	return err
+}
+
+func (m *Migrator) find(version int) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i]
+		}
+	}
+	return nil
+}
+
+type appliedMigration struct {
+	checksum  string
+	appliedAt time.Time
+}
+
+// applied returns the applied migrations by version, after ensuring they match
+// the known ones.
+func (m *Migrator) applied(ctx context.Context, q querier) (map[int]appliedMigration, error) {
+	//language=sqlite
+	if _, err := q.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations
+(
+    version    INTEGER PRIMARY KEY,
+    name       TEXT      NOT NULL,
+    checksum   TEXT      NOT NULL,
+    applied_at TIMESTAMP NOT NULL
+)`); err != nil {
+		return nil, fmt.Errorf("create schema_migrations table: %w", err)
+	}
+
+	//language=sqlite
+	rows, err := q.QueryContext(ctx, `SELECT version, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("query schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	res := map[int]appliedMigration{}
+	for rows.Next() {
+		var (
+			version int
+			a       appliedMigration
+		)
+		if err := rows.Scan(&version, &a.checksum, &a.appliedAt); err != nil {
+			return nil, fmt.Errorf("scan schema_migrations: %w", err)
+		}
+		a.appliedAt = a.appliedAt.UTC()
+		res[version] = a
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate schema_migrations: %w", err)
+	}
+
+	for version, a := range res {
+		mig := m.find(version)
+		if mig == nil {
+			return nil, fmt.Errorf("applied migration %d is unknown", version)
+		}
+		if mig.Checksum != a.checksum {
+			return nil, fmt.Errorf("migration %d_%s was edited after it had been applied: %w", mig.Version, mig.Name, ErrChecksumMismatch)
+		}
+	}
+
+	return res, nil
+}
+
+func (m *Migrator) apply(ctx context.Context, q querier, mig Migration) error {
+	if _, err := q.ExecContext(ctx, mig.Up); err != nil {
+		return fmt.Errorf("execute up: %w", err)
+	}
+	return m.record(ctx, q, mig)
+}
+
+func (m *Migrator) revert(ctx context.Context, q querier, mig Migration) error {
+	if mig.Down == "" {
+		return errors.New("migration has no down file")
+	}
+
+	if _, err := q.ExecContext(ctx, mig.Down); err != nil {
+		return fmt.Errorf("execute down: %w", err)
+	}
+	//language=sqlite
+	if _, err := q.ExecContext(ctx, m.dialect.Rebind(`DELETE FROM schema_migrations WHERE version = ?`), mig.Version); err != nil {
+		return fmt.Errorf("delete from schema_migrations: %w", err)
+	}
+	return nil
+}
+
+// querier is implemented by *sql.DB, *sql.Conn and *sql.Tx.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func (m *Migrator) record(ctx context.Context, q querier, mig Migration) error {
+	//language=sqlite
+	if _, err := q.ExecContext(ctx, m.dialect.Rebind(`INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)`),
+		mig.Version, mig.Name, mig.Checksum, time.Now().UTC()); err != nil {
+		return fmt.Errorf("insert into schema_migrations: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+var testMigrations = fstest.MapFS{
+	"01_create_a.up.sql":   {Data: []byte(`CREATE TABLE a (id INTEGER PRIMARY KEY);`)},
+	"01_create_a.down.sql": {Data: []byte(`DROP TABLE a;`)},
+	"02_create_b.up.sql":   {Data: []byte(`CREATE TABLE b (id INTEGER PRIMARY KEY);`)},
+	"02_create_b.down.sql": {Data: []byte(`DROP TABLE b;`)},
+	"03_create_c.up.sql":   {Data: []byte(`CREATE TABLE c (id INTEGER PRIMARY KEY);`)},
+	"03_create_c.down.sql": {Data: []byte(`DROP TABLE c;`)},
+	"README.md":            {Data: []byte(`Not a migration.`)},
+}
+
+func TestMigrator(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+
+	m, err := New(db, testMigrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name       string
+		run        func() error
+		wantTables []string
+	}{
+		{"up", func() error { return m.Up(ctx) }, []string{"a", "b", "c"}},
+		{"up again", func() error { return m.Up(ctx) }, []string{"a", "b", "c"}},
+		{"down", func() error { return m.Down(ctx) }, []string{"a", "b"}},
+		{"to 1", func() error { return m.To(ctx, 1) }, []string{"a"}},
+		{"to 2", func() error { return m.To(ctx, 2) }, []string{"a", "b"}},
+		{"to 0", func() error { return m.To(ctx, 0) }, nil},
+		{"down with nothing applied", func() error { return m.Down(ctx) }, nil},
+	}
+
+	for _, step := range steps {
+		if err := step.run(); err != nil {
+			t.Fatalf("%s: %s", step.name, err)
+		}
+
+		got := tables(t, db)
+		if len(got) != len(step.wantTables) {
+			t.Fatalf("%s: tables not equal.\nwant: %v\ngot:  %v", step.name, step.wantTables, got)
+		}
+		for i := range got {
+			if got[i] != step.wantTables[i] {
+				t.Fatalf("%s: tables not equal.\nwant: %v\ngot:  %v", step.name, step.wantTables, got)
+			}
+		}
+
+		ss, err := m.Status(ctx)
+		if err != nil {
+			t.Fatalf("%s: %s", step.name, err)
+		}
+		for i, s := range ss {
+			if applied := i < len(step.wantTables); (s.AppliedAt != nil) != applied {
+				t.Errorf("%s: migration %d applied: want %t", step.name, s.Version, applied)
+			}
+		}
+	}
+}
+
+func TestMigratorChecksumMismatch(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+
+	m, err := New(db, testMigrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	edited := fstest.MapFS{}
+	for name, f := range testMigrations {
+		edited[name] = f
+	}
+	edited["02_create_b.up.sql"] = &fstest.MapFile{Data: []byte(`CREATE TABLE b (id INTEGER PRIMARY KEY, name TEXT);`)}
+
+	m, err = New(db, edited)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Up(ctx); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("Errors not equal.\nwant: %s\ngot:  %v", ErrChecksumMismatch, err)
+	}
+}
+
+func TestMigratorBaseline(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+
+	if _, err := db.Exec(`CREATE TABLE a (id INTEGER PRIMARY KEY)`); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := New(db, testMigrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Baseline(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	current, err := m.Current(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current != 3 {
+		t.Errorf("Current versions not equal.\nwant: %d\ngot:  %d", 3, current)
+	}
+}
+
+func TestMigratorConcurrentUp(t *testing.T) {
+	ctx := context.Background()
+	dsn := "file:" + filepath.Join(t.TempDir(), "migrate.sqlite")
+
+	var (
+		wg   sync.WaitGroup
+		errs = make([]error, 8)
+	)
+	for i := range errs {
+		// Every migrator has a database handle of its own, like separate
+		// processes would.
+		db, err := sql.Open("sqlite3", dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+
+		m, err := New(db, testMigrations)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = m.Up(ctx)
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("Migrator %d failed: %s", i, err)
+		}
+	}
+
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("Applied migrations not equal.\nwant: %d\ngot:  %d", 3, n)
+	}
+}
+
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every connection to :memory: opens a database of its own.
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func tables(t *testing.T, db *sql.DB) []string {
+	t.Helper()
+	rows, err := db.Query(`SELECT name FROM sqlite_master WHERE type = 'table' AND name != 'schema_migrations' ORDER BY name`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var res []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		res = append(res, name)
+	}
+	return res
+}
@@ -15,6 +15,10 @@ func (e tagErr) Code() int {
 	return e.code
 }
 
+func (e tagErr) Unwrap() error {
+	return e.err
+}
+
 // TagBadRequest tags an error with the 400 Bad Request status code.
 func TagBadRequest(err error) error {
 	return tagErr{
@@ -43,3 +47,11 @@ func NewBadRequest(msg string) error {
 		code: http.StatusBadRequest,
 	}
 }
+
+// NewNotFound creates a new error with the 404 Not Found status code.
+func NewNotFound(msg string) error {
+	return codeErr{
+		msg:  msg,
+		code: http.StatusNotFound,
+	}
+}
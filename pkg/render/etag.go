@@ -0,0 +1,80 @@
+package render
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ETag returns a weak entity tag identifying the given parts. Tags are weak
+// since responses may be served compressed or not.
+func ETag(parts ...string) string {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write([]byte(p))
+		// Separate the parts, so that ("ab", "c") and ("a", "bc") differ.
+		h.Write([]byte{0})
+	}
+	return fmt.Sprintf(`W/"%x"`, h.Sum(nil)[:16])
+}
+
+// SetETag tags a response with etag, and asks clients to revalidate it on
+// every use.
+func SetETag(w http.ResponseWriter, etag string) {
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+}
+
+// NotModified answers 304 Not Modified if the If-None-Match header of the
+// request matches etag. It reports whether it did so, in which case nothing
+// else must be written.
+func NotModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	if !etagMatches(r.Header.Get("If-None-Match"), etag) {
+		return false
+	}
+	SetETag(w, etag)
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// CachedJSON renders the given value as JSON like JSON, tagged with the hash of
+// the rendered document. If the client already has that document, 304 Not
+// Modified is sent instead.
+func CachedJSON(w http.ResponseWriter, r *http.Request, v interface{}) {
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(true)
+	if err := enc.Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	etag := ETag(buf.String())
+	if NotModified(w, r, etag) {
+		return
+	}
+	SetETag(w, etag)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
+// etagMatches reports whether an If-None-Match header matches etag, using the
+// weak comparison RFC 9110 requires for If-None-Match.
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	if strings.TrimSpace(ifNoneMatch) == "*" {
+		return true
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, t := range strings.Split(ifNoneMatch, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(t), "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
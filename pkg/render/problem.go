@@ -0,0 +1,114 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog/hlog"
+)
+
+// problemTypePrefix prefixes the codes of problems to make their types.
+const problemTypePrefix = "urn:feature:problem:"
+
+// A Problem is the body of an error response, as described by RFC 7807.
+type Problem struct {
+	// Type identifies the kind of problem, and is stable across releases.
+	Type  string `json:"type"`
+	Title string `json:"title"`
+	// Status repeats the status code of the response.
+	Status int `json:"status"`
+	// Detail explains this occurrence of the problem. It is left out for
+	// internal errors, whose details are only logged.
+	Detail string `json:"detail,omitempty"`
+	// Code is the short form of Type, like feature-not-found.
+	Code string `json:"code"`
+	// RequestID identifies the request in the logs of the server.
+	RequestID string       `json:"requestId,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// A FieldError is a violation reported by the validation of a request. Field
+// is left out for violations that don't concern a single field.
+type FieldError struct {
+	Field  string `json:"field,omitempty"`
+	Detail string `json:"detail"`
+}
+
+// Error renders err as a Problem, with the status code of err.
+//
+// The status code and the detail of the problem come from the outermost error
+// in the chain of err with a Code method. Its code comes from an ErrorCode
+// method of that error, or from the status code otherwise. Field errors are
+// listed if that error has a FieldErrors method. Errors without a status code
+// are rendered as 500 Internal Server Error, and their message is hidden from
+// clients. Errors caused by a request body exceeding the limit of
+// http.MaxBytesReader are rendered as 413 Request Entity Too Large, however
+// they are tagged.
+func Error(w http.ResponseWriter, r *http.Request, err error) {
+	p := problemFromErr(err)
+	if id, ok := hlog.IDFromRequest(r); ok {
+		p.RequestID = id.String()
+	}
+
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(true)
+	if err := enc.Encode(p); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(p.Status)
+	w.Write(buf.Bytes())
+}
+
+func problemFromErr(err error) Problem {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		err = codeErr{
+			msg:  fmt.Sprintf("request body exceeds the limit of %d bytes", tooLarge.Limit),
+			code: http.StatusRequestEntityTooLarge,
+		}
+	}
+
+	for ; err != nil; err = errors.Unwrap(err) {
+		c, ok := err.(interface{ Code() int })
+		if !ok {
+			continue
+		}
+
+		p := newProblem(c.Code(), codeFromStatus(c.Code()))
+		if ec, ok := err.(interface{ ErrorCode() string }); ok {
+			p = newProblem(c.Code(), ec.ErrorCode())
+		}
+		if p.Status < http.StatusInternalServerError {
+			p.Detail = err.Error()
+		}
+		if fe, ok := err.(interface{ FieldErrors() []FieldError }); ok {
+			p.Errors = fe.FieldErrors()
+		}
+		return p
+	}
+
+	return newProblem(http.StatusInternalServerError, codeFromStatus(http.StatusInternalServerError))
+}
+
+func newProblem(status int, code string) Problem {
+	return Problem{
+		Type:   problemTypePrefix + code,
+		Title:  http.StatusText(status),
+		Status: status,
+		Code:   code,
+	}
+}
+
+// codeFromStatus derives a problem code from the text of a status code, like
+// not-found from 404 Not Found.
+func codeFromStatus(status int) string {
+	return strings.ToLower(strings.ReplaceAll(http.StatusText(status), " ", "-"))
+}
@@ -0,0 +1,98 @@
+package render
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog/hlog"
+)
+
+type testFieldErr struct{}
+
+func (e testFieldErr) Error() string { return "'name' must be set" }
+func (e testFieldErr) Code() int     { return http.StatusBadRequest }
+
+func (e testFieldErr) ErrorCode() string { return "validation-failed" }
+
+func (e testFieldErr) FieldErrors() []FieldError {
+	return []FieldError{{Field: "name", Detail: "'name' must be set"}}
+}
+
+func TestError(t *testing.T) {
+	tests := map[string]struct {
+		err error
+
+		wantStatus int
+		wantBody   string
+	}{
+		"detail of the coded error": {
+			err: fmt.Errorf("find thing: %w", NewBadRequest("thing is broken")),
+
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"type":"urn:feature:problem:bad-request","title":"Bad Request","status":400,"detail":"thing is broken","code":"bad-request"}`,
+		},
+		"code and field errors of the error": {
+			err: fmt.Errorf("validate thing: %w", testFieldErr{}),
+
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"type":"urn:feature:problem:validation-failed","title":"Bad Request","status":400,"detail":"'name' must be set","code":"validation-failed","errors":[{"field":"name","detail":"'name' must be set"}]}`,
+		},
+		"internal errors are masked": {
+			err: fmt.Errorf("query things: %w", errors.New("pq: password authentication failed")),
+
+			wantStatus: http.StatusInternalServerError,
+			wantBody:   `{"type":"urn:feature:problem:internal-server-error","title":"Internal Server Error","status":500,"code":"internal-server-error"}`,
+		},
+		"body too large however tagged": {
+			err: TagBadRequest(fmt.Errorf("decode request body: %w", &http.MaxBytesError{Limit: 10})),
+
+			wantStatus: http.StatusRequestEntityTooLarge,
+			wantBody:   `{"type":"urn:feature:problem:request-entity-too-large","title":"Request Entity Too Large","status":413,"detail":"request body exceeds the limit of 10 bytes","code":"request-entity-too-large"}`,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			res := httptest.NewRecorder()
+
+			Error(res, req, test.err)
+
+			if res.Code != test.wantStatus {
+				t.Errorf("Status codes not equal.\nwant: %d\ngot:  %d", test.wantStatus, res.Code)
+			}
+			if ct := res.Header().Get("Content-Type"); ct != "application/problem+json" {
+				t.Errorf("Content types not equal.\nwant: application/problem+json\ngot:  %s", ct)
+			}
+
+			resBody := strings.TrimSpace(res.Body.String())
+			if resBody != test.wantBody {
+				t.Errorf("Response bodies not equal.\nwant: %s\ngot:  %s", test.wantBody, resBody)
+			}
+		})
+	}
+}
+
+func TestErrorRequestID(t *testing.T) {
+	var requestID string
+	handler := hlog.RequestIDHandler("requestId", "X-Request-Id")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, _ := hlog.IDFromRequest(r)
+		requestID = id.String()
+		Error(w, r, NewBadRequest("thing is broken"))
+	}))
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	want := `"requestId":"` + requestID + `"`
+	if requestID == "" || !strings.Contains(res.Body.String(), want) {
+		t.Errorf("Expected the request ID %q in the response, got: %s", requestID, res.Body.String())
+	}
+}
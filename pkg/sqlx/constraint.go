@@ -0,0 +1,73 @@
+package sqlx
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+// ConstraintError is a write violating a unique or foreign key constraint,
+// described the same way regardless of the driver reporting it.
+type ConstraintError struct {
+	// Columns are the columns of a violated unique constraint, qualified with
+	// their table. Nil if a foreign key was violated, as SQLite doesn't tell
+	// which one.
+	Columns []string
+	Err     error
+}
+
+func (e ConstraintError) Error() string {
+	if e.Columns == nil {
+		return "foreign key refers to missing row"
+	}
+	return fmt.Sprintf("%s is not unique", strings.Join(e.Columns, ", "))
+}
+
+func (e ConstraintError) Unwrap() error {
+	return e.Err
+}
+
+// pqKeyDetail matches the detail of PostgreSQL unique violations, e.g.
+// "Key (customer_id, feature_id)=(1, 2) already exists.".
+var pqKeyDetail = regexp.MustCompile(`^Key \(([^)]*)\)=`)
+
+// AsConstraintError returns the constraint violation err reports, or nil if it
+// doesn't report one.
+func AsConstraintError(err error) *ConstraintError {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.Code == sqlite3.ErrConstraint {
+		switch sqliteErr.ExtendedCode {
+		case sqlite3.ErrConstraintForeignKey:
+			return &ConstraintError{Err: err}
+		case sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey:
+			// The message is e.g. "UNIQUE constraint failed: features.technical_name".
+			_, columns, _ := strings.Cut(sqliteErr.Error(), ": ")
+			return &ConstraintError{Columns: strings.Split(columns, ", "), Err: err}
+		}
+		return nil
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code.Name() {
+		case "foreign_key_violation":
+			return &ConstraintError{Err: err}
+		case "unique_violation":
+			var columns []string
+			if m := pqKeyDetail.FindStringSubmatch(pqErr.Detail); m != nil {
+				for _, c := range strings.Split(m[1], ", ") {
+					columns = append(columns, pqErr.Table+"."+c)
+				}
+			}
+			if columns == nil {
+				columns = []string{pqErr.Table}
+			}
+			return &ConstraintError{Columns: columns, Err: err}
+		}
+	}
+	return nil
+}
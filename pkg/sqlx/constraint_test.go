@@ -0,0 +1,96 @@
+package sqlx
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestAsConstraintError(t *testing.T) {
+	db, err := sql.Open("sqlite3", "file::memory:?_foreign_keys=on")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(`
+		CREATE TABLE features (id INTEGER PRIMARY KEY, technical_name TEXT NOT NULL UNIQUE);
+		CREATE TABLE customer_features (
+			feature_id  INTEGER NOT NULL REFERENCES features (id),
+			customer_id TEXT    NOT NULL,
+			UNIQUE (customer_id, feature_id)
+		);
+		INSERT INTO features VALUES (1, 'feature-1');
+		INSERT INTO customer_features VALUES (1, 'customer-1');
+	`); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		query string
+
+		want    *ConstraintError
+		wantMsg string
+	}{
+		"unique column": {
+			query:   `INSERT INTO features VALUES (2, 'feature-1')`,
+			want:    &ConstraintError{Columns: []string{"features.technical_name"}},
+			wantMsg: "features.technical_name is not unique",
+		},
+		"primary key": {
+			query:   `INSERT INTO features VALUES (1, 'feature-2')`,
+			want:    &ConstraintError{Columns: []string{"features.id"}},
+			wantMsg: "features.id is not unique",
+		},
+		"unique columns": {
+			query:   `INSERT INTO customer_features VALUES (1, 'customer-1')`,
+			want:    &ConstraintError{Columns: []string{"customer_features.customer_id", "customer_features.feature_id"}},
+			wantMsg: "customer_features.customer_id, customer_features.feature_id is not unique",
+		},
+		"foreign key": {
+			query:   `INSERT INTO customer_features VALUES (2, 'customer-1')`,
+			want:    &ConstraintError{},
+			wantMsg: "foreign key refers to missing row",
+		},
+		"not null is no constraint error": {
+			query: `INSERT INTO features VALUES (3, NULL)`,
+		},
+		"syntax error": {
+			query: `INSERT INTO`,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			_, err := db.Exec(test.query)
+			if err == nil {
+				t.Fatal("Expected an error")
+			}
+
+			got := AsConstraintError(err)
+			if test.want == nil {
+				if got != nil {
+					t.Errorf("Expected no constraint error, got: %v", got)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatalf("Expected a constraint error, got: %v", err)
+			}
+			if !reflect.DeepEqual(test.want.Columns, got.Columns) {
+				t.Errorf("Columns not equal.\nwant: %v\ngot:  %v", test.want.Columns, got.Columns)
+			}
+			if got.Error() != test.wantMsg {
+				t.Errorf("Messages not equal.\nwant: %s\ngot:  %s", test.wantMsg, got.Error())
+			}
+			if !errors.Is(got, err) {
+				t.Errorf("Expected %v to wrap %v", got, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package sqlx
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// Dialect identifies the SQL dialect spoken by a database, and provides the
+// fragments of SQL that differ between dialects. Queries are written with '?'
+// placeholders, and rebound for dialects using other ones.
+type Dialect int
+
+// Supported dialects.
+const (
+	SQLite Dialect = iota
+	Postgres
+)
+
+// DialectOf returns the dialect of the database behind db.
+func DialectOf(db *sql.DB) Dialect {
+	if _, ok := db.Driver().(*pq.Driver); ok {
+		return Postgres
+	}
+	return SQLite
+}
+
+// Goqu returns the name of the goqu dialect.
+func (d Dialect) Goqu() string {
+	if d == Postgres {
+		return "postgres"
+	}
+	return "sqlite3"
+}
+
+// Rebind replaces the '?' placeholders of query with the ones of the dialect.
+// Question marks within string literals are left alone.
+func (d Dialect) Rebind(query string) string {
+	if d != Postgres || !strings.Contains(query, "?") {
+		return query
+	}
+
+	var (
+		b      strings.Builder
+		n      int
+		quoted bool
+	)
+	b.Grow(len(query) + 8)
+	for _, r := range query {
+		switch {
+		case r == '\'':
+			quoted = !quoted
+		case r == '?' && !quoted:
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// JSONArrayAgg returns an aggregate expression collecting expr into a JSON
+// array, which is empty rather than NULL when there are no rows.
+func (d Dialect) JSONArrayAgg(expr string) string {
+	if d == Postgres {
+		return fmt.Sprintf("COALESCE(json_agg(%s), '[]')", expr)
+	}
+	return fmt.Sprintf("json_group_array(%s)", expr)
+}
+
+// UnixEpoch returns an expression converting the timestamp expr to whole
+// seconds since the Unix epoch.
+func (d Dialect) UnixEpoch(expr string) string {
+	if d == Postgres {
+		return fmt.Sprintf("CAST(FLOOR(EXTRACT(EPOCH FROM %s)) AS BIGINT)", expr)
+	}
+	return fmt.Sprintf("unixepoch(%s)", expr)
+}
+
+// SkipLocked returns the locking clause of a query selecting rows to claim,
+// skipping the rows claimed by concurrent transactions. SQLite serializes
+// writing transactions, so it needs none.
+func (d Dialect) SkipLocked() string {
+	if d == Postgres {
+		return " FOR UPDATE SKIP LOCKED"
+	}
+	return ""
+}
+
+// JSONArrayContains returns a condition on whether the JSON array expr contains
+// the string bound to its only placeholder.
+func (d Dialect) JSONArrayContains(expr string) string {
+	if d == Postgres {
+		return fmt.Sprintf("EXISTS (SELECT 1 FROM json_array_elements_text(CAST(%s AS json)) AS e(value) WHERE e.value = ?)", expr)
+	}
+	return fmt.Sprintf("EXISTS (SELECT 1 FROM json_each(%s) WHERE json_each.value = ?)", expr)
+}
@@ -0,0 +1,43 @@
+package sqlx
+
+import "testing"
+
+func TestDialectRebind(t *testing.T) {
+	tests := map[string]struct {
+		dialect Dialect
+		query   string
+		want    string
+	}{
+		"sqlite is left alone": {
+			dialect: SQLite,
+			query:   `SELECT * FROM features WHERE id=? AND technical_name=?`,
+			want:    `SELECT * FROM features WHERE id=? AND technical_name=?`,
+		},
+		"postgres placeholders are numbered": {
+			dialect: Postgres,
+			query:   `SELECT * FROM features WHERE id=? AND technical_name=?`,
+			want:    `SELECT * FROM features WHERE id=$1 AND technical_name=$2`,
+		},
+		"question marks in string literals are kept": {
+			dialect: Postgres,
+			query:   `SELECT '?' FROM features WHERE description LIKE '%?' AND id=?`,
+			want:    `SELECT '?' FROM features WHERE description LIKE '%?' AND id=$1`,
+		},
+		"query without placeholders": {
+			dialect: Postgres,
+			query:   `SELECT 1`,
+			want:    `SELECT 1`,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := test.dialect.Rebind(test.query); got != test.want {
+				t.Errorf("Queries not equal.\nwant: %s\ngot:  %s", test.want, got)
+			}
+		})
+	}
+}
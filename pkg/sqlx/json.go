@@ -2,23 +2,69 @@
 package sqlx
 
 import (
+	"database/sql/driver"
 	"encoding/json"
 	"fmt"
 )
 
-// JSONArray allows scanning SQLite JSON functions that result with an array into
-// a native Go slice.
-type JSONArray[T bool | float64 | string] []T
+// JSONArray allows scanning JSON functions that result with an array into a
+// native Go slice, as well as storing a native Go slice as a JSON array. Its
+// elements may be of any type encoding/json handles.
+type JSONArray[T any] []T
 
 // Scan implements the sql.Scanner interface.
 func (a *JSONArray[T]) Scan(src any) error {
-	// SQLite JSON functions generally return JSON as string.
+	// SQLite JSON functions generally return JSON as string, PostgreSQL as bytes.
 	switch v := src.(type) {
 	case nil:
 		return nil
 	case string:
 		return json.Unmarshal([]byte(v), a)
+	case []byte:
+		return json.Unmarshal(v, a)
 	default:
 		return fmt.Errorf("cannot scan %T into %T", src, a)
 	}
 }
+
+// Value implements the driver.Valuer interface.
+func (a JSONArray[T]) Value() (driver.Value, error) {
+	if a == nil {
+		return "[]", nil
+	}
+	b, err := json.Marshal(a)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// JSONObject allows scanning a JSON object into a native Go map, as well as
+// storing a native Go map as a JSON object.
+type JSONObject[T bool | float64 | string] map[string]T
+
+// Scan implements the sql.Scanner interface.
+func (o *JSONObject[T]) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case string:
+		return json.Unmarshal([]byte(v), o)
+	case []byte:
+		return json.Unmarshal(v, o)
+	default:
+		return fmt.Errorf("cannot scan %T into %T", src, o)
+	}
+}
+
+// Value implements the driver.Valuer interface.
+func (o JSONObject[T]) Value() (driver.Value, error) {
+	if o == nil {
+		return "{}", nil
+	}
+	b, err := json.Marshal(o)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,103 @@
+// Package static serves static assets, preferring variants compressed ahead of
+// time over compressing them on every request.
+package static
+
+import (
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"feature/pkg/set"
+)
+
+// encodings are the content codings of precompressed variants, in order of
+// preference, along with the suffix of their file names.
+var encodings = []struct {
+	name   string
+	suffix string
+}{
+	{name: "br", suffix: ".br"},
+	{name: "gzip", suffix: ".gz"},
+}
+
+// Handler serves the files of fsys like http.FileServer. When the client
+// accepts it, a file is served from a compressed variant next to it instead,
+// like app.js.br or app.js.gz for app.js.
+func Handler(fsys fs.FS) http.Handler {
+	files := http.FileServer(http.FS(fsys))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+		if name == "" || strings.HasSuffix(r.URL.Path, "/") {
+			name = path.Join(name, "index.html")
+		}
+
+		if !serveCompressed(w, r, fsys, name) {
+			files.ServeHTTP(w, r)
+		}
+	})
+}
+
+// serveCompressed serves the preferred precompressed variant of the named file
+// accepted by the client. It reports whether there was one.
+func serveCompressed(w http.ResponseWriter, r *http.Request, fsys fs.FS, name string) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	accepted := acceptedEncodings(r.Header.Get("Accept-Encoding"))
+	for _, enc := range encodings {
+		if _, ok := accepted[enc.name]; !ok {
+			continue
+		}
+
+		f, err := fsys.Open(name + enc.suffix)
+		if err != nil {
+			continue
+		}
+		info, err := f.Stat()
+		content, ok := f.(io.ReadSeeker)
+		if err != nil || info.IsDir() || !ok {
+			f.Close()
+			continue
+		}
+		defer f.Close()
+
+		ctype := mime.TypeByExtension(path.Ext(name))
+		if ctype == "" {
+			ctype = "application/octet-stream"
+		}
+		w.Header().Set("Content-Type", ctype)
+		w.Header().Set("Content-Encoding", enc.name)
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		http.ServeContent(w, r, name, info.ModTime(), content)
+		return true
+	}
+	return false
+}
+
+// acceptedEncodings parses an Accept-Encoding header into the set of content
+// codings it doesn't refuse with q=0.
+func acceptedEncodings(header string) set.Set[string] {
+	res := set.Of[string]()
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" {
+			continue
+		}
+
+		if params = strings.TrimSpace(params); strings.HasPrefix(params, "q=") {
+			if q, err := strconv.ParseFloat(params[len("q="):], 64); err == nil && q == 0 {
+				continue
+			}
+		}
+		res[coding] = struct{}{}
+	}
+	return res
+}
@@ -0,0 +1,103 @@
+package static
+
+import (
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"testing"
+	"testing/fstest"
+)
+
+func TestHandler(t *testing.T) {
+	fsys := fstest.MapFS{
+		"index.html":    {Data: []byte("<html>")},
+		"index.html.gz": {Data: []byte("gzipped html")},
+		"main.js":       {Data: []byte("console.log()")},
+		"main.js.br":    {Data: []byte("brotli js")},
+		"main.js.gz":    {Data: []byte("gzipped js")},
+		"favicon.ico":   {Data: []byte("icon")},
+	}
+
+	tests := map[string]struct {
+		path           string
+		acceptEncoding string
+
+		wantBody     string
+		wantEncoding string
+	}{
+		"brotli is preferred": {
+			path:           "/main.js",
+			acceptEncoding: "gzip, deflate, br",
+
+			wantBody:     "brotli js",
+			wantEncoding: "br",
+		},
+		"gzip when brotli is not accepted": {
+			path:           "/main.js",
+			acceptEncoding: "gzip",
+
+			wantBody:     "gzipped js",
+			wantEncoding: "gzip",
+		},
+		"encodings refused with q=0": {
+			path:           "/main.js",
+			acceptEncoding: "br;q=0, gzip;q=0.5",
+
+			wantBody:     "gzipped js",
+			wantEncoding: "gzip",
+		},
+		"uncompressed without Accept-Encoding": {
+			path: "/main.js",
+
+			wantBody: "console.log()",
+		},
+		"index of the root": {
+			path:           "/",
+			acceptEncoding: "br, gzip",
+
+			wantBody:     "gzipped html",
+			wantEncoding: "gzip",
+		},
+		"file without compressed variants": {
+			path:           "/favicon.ico",
+			acceptEncoding: "br, gzip",
+
+			wantBody: "icon",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodGet, test.path, nil)
+			if test.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", test.acceptEncoding)
+			}
+			res := httptest.NewRecorder()
+
+			Handler(fsys).ServeHTTP(res, req)
+
+			if res.Code != http.StatusOK {
+				t.Errorf("Status codes not equal.\nwant: %d\ngot:  %d", http.StatusOK, res.Code)
+			}
+			if got := res.Body.String(); got != test.wantBody {
+				t.Errorf("Response bodies not equal.\nwant: %s\ngot:  %s", test.wantBody, got)
+			}
+			if got := res.Header().Get("Content-Encoding"); got != test.wantEncoding {
+				t.Errorf("Content encodings not equal.\nwant: %s\ngot:  %s", test.wantEncoding, got)
+			}
+
+			// Compressed variants are served with the type of the original file.
+			ext := path.Ext(test.path)
+			if ext == "" {
+				ext = ".html"
+			}
+			if want, got := mime.TypeByExtension(ext), res.Header().Get("Content-Type"); got != want {
+				t.Errorf("Content types not equal.\nwant: %s\ngot:  %s", want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+#!/bin/sh
+# Runs the test suite against a throwaway PostgreSQL cluster. Requires the
+# PostgreSQL server binaries (initdb, pg_ctl) on PATH, or their directory in
+# PG_BIN.
+set -eu
+
+if [ -n "${PG_BIN:-}" ]; then
+	PATH="$PG_BIN:$PATH"
+fi
+
+dir=$(mktemp -d)
+port=${PG_PORT:-54329}
+
+cleanup() {
+	pg_ctl -D "$dir/data" -m immediate stop >/dev/null 2>&1 || true
+	rm -rf "$dir"
+}
+trap cleanup EXIT
+
+initdb -D "$dir/data" -U feature -A trust >/dev/null
+pg_ctl -D "$dir/data" -o "-p $port -k $dir -c listen_addresses=localhost" -l "$dir/log" -w start >/dev/null
+createdb -h localhost -p "$port" -U feature feature_test
+
+export DSN="postgres://feature@localhost:$port/feature_test?sslmode=disable"
+go run ./cmd/makedb
+go test "$@" ./...